@@ -0,0 +1,190 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"path"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// the limits of the files of archives and repositories, which come from anyone
+const (
+	maxArchiveFiles    = 1000
+	maxArchiveFileSize = 1 << 20
+	maxArchiveSize     = 32 << 20
+)
+
+var (
+	zipMagic  = []byte("PK\x03\x04")
+	gzipMagic = []byte{0x1f, 0x8b}
+)
+
+// sourceCollector collects the template files of a directory or archive within the limits
+type sourceCollector struct {
+	sources []source
+	size    int64
+}
+
+// add adds the file at name, which says it has size bytes, if it's a template
+func (c *sourceCollector) add(name string, r io.Reader, size int64) error {
+	name, ok, err := archivePath(name)
+	if err != nil || !ok {
+		return err
+	}
+	if len(c.sources) >= maxArchiveFiles {
+		return fmt.Errorf("more than %d files", maxArchiveFiles)
+	}
+	if size > maxArchiveFileSize {
+		return fmt.Errorf("%s is bigger than %d bytes", name, maxArchiveFileSize)
+	}
+	// the sizes of entries can lie, so reading stops at the limit too
+	text, err := ioutil.ReadAll(io.LimitReader(r, maxArchiveFileSize+1))
+	if err != nil {
+		return fmt.Errorf("%s: %v", name, err)
+	}
+	if len(text) > maxArchiveFileSize {
+		return fmt.Errorf("%s is bigger than %d bytes", name, maxArchiveFileSize)
+	}
+	if c.size += int64(len(text)); c.size > maxArchiveSize {
+		return fmt.Errorf("more than %d bytes of files", maxArchiveSize)
+	}
+	if utf8.Valid(text) {
+		c.sources = append(c.sources, source{name: name, text: string(text)})
+	}
+	return nil
+}
+
+// result returns the sources sorted by path
+func (c *sourceCollector) result() ([]source, error) {
+	if len(c.sources) == 0 {
+		return nil, errors.New("no template files found")
+	}
+	sort.Slice(c.sources, func(i, j int) bool { return c.sources[i].name < c.sources[j].name })
+	return c.sources, nil
+}
+
+// extractArchive returns the template files in a zip, tar or gzipped tar archive, sorted by path. Directories, links,
+// hidden files and binary files are skipped.
+func extractArchive(b []byte) ([]source, error) {
+	c := &sourceCollector{}
+	var err error
+	switch {
+	case bytes.HasPrefix(b, zipMagic):
+		err = extractZip(b, c.add)
+	case bytes.HasPrefix(b, gzipMagic):
+		var r *gzip.Reader
+		if r, err = gzip.NewReader(bytes.NewReader(b)); err == nil {
+			err = extractTar(r, c.add)
+		}
+	default:
+		err = extractTar(bytes.NewReader(b), c.add)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return c.result()
+}
+
+func extractZip(b []byte, add func(name string, r io.Reader, size int64) error) error {
+	zr, err := zip.NewReader(bytes.NewReader(b), int64(len(b)))
+	if err != nil {
+		return err
+	}
+	for _, f := range zr.File {
+		if !f.Mode().IsRegular() {
+			continue
+		}
+		r, err := f.Open()
+		if err != nil {
+			return fmt.Errorf("%s: %v", f.Name, err)
+		}
+		err = add(f.Name, r, int64(f.UncompressedSize64))
+		r.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func extractTar(r io.Reader, add func(name string, r io.Reader, size int64) error) error {
+	tr := tar.NewReader(r)
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("not a zip, tar or tar.gz archive: %v", err)
+		}
+		if !h.FileInfo().Mode().IsRegular() {
+			continue
+		}
+		if err := add(h.Name, tr, h.Size); err != nil {
+			return err
+		}
+	}
+}
+
+// archivePath cleans the path of a file in an archive, which must stay inside it. It's not ok for files that aren't
+// templates, like hidden files and the metadata macOS adds to archives.
+func archivePath(name string) (string, bool, error) {
+	name = strings.ReplaceAll(name, "\\", "/")
+	clean := path.Clean(name)
+	if path.IsAbs(clean) || clean == ".." || strings.HasPrefix(clean, "../") {
+		return "", false, fmt.Errorf("%s is outside the archive", name)
+	}
+	for _, part := range strings.Split(clean, "/") {
+		if strings.HasPrefix(part, ".") || part == "__MACOSX" {
+			return clean, false, nil
+		}
+	}
+	return clean, true, nil
+}
+
+// ValidateArchive validates the templates in the uploaded archive as one set and serves the errors in each, as JSON
+func (a *App) ValidateArchive(w http.ResponseWriter, r *http.Request) {
+	sources, err := getArchive(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	rawData, err := getFormValue(r, "data")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	results := a.validateSet(r.Context(), sources, rawData, r.FormValue("functions"), getOptions(r))
+	if r.Context().Err() != nil {
+		return
+	}
+	writeJSON(w, results)
+}
+
+// getArchive extracts the archive uploaded as the archive form file, it's http.ErrMissingFile if there's none
+func getArchive(r *http.Request) ([]source, error) {
+	if err := r.ParseMultipartForm(maxRequestSize); err == http.ErrNotMultipart {
+		return nil, http.ErrMissingFile
+	} else if err != nil {
+		return nil, err
+	}
+	file, _, err := r.FormFile("archive")
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	b, err := ioutil.ReadAll(file)
+	if err != nil {
+		return nil, err
+	}
+	return extractArchive(b)
+}
@@ -0,0 +1,268 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func zipArchive(t *testing.T, files map[string]string) []byte {
+	var b bytes.Buffer
+	w := zip.NewWriter(&b)
+	for name, text := range files {
+		f, err := w.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.Write([]byte(text))
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return b.Bytes()
+}
+
+func tarGzArchive(t *testing.T, files map[string]string) []byte {
+	var b bytes.Buffer
+	gw := gzip.NewWriter(&b)
+	w := tar.NewWriter(gw)
+	for name, text := range files {
+		if err := w.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(text)), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(text))
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	gw.Close()
+	return b.Bytes()
+}
+
+func TestExtractArchive(t *testing.T) {
+	files := map[string]string{
+		"templates/page.tmpl":            `{{template "partials/footer.tmpl" .}}`,
+		"templates/partials/footer.tmpl": `{{.Year}}`,
+		"templates/.hidden":              "skipped",
+		"__MACOSX/templates/._page.tmpl": "skipped",
+		"logo.png":                       "\xff\xd8",
+	}
+	expected := []source{
+		{name: "templates/page.tmpl", text: files["templates/page.tmpl"]},
+		{name: "templates/partials/footer.tmpl", text: files["templates/partials/footer.tmpl"]},
+	}
+	for name, archive := range map[string][]byte{"zip": zipArchive(t, files), "tar.gz": tarGzArchive(t, files)} {
+		sources, err := extractArchive(archive)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if !reflect.DeepEqual(expected, sources) {
+			t.Errorf("%s: expected %v, actual %v", name, expected, sources)
+		}
+	}
+}
+
+func TestExtractUnsafeArchive(t *testing.T) {
+	tests := map[string][]byte{
+		"outside":  zipArchive(t, map[string]string{"../../etc/passwd": "x"}),
+		"absolute": tarGzArchive(t, map[string]string{"/etc/passwd": "x"}),
+		"too big":  zipArchive(t, map[string]string{"big.tmpl": strings.Repeat("x", maxArchiveFileSize+1)}),
+		"empty":    zipArchive(t, map[string]string{".hidden": "x"}),
+		"garbage":  []byte("not an archive at all, but long enough to look for a tar header in it"),
+	}
+	for name, archive := range tests {
+		if _, err := extractArchive(archive); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestValidateSet(t *testing.T) {
+	sources := []source{
+		{name: "page.tmpl", text: "{{template \"footer.tmpl\" .}}\n{{if .X}}"},
+		{name: "footer.tmpl", text: "{{.Year | year}}"},
+	}
+	results := (&App{}).validateSet(context.Background(), sources, "", "", options{})
+	if len(results) != 2 || results[0].File != "page.tmpl" || results[1].File != "footer.tmpl" {
+		t.Fatalf("unexpected results %+v", results)
+	}
+	if len(results[0].Errors) != 1 {
+		t.Fatalf("unexpected errors in page.tmpl: %v", results[0].Errors)
+	}
+	assertError(t, templateError{Line: 1, Char: 0, Level: parseErrorLevel, Description: "{{if}} has no matching {{end}}"}, results[0].Errors[0])
+	if len(results[1].Errors) != 1 {
+		t.Fatalf("unexpected errors in footer.tmpl: %v", results[1].Errors)
+	}
+	assertError(t, templateError{Line: 0, Char: 10, Level: parseErrorLevel, Description: `function "year" not defined`}, results[1].Errors[0])
+}
+
+func TestValidateSetExecuted(t *testing.T) {
+	sources := []source{
+		{name: "page.tmpl", text: "{{template \"partials/footer.tmpl\" .User}}"},
+		{name: "partials/footer.tmpl", text: "\n{{.Name.First}}"},
+	}
+	results := (&App{}).validateSet(context.Background(), sources, `{"User": {"Name": "x"}}`, "", options{})
+	if len(results) != 2 || len(results[0].Errors) != 0 || len(results[1].Errors) != 1 {
+		t.Fatalf("unexpected results %+v", results)
+	}
+	tplErr := results[1].Errors[0]
+	if tplErr.File != "partials/footer.tmpl" || tplErr.Level != execErrorLevel || tplErr.Line != 1 {
+		t.Errorf("unexpected error %+v", tplErr)
+	}
+
+	results = (&App{}).validateSet(context.Background(), sources, "{", "", options{})
+	if len(results) != 3 || results[0].File != "" || results[0].Errors[0].Level != dataErrorLevel {
+		t.Errorf("unexpected results %+v", results)
+	}
+}
+
+func TestValidateSetParseGlob(t *testing.T) {
+	sources := []source{
+		{name: "pages/index.tmpl", text: `{{template "footer.tmpl" .}}`},
+		{name: "partials/footer.tmpl", text: "\n{{.Name.First}}"},
+		{name: "old/footer.tmpl", text: "old"},
+	}
+	results := (&App{}).validateSet(context.Background(), sources, `{"Name": "x"}`, "", options{ParseGlob: true})
+	var files []string
+	for _, result := range results {
+		files = append(files, result.File)
+	}
+	if !reflect.DeepEqual(files, []string{"old/footer.tmpl", "pages/index.tmpl", "partials/footer.tmpl"}) {
+		t.Fatalf("unexpected files %v", files)
+	}
+	expected := [][]string{
+		{`parsed as "footer.tmpl", like ParseGlob names it, and replaced by partials/footer.tmpl`},
+		nil,
+		{`parsed as "footer.tmpl", like ParseGlob names it, replacing old/footer.tmpl`,
+			`executing "footer.tmpl" at <.Name.First>: can't evaluate field First in type interface {}: ` +
+				"`.Name` is a string, not an object with key `First`"},
+	}
+	for i, result := range results {
+		var descriptions []string
+		for _, tplErr := range result.Errors {
+			descriptions = append(descriptions, tplErr.Description)
+		}
+		if !reflect.DeepEqual(expected[i], descriptions) {
+			t.Errorf("%s: expected %q, actual %q", result.File, expected[i], descriptions)
+		}
+	}
+	// ParseGlob returns the template named like the first file, which the later one replaced
+	if tplErr := results[2].Errors[1]; tplErr.Line != 1 || tplErr.Level != execErrorLevel {
+		t.Errorf("unexpected error %+v", tplErr)
+	}
+}
+
+func TestValidateSetOverrides(t *testing.T) {
+	sources := []source{
+		{name: "layout.tmpl", text: `<h1>{{block "title" .}}{{.Site}}{{end}}</h1>{{block "content" .}}{{end}}`},
+		{name: "pages/about.tmpl", text: `{{define "content"}}{{.About.Text}}{{end}}`},
+		{name: "pages/home.tmpl", text: `{{define "title"}}{{.Home.Title}}{{end}}{{define "content"}}hi{{end}}`},
+	}
+	results := (&App{}).validateSet(context.Background(), sources, `{"Site": "x", "Home": {"Title": "home"}}`, "",
+		options{Overrides: "pages/*", MissingKey: "error"})
+	if len(results) != 3 || len(results[0].Errors) != 0 || len(results[2].Errors) != 0 {
+		t.Fatalf("unexpected results %+v", results)
+	}
+	// the home page's title doesn't leak into the about page, which fails on its own
+	if len(results[1].Errors) != 1 || results[1].Errors[0].Level != execErrorLevel ||
+		!strings.HasPrefix(results[1].Errors[0].Description, `executing "content" at <.About.Text>: map has no entry for key "About"`) {
+		t.Errorf("unexpected errors in pages/about.tmpl %+v", results[1].Errors)
+	}
+
+	results = (&App{}).validateSet(context.Background(), sources, "", "", options{Overrides: "["})
+	if len(results) != 4 || !strings.HasPrefix(results[0].Errors[0].Description, "failed to understand the overrides") {
+		t.Errorf("unexpected results %+v", results)
+	}
+}
+
+func TestValidateArchive(t *testing.T) {
+	archive := zipArchive(t, map[string]string{"a.tmpl": `{{template "b.tmpl"}}`, "b.tmpl": "{{.}}"})
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	fw, err := mw.CreateFormFile("archive", "templates.zip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fw.Write(archive)
+	mw.Close()
+
+	r := httptest.NewRequest("POST", "/api/v1/archive", &body)
+	r.Header.Set("Content-Type", mw.FormDataContentType())
+	w := httptest.NewRecorder()
+	(&App{}).ValidateArchive(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("unexpected response: %d %s", w.Code, w.Body)
+	}
+	var results []fileResult
+	if err := json.NewDecoder(w.Body).Decode(&results); err != nil {
+		t.Fatal(err)
+	}
+	expected := []fileResult{
+		{File: "a.tmpl", Errors: []templateError{}, Stats: &templateStats{Lines: 1, Bytes: 21, Actions: 1}},
+		{File: "b.tmpl", Errors: []templateError{}, Stats: &templateStats{Lines: 1, Bytes: 5, Actions: 1}},
+	}
+	if !reflect.DeepEqual(expected, results) {
+		t.Errorf("expected %+v, actual %+v", expected, results)
+	}
+}
+
+func TestGetFiles(t *testing.T) {
+	upload := func(files ...string) *http.Request {
+		var body bytes.Buffer
+		mw := multipart.NewWriter(&body)
+		for i := 0; i < len(files); i += 2 {
+			fw, err := mw.CreateFormFile("from-file", files[i])
+			if err != nil {
+				t.Fatal(err)
+			}
+			fw.Write([]byte(files[i+1]))
+		}
+		mw.Close()
+		r := httptest.NewRequest("POST", "/", &body)
+		r.Header.Set("Content-Type", mw.FormDataContentType())
+		if err := r.ParseMultipartForm(maxRequestSize); err != nil {
+			t.Fatal(err)
+		}
+		return r
+	}
+
+	sources, err := getFiles(upload("a.tmpl", `{{template "b.tmpl"}}`, "b.tmpl", "{{.}}"))
+	expected := []source{{name: "a.tmpl", text: `{{template "b.tmpl"}}`}, {name: "b.tmpl", text: "{{.}}"}}
+	if err != nil || !reflect.DeepEqual(expected, sources) {
+		t.Errorf("expected %+v, actual %+v, %v", expected, sources, err)
+	}
+	if _, err := getFiles(upload("a.tmpl", "{{.}}")); err != http.ErrMissingFile {
+		t.Errorf("expected a single file not to be a set, actual %v", err)
+	}
+	if _, err := getFiles(upload("a.tmpl", "a", "a.tmpl", "b")); err == nil || err.Error() != "a.tmpl is uploaded twice" {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+func TestGetFormValue(t *testing.T) {
+	form := url.Values{"template": {"plain"}, "data_b64": {base64.StdEncoding.EncodeToString([]byte(`{"a": "{{"}`))},
+		"functions_b64": {"!"}}
+	r := httptest.NewRequest("POST", "/", strings.NewReader(form.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if v, err := getFormValue(r, "template"); err != nil || v != "plain" {
+		t.Errorf("unexpected template %q, %v", v, err)
+	}
+	if v, err := getFormValue(r, "data"); err != nil || v != `{"a": "{{"}` {
+		t.Errorf("unexpected data %q, %v", v, err)
+	}
+	if _, err := getFormValue(r, "functions"); err == nil ||
+		err.Error() != "failed to understand functions_b64: illegal base64 data at input byte 0" {
+		t.Errorf("unexpected error %v", err)
+	}
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+)
+
+const assertErrorLevel ErrorLevel = "assert"
+
+// assertion is a property the output must have. Every check it sets must pass.
+type assertion struct {
+	// Matches is a regular expression the output must match
+	Matches     string `json:"matches"`
+	Contains    string `json:"contains"`
+	NotContains string `json:"notContains"`
+	// MinLength and MaxLength bound the length of the output in bytes
+	MinLength *int `json:"minLength"`
+	MaxLength *int `json:"maxLength"`
+	// ParsesAs is a format the output must be valid in: json, xml or csv
+	ParsesAs string `json:"parsesAs"`
+
+	matches *regexp.Regexp
+}
+
+// outputParsers check that output is valid in a format
+var outputParsers = map[string]func(output string) error{
+	"json": func(output string) error {
+		var v interface{}
+		return json.Unmarshal([]byte(output), &v)
+	},
+	"xml": func(output string) error {
+		d := xml.NewDecoder(strings.NewReader(output))
+		for {
+			if _, err := d.Token(); err == io.EOF {
+				return nil
+			} else if err != nil {
+				return err
+			}
+		}
+	},
+	"csv": func(output string) error {
+		_, err := csv.NewReader(strings.NewReader(output)).ReadAll()
+		return err
+	},
+}
+
+// parseAssertions parses a JSON array of assertions
+func parseAssertions(raw string) ([]assertion, error) {
+	d := json.NewDecoder(bytes.NewReader([]byte(raw)))
+	d.DisallowUnknownFields()
+	var assertions []assertion
+	if err := d.Decode(&assertions); err != nil {
+		return nil, err
+	}
+	for i := range assertions {
+		a := &assertions[i]
+		if a.Matches != "" {
+			var err error
+			if a.matches, err = regexp.Compile(a.Matches); err != nil {
+				return nil, fmt.Errorf("assertion %d: %v", i+1, err)
+			}
+		}
+		if _, ok := outputParsers[a.ParsesAs]; a.ParsesAs != "" && !ok {
+			return nil, fmt.Errorf("assertion %d: can't parse output as %q, only as json, xml or csv", i+1, a.ParsesAs)
+		}
+	}
+	return assertions, nil
+}
+
+// checkAssertions returns an error for every check of assertions output fails. size is the length of the full output,
+// which is more than output's if it was truncated.
+func checkAssertions(assertions []assertion, output string, size int) []templateError {
+	var failures []string
+	for _, a := range assertions {
+		if a.matches != nil && !a.matches.MatchString(output) {
+			failures = append(failures, fmt.Sprintf("output doesn't match `%s`", a.Matches))
+		}
+		if a.Contains != "" && !strings.Contains(output, a.Contains) {
+			failures = append(failures, fmt.Sprintf("output doesn't contain %q", a.Contains))
+		}
+		if a.NotContains != "" && strings.Contains(output, a.NotContains) {
+			failures = append(failures, fmt.Sprintf("output contains %q", a.NotContains))
+		}
+		if a.MinLength != nil && size < *a.MinLength {
+			failures = append(failures, fmt.Sprintf("output is %d bytes, shorter than %d", size, *a.MinLength))
+		}
+		if a.MaxLength != nil && size > *a.MaxLength {
+			failures = append(failures, fmt.Sprintf("output is %d bytes, longer than %d", size, *a.MaxLength))
+		}
+		if a.ParsesAs != "" {
+			if err := outputParsers[a.ParsesAs](output); err != nil {
+				failures = append(failures, fmt.Sprintf("output isn't valid %s: %v", a.ParsesAs, err))
+			}
+		}
+	}
+
+	tplErrs := make([]templateError, len(failures))
+	for i, failure := range failures {
+		tplErrs[i] = templateError{Line: -1, Char: -1, Level: assertErrorLevel, Description: failure}
+	}
+	return tplErrs
+}
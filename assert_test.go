@@ -0,0 +1,44 @@
+package main
+
+import (
+	"context"
+	"testing"
+)
+
+func TestAssertions(t *testing.T) {
+	assertions, err := parseAssertions(`[{"contains": "Hi", "notContains": "<no value>"}, {"matches": "^\\{", "minLength": 3, "maxLength": 5}, {"parsesAs": "json"}]`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if errs := checkAssertions(assertions, `{"a": "Hi"}`, 11); len(errs) != 1 {
+		t.Errorf("unexpected errors found: %v", errs)
+	} else {
+		assertError(t, templateError{Line: -1, Char: -1, Level: assertErrorLevel, Description: "output is 11 bytes, longer than 5"}, errs[0])
+	}
+
+	errs := checkAssertions(assertions, "<no value>", 10)
+	expected := []string{`output doesn't contain "Hi"`, `output contains "<no value>"`, "output doesn't match `^\\{`",
+		"output is 10 bytes, longer than 5", "output isn't valid json: invalid character '<' looking for beginning of value"}
+	if len(errs) != len(expected) {
+		t.Fatalf("unexpected errors found: %v", errs)
+	}
+	for i, tplErr := range errs {
+		assertError(t, templateError{Line: -1, Char: -1, Level: assertErrorLevel, Description: expected[i]}, tplErr)
+	}
+}
+
+func TestBadAssertions(t *testing.T) {
+	for _, raw := range []string{`{"contains": "a"}`, `[{"matches": "("}]`, `[{"parsesAs": "yaml"}]`, `[{"startsWith": "a"}]`} {
+		if _, err := parseAssertions(raw); err == nil {
+			t.Errorf("expected %s to be rejected", raw)
+		}
+	}
+}
+
+func TestAssertionsOption(t *testing.T) {
+	data := (&App{}).createData(context.Background(), `<a>{{.}}</a>`, `"x"`, "", options{Assertions: `[{"parsesAs": "xml"}, {"contains": "y"}]`})
+	if len(data.Errors) != 1 {
+		t.Fatalf("unexpected errors found: %v", data.Errors)
+	}
+	assertError(t, templateError{Line: -1, Char: -1, Level: assertErrorLevel, Description: `output doesn't contain "y"`}, data.Errors[0])
+}
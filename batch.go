@@ -0,0 +1,88 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// batch is a set of templates to validate together, inline or fetched from URLs
+type batch struct {
+	Functions string `json:"functions,omitempty"`
+	// Data is what the first template is executed with, it isn't executed without it
+	Data json.RawMessage `json:"data,omitempty"`
+	// DataB64 is the data encoded as base64, instead of Data
+	DataB64   []byte          `json:"data_b64,omitempty"`
+	Templates []batchTemplate `json:"templates"`
+}
+
+type batchTemplate struct {
+	// Name is what the template is named in the set, the path of its URL by default
+	Name string `json:"name,omitempty"`
+	Text string `json:"text,omitempty"`
+	// TextB64 is the text encoded as base64, instead of Text, so it doesn't have to be escaped
+	TextB64 []byte `json:"text_b64,omitempty"`
+	// URL is an http, https, s3 or gs URL to fetch the template from, instead of its text
+	URL string `json:"url,omitempty"`
+}
+
+// ValidateBatch validates the batch in the body as one set and serves the errors in each template, as JSON. A template
+// that can't be fetched has that as its error.
+func (a *App) ValidateBatch(w http.ResponseWriter, r *http.Request) {
+	var b batch
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestSize)).Decode(&b); err != nil {
+		http.Error(w, fmt.Sprintf("failed to understand batch: %v", err), http.StatusBadRequest)
+		return
+	}
+	if len(b.Templates) > maxArchiveFiles {
+		http.Error(w, fmt.Sprintf("batches can have at most %d templates", maxArchiveFiles), http.StatusBadRequest)
+		return
+	}
+
+	var sources []source
+	var failed []fileResult
+	for _, bt := range b.Templates {
+		src, err := a.batchSource(r, bt)
+		if err != nil {
+			failed = append(failed, fileResult{File: src.name, Errors: []templateError{{Line: -1, Char: -1,
+				Level: misunderstoodError, Description: fmt.Sprintf("failed to fetch template: %v", err)}}})
+			continue
+		}
+		sources = append(sources, src)
+	}
+	rawData := string(b.Data)
+	if b.DataB64 != nil {
+		rawData = string(b.DataB64)
+	}
+	results := append(a.validateSet(r.Context(), sources, rawData, b.Functions, getOptions(r)), failed...)
+	if r.Context().Err() != nil {
+		return
+	}
+	writeJSON(w, results)
+}
+
+// batchSource returns the source of a template of a batch, fetching it if it has a URL. It's named even if it fails.
+func (a *App) batchSource(r *http.Request, bt batchTemplate) (source, error) {
+	src := source{name: bt.Name, text: bt.Text}
+	if bt.TextB64 != nil {
+		src.text = string(bt.TextB64)
+	}
+	if bt.URL == "" {
+		return src, nil
+	}
+	if src.name == "" {
+		src.name = bt.URL
+		if u, err := url.Parse(bt.URL); err == nil && u.Path != "" {
+			src.name = strings.TrimPrefix(u.Path, "/")
+		}
+	}
+	if a.fetcher == nil {
+		return src, errors.New("fetching templates isn't configured")
+	}
+	text, err := a.fetcher.fetch(r.Context(), bt.URL)
+	src.text = text
+	return src, err
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"io"
+	"runtime"
+	"sort"
+	"text/template"
+	"time"
+)
+
+const (
+	// benchmarkWarmups are executions before a benchmark which aren't measured
+	benchmarkWarmups = 3
+	// maxBenchmarkRuns is the most executions a benchmark on the command line may measure
+	maxBenchmarkRuns = 10000
+	// maxServerBenchmarkRuns is the most executions a benchmark of a request to the server may measure, as it's shared
+	maxServerBenchmarkRuns = 100
+)
+
+// benchmark measures how long executing a template takes, and how much it allocates
+type benchmark struct {
+	Runs int
+	Min  time.Duration
+	Avg  time.Duration
+	P95  time.Duration
+	// Allocs and Bytes are the average allocations of a run, if MeasuredAllocs. The allocations of a process serving
+	// others can't be told apart from theirs.
+	Allocs         uint64
+	Bytes          uint64
+	MeasuredAllocs bool
+}
+
+// runBenchmark executes t with data runs times after warming up, with the limits of executing maxSteps actions and
+// maxIterations iterations of a range in every run. Executing is stopped early if ctx is cancelled, and the runs so far
+// are measured. Only local benchmarks, on the command line, run up to maxBenchmarkRuns times and measure allocations.
+func runBenchmark(ctx context.Context, t *template.Template, data interface{}, runs, maxSteps, maxIterations int,
+	local bool) (*benchmark, error) {
+	if local {
+		runs = min(runs, maxBenchmarkRuns)
+	} else {
+		runs = min(runs, maxServerBenchmarkRuns)
+	}
+	limitedT, limiter, err := newLimitedTemplate(ctx, t, maxSteps, maxIterations)
+	if err != nil {
+		return nil, err
+	}
+	execute := func() error {
+		limiter.reset()
+		return limitedT.Execute(io.Discard, data)
+	}
+	for i := 0; i < benchmarkWarmups; i++ {
+		if err := execute(); err != nil {
+			return nil, err
+		}
+	}
+
+	durations := make([]time.Duration, 0, runs)
+	var before, after runtime.MemStats
+	if local {
+		runtime.ReadMemStats(&before)
+	}
+	for i := 0; i < runs && ctx.Err() == nil; i++ {
+		start := time.Now()
+		err := execute()
+		durations = append(durations, time.Since(start))
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(durations) == 0 {
+		return nil, ctx.Err()
+	}
+	var allocs, bytes uint64
+	if local {
+		runtime.ReadMemStats(&after)
+		allocs, bytes = after.Mallocs-before.Mallocs, after.TotalAlloc-before.TotalAlloc
+	}
+
+	sort.Slice(durations, func(i, j int) bool { return durations[i] < durations[j] })
+	var total time.Duration
+	for _, d := range durations {
+		total += d
+	}
+	n := len(durations)
+	return &benchmark{
+		Runs:           n,
+		Min:            durations[0],
+		Avg:            total / time.Duration(n),
+		P95:            durations[(n*95+99)/100-1],
+		Allocs:         allocs / uint64(n),
+		Bytes:          bytes / uint64(n),
+		MeasuredAllocs: local,
+	}, nil
+}
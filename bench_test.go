@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"text/template"
+)
+
+func TestRunBenchmark(t *testing.T) {
+	tpl := template.Must(template.New("t").Parse(`{{range .}}{{.}}{{end}}`))
+	b, err := runBenchmark(context.Background(), tpl, []int{1, 2, 3}, 20, defaultMaxSteps, defaultMaxIterations, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.Runs != 20 || b.Min <= 0 || b.Min > b.Avg || b.Min > b.P95 || !b.MeasuredAllocs {
+		t.Errorf("unexpected benchmark: %+v", b)
+	}
+
+	tpl = template.Must(template.New("t").Parse(`{{.X}}`))
+	if _, err := runBenchmark(context.Background(), tpl, 1, 20, defaultMaxSteps, defaultMaxIterations, true); err == nil {
+		t.Error("expected executing to fail")
+	}
+}
+
+func TestRunBenchmarkLimits(t *testing.T) {
+	// every run gets all of the steps, but no more
+	tpl := template.Must(template.New("t").Parse(`{{range .}}{{.}}{{end}}`))
+	b, err := runBenchmark(context.Background(), tpl, []int{1, 2, 3}, maxBenchmarkRuns, 10, 3, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.Runs != maxServerBenchmarkRuns || b.MeasuredAllocs || b.Allocs != 0 {
+		t.Errorf("unexpected benchmark: %+v", b)
+	}
+	_, err = runBenchmark(context.Background(), tpl, []int{1, 2, 3, 4}, 5, defaultMaxSteps, 3, false)
+	if err == nil || !strings.Contains(err.Error(), "range stopped after 3 iterations") {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+func TestBenchmarkOption(t *testing.T) {
+	data := (&App{}).createData(context.Background(), `{{.}}`, "1", "", options{Benchmark: 5})
+	if len(data.Errors) != 0 {
+		t.Fatalf("unexpected errors found: %v", data.Errors)
+	}
+	if data.Benchmark == nil || data.Benchmark.Runs != 5 {
+		t.Errorf("unexpected benchmark: %+v", data.Benchmark)
+	}
+
+	// templates with errors aren't benchmarked
+	data = (&App{}).createData(context.Background(), `{{.X}}`, "1", "", options{Benchmark: 5})
+	if data.Benchmark != nil {
+		t.Errorf("unexpected benchmark: %+v", data.Benchmark)
+	}
+}
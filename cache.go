@@ -0,0 +1,121 @@
+package main
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"text/template"
+)
+
+// parseCacheSize is how many parsed templates the server keeps
+const parseCacheSize = 256
+
+// parseKey identifies what parsing a template depends on: its text, the functions it can call and its options
+type parseKey [sha256.Size]byte
+
+func newParseKey(text string, functions []string, missingKey string) parseKey {
+	h := sha256.New()
+	// lengths keep the parts from running into each other
+	for _, s := range append([]string{text, missingKey}, functions...) {
+		fmt.Fprintf(h, "%d:%s", len(s), s)
+	}
+	var key parseKey
+	copy(key[:], h.Sum(nil))
+	return key
+}
+
+type parseCacheEntry struct {
+	key     parseKey
+	t       *template.Template
+	tplErrs []templateError
+}
+
+// parseCache keeps the results of parsing the most recently validated templates, so validating the same template again
+// skips parsing and recovering from its errors. A nil cache keeps nothing.
+type parseCache struct {
+	mu      sync.Mutex
+	size    int
+	entries map[parseKey]*list.Element
+	// recent holds the entries, most recently used first
+	recent *list.List
+	hits   int
+	misses int
+}
+
+// parseCacheStats describe how well the parse cache is doing
+type parseCacheStats struct {
+	Size     int `json:"size"`
+	Capacity int `json:"capacity"`
+	Hits     int `json:"hits"`
+	Misses   int `json:"misses"`
+}
+
+func newParseCache(size int) *parseCache {
+	return &parseCache{size: size, entries: map[parseKey]*list.Element{}, recent: list.New()}
+}
+
+// get returns a copy of the template and errors parsed for key, if they're kept
+func (c *parseCache) get(key parseKey) (*template.Template, []templateError, bool) {
+	if c == nil {
+		return nil, nil, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[key]
+	if !ok {
+		c.misses++
+		return nil, nil, false
+	}
+	entry := e.Value.(*parseCacheEntry)
+	t, err := copyTemplate(entry.t)
+	if err != nil {
+		c.misses++
+		return nil, nil, false
+	}
+	c.hits++
+	c.recent.MoveToFront(e)
+	return t, append([]templateError{}, entry.tplErrs...), true
+}
+
+// add keeps a copy of the template and errors parsed for key, forgetting the least recently used ones if there are too
+// many
+func (c *parseCache) add(key parseKey, t *template.Template, tplErrs []templateError) {
+	if c == nil {
+		return
+	}
+	t, err := copyTemplate(t)
+	if err != nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.entries[key]; ok {
+		c.recent.Remove(e)
+	}
+	c.entries[key] = c.recent.PushFront(&parseCacheEntry{key: key, t: t, tplErrs: append([]templateError{}, tplErrs...)})
+	for c.recent.Len() > c.size {
+		oldest := c.recent.Back()
+		c.recent.Remove(oldest)
+		delete(c.entries, oldest.Value.(*parseCacheEntry).key)
+	}
+}
+
+func (c *parseCache) stats() parseCacheStats {
+	if c == nil {
+		return parseCacheStats{}
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return parseCacheStats{Size: c.recent.Len(), Capacity: c.size, Hits: c.hits, Misses: c.misses}
+}
+
+// CacheStats serves the parse cache's stats as JSON
+func (a *App) CacheStats(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(a.parseCache.stats()); err != nil {
+		http.Error(w, fmt.Sprintf("Encode error: %v", err), http.StatusInternalServerError)
+	}
+}
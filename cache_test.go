@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"text/template"
+)
+
+func TestParseCacheEviction(t *testing.T) {
+	c := newParseCache(2)
+	keys := []parseKey{newParseKey("a", nil, ""), newParseKey("b", nil, ""), newParseKey("c", nil, "")}
+	for _, key := range keys[:2] {
+		c.add(key, template.Must(template.New("t").Parse("x")), nil)
+	}
+	// a is now more recently used than b
+	if _, _, ok := c.get(keys[0]); !ok {
+		t.Fatal("expected a to be kept")
+	}
+	c.add(keys[2], template.Must(template.New("t").Parse("x")), nil)
+	if _, _, ok := c.get(keys[1]); ok {
+		t.Error("expected b to be forgotten")
+	}
+	if expected, actual := (parseCacheStats{Size: 2, Capacity: 2, Hits: 1, Misses: 1}), c.stats(); expected != actual {
+		t.Errorf("expected %+v, actual %+v", expected, actual)
+	}
+}
+
+func TestParseKey(t *testing.T) {
+	if newParseKey("a", []string{"b"}, "") == newParseKey("ab", nil, "") {
+		t.Error("text and functions aren't kept apart")
+	}
+	if newParseKey("a", nil, "") == newParseKey("a", nil, "error") {
+		t.Error("missingkey isn't part of the key")
+	}
+}
+
+func TestCachedCreateData(t *testing.T) {
+	a := &App{parseCache: newParseCache(parseCacheSize)}
+	text := "{{foo}}{{now | date \"2006\"}}\n{{if}}{{end}}"
+	first := a.createData(context.Background(), text, "", "", options{})
+	a.tplErrs = nil
+	second := a.createData(context.Background(), text, "", "", options{Now: "2021-07-18T10:00:00Z"})
+	if stats := a.parseCache.stats(); stats.Hits != 1 || stats.Misses != 1 {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+	if len(first.Errors) != len(second.Errors) {
+		t.Fatalf("errors changed: %v then %v", first.Errors, second.Errors)
+	}
+	for i := range first.Errors {
+		assertError(t, first.Errors[i], second.Errors[i])
+	}
+	if first.Output != "<nil>2006\n" || second.Output != "<nil>2021\n" {
+		t.Errorf("unexpected output: `%s` then `%s`", first.Output, second.Output)
+	}
+}
@@ -0,0 +1,294 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+var (
+	fixFlag       = flag.Bool("fix", false, "apply safe fixes and print the diff")
+	writeFlag     = flag.Bool("w", false, "with -fix, write the fixed template back to its file")
+	dataFlag      = flag.String("data", "", "JSON `file` with data to execute the templates with, - for stdin")
+	jsonFlag      = flag.Bool("json", false, "print the errors of each template as JSON instead")
+	functionsFlag = flag.String("functions", "", "comma separated list of function names to mock")
+	goFlag        = flag.String("go", "", "Go `file` declaring functions to call instead of mocking them")
+	profileFlag   = flag.String("profile", "", "`name` of the profile of options to validate with, like ci-strict")
+	goVersionFlag = flag.String("go-version", "", "`version` of Go, like 1.17, whose templates to validate like")
+	presetFlag    = flag.String("function-preset", "", "`name` of the preset of functions to mock, like sprig or helm")
+	strictFlag    = flag.Bool("strict", false, "report every call to an unknown function and don't execute templates calling them")
+	exprFlag      = flag.String("expr", "", "`file` of functions defined by expressions, like double: x * 2")
+	metricsFlag   = flag.Bool("metrics", false, "print the complexity metrics of each template")
+	graphFlag     = flag.Bool("graph", false, "print which templates include which, in the Graphviz DOT language")
+	benchFlag     = flag.Int("benchmark", 0, "execute each template this many `times` and print how long it took")
+	fuzzFlag      = flag.Bool("fuzz", false, "execute each template with variations of the data and print the ones it fails with")
+	protoFlag     = flag.String("proto", "", "descriptor set or .proto `file` of the protobuf message the -data file is")
+	protoMsgFlag  = flag.String("proto-message", "", "`type` of the protobuf message the -data file is, if -proto has several")
+	dataFmtFlag   = flag.String("data-format", "", "`format` of the -data file, json, cue, hcl, ini, properties or urlencoded, by its extension if it's not set")
+	ndjsonFlag    = flag.Bool("ndjson", false, "read the data as newline delimited JSON and execute each template with every line")
+	emailFlag     = flag.Bool("email", false, "render the subject, html and text templates as an email and print it")
+	shotFlag      = flag.Bool("screenshot", false, "with -chrome, write a PNG thumbnail of each template's output next to it")
+	usageFlag     = flag.Bool("function-usage", false, "print the functions each template calls, with where and how many arguments")
+	entryFlag     = flag.String("entry", "", "`name` of the template to execute instead of the root one")
+	dotFlag       = flag.String("dot", "", "field `path` of the data to execute with, like .Page")
+	nowFlag       = flag.String("now", "", "RFC 3339 `time` the time functions use as the current time")
+	seedFlag      = flag.String("seed", "", "`seed` of the random functions, so output is the same every time")
+	maxStepsFlag  = flag.Int("max-steps", defaultMaxSteps, "most actions executing a template may run, the most requests can set it to when serving")
+	maxItersFlag  = flag.Int("max-iterations", defaultMaxIterations, "most times a range may iterate in a row, the most requests can set it to when serving")
+	maxOutputFlag = flag.Int("max-output", defaultMaxOutput, "most `bytes` of output kept, the most requests can set it to when serving")
+	assertFlag    = flag.String("assert", "", "JSON `file` of assertions the output must pass")
+	lintFlag      = flag.String("lint", "", "JSON `file` of lint rule names to severities: off, info, warning or error")
+)
+
+const (
+	// stdinPath is the path reading from stdin instead of a file
+	stdinPath = "-"
+	// stdinName is what errors in a template from stdin are reported against, like gofmt does
+	stdinName = "<standard input>"
+)
+
+// runCLI validates the template files in paths, - for stdin, writing errors to stdout, and returns the process exit
+// code
+func runCLI(paths []string, stdin io.Reader, stdout io.Writer) int {
+	stdins := 0
+	for _, path := range append([]string{*dataFlag}, paths...) {
+		if path == stdinPath {
+			stdins++
+		}
+	}
+	if stdins > 1 {
+		fmt.Fprintln(os.Stderr, "only one of the templates and the data can be read from stdin")
+		return 2
+	}
+
+	rawData := ""
+	if *dataFlag != "" {
+		b, err := readInput(*dataFlag, stdin)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 2
+		}
+		rawData = string(b)
+		if *protoFlag != "" {
+			schema, err := ioutil.ReadFile(*protoFlag)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				return 2
+			}
+			if rawData, err = protobufJSON(schema, strings.HasSuffix(*protoFlag, ".proto"), *protoMsgFlag, b); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				return 2
+			}
+		}
+	}
+
+	opts := options{Now: *nowFlag, Seed: *seedFlag, MaxSteps: *maxStepsFlag, MaxIterations: *maxItersFlag,
+		MaxOutput: *maxOutputFlag, Benchmark: *benchFlag, Fuzz: *fuzzFlag, NDJSON: *ndjsonFlag, Email: *emailFlag,
+		Screenshot: *shotFlag, Entry: *entryFlag, Dot: *dotFlag, Strict: *strictFlag, Profile: *profileFlag,
+		FunctionPreset: *presetFlag, GoVersion: *goVersionFlag, DataFormat: *dataFmtFlag}
+	if opts.DataFormat == "" {
+		opts.DataFormat = dataFormatOf(*dataFlag)
+	}
+	if *lintFlag != "" {
+		b, err := ioutil.ReadFile(*lintFlag)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 2
+		}
+		opts.LintConfig = string(b)
+	}
+	if *goFlag != "" {
+		b, err := ioutil.ReadFile(*goFlag)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 2
+		}
+		opts.GoFunctions = string(b)
+	}
+	if *exprFlag != "" {
+		b, err := ioutil.ReadFile(*exprFlag)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 2
+		}
+		opts.Expressions = string(b)
+	}
+	if *assertFlag != "" {
+		b, err := ioutil.ReadFile(*assertFlag)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 2
+		}
+		opts.Assertions = string(b)
+	}
+	extensions, err := loadExtensions(splitList(*pluginsFlag), splitList(*extensionsFlag))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	profiles, err := loadProfiles(*profilesFlag)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	functionPresets, err := loadFunctionPresets(*presetsFlag)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	code := 0
+	results := make([]fileResult, 0, len(paths))
+	for _, path := range paths {
+		b, err := readInput(path, stdin)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 2
+		}
+		text := string(b)
+		name := path
+		if path == stdinPath {
+			name = stdinName
+		}
+
+		a := &App{tplErrs: make([]templateError, 0), browser: newBrowser(*chromeFlag, *browsersFlag), interpretGo: true,
+			extensions: extensions, profiles: profiles, functionPresets: functionPresets, localBenchmarks: true,
+			maxSteps: *maxStepsFlag, maxIterations: *maxItersFlag, maxOutput: *maxOutputFlag}
+		var data indexData
+		if *fixFlag {
+			data = a.autoFix(context.Background(), text, rawData, *functionsFlag, opts)
+			switch {
+			case *writeFlag && path == stdinPath:
+				// filters write the fixed template instead
+				fmt.Fprint(stdout, data.RawText)
+			case !*jsonFlag:
+				fmt.Fprint(stdout, data.Diff)
+			}
+			if *writeFlag && path != stdinPath && data.Diff != "" {
+				if err := ioutil.WriteFile(path, []byte(data.RawText), 0644); err != nil {
+					fmt.Fprintln(os.Stderr, err)
+					return 2
+				}
+			}
+		} else {
+			data = a.createData(context.Background(), text, rawData, *functionsFlag, opts)
+		}
+
+		if *metricsFlag {
+			s := data.Stats
+			fmt.Fprintf(stdout, "%s: %d lines, %d bytes, %d actions, nesting depth %d, %d defines\n",
+				name, s.Lines, s.Bytes, s.Actions, s.NestingDepth, s.Defines)
+			for _, m := range data.Metrics {
+				fmt.Fprintf(stdout, "%s: %q: nesting depth %d, %d actions, %d fields, %d branches, longest pipeline %d\n",
+					name, m.Name, m.NestingDepth, m.Actions, m.Fields, m.Branches, m.LongestPipeline)
+			}
+		}
+
+		if *graphFlag && data.Graph != nil {
+			fmt.Fprint(stdout, data.Graph.DOT())
+		}
+
+		if b := data.Benchmark; b != nil {
+			fmt.Fprintf(stdout, "%s: benchmark: %d runs, min %v, avg %v, p95 %v, %d allocs (%d bytes) per run\n",
+				name, b.Runs, b.Min, b.Avg, b.P95, b.Allocs, b.Bytes)
+		}
+
+		for _, f := range data.Fuzz {
+			fmt.Fprintf(stdout, "%s: fuzz: %s %s: %s\n", name, f.Path, f.Shape, f.Error)
+		}
+
+		for _, r := range data.Records {
+			if r.Error != "" {
+				fmt.Fprintf(stdout, "%s: record %d (data line %d): %s\n", name, r.Record, r.Line+1, r.Error)
+				code = 1
+			}
+		}
+
+		if e := data.Email; e != nil {
+			for _, issue := range e.Compatibility {
+				fmt.Fprintf(stdout, "%s: email: %s: not supported by %s\n", name, issue.Feature, issue.Clients)
+			}
+			fmt.Fprint(stdout, e.MIME)
+		}
+
+		if data.Screenshot != "" && path != stdinPath {
+			b, _ := base64.StdEncoding.DecodeString(data.Screenshot)
+			if err := ioutil.WriteFile(path+".png", b, 0644); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				return 2
+			}
+		}
+
+		if *usageFlag {
+			for _, usage := range data.Functions {
+				for _, c := range usage.Calls {
+					fmt.Fprintf(stdout, "%s:%d:%d: %s function %s called with %d args\n",
+						name, c.At.Line+1, c.At.Char+1, usage.Kind, usage.Name, c.Args)
+				}
+			}
+		}
+
+		if *jsonFlag {
+			results = append(results, fileResult{File: name, Errors: data.Errors, Stats: &data.Stats, Mocked: data.Mocked,
+				Records: data.Records})
+		} else {
+			for _, tplErr := range data.Errors {
+				fmt.Fprintln(stdout, formatError(name, tplErr))
+				if tplErr.Fix != nil {
+					fmt.Fprintf(stdout, "\tfix: %s\n", tplErr.Fix.Description)
+				}
+			}
+			for _, m := range data.Mocked {
+				fmt.Fprintln(stdout, formatMocked(name, m))
+			}
+		}
+		if failing(data.Errors) {
+			code = 1
+		}
+	}
+	if *jsonFlag {
+		if err := json.NewEncoder(stdout).Encode(results); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 2
+		}
+	}
+	return code
+}
+
+// readInput reads the file at path, or stdin for stdinPath
+func readInput(path string, stdin io.Reader) ([]byte, error) {
+	if path == stdinPath {
+		return ioutil.ReadAll(stdin)
+	}
+	return ioutil.ReadFile(path)
+}
+
+// formatError formats an error the way compilers do, with one indexed lines and characters
+// formatMocked formats a mocked function as a warning, which doesn't fail the validation
+func formatMocked(path string, m autoMockedFunction) string {
+	return fmt.Sprintf("%s:%d:%d: function %s was mocked, its calls return nothing [warning]", path, m.At.Line+1,
+		m.At.Char+1, m.Name)
+}
+
+func formatError(path string, tplErr templateError) string {
+	if tplErr.Level == dataErrorLevel {
+		path = tplErr.File
+	}
+	kind := string(tplErr.Level)
+	if tplErr.Rule != "" {
+		kind += " " + string(tplErr.Severity) + " " + tplErr.Rule
+	}
+	switch {
+	case tplErr.Line < 0:
+		return fmt.Sprintf("%s: %s [%s]", path, tplErr.Description, kind)
+	case tplErr.Char < 0:
+		return fmt.Sprintf("%s:%d: %s [%s]", path, tplErr.Line+1, tplErr.Description, kind)
+	}
+	return fmt.Sprintf("%s:%d:%d: %s [%s]", path, tplErr.Line+1, tplErr.Char+1, tplErr.Description, kind)
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// withFlag sets a flag for the rest of the test
+func withFlag(t *testing.T, flag *string, value string) {
+	previous := *flag
+	*flag = value
+	t.Cleanup(func() { *flag = previous })
+}
+
+func withBoolFlag(t *testing.T, flag *bool, value bool) {
+	previous := *flag
+	*flag = value
+	t.Cleanup(func() { *flag = previous })
+}
+
+func TestRunCLIStdin(t *testing.T) {
+	var stdout bytes.Buffer
+	if code := runCLI([]string{stdinPath}, strings.NewReader("{{if .}}"), &stdout); code != 1 {
+		t.Errorf("expected exit code 1, actual %d", code)
+	}
+	expected := "<standard input>:1:1: {{if}} has no matching {{end}} [parse]\n\tfix: add the missing {{end}}\n"
+	if stdout.String() != expected {
+		t.Errorf("expected %q, actual %q", expected, stdout.String())
+	}
+}
+
+func TestRunCLIStdinFix(t *testing.T) {
+	withBoolFlag(t, fixFlag, true)
+	withBoolFlag(t, writeFlag, true)
+	var stdout bytes.Buffer
+	runCLI([]string{stdinPath}, strings.NewReader("{{if .}}a"), &stdout)
+	if stdout.String() != "{{if .}}a{{end}}" {
+		t.Errorf("expected the fixed template, actual %q", stdout.String())
+	}
+}
+
+func TestRunCLIStdinDataJSON(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "page.tmpl")
+	if err := ioutil.WriteFile(path, []byte("{{.Name.First}}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	withFlag(t, dataFlag, stdinPath)
+	withBoolFlag(t, jsonFlag, true)
+
+	var stdout bytes.Buffer
+	if code := runCLI([]string{path}, strings.NewReader(`{"Name": "Bob"}`), &stdout); code != 1 {
+		t.Errorf("expected exit code 1, actual %d", code)
+	}
+	var results []fileResult
+	if err := json.Unmarshal(stdout.Bytes(), &results); err != nil {
+		t.Fatalf("%v: %s", err, stdout.String())
+	}
+	if len(results) != 1 || results[0].File != path || len(results[0].Errors) != 1 ||
+		results[0].Errors[0].Level != execErrorLevel {
+		t.Errorf("unexpected results %+v", results)
+	}
+
+	if code := runCLI([]string{stdinPath}, strings.NewReader(""), &stdout); code != 2 {
+		t.Errorf("expected exit code 2 reading both from stdin, actual %d", code)
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"text/template"
+	"time"
+	// dateInZone needs time zones even where the system has none
+	_ "time/tzdata"
+)
+
+// defaultNow is what now returns unless another time is set, go's reference time
+var defaultNow = time.Date(2006, time.January, 2, 15, 4, 5, 0, time.UTC)
+
+// timeFunctionDocs document the time functions, named like Sprig's
+var timeFunctionDocs = []functionDoc{
+	{"now", "now", "Returns the current time, which is fixed so output is reproducible.", `{{now | date "2006-01-02"}}`, "time"},
+	{"date", "date layout time", "Formats a time, or a number of seconds since the unix epoch, with a go time layout.", `{{date "Jan 2, 2006" .Created}}`, "time"},
+	{"dateInZone", "dateInZone layout time zone", "Formats a time in an IANA time zone.", `{{dateInZone "15:04" now "Asia/Shanghai"}}`, "time"},
+	{"unixEpoch", "unixEpoch time", "Returns the number of seconds since the unix epoch.", `{{unixEpoch now}}`, "time"},
+}
+
+// parseNow parses the time now returns, in RFC 3339 format
+func parseNow(s string) (time.Time, error) {
+	if s == "" {
+		return defaultNow, nil
+	}
+	return time.Parse(time.RFC3339, s)
+}
+
+// timeFunctions returns the time functions, with now returning now
+func timeFunctions(now time.Time) template.FuncMap {
+	return template.FuncMap{
+		"now": func() time.Time { return now },
+		"date": func(layout string, t interface{}) (string, error) {
+			return dateInZone(layout, t, "")
+		},
+		"dateInZone": dateInZone,
+		"unixEpoch": func(t interface{}) (int64, error) {
+			tt, err := toTime(t)
+			return tt.Unix(), err
+		},
+	}
+}
+
+func dateInZone(layout string, t interface{}, zone string) (string, error) {
+	tt, err := toTime(t)
+	if err != nil {
+		return "", err
+	}
+	if zone != "" {
+		loc, err := time.LoadLocation(zone)
+		if err != nil {
+			return "", err
+		}
+		tt = tt.In(loc)
+	}
+	return tt.Format(layout), nil
+}
+
+// toTime converts times, numbers of seconds since the unix epoch in JSON or templates, and RFC 3339 strings to times
+func toTime(t interface{}) (time.Time, error) {
+	switch t := t.(type) {
+	case time.Time:
+		return t, nil
+	case float64:
+		return time.Unix(int64(t), 0).UTC(), nil
+	case int:
+		return time.Unix(int64(t), 0).UTC(), nil
+	case int64:
+		return time.Unix(t, 0).UTC(), nil
+	case string:
+		return time.Parse(time.RFC3339, t)
+	}
+	return time.Time{}, fmt.Errorf("can't use %v of type %T as a time", t, t)
+}
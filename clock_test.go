@@ -0,0 +1,32 @@
+package main
+
+import (
+	"context"
+	"testing"
+)
+
+func TestTimeFunctions(t *testing.T) {
+	text := `{{now | date "2006-01-02 15:04"}} {{unixEpoch now}} {{date "2006" .Created}} {{dateInZone "15:04" now "Asia/Shanghai"}}`
+	data := (&App{}).createData(context.Background(), text, `{"Created": 0}`, "", options{})
+	if len(data.Errors) != 0 {
+		t.Fatalf("unexpected errors found: %v", data.Errors)
+	}
+	if expected := "2006-01-02 15:04 1136214245 1970 23:04"; data.Output != expected {
+		t.Errorf("expected `%s`, actual `%s`", expected, data.Output)
+	}
+
+	data = (&App{}).createData(context.Background(), `{{now | date "2006-01-02"}}`, "", "", options{Now: "2021-07-18T10:00:00+08:00"})
+	if data.Output != "2021-07-18" {
+		t.Errorf("unexpected output: %s", data.Output)
+	}
+}
+
+func TestBadNow(t *testing.T) {
+	data := (&App{}).createData(context.Background(), `{{now | date "2006"}}`, "", "", options{Now: "yesterday"})
+	if len(data.Errors) != 1 || data.Errors[0].Level != misunderstoodError {
+		t.Fatalf("unexpected errors found: %v", data.Errors)
+	}
+	if data.Output != "2006" {
+		t.Errorf("unexpected output: %s", data.Output)
+	}
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// ColumnUnit is what error characters are counted in
+type ColumnUnit string
+
+const (
+	// runeColumns count unicode code points, which matches what users see for most text
+	runeColumns ColumnUnit = "rune"
+	// utf16Columns count UTF-16 code units, as used by LSP clients
+	utf16Columns ColumnUnit = "utf16"
+	// byteColumns count bytes, which is what the template packages report
+	byteColumns ColumnUnit = "byte"
+)
+
+func parseColumnUnit(s string) ColumnUnit {
+	switch ColumnUnit(s) {
+	case utf16Columns, byteColumns:
+		return ColumnUnit(s)
+	}
+	return runeColumns
+}
+
+// toVisualLocations moves error locations from the lines the template packages count, which only end at "\n", to the
+// lines an editor shows, which also end at a lone "\r". Characters stay byte offsets into the line.
+func toVisualLocations(tplErrs []templateError, text string) {
+	if !strings.Contains(text, "\r") {
+		return
+	}
+	for i := range tplErrs {
+		tplErr := &tplErrs[i]
+		// errors in the data are located in it already
+		if tplErr.Line < 0 || tplErr.Level == dataErrorLevel {
+			continue
+		}
+		char := tplErr.Char
+		if char < 0 {
+			char = 0
+		}
+		tplErr.Line, char = visualPosition(text, errorOffset(text, tplErr.Line, char))
+		if tplErr.Char >= 0 {
+			tplErr.Char = char
+		}
+	}
+}
+
+// errorOffset returns the offset into text of a line, as counted by the template packages, and a byte offset into it
+func errorOffset(text string, line, char int) int {
+	offset := 0
+	for i := 0; i < line; i++ {
+		next := strings.Index(text[offset:], "\n")
+		if next == -1 {
+			return len(text)
+		}
+		offset += next + 1
+	}
+	offset += char
+	if offset > len(text) {
+		offset = len(text)
+	}
+	return offset
+}
+
+// visualPosition returns the line, as an editor shows them, and byte offset into that line of an offset into text
+func visualPosition(text string, offset int) (line, char int) {
+	before := text[:offset]
+	if strings.HasSuffix(before, "\r") && strings.HasPrefix(text[offset:], "\n") {
+		// a "\r\n" split by the offset is still a single line ending
+		before = before[:len(before)-1]
+	}
+	return len(SplitVisualLines(before)) - 1, len(before) - (strings.LastIndexAny(before, "\r\n") + 1)
+}
+
+const (
+	defaultTabWidth = 8
+	// maxTabWidth is the widest tab stops can be, wider ones are cut to it
+	maxTabWidth = 16
+)
+
+// clampTabWidth returns tabWidth between 1 and maxTabWidth, the default if it isn't set
+func clampTabWidth(tabWidth int) int {
+	if tabWidth <= 0 {
+		return defaultTabWidth
+	}
+	return min(tabWidth, maxTabWidth)
+}
+
+// convertColumns converts error characters, which are byte offsets into the line while validating, to unit, and sets
+// the visual column of each error with tabs expanded to tabWidth
+func convertColumns(tplErrs []templateError, lines []string, unit ColumnUnit, tabWidth int) {
+	tabWidth = clampTabWidth(tabWidth)
+	for i := range tplErrs {
+		tplErr := &tplErrs[i]
+		if tplErr.Level == dataErrorLevel {
+			continue
+		}
+		if tplErr.Line < 0 || tplErr.Line >= len(lines) || tplErr.Char < 0 {
+			tplErr.Column = -1
+			continue
+		}
+		tplErr.Column = visualColumn(lines[tplErr.Line], tplErr.Char, tabWidth)
+		if unit != byteColumns {
+			tplErr.Char = column(lines[tplErr.Line], tplErr.Char, unit)
+		}
+	}
+}
+
+// visualColumn returns the column a byte offset in line is displayed at, with tab stops every tabWidth columns
+func visualColumn(line string, offset int, tabWidth int) int {
+	extra := 0
+	if offset > len(line) {
+		extra = offset - len(line)
+		offset = len(line)
+	}
+	col := 0
+	for _, r := range line[:offset] {
+		if r == '\t' {
+			col = (col/tabWidth + 1) * tabWidth
+		} else {
+			col++
+		}
+	}
+	return col + extra
+}
+
+// column converts a byte offset in line to unit. Offsets past the end of the line count as one unit per byte.
+func column(line string, offset int, unit ColumnUnit) int {
+	extra := 0
+	if offset > len(line) {
+		extra = offset - len(line)
+		offset = len(line)
+	}
+	prefix := line[:offset]
+	if unit == utf16Columns {
+		return len(utf16.Encode([]rune(prefix))) + extra
+	}
+	return utf8.RuneCountInString(prefix) + extra
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestConvertColumnsMultibyte(t *testing.T) {
+	data := (&App{}).createData(context.Background(), "兵哥哥 {{.Foo[1]}}", "", "", options{})
+	if len(data.Errors) != 1 {
+		t.Fatalf("unexpected errors found: %v", data.Errors)
+	}
+	assertError(t, templateError{
+		Char:        10,
+		Line:        0,
+		Level:       parseErrorLevel,
+		Description: `bad character U+005B '['`,
+	}, data.Errors[0])
+}
+
+func TestConvertColumnsExec(t *testing.T) {
+	data := (&App{}).createData(context.Background(), "立正 {{.Name.First}}", `{"Name": null}`, "", options{})
+	if len(data.Errors) != 1 {
+		t.Fatalf("unexpected errors found: %v", data.Errors)
+	}
+	if data.Errors[0].Char != 10 {
+		t.Errorf("error Char doesn't match: expected `10`, actual `%d`", data.Errors[0].Char)
+	}
+}
+
+func TestConvertColumnsUnits(t *testing.T) {
+	// 𝒳 is outside the basic multilingual plane, so it's two UTF-16 units
+	line := "兵𝒳 {{.A[}}"
+	offset := len("兵𝒳 {{.A")
+	for unit, expected := range map[ColumnUnit]int{
+		runeColumns:  7,
+		utf16Columns: 8,
+		byteColumns:  offset,
+	} {
+		tplErrs := []templateError{{Line: 0, Char: offset}}
+		convertColumns(tplErrs, []string{line}, unit, defaultTabWidth)
+		if tplErrs[0].Char != expected {
+			t.Errorf("%s column doesn't match: expected `%d`, actual `%d`", unit, expected, tplErrs[0].Char)
+		}
+	}
+}
+
+func TestConvertColumnsSkipsUnlocated(t *testing.T) {
+	tplErrs := []templateError{{Line: -1, Char: -1}, {Line: 0, Char: -1}, {Line: 3, Char: 2}}
+	convertColumns(tplErrs, []string{"兵哥哥"}, runeColumns, defaultTabWidth)
+	if tplErrs[0].Char != -1 || tplErrs[1].Char != -1 || tplErrs[2].Char != 2 {
+		t.Errorf("unlocated errors were changed: %v", tplErrs)
+	}
+}
+
+func TestLineEndings(t *testing.T) {
+	for name, text := range map[string]string{
+		"lf":    "a\n\n{{.Foo[1]}}",
+		"crlf":  "a\r\n\r\n{{.Foo[1]}}",
+		"cr":    "a\r\r{{.Foo[1]}}",
+		"mixed": "a\r\n\r{{.Foo[1]}}",
+	} {
+		data := (&App{}).createData(context.Background(), text, "", "", options{})
+		if len(data.TextLines) != 3 {
+			t.Errorf("%s: unexpected lines: %q", name, data.TextLines)
+		}
+		if len(data.Errors) != 1 {
+			t.Fatalf("%s: unexpected errors found: %v", name, data.Errors)
+		}
+		assertError(t, templateError{
+			Char:        6,
+			Line:        2,
+			Level:       parseErrorLevel,
+			Description: `bad character U+005B '['`,
+		}, data.Errors[0])
+	}
+}
+
+func TestLineEndingsExec(t *testing.T) {
+	data := (&App{}).createData(context.Background(), "a\rb {{.A.B}}\r\n{{.A.B}}", `{"A": 1}`, "", options{AllExecErrors: true})
+	if len(data.Errors) != 2 {
+		t.Fatalf("unexpected errors found: %v", data.Errors)
+	}
+	if data.Errors[0].Line != 1 || data.Errors[0].Char != 6 {
+		t.Errorf("unexpected location: %v", data.Errors[0])
+	}
+	if data.Errors[1].Line != 2 || data.Errors[1].Char != 4 {
+		t.Errorf("unexpected location: %v", data.Errors[1])
+	}
+}
+
+func TestLineEndingsUnlocatedChar(t *testing.T) {
+	tplErrs := []templateError{{Line: 1, Char: -1}}
+	toVisualLocations(tplErrs, "a\rb\r\nc")
+	if tplErrs[0].Line != 2 || tplErrs[0].Char != -1 {
+		t.Errorf("unexpected location: %v", tplErrs[0])
+	}
+}
+
+func TestColumnTabs(t *testing.T) {
+	for tabWidth, expected := range map[int]int{
+		2: 10,
+		4: 14,
+		8: 22,
+		// wider tab stops are cut to maxTabWidth
+		1099511627776: 38,
+	} {
+		data := (&App{}).createData(context.Background(), "\tx\t{{.Foo[1]}}", "", "", options{TabWidth: tabWidth})
+		if len(data.Errors) != 1 {
+			t.Fatalf("unexpected errors found: %v", data.Errors)
+		}
+		if data.Errors[0].Char != 9 {
+			t.Errorf("error Char doesn't match: expected `9`, actual `%d`", data.Errors[0].Char)
+		}
+		if data.Errors[0].Column != expected {
+			t.Errorf("tab width %d: error Column doesn't match: expected `%d`, actual `%d`", tabWidth, expected, data.Errors[0].Column)
+		}
+	}
+}
+
+func TestGetOptionsTabWidth(t *testing.T) {
+	for value, expected := range map[string]int{"": 8, "x": 8, "-3": 8, "4": 4, "1099511627776": 16} {
+		r := httptest.NewRequest("POST", "/", strings.NewReader("tab-width="+value))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		if actual := getOptions(r).TabWidth; actual != expected {
+			t.Errorf("%q: expected tab width %d, actual %d", value, expected, actual)
+		}
+	}
+}
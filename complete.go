@@ -0,0 +1,310 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+var (
+	completionTokenRegex = regexp.MustCompile(`[$.\w]*$`)
+	declarationRegex     = regexp.MustCompile(`(\$\w+)\s*(?:,\s*(\$\w+)\s*)?:=`)
+	blockStartRegex      = regexp.MustCompile(`^{{-?\s*(if|range|with|define|block|else with|else if|else)\b`)
+	blockEndRegex        = regexp.MustCompile(`^{{-?\s*end\b`)
+	// dotPipelineRegex matches the rest of a with or range action rebinding dot to a plain field chain
+	dotPipelineRegex = regexp.MustCompile(`^\s+(?:\$\w+\s*(?:,\s*\$\w+\s*)?:=\s*)?(\$?(?:\.\w+)+|\.|\$)\s*-?}}$`)
+)
+
+// completion is a candidate for the text being typed at the cursor
+type completion struct {
+	Label string `json:"label"`
+	// Kind is field, variable or function
+	Kind   string `json:"kind"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// completions are the candidates replacing Prefix, the text before the cursor they're completing
+type completions struct {
+	Prefix     string       `json:"prefix"`
+	Candidates []completion `json:"candidates"`
+}
+
+// scope is a block open at the cursor
+type scope struct {
+	variables []string
+	// dot is the field chain dot is rebound to, relative to the outer dot or to $ if it starts with it
+	dot string
+	// each is set when dot is an element of dot's value
+	each  bool
+	known bool
+}
+
+// complete returns the candidates for what's being typed at offset in text. It works lexically so incomplete templates
+// can be completed.
+func complete(text string, offset int, data interface{}, functions []string) completions {
+	before := text[:offset]
+	open := strings.LastIndex(before, "{{")
+	if open == -1 || strings.LastIndex(before, "}}") > open {
+		return completions{Candidates: []completion{}}
+	}
+	token := completionTokenRegex.FindString(before[open:])
+
+	// track the blocks open at the cursor, with the variables declared in them
+	scopes := []scope{{dot: ".", known: true}}
+	for _, a := range findActions(text[:open]) {
+		action := text[a.start:a.end]
+		if blockEndRegex.MatchString(action) {
+			if len(scopes) > 1 {
+				scopes = scopes[:len(scopes)-1]
+			}
+			continue
+		}
+		if loc := blockStartRegex.FindStringSubmatchIndex(action); loc != nil {
+			keyword := action[loc[2]:loc[3]]
+			s := scope{dot: ".", known: true}
+			switch keyword {
+			case "with", "range", "else with":
+				matches := dotPipelineRegex.FindStringSubmatch(action[loc[1]:])
+				if matches != nil {
+					s.dot, s.each = matches[1], keyword == "range"
+				} else {
+					s.known = false
+				}
+			case "define", "block":
+				// dot is whatever the template is invoked with
+				s.known = false
+			}
+			if strings.HasPrefix(keyword, "else") {
+				// else branches are evaluated with the dot outside the block, but keep its variables
+				if len(scopes) > 1 {
+					s.variables = scopes[len(scopes)-1].variables
+					scopes = scopes[:len(scopes)-1]
+				}
+			}
+			scopes = append(scopes, s)
+		}
+		top := &scopes[len(scopes)-1]
+		for _, matches := range declarationRegex.FindAllStringSubmatch(action, -1) {
+			for _, name := range matches[1:] {
+				if name != "" {
+					top.variables = append(top.variables, name)
+				}
+			}
+		}
+	}
+	// variables declared earlier in the action being typed are in scope too
+	for _, matches := range declarationRegex.FindAllStringSubmatch(before[open:len(before)-len(token)], -1) {
+		scopes[len(scopes)-1].variables = append(scopes[len(scopes)-1].variables, matches[1])
+	}
+
+	candidates := []completion{}
+	split := strings.LastIndex(token, ".")
+	switch {
+	case split >= 0:
+		// a field of dot, $ or the field chain before the last dot
+		path, prefix := token[:split], token[split+1:]
+		var value interface{}
+		var ok bool
+		switch {
+		case path == "":
+			value, ok = dotValue(scopes, data)
+		case path == "$" || strings.HasPrefix(path, "$."):
+			value, ok = lookupPath(data, fieldPath(strings.TrimPrefix(path, "$")))
+		case strings.HasPrefix(path, "."):
+			if value, ok = dotValue(scopes, data); ok {
+				value, ok = lookupPath(value, fieldPath(path))
+			}
+		}
+		if m, isMap := value.(map[string]interface{}); ok && isMap {
+			for _, key := range sortedKeys(m) {
+				if strings.HasPrefix(key, prefix) {
+					candidates = append(candidates, completion{Label: key, Kind: "field", Detail: completionDetail(m[key])})
+				}
+			}
+		}
+		return completions{Prefix: prefix, Candidates: candidates}
+	case strings.HasPrefix(token, "$"):
+		seen := make(map[string]bool)
+		for i := len(scopes) - 1; i >= 0; i-- {
+			for _, name := range scopes[i].variables {
+				if strings.HasPrefix(name, token) && !seen[name] {
+					seen[name] = true
+					candidates = append(candidates, completion{Label: name, Kind: "variable"})
+				}
+			}
+		}
+		sort.Slice(candidates, func(i, j int) bool { return candidates[i].Label < candidates[j].Label })
+	default:
+		for _, doc := range documentedFunctions() {
+			if strings.HasPrefix(doc.Name, token) {
+				candidates = append(candidates, completion{Label: doc.Name, Kind: "function", Detail: doc.Signature})
+			}
+		}
+		for _, fn := range functions {
+			if fn != "" && strings.HasPrefix(fn, token) {
+				candidates = append(candidates, completion{Label: fn, Kind: "function"})
+			}
+		}
+		sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].Label < candidates[j].Label })
+	}
+	return completions{Prefix: token, Candidates: candidates}
+}
+
+// fieldPath splits a field chain like .A.B into its keys
+func fieldPath(chain string) []string {
+	chain = strings.TrimPrefix(chain, ".")
+	if chain == "" {
+		return nil
+	}
+	return strings.Split(chain, ".")
+}
+
+// dotValue follows the with and range blocks open at the cursor through data to find the value of dot. Ranges take the
+// value of their first element.
+func dotValue(scopes []scope, data interface{}) (interface{}, bool) {
+	value := data
+	for _, s := range scopes {
+		if !s.known {
+			return nil, false
+		}
+		var ok bool
+		if strings.HasPrefix(s.dot, "$") {
+			value, ok = lookupPath(data, fieldPath(strings.TrimPrefix(s.dot, "$")))
+		} else {
+			value, ok = lookupPath(value, fieldPath(s.dot))
+		}
+		if !ok {
+			return nil, false
+		}
+		if s.each {
+			switch v := value.(type) {
+			case []interface{}:
+				if len(v) == 0 {
+					return nil, false
+				}
+				value = v[0]
+			case map[string]interface{}:
+				keys := sortedKeys(v)
+				if len(keys) == 0 {
+					return nil, false
+				}
+				value = v[keys[0]]
+			default:
+				return nil, false
+			}
+		}
+	}
+	return value, true
+}
+
+func completionDetail(value interface{}) string {
+	switch value.(type) {
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	case string:
+		return "string"
+	case float64:
+		return "number"
+	case bool:
+		return "boolean"
+	}
+	return "null"
+}
+
+// lineOffset returns the byte offset of a character counted in unit on a zero indexed line of text, with lines as an
+// editor shows them like errors are located
+func lineOffset(text string, line, char int, unit ColumnUnit) int {
+	return offsetInLine(text, visualLineStarts(text), line, char, unit)
+}
+
+// visualLineStarts returns the offsets into text of the lines an editor shows, which end at "\n", "\r\n" or a lone "\r"
+func visualLineStarts(text string) []int {
+	starts := []int{0}
+	for i := 0; i < len(text); i++ {
+		switch text[i] {
+		case '\r':
+			if i+1 < len(text) && text[i+1] == '\n' {
+				i++
+			}
+			starts = append(starts, i+1)
+		case '\n':
+			starts = append(starts, i+1)
+		}
+	}
+	return starts
+}
+
+// offsetInLine is lineOffset with the starts of the lines of text
+func offsetInLine(text string, starts []int, line, char int, unit ColumnUnit) int {
+	if line < 0 || line >= len(starts) {
+		return len(text)
+	}
+	start, end := starts[line], len(text)
+	if line+1 < len(starts) {
+		end = starts[line+1]
+	}
+	l := strings.TrimSuffix(strings.TrimSuffix(text[start:end], "\n"), "\r")
+	offset := 0
+	for i := 0; offset < len(l) && i < char; {
+		r, size := utf8.DecodeRuneInString(l[offset:])
+		switch unit {
+		case byteColumns:
+			i += size
+		case utf16Columns:
+			i += len(utf16.Encode([]rune{r}))
+		default:
+			i++
+		}
+		offset += size
+	}
+	return start + offset
+}
+
+// Complete serves completions as JSON for the template, cursor line and char, data and functions form values
+func (a *App) Complete(w http.ResponseWriter, r *http.Request) {
+	text, err := getFormValue(r, "template")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	line, err := strconv.Atoi(r.FormValue("line"))
+	if err != nil {
+		http.Error(w, "line must be a number", http.StatusBadRequest)
+		return
+	}
+	char, err := strconv.Atoi(r.FormValue("char"))
+	if err != nil {
+		http.Error(w, "char must be a number", http.StatusBadRequest)
+		return
+	}
+	rawData, err := getFormValue(r, "data")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var data interface{}
+	if rawData != "" {
+		if data, err = decodeDataFormat(rawData, r.FormValue("data-format")); err != nil {
+			http.Error(w, dataTemplateError(rawData, err).Description, http.StatusBadRequest)
+			return
+		}
+	}
+	var functions []string
+	for _, fn := range strings.Split(r.FormValue("functions"), ",") {
+		functions = append(functions, strings.TrimSpace(fn))
+	}
+
+	offset := lineOffset(text, line, char, parseColumnUnit(r.FormValue("columns")))
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(complete(text, offset, data, functions)); err != nil {
+		http.Error(w, fmt.Sprintf("Encode error: %v", err), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func completionLabels(c completions) []string {
+	labels := make([]string, len(c.Candidates))
+	for i, candidate := range c.Candidates {
+		labels[i] = candidate.Label
+	}
+	return labels
+}
+
+func TestComplete(t *testing.T) {
+	var data interface{}
+	if err := json.Unmarshal([]byte(`{"User": {"Name": "a", "Nick": "b", "Age": 3}, "Items": [{"Title": "x"}], "Note": null}`), &data); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		text     string
+		prefix   string
+		expected []string
+	}{
+		{"{{.", "", []string{"Items", "Note", "User"}},
+		{"{{.User.N", "N", []string{"Name", "Nick"}},
+		{"{{with .User}}{{.", "", []string{"Age", "Name", "Nick"}},
+		{"{{with .User}}{{else}}{{.I", "I", []string{"Items"}},
+		{"{{range .Items}}{{.", "", []string{"Title"}},
+		{"{{range .Items}}{{$.U", "U", []string{"User"}},
+		{"{{range .Items}}{{end}}{{.U", "U", []string{"User"}},
+		{"{{range $i, $item := .Items}}{{$a := 1}}{{$", "$", []string{"$a", "$i", "$item"}},
+		{"{{range $i, $item := .Items}}{{end}}{{$", "$", []string{}},
+		{"{{$x := .User}}{{pr", "pr", []string{"print", "printf", "println"}},
+		{"{{up", "up", []string{"upper"}},
+		{"{{.User}} .", "", []string{}},
+		{"{{with $.Missing}}{{.", "", []string{}},
+	}
+	for _, test := range tests {
+		c := complete(test.text, len(test.text), data, []string{"upper"})
+		if c.Prefix != test.prefix {
+			t.Errorf("%s: expected prefix `%s`, actual `%s`", test.text, test.prefix, c.Prefix)
+		}
+		if actual := completionLabels(c); strings.Join(actual, ",") != strings.Join(test.expected, ",") {
+			t.Errorf("%s: expected %v, actual %v", test.text, test.expected, actual)
+		}
+	}
+}
+
+func TestCompleteEndpoint(t *testing.T) {
+	form := url.Values{
+		"template": {"a\n{{.Us}} b"},
+		"line":     {"1"},
+		"char":     {"5"},
+		"data":     {`{"User": {"Name": "a"}}`},
+	}
+	r := httptest.NewRequest("POST", "/api/v1/complete", strings.NewReader(form.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+	(&App{}).Complete(w, r)
+
+	var c completions
+	if err := json.Unmarshal(w.Body.Bytes(), &c); err != nil {
+		t.Fatalf("%v: %s", err, w.Body.String())
+	}
+	if c.Prefix != "Us" || len(c.Candidates) != 1 || c.Candidates[0] != (completion{Label: "User", Kind: "field", Detail: "object"}) {
+		t.Errorf("unexpected completions: %+v", c)
+	}
+}
+
+func TestLineOffset(t *testing.T) {
+	text := "a\rbc\r\nd\ne¿f"
+	for _, c := range []struct{ line, char, expected int }{
+		{0, 0, 0}, {0, 5, 1}, {1, 1, 3}, {2, 0, 6}, {3, 2, 11}, {4, 0, len(text)},
+	} {
+		if actual := lineOffset(text, c.line, c.char, runeColumns); actual != c.expected {
+			t.Errorf("%d:%d: expected offset %d, actual %d", c.line, c.char, c.expected, actual)
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"cuelang.org/go/cue"
+	"cuelang.org/go/cue/cuecontext"
+	cueErrors "cuelang.org/go/cue/errors"
+)
+
+// decodeCUE evaluates the CUE raw, which must be within maxDataSize, into the data it's exported as. Its constraints
+// are checked, and every value must be concrete, like cue export requires.
+func decodeCUE(raw string) (interface{}, error) {
+	if len(raw) > maxDataSize {
+		return nil, fmt.Errorf("data is bigger than %d bytes", maxDataSize)
+	}
+	v := cuecontext.New().CompileString(raw, cue.Filename(dataFile))
+	if err := v.Validate(cue.Concrete(true)); err != nil {
+		return nil, cueDataErrors(err)
+	}
+	b, err := v.MarshalJSON()
+	if err != nil {
+		return nil, cueDataErrors(err)
+	}
+	return decodeData(string(b))
+}
+
+// cueDataErrors returns the errors of evaluating CUE data in err, at their positions in it
+func cueDataErrors(err error) error {
+	var list dataErrors
+	for _, e := range cueErrors.Errors(err) {
+		format, args := e.Msg()
+		message := fmt.Sprintf(format, args...)
+		if path := e.Path(); len(path) > 0 {
+			message = strings.Join(path, ".") + ": " + message
+		}
+		// the first input is the value which is wrong, the position can be the constraint it breaks
+		offset := -1
+		positions := append(e.InputPositions(), e.Position())
+		for _, pos := range positions {
+			if pos.IsValid() {
+				offset = pos.Offset()
+				break
+			}
+		}
+		list = append(list, &dataError{offset: offset, err: fmt.Errorf("%s", message)})
+	}
+	if len(list) == 0 {
+		return err
+	}
+	return list
+}
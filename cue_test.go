@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestDecodeCUE(t *testing.T) {
+	data, err := decodeCUE(`
+#User: {name: string, age: int & >=0}
+user: #User & {name: "Bob", age: 3}
+tags: ["a", "b"]
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]interface{}{
+		"user": map[string]interface{}{"name": "Bob", "age": 3.0},
+		"tags": []interface{}{"a", "b"},
+	}
+	if !reflect.DeepEqual(data, expected) {
+		t.Errorf("expected %v, got %v", expected, data)
+	}
+
+	tests := []struct {
+		raw         string
+		line, char  int
+		description string
+	}{
+		{"#User: {name: string, age: int & >=0}\nuser: #User & {name: \"Bob\", age: -1}\n", 1, 33,
+			"failed to understand data: user.age: invalid value -1 (out of bound >=0)"},
+		{"name: \"Bob\"\nother: string\n", 1, 7, "failed to understand data: other: incomplete value string"},
+		{"a: {\nb: 1\n", 2, 0, "failed to understand data: expected '}', found 'EOF'"},
+	}
+	for _, test := range tests {
+		_, err := decodeCUE(test.raw)
+		tplErrs := dataTemplateErrors(test.raw, err)
+		if len(tplErrs) != 1 {
+			t.Errorf("expected one error, got %+v", tplErrs)
+			continue
+		}
+		assertError(t, templateError{File: dataFile, Line: test.line, Char: test.char, Column: -1,
+			Description: test.description, Level: dataErrorLevel}, tplErrs[0])
+	}
+}
+
+func TestCreateDataCUE(t *testing.T) {
+	data := (&App{}).createData(context.Background(), "{{.user.name}}", "user: name: \"Bob\"\n", "",
+		options{DataFormat: cueDataFormat})
+	if len(data.Errors) != 0 || data.Output != "Bob" {
+		t.Errorf("unexpected errors %+v, output %q", data.Errors, data.Output)
+	}
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+// dataErrorLevel is the level of errors in the data, which are located in it rather than in the template
+const dataErrorLevel ErrorLevel = "data"
+
+// dataFile is the file errors in the data are in
+const dataFile = "data"
+
+// maxDataSize is the most bytes of JSON data a validation decodes
+const maxDataSize = 16 << 20
+
+// the formats data can be in
+const (
+	jsonDataFormat       = "json"
+	cueDataFormat        = "cue"
+	hclDataFormat        = "hcl"
+	iniDataFormat        = "ini"
+	propertiesDataFormat = "properties"
+	urlEncodedDataFormat = "urlencoded"
+)
+
+// decodeDataFormat decodes raw, which is in format, JSON if it's empty
+func decodeDataFormat(raw, format string) (interface{}, error) {
+	switch format {
+	case "", jsonDataFormat:
+		return decodeData(raw)
+	case cueDataFormat:
+		return decodeCUE(raw)
+	case hclDataFormat:
+		return decodeHCL(raw)
+	case iniDataFormat:
+		return decodeINI(raw)
+	case propertiesDataFormat:
+		return decodeProperties(raw)
+	case urlEncodedDataFormat:
+		return decodeURLEncoded(raw)
+	}
+	return nil, fmt.Errorf("unknown data format %q", format)
+}
+
+// dataFormatOf returns the format of the data file at path by its extension, empty if it's not one of another format
+func dataFormatOf(path string) string {
+	switch filepath.Ext(path) {
+	case ".cue":
+		return cueDataFormat
+	case ".hcl", ".tfvars", ".tf", ".nomad":
+		return hclDataFormat
+	case ".ini", ".cfg", ".conf":
+		return iniDataFormat
+	case ".properties":
+		return propertiesDataFormat
+	}
+	return ""
+}
+
+// dataError is a mistake in data, at a byte offset into it, -1 if it's not known
+type dataError struct {
+	offset int
+	err    error
+}
+
+func (e *dataError) Error() string {
+	return e.err.Error()
+}
+
+// dataErrors are several mistakes in data, found together
+type dataErrors []*dataError
+
+func (e dataErrors) Error() string {
+	messages := make([]string, len(e))
+	for i, err := range e {
+		messages[i] = err.Error()
+	}
+	return strings.Join(messages, "; ")
+}
+
+// decodeData decodes the JSON value raw, which must be all of raw and within maxDataSize, as it's read. Comments and
+// trailing commas are allowed.
+func decodeData(raw string) (interface{}, error) {
+	if len(raw) > maxDataSize {
+		return nil, fmt.Errorf("data is bigger than %d bytes", maxDataSize)
+	}
+	standard := standardJSON(raw)
+	dec := json.NewDecoder(strings.NewReader(standard))
+	var data interface{}
+	if err := dec.Decode(&data); err != nil {
+		var syntaxErr *json.SyntaxError
+		switch {
+		case errors.As(err, &syntaxErr):
+			// the offset is after the byte which is wrong
+			return nil, &dataError{offset: max(int(syntaxErr.Offset)-1, 0), err: err}
+		case err == io.EOF || err == io.ErrUnexpectedEOF:
+			return nil, &dataError{offset: len(raw), err: errors.New("unexpected end of JSON input")}
+		}
+		return nil, err
+	}
+	if rest := strings.TrimLeft(standard[dec.InputOffset():], " \t\r\n"); rest != "" {
+		return nil, &dataError{offset: len(raw) - len(rest), err: errors.New("invalid character after top-level value")}
+	}
+	return data, nil
+}
+
+// standardJSON returns raw, which can be JSONC or hand written JSON5 with comments and trailing commas, as standard
+// JSON by turning those into spaces. Line endings are kept so the offsets of the rest are still those of raw.
+func standardJSON(raw string) string {
+	b := []byte(raw)
+	blank := func(start, end int) {
+		for i := start; i < end; i++ {
+			if b[i] != '\n' && b[i] != '\r' {
+				b[i] = ' '
+			}
+		}
+	}
+	// lastComma is the offset of a comma after a value which nothing but spaces and comments follow yet, -1 if there's
+	// none, and value is whether there's a value since the last comma or opening bracket
+	lastComma, value := -1, false
+	for i := 0; i < len(b); i++ {
+		switch c := b[i]; {
+		case c == '"':
+			lastComma, value = -1, true
+			for i++; i < len(b) && b[i] != '"'; i++ {
+				if b[i] == '\\' {
+					i++
+				}
+			}
+		case c == '/' && i+1 < len(b) && b[i+1] == '/':
+			end := lineEnd(raw, i)
+			blank(i, end)
+			i = end - 1
+		case c == '/' && i+1 < len(b) && b[i+1] == '*':
+			// an unclosed comment is left for decoding to report
+			end := strings.Index(raw[i+2:], "*/")
+			if end == -1 {
+				return string(b)
+			}
+			blank(i, i+2+end+2)
+			i += 2 + end + 1
+		case c == ',':
+			lastComma = -1
+			if value {
+				lastComma = i
+			}
+			value = false
+		case c == '{' || c == '[':
+			lastComma, value = -1, false
+		case c == '}' || c == ']':
+			if lastComma != -1 {
+				blank(lastComma, lastComma+1)
+			}
+			lastComma, value = -1, true
+		case c != ' ' && c != '\t' && c != '\r' && c != '\n':
+			lastComma, value = -1, true
+		}
+	}
+	return string(b)
+}
+
+// dataTemplateError describes the error decoding the data raw, located in raw if it can be
+func dataTemplateError(raw string, err error) templateError {
+	tplErr := templateError{File: dataFile, Line: -1, Char: -1, Column: -1, Level: dataErrorLevel,
+		Description: fmt.Sprintf("failed to understand data: %v", err)}
+	var dataErr *dataError
+	if errors.As(err, &dataErr) && dataErr.offset >= 0 {
+		tplErr.Line, tplErr.Char = visualPosition(raw, dataErr.offset)
+	}
+	return tplErr
+}
+
+// dataTemplateErrors describes each of the errors decoding the data raw
+func dataTemplateErrors(raw string, err error) []templateError {
+	var list dataErrors
+	if !errors.As(err, &list) {
+		return []templateError{dataTemplateError(raw, err)}
+	}
+	tplErrs := make([]templateError, len(list))
+	for i, dataErr := range list {
+		tplErrs[i] = dataTemplateError(raw, dataErr)
+	}
+	return tplErrs
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestDecodeData(t *testing.T) {
+	data, err := decodeData(`{"Name": "Bob"} `)
+	if err != nil || data.(map[string]interface{})["Name"] != "Bob" {
+		t.Errorf("unexpected data %v, error %v", data, err)
+	}
+
+	data, err = decodeData("{\n  // the user\n  \"Name\": \"Bob // not a comment\", /* the age */ \"Tags\": [1, 2,],\n}\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if object := data.(map[string]interface{}); object["Name"] != "Bob // not a comment" ||
+		len(object["Tags"].([]interface{})) != 2 {
+		t.Errorf("unexpected data %v", data)
+	}
+
+	tests := []struct {
+		raw         string
+		line, char  int
+		description string
+	}{
+		{"{\n  \"Name\": \"Bob\",\n  \"Age\" 3\n}", 2, 8, `failed to understand data: invalid character '3' after object key`},
+		{`{"Name": `, 0, 9, "failed to understand data: unexpected end of JSON input"},
+		{"", 0, 0, "failed to understand data: unexpected end of JSON input"},
+		{"{}\n{}", 1, 0, "failed to understand data: invalid character after top-level value"},
+		{"// the user\n{\"Name\": \"Bob\" /* unclosed", 1, 15, `failed to understand data: invalid character '/' after object key:value pair`},
+		{"[1,,]", 0, 3, `failed to understand data: invalid character ',' looking for beginning of value`},
+		{"[,]", 0, 1, `failed to understand data: invalid character ',' looking for beginning of value`},
+	}
+	for _, test := range tests {
+		_, err := decodeData(test.raw)
+		if err == nil {
+			t.Errorf("expected an error decoding %q", test.raw)
+			continue
+		}
+		assertError(t, templateError{File: dataFile, Line: test.line, Char: test.char, Column: -1,
+			Description: test.description, Level: dataErrorLevel}, dataTemplateError(test.raw, err))
+	}
+
+	_, err = decodeData(`"` + strings.Repeat("x", maxDataSize) + `"`)
+	assertError(t, templateError{File: dataFile, Line: -1, Char: -1, Column: -1, Level: dataErrorLevel,
+		Description: "failed to understand data: data is bigger than 16777216 bytes"},
+		dataTemplateError("", err))
+}
+
+func TestCreateDataDataError(t *testing.T) {
+	data := (&App{}).createData(context.Background(), "{{.Name}}", `{"Name": }`, "", options{})
+	if len(data.Errors) != 1 {
+		t.Fatalf("expected one error, got %+v", data.Errors)
+	}
+	if tplErr := data.Errors[0]; tplErr.File != dataFile || tplErr.Level != dataErrorLevel || tplErr.Line != 0 ||
+		tplErr.Char != 9 {
+		t.Errorf("unexpected error %+v", tplErr)
+	}
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"text/template"
+	templateParse "text/template/parse"
+)
+
+var dataPathErrorRegex = regexp.MustCompile(`(nil pointer evaluating|can't evaluate field|map has no entry for key)`)
+
+// explainDataPath cross references an exec error evaluating a field chain with the JSON data it was evaluated against,
+// describing where the path stops matching the data. It returns an empty string if it can't tell.
+func explainDataPath(t *template.Template, tplErr templateError, data interface{}) string {
+	if !dataPathErrorRegex.MatchString(tplErr.Description) {
+		return ""
+	}
+	tree, chain := findNode(t, tplErr.Line, tplErr.Char)
+	if chain == nil {
+		return ""
+	}
+	// the dot of a template invoked with {{template}} isn't known
+	if tree.Name != t.Name() {
+		return ""
+	}
+
+	var prefix string
+	var path []string
+	dots := []interface{}{data}
+	switch n := chain[len(chain)-1].(type) {
+	case *templateParse.FieldNode:
+		prefix, path = ".", n.Ident
+		var ok bool
+		if dots, ok = resolveDot(chain, data); !ok {
+			return ""
+		}
+	case *templateParse.VariableNode:
+		if n.Ident[0] != "$" {
+			return ""
+		}
+		prefix, path = "$.", n.Ident[1:]
+	default:
+		return ""
+	}
+
+	for _, dot := range dots {
+		if explanation := explainPath(dot, prefix, path); explanation != "" {
+			return explanation
+		}
+	}
+	return ""
+}
+
+// resolveDot returns the values dot can have at the innermost node of chain, following the with and range blocks
+// enclosing it. Only blocks whose pipeline is a plain field chain can be followed.
+func resolveDot(chain []templateParse.Node, data interface{}) ([]interface{}, bool) {
+	dots := []interface{}{data}
+	for i := 0; i < len(chain)-1; i++ {
+		var branch *templateParse.BranchNode
+		isRange := false
+		switch n := chain[i].(type) {
+		case *templateParse.WithNode:
+			branch = &n.BranchNode
+		case *templateParse.RangeNode:
+			branch = &n.BranchNode
+			isRange = true
+		default:
+			continue
+		}
+		// only the main list rebinds dot
+		if chain[i+1] != branch.List {
+			continue
+		}
+
+		if len(branch.Pipe.Cmds) != 1 || len(branch.Pipe.Cmds[0].Args) != 1 {
+			return nil, false
+		}
+		var path []string
+		var from []interface{}
+		switch arg := branch.Pipe.Cmds[0].Args[0].(type) {
+		case *templateParse.DotNode:
+			from = dots
+		case *templateParse.FieldNode:
+			from, path = dots, arg.Ident
+		case *templateParse.VariableNode:
+			if arg.Ident[0] != "$" {
+				return nil, false
+			}
+			from, path = []interface{}{data}, arg.Ident[1:]
+		default:
+			return nil, false
+		}
+
+		var next []interface{}
+		for _, dot := range from {
+			value, ok := lookupPath(dot, path)
+			if !ok {
+				continue
+			}
+			if !isRange {
+				next = append(next, value)
+				continue
+			}
+			switch value := value.(type) {
+			case []interface{}:
+				next = append(next, value...)
+			case map[string]interface{}:
+				for _, key := range sortedKeys(value) {
+					next = append(next, value[key])
+				}
+			}
+		}
+		dots = next
+	}
+	return dots, true
+}
+
+// selectDot returns the value at path in data, a field path like .Page.Header or . for all of it
+func selectDot(data interface{}, path string) (interface{}, error) {
+	if path == "." {
+		return data, nil
+	}
+	if !strings.HasPrefix(path, ".") || strings.HasSuffix(path, ".") {
+		return nil, fmt.Errorf("%q isn't a field path like .Page", path)
+	}
+	keys := strings.Split(path[1:], ".")
+	value, ok := lookupPath(data, keys)
+	if !ok {
+		if explanation := explainPath(data, "$.", keys); explanation != "" {
+			return nil, fmt.Errorf("%s: %s", path, explanation)
+		}
+		return nil, fmt.Errorf("%s isn't in the data", path)
+	}
+	return value, nil
+}
+
+func lookupPath(value interface{}, path []string) (interface{}, bool) {
+	for _, key := range path {
+		m, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if value, ok = m[key]; !ok {
+			return nil, false
+		}
+	}
+	return value, true
+}
+
+// explainPath walks path through value, describing the first key that doesn't exist or can't be evaluated
+func explainPath(value interface{}, prefix string, path []string) string {
+	for i, key := range path {
+		walked := "`" + prefix + strings.Join(path[:i], ".") + "`"
+		if i == 0 {
+			walked = "the data"
+			if prefix == "." {
+				walked = "dot"
+			}
+		}
+		switch v := value.(type) {
+		case map[string]interface{}:
+			next, ok := v[key]
+			if !ok {
+				explanation := fmt.Sprintf("%s exists but has no key `%s`", walked, key)
+				if i == 0 {
+					explanation = fmt.Sprintf("%s has no key `%s`", walked, key)
+				}
+				if suggestion := closestKey(key, sortedKeys(v)); suggestion != "" {
+					explanation += fmt.Sprintf("; did you mean `%s`?", suggestion)
+				}
+				return explanation
+			}
+			value = next
+		case nil:
+			return fmt.Sprintf("%s is null, so it has no key `%s`", walked, key)
+		case []interface{}, string, float64, bool:
+			return fmt.Sprintf("%s is %s, not an object with key `%s`", walked, jsonTypeName(v), key)
+		default:
+			// not JSON data
+			return ""
+		}
+	}
+	return ""
+}
+
+func jsonTypeName(value interface{}) string {
+	switch value.(type) {
+	case []interface{}:
+		return "an array"
+	case string:
+		return "a string"
+	case float64:
+		return "a number"
+	}
+	return "a boolean"
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// closestKey returns the candidate most likely meant instead of key, or an empty string if none are close enough
+func closestKey(key string, candidates []string) string {
+	best := ""
+	// allow roughly one typo per three characters
+	bestDistance := len(key)/3 + 2
+	for _, candidate := range candidates {
+		if strings.EqualFold(candidate, key) {
+			return candidate
+		}
+		if distance := Levenshtein(key, candidate); distance < bestDistance {
+			best, bestDistance = candidate, distance
+		}
+	}
+	return best
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+	textTemplate "text/template"
+)
+
+func execJSON(t *testing.T, text, rawData string) []templateError {
+	var data interface{}
+	if err := json.Unmarshal([]byte(rawData), &data); err != nil {
+		t.Fatal(err)
+	}
+	tpl, err := textTemplate.New("base").Option("missingkey=error").Parse(text)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	return exec(tpl, data, &buf)
+}
+
+func TestExplainMissingKey(t *testing.T) {
+	errs := execJSON(t, "{{.User.Nmae}}", `{"User": {"Name": "兵哥哥"}}`)
+	if len(errs) != 1 {
+		t.Fatalf("unexpected errs: %v", errs)
+	}
+	assertError(t, templateError{
+		Line:        0,
+		Char:        7,
+		Level:       execErrorLevel,
+		Description: "executing \"base\" at <.User.Nmae>: map has no entry for key \"Nmae\": `.User` exists but has no key `Nmae`; did you mean `Name`?",
+	}, errs[0])
+}
+
+func TestExplainNull(t *testing.T) {
+	errs := execJSON(t, "{{$.User.Name}}", `{"User": null}`)
+	if len(errs) != 1 {
+		t.Fatalf("unexpected errs: %v", errs)
+	}
+	assertError(t, templateError{
+		Line:        0,
+		Char:        3,
+		Level:       execErrorLevel,
+		Description: "executing \"base\" at <$.User.Name>: nil pointer evaluating interface {}.Name: `$.User` is null, so it has no key `Name`",
+	}, errs[0])
+}
+
+func TestExplainInsideRange(t *testing.T) {
+	errs := execJSON(t, "{{range .Users}}{{.Tags.First}}{{end}}", `{"Users": [{"Tags": {"First": 1}}, {"Tags": ["a"]}]}`)
+	if len(errs) != 1 {
+		t.Fatalf("unexpected errs: %v", errs)
+	}
+	assertError(t, templateError{
+		Line:        0,
+		Char:        23,
+		Level:       execErrorLevel,
+		Description: "executing \"base\" at <.Tags.First>: can't evaluate field First in type interface {}: `.Tags` is an array, not an object with key `First`",
+	}, errs[0])
+}
+
+func TestSelectDot(t *testing.T) {
+	data := map[string]interface{}{"Page": map[string]interface{}{"Title": "hi"}}
+	dot, err := selectDot(data, ".Page.Title")
+	if err != nil || dot != "hi" {
+		t.Errorf("unexpected dot %v, %v", dot, err)
+	}
+	if dot, err := selectDot(data, "."); err != nil || !reflect.DeepEqual(dot, data) {
+		t.Errorf("unexpected dot %v, %v", dot, err)
+	}
+	if _, err := selectDot(data, ".Pages"); err == nil || err.Error() != ".Pages: the data has no key `Pages`; did you mean `Page`?" {
+		t.Errorf("unexpected error %v", err)
+	}
+	if _, err := selectDot(data, "Page"); err == nil {
+		t.Error("expected an error for a path without a dot")
+	}
+}
+
+func TestExecuteEntry(t *testing.T) {
+	text := `{{define "page"}}<h1>{{.Title}}</h1>{{end}}{{define "unused"}}{{end}}`
+	data := (&App{}).createData(context.Background(), text, `{"Page": {"Title": "hi"}}`, "",
+		options{Entry: "page", Dot: ".Page"})
+	if len(data.Errors) != 1 || data.Errors[0].Rule != "unused-define" {
+		t.Fatalf("unexpected errors found: %v", data.Errors)
+	}
+	if data.Output != "<h1>hi</h1>" {
+		t.Errorf("unexpected output %q", data.Output)
+	}
+
+	data = (&App{}).createData(context.Background(), text, "", "", options{Entry: "missing"})
+	if len(data.Errors) != 3 {
+		t.Fatalf("unexpected errors found: %v", data.Errors)
+	}
+	assertError(t, templateError{Line: -1, Char: -1, Level: misunderstoodError,
+		Description: `failed to understand the template to execute: "missing" isn't defined`}, data.Errors[0])
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"text/template"
+	templateParse "text/template/parse"
+)
+
+// otherDelimiters are delimiters templates are often parsed with instead of {{ and }}, with what an action between them
+// looks like: a field, variable or keyword right after the left one
+var otherDelimiters = []struct {
+	left, right string
+	action      *regexp.Regexp
+}{
+	{"[[", "]]", regexp.MustCompile(`\[\[-?\s*(?:[.$]|(?:if|else|end|range|with|define|template|block)\b)[^\]\n]*\]\]`)},
+	{"<%", "%>", regexp.MustCompile(`<%-?\s*(?:[.$]|(?:if|else|end|range|with|define|template|block)\b)[^%\n]*%>`)},
+}
+
+// lintOtherDelimiters warns about templates without a single action, which look like they have some between other
+// delimiters. Parsed with the default ones, they're valid and output themselves.
+func lintOtherDelimiters(t *template.Template, sources []source, _ lintConfig) []templateError {
+	for _, tree := range trees(t) {
+		for _, node := range tree.Root.Nodes {
+			if _, ok := node.(*templateParse.TextNode); !ok {
+				return nil
+			}
+		}
+	}
+
+	var tplErrs []templateError
+	for _, src := range sources {
+		for _, delims := range otherDelimiters {
+			loc := delims.action.FindStringIndex(src.text)
+			if loc == nil {
+				continue
+			}
+			tplErrs = append(tplErrs, templateError{
+				File: src.name,
+				Line: lineOf(src.text, loc[0]),
+				Char: columnOf(src.text, loc[0]),
+				Description: fmt.Sprintf("the template has no actions, but %s looks like one: it may be parsed with "+
+					"Delims(%q, %q)", src.text[loc[0]:loc[1]], delims.left, delims.right),
+			})
+			break
+		}
+	}
+	return tplErrs
+}
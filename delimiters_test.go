@@ -0,0 +1,31 @@
+package main
+
+import "testing"
+
+func TestLintOtherDelimiters(t *testing.T) {
+	tests := []struct {
+		text, expected string
+	}{
+		{"Hi\n  [[ .Name ]]!", `the template has no actions, but [[ .Name ]] looks like one: it may be parsed with Delims("[[", "]]")`},
+		{"<%- if .Admin %>admin<% end %>", `the template has no actions, but <%- if .Admin %> looks like one: it may be parsed with Delims("<%", "%>")`},
+		{"[[ .Name ]] {{.Name}}", ""},
+		{"[1, [2]] and 5 <% 3", ""},
+		{"", ""},
+	}
+	for _, test := range tests {
+		errs := lintOtherDelimiters(mustParse(t, test.text), []source{{name: "base", text: test.text}}, lintConfig{})
+		actual := ""
+		if len(errs) > 0 {
+			actual = errs[0].Description
+		}
+		if actual != test.expected {
+			t.Errorf("%s: expected `%s`, actual `%s`", test.text, test.expected, actual)
+		}
+	}
+
+	errs := lintOtherDelimiters(mustParse(t, "Hi\n  [[ .Name ]]!"), []source{{name: "base", text: "Hi\n  [[ .Name ]]!"}},
+		lintConfig{})
+	if len(errs) != 1 || errs[0].Line != 1 || errs[0].Char != 2 {
+		t.Errorf("unexpected errors %+v", errs)
+	}
+}
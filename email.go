@@ -0,0 +1,148 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/textproto"
+	"regexp"
+	"strings"
+	"text/template"
+)
+
+// the templates an email template defines its parts with
+const (
+	emailSubjectTemplate = "subject"
+	emailHTMLTemplate    = "html"
+	emailTextTemplate    = "text"
+)
+
+// emailBoundary separates the parts of rendered emails, it's fixed so they're reproducible
+const emailBoundary = "go-template-validator-alternative"
+
+// emailPreview is an email rendered from the subject, html and text templates of a template
+type emailPreview struct {
+	Subject string
+	// HTML has its style blocks inlined
+	HTML string
+	Text string
+	// MIME is the whole message, with the text and html as alternative parts
+	MIME          string
+	Compatibility []compatibilityIssue
+}
+
+// compatibilityIssue is something in an email's HTML that email clients don't all support
+type compatibilityIssue struct {
+	Feature string
+	Clients string
+}
+
+// emailFeatures are what compatibility issues are found from, a short list of the common ones
+var emailFeatures = []struct {
+	regex   *regexp.Regexp
+	feature string
+	clients string
+}{
+	{regexp.MustCompile(`(?i)<style`), "<style> blocks which couldn't be inlined", "Gmail with non-Google accounts, older Outlook.com"},
+	{regexp.MustCompile(`(?i)@media`), "@media queries", "Gmail with non-Google accounts, Outlook for Windows"},
+	{regexp.MustCompile(`(?i)display\s*:\s*(inline-)?flex`), "flexbox", "Outlook for Windows, Gmail"},
+	{regexp.MustCompile(`(?i)display\s*:\s*(inline-)?grid`), "grid layout", "Outlook for Windows, Gmail"},
+	{regexp.MustCompile(`(?i)position\s*:\s*(absolute|fixed|relative)`), "positioning", "Outlook for Windows, Gmail"},
+	{regexp.MustCompile(`(?i)background-image|background\s*:[^;"]*url\(`), "CSS background images", "Outlook for Windows"},
+	{regexp.MustCompile(`(?i)border-radius`), "border-radius", "Outlook for Windows"},
+	{regexp.MustCompile(`(?i)max-width`), "max-width", "Outlook for Windows"},
+	{regexp.MustCompile(`(?i)<script`), "<script>", "every client, scripts are removed"},
+	{regexp.MustCompile(`(?i)<form`), "forms", "Gmail, Outlook, Yahoo ask before submitting or remove them"},
+	{regexp.MustCompile(`(?i)<(video|audio)`), "<video> and <audio>", "Gmail, Outlook"},
+	{regexp.MustCompile(`(?i)<svg`), "inline SVG", "Gmail, Outlook"},
+	{regexp.MustCompile(`(?i)url\(\s*['"]?data:|src\s*=\s*['"]?data:`), "data: URLs", "Gmail, Outlook"},
+}
+
+// renderEmail executes the subject, html and text templates of t with data. At least one of html and text is needed.
+func renderEmail(ctx context.Context, t *template.Template, data interface{}, opts options) (*emailPreview, []templateError) {
+	limitedT, err := limitTemplate(ctx, t, opts.MaxSteps, opts.MaxIterations)
+	if err != nil {
+		limitedT = t
+	}
+	if limitedT.Lookup(emailHTMLTemplate) == nil && limitedT.Lookup(emailTextTemplate) == nil {
+		return nil, []templateError{{Line: -1, Char: -1, Level: execErrorLevel,
+			Description: fmt.Sprintf("email templates need to define %q or %q", emailHTMLTemplate, emailTextTemplate)}}
+	}
+
+	var tplErrs []templateError
+	render := func(name string) string {
+		tt := limitedT.Lookup(name)
+		if tt == nil {
+			return ""
+		}
+		buf := newCappedBuffer(opts.MaxOutput)
+		execTplErrs := exec(tt, data, buf)
+		describeLimitErrors(execTplErrs)
+		tplErrs = append(tplErrs, execTplErrs...)
+		return buf.String()
+	}
+	email := &emailPreview{
+		Subject: strings.Join(strings.Fields(render(emailSubjectTemplate)), " "),
+		HTML:    inlineCSS(render(emailHTMLTemplate)),
+		Text:    render(emailTextTemplate),
+	}
+	if email.Subject == "" {
+		tplErrs = append(tplErrs, templateError{Line: -1, Char: -1, Level: execErrorLevel,
+			Description: fmt.Sprintf("email has no subject, define it with the %q template", emailSubjectTemplate)})
+	}
+	for _, f := range emailFeatures {
+		if f.regex.MatchString(email.HTML) {
+			email.Compatibility = append(email.Compatibility, compatibilityIssue{Feature: f.feature, Clients: f.clients})
+		}
+	}
+	email.MIME = email.message()
+	return email, tplErrs
+}
+
+type emailPart struct {
+	contentType string
+	body        string
+}
+
+// message formats the email as a MIME message
+func (e *emailPreview) message() string {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "Subject: %s\r\nMIME-Version: 1.0\r\n", mime.QEncoding.Encode("utf-8", e.Subject))
+
+	var parts []emailPart
+	if e.Text != "" {
+		parts = append(parts, emailPart{"text/plain", e.Text})
+	}
+	if e.HTML != "" {
+		parts = append(parts, emailPart{"text/html", e.HTML})
+	}
+	if len(parts) == 1 {
+		fmt.Fprintf(&b, "Content-Type: %s; charset=utf-8\r\nContent-Transfer-Encoding: quoted-printable\r\n\r\n", parts[0].contentType)
+		writeQuotedPrintable(&b, parts[0].body)
+		return b.String()
+	}
+
+	w := multipart.NewWriter(&b)
+	w.SetBoundary(emailBoundary)
+	fmt.Fprintf(&b, "Content-Type: multipart/alternative; boundary=%s\r\n\r\n", emailBoundary)
+	// clients show the last part they can, so the html goes after the text
+	for _, part := range parts {
+		pw, _ := w.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType + "; charset=utf-8"},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		writeQuotedPrintable(pw, part.body)
+	}
+	w.Close()
+	return b.String()
+}
+
+func writeQuotedPrintable(w io.Writer, body string) {
+	qp := quotedprintable.NewWriter(w)
+	qp.Write([]byte(body))
+	qp.Close()
+}
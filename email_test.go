@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestInlineCSS(t *testing.T) {
+	html := `<style>
+/* kept out */ p { color: red; } .big, h1 { font-size: 20px }
+#main.big { margin: 0 }
+@media (max-width: 600px) { p { color: blue } }
+div p { color: green }
+</style><h1>T</h1><p class="big" id="main" style="color: black">x</p><img src="a.png"/>`
+	expected := `<style>
+@media (max-width: 600px) { p { color: blue } }
+div p { color: green }
+</style><h1 style="font-size: 20px">T</h1><p class="big" id="main" style="color: red; font-size: 20px; margin: 0; color: black">x</p><img src="a.png"/>`
+	if actual := inlineCSS(html); actual != expected {
+		t.Errorf("expected `%s`, actual `%s`", expected, actual)
+	}
+
+	html = `<style>td { padding: 4px }</style><table><tr><td>1</td></tr></table>`
+	if expected, actual := `<table><tr><td style="padding: 4px">1</td></tr></table>`, inlineCSS(html); actual != expected {
+		t.Errorf("expected `%s`, actual `%s`", expected, actual)
+	}
+}
+
+func TestEmail(t *testing.T) {
+	text := `{{define "subject"}}Hello
+  {{.Name}}{{end}}{{define "html"}}<style>p { border-radius: 4px }</style><p>Hi {{.Name}}</p>{{end}}{{define "text"}}Hi {{.Name}}{{end}}`
+	data := (&App{}).createData(context.Background(), text, `{"Name": "Bob"}`, "", options{Email: true})
+	if len(data.Errors) != 0 {
+		t.Fatalf("unexpected errors found: %v", data.Errors)
+	}
+	e := data.Email
+	if e.Subject != "Hello Bob" || e.HTML != `<p style="border-radius: 4px">Hi Bob</p>` || e.Text != "Hi Bob" {
+		t.Errorf("unexpected email: %+v", e)
+	}
+	if len(e.Compatibility) != 1 || e.Compatibility[0].Feature != "border-radius" {
+		t.Errorf("unexpected compatibility: %v", e.Compatibility)
+	}
+	for _, part := range []string{"Subject: Hello Bob\r\n", "Content-Type: multipart/alternative; boundary=" + emailBoundary,
+		"Content-Type: text/plain; charset=utf-8", "Content-Type: text/html; charset=utf-8", `<p style=3D"border-radius: 4px">`} {
+		if !strings.Contains(e.MIME, part) {
+			t.Errorf("expected the message to contain %q: %s", part, e.MIME)
+		}
+	}
+	if strings.Index(e.MIME, "text/plain") > strings.Index(e.MIME, "text/html") {
+		t.Errorf("expected the text part first: %s", e.MIME)
+	}
+}
+
+func TestEmailParts(t *testing.T) {
+	data := (&App{}).createData(context.Background(), `{{define "text"}}Hi{{end}}`, "", "", options{Email: true})
+	if len(data.Errors) != 1 {
+		t.Fatalf("unexpected errors found: %v", data.Errors)
+	}
+	assertError(t, templateError{Line: -1, Char: -1, Level: execErrorLevel, Description: `email has no subject, define it with the "subject" template`}, data.Errors[0])
+	if !strings.Contains(data.Email.MIME, "Content-Type: text/plain; charset=utf-8\r\n") || strings.Contains(data.Email.MIME, "multipart") {
+		t.Errorf("unexpected message: %s", data.Email.MIME)
+	}
+
+	data = (&App{}).createData(context.Background(), `Hi`, "", "", options{Email: true})
+	if len(data.Errors) != 1 || data.Email != nil {
+		t.Fatalf("unexpected errors found: %v", data.Errors)
+	}
+	assertError(t, templateError{Line: -1, Char: -1, Level: execErrorLevel, Description: `email templates need to define "html" or "text"`}, data.Errors[0])
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// embedParts are the parts of the embedded view which can be hidden
+var embedParts = []string{"template", "errors", "output"}
+
+// embedData is what the embedded view of a validation shows
+type embedData struct {
+	indexData
+	// Hidden are the parts of embedParts not shown
+	Hidden map[string]bool
+	// Link opens the validation in the full page, if it's a share
+	Link string
+}
+
+// Show returns whether the part of the view is shown
+func (d embedData) Show(part string) bool {
+	return !d.Hidden[part]
+}
+
+// Embed serves a minimal read-only view of a validation, its template, errors and output, to embed in other pages in
+// an iframe. It's of the template, data and functions query values, with the same options as the page, or of the share
+// query value. The hide query value is a comma separated list of the parts not to show.
+func (a *App) Embed(w http.ResponseWriter, r *http.Request) {
+	data := embedData{Hidden: make(map[string]bool)}
+	for _, part := range splitList(r.FormValue("hide")) {
+		known := false
+		for _, p := range embedParts {
+			known = known || p == part
+		}
+		if !known {
+			http.Error(w, fmt.Sprintf("unknown part %q to hide, it can be %s", part, strings.Join(embedParts, ", ")), http.StatusBadRequest)
+			return
+		}
+		data.Hidden[part] = true
+	}
+
+	if id := r.FormValue("share"); id != "" {
+		if a.refuseGuessing(w, r) {
+			return
+		}
+		session, err := a.shares.view(id, "", time.Now())
+		switch {
+		case err == errShareNotFound:
+			a.shareNotFound(w, r)
+			return
+		case err == errWrongPassphrase:
+			http.Error(w, "shares with a passphrase can't be embedded", http.StatusForbidden)
+			return
+		case err != nil:
+			http.Error(w, fmt.Sprintf("failed to load share: %v", err), http.StatusInternalServerError)
+			return
+		}
+		data.indexData = a.forRequest().createData(r.Context(), session.Template, session.Data, session.Functions, session.Options)
+		data.Link = shareURL(r, strings.ToLower(id))
+	} else {
+		text, err := getFormValue(r, "template")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		rawData, err := getFormValue(r, "data")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		data.indexData = a.forRequest().createData(r.Context(), text, rawData, r.FormValue("functions"), getOptions(r))
+	}
+	if err := a.index.ExecuteTemplate(w, "embed.html", data); err != nil {
+		http.Error(w, fmt.Sprintf("Execute error: %v", err), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEmbed(t *testing.T) {
+	index, err := parseIndex()
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := &App{index: index, shares: newShareStore(t.TempDir(), nil, time.Hour)}
+	get := func(query url.Values) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		a.Embed(w, httptest.NewRequest("GET", "/embed?"+query.Encode(), nil))
+		return w
+	}
+
+	w := get(url.Values{"template": {"Hi {{.Name}}\n{{.Name.First}}"}, "data": {`{"Name": "Bob"}`}})
+	body := w.Body.String()
+	if w.Code != http.StatusOK || !strings.Contains(body, `class="token-field"`) ||
+		!strings.Contains(body, "can&#39;t evaluate field First") || !strings.Contains(body, `<pre class="output">Hi Bob`) {
+		t.Errorf("unexpected response: %d %s", w.Code, body)
+	}
+	if strings.Contains(body, "<form") || strings.Contains(body, "Open in") {
+		t.Errorf("expected a read-only view without a link: %s", body)
+	}
+
+	w = get(url.Values{"template": {"Hi {{.Name}}"}, "data": {`{"Name": "Bob"}`}, "hide": {"template,errors"}})
+	if body := w.Body.String(); strings.Contains(body, `class="token-field"`) || strings.Contains(body, "No errors") ||
+		!strings.Contains(body, "Hi Bob") {
+		t.Errorf("expected only the output: %s", body)
+	}
+	if w := get(url.Values{"hide": {"everything"}}); w.Code != http.StatusBadRequest {
+		t.Errorf("unexpected response: %d %s", w.Code, w.Body)
+	}
+
+	id, _, err := a.shares.save(shareSession{Template: "Hi {{.}}", Data: `"Alice"`}, "", 0, 0, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	w = get(url.Values{"share": {id}})
+	if body := w.Body.String(); !strings.Contains(body, "Hi Alice") || !strings.Contains(body, "/s/"+id) {
+		t.Errorf("unexpected response: %d %s", w.Code, body)
+	}
+	locked, _, _ := a.shares.save(shareSession{Template: "Hi"}, "open sesame", 0, 0, time.Now())
+	if w := get(url.Values{"share": {locked}}); w.Code != http.StatusForbidden {
+		t.Errorf("unexpected response: %d %s", w.Code, w.Body)
+	}
+	if w := get(url.Values{"share": {"missing"}}); w.Code != http.StatusNotFound {
+		t.Errorf("unexpected response: %d %s", w.Code, w.Body)
+	}
+}
@@ -0,0 +1,176 @@
+package main
+
+import "regexp"
+
+const docsURL = "https://pkg.go.dev/text/template"
+
+// explanation describes what an error means in more detail than the template packages do
+type explanation struct {
+	Text    string
+	Example string
+	Link    string
+
+	// id is the knowledge base entry, used to find translations
+	id string
+}
+
+// knowledgeBase maps error descriptions to their explanations, the first matching pattern wins
+var knowledgeBase = []struct {
+	id          string
+	pattern     *regexp.Regexp
+	explanation explanation
+}{
+	{"unclosed-block", regexp.MustCompile(`^(unexpected EOF|{{\w+}} has no matching {{end}})$`), explanation{
+		Text:    "The template ended while a block was still open. Every {{if}}, {{range}}, {{with}}, {{define}} and {{block}} needs a matching {{end}}.",
+		Example: `{{if .Ready}}ready{{end}}`,
+		Link:    docsURL + "#hdr-Actions",
+	}},
+	{"undefined-function", regexp.MustCompile(`^function ".*" not defined$`), explanation{
+		Text:    "Only builtin functions and functions added with Funcs before parsing can be called. The validator mocks unknown functions so it can keep going, add their names to the function list to silence this.",
+		Example: `template.New("t").Funcs(template.FuncMap{"upper": strings.ToUpper}).Parse(text)`,
+		Link:    docsURL + "#hdr-Functions",
+	}},
+	{"function-policy", regexp.MustCompile(`^function ".*" (is forbidden|isn't allowed to be mocked) by the server's policy$`), explanation{
+		Text:    "The server running the validator doesn't let templates call this function, or doesn't mock unknown functions of this name, so the template isn't executed. Ask whoever runs it, or validate it locally with the command line.",
+		Example: `go-template-validator page.tmpl`,
+		Link:    docsURL + "#hdr-Functions",
+	}},
+	{"undefined-template", regexp.MustCompile(`template ".*" not defined$`), explanation{
+		Text:    "No template with this name is defined in the set. Templates are only associated with each other if they're parsed together or defined with {{define}} or {{block}}, otherwise invoking them fails once it's reached.",
+		Example: `{{define "footer"}}…{{end}}{{template "footer" .}}`,
+		Link:    docsURL + "#hdr-Nested_template_definitions",
+	}},
+	{"empty-action", regexp.MustCompile(`^missing value for command$`), explanation{
+		Text:    "An action is empty. Actions must contain a pipeline, such as a field, variable or function call.",
+		Example: `{{.Name}}`,
+		Link:    docsURL + "#hdr-Actions",
+	}},
+	{"missing-block-value", regexp.MustCompile(`^missing value for (if|range|with)$`), explanation{
+		Text:    "This block needs a pipeline to test or iterate over.",
+		Example: `{{range .Items}}{{.}}{{end}}`,
+		Link:    docsURL + "#hdr-Actions",
+	}},
+	{"unexpected-end", regexp.MustCompile(`^unexpected {{(end|else)}}`), explanation{
+		Text:    "There's no open block for this to belong to. It's either left over or the block's opening action is missing or misspelled.",
+		Example: `{{if .A}}a{{else}}b{{end}}`,
+		Link:    docsURL + "#hdr-Actions",
+	}},
+	{"index-syntax", regexp.MustCompile(`^bad character U\+005B '\['`), explanation{
+		Text:    "Templates don't support index expressions with brackets. Use the index function instead.",
+		Example: `{{index .Items 2}}`,
+		Link:    docsURL + "#hdr-Functions",
+	}},
+	{"bad-character", regexp.MustCompile(`^(bad character|unrecognized character in action)`), explanation{
+		Text:    "This character isn't allowed inside an action. Actions only support fields, variables, constants, function calls and pipelines, there are no operators.",
+		Example: `{{add .A 1}} rather than {{.A + 1}}`,
+		Link:    docsURL + "#hdr-Arguments",
+	}},
+	{"undefined-variable", regexp.MustCompile(`^undefined variable`), explanation{
+		Text:    "Variables must be declared with := before they're used, and are only in scope until the {{end}} of the block they're declared in.",
+		Example: `{{$name := .Name}}{{$name}}`,
+		Link:    docsURL + "#hdr-Variables",
+	}},
+	{"unclosed-action", regexp.MustCompile(`^unclosed action`), explanation{
+		Text:    "An action was opened with {{ but never closed with }}.",
+		Example: `{{.Name}}`,
+		Link:    docsURL + "#hdr-Actions",
+	}},
+	{"bad-comment", regexp.MustCompile(`^(unclosed comment|comment ends before closing delimiter)$`), explanation{
+		Text:    "Comments must be written as {{/* ... */}}, with nothing but optional trim markers between */ and }}.",
+		Example: `{{- /* a comment */ -}}`,
+		Link:    docsURL + "#hdr-Actions",
+	}},
+	{"unterminated-string", regexp.MustCompile(`^unterminated ((raw )?quoted string|character constant)$`), explanation{
+		Text:    "A string constant inside an action is missing its closing quote.",
+		Example: `{{printf "%s!" .Name}}`,
+		Link:    docsURL + "#hdr-Arguments",
+	}},
+	{"break-outside-range", regexp.MustCompile(`^{{(break|continue)}} outside {{range}}$`), explanation{
+		Text:    "{{break}} and {{continue}} can only be used inside a {{range}}.",
+		Example: `{{range .Items}}{{if .Hidden}}{{continue}}{{end}}{{.}}{{end}}`,
+		Link:    docsURL + "#hdr-Actions",
+	}},
+	{"non-executable-command", regexp.MustCompile(`^non executable command in pipeline stage`), explanation{
+		Text:    "Every stage of a pipeline after the first receives the previous result as its last argument, so it has to be a function or method call.",
+		Example: `{{.Name | printf "%q"}}`,
+		Link:    docsURL + "#hdr-Pipelines",
+	}},
+	{"argument-to-non-function", regexp.MustCompile(`can't give argument to non-function`), explanation{
+		Text:    "Arguments were passed to something that isn't a function or method, such as a field or a map key.",
+		Example: `{{index .Map "key"}} rather than {{.Map "key"}}`,
+		Link:    docsURL + "#hdr-Arguments",
+	}},
+	{"nil-pointer", regexp.MustCompile(`nil pointer evaluating`), explanation{
+		Text:    "A value in the field chain is nil (null in JSON), so fields after it can't be looked up. Guard the access with {{with}} or {{if}}.",
+		Example: `{{with .User}}{{.Name}}{{end}}`,
+		Link:    docsURL + "#hdr-Actions",
+	}},
+	{"unknown-field", regexp.MustCompile(`can't evaluate field`), explanation{
+		Text:    "The value has no field or method with this name. Struct fields must be exported, and JSON data of the wrong shape, such as an array where an object was expected, causes this too.",
+		Example: `{{range .Users}}{{.Name}}{{end}} rather than {{.Users.Name}}`,
+		Link:    docsURL + "#hdr-Arguments",
+	}},
+	{"missing-key", regexp.MustCompile(`map has no entry for key`), explanation{
+		Text:    "The missingkey=error option is set and the key doesn't exist in the map.",
+		Example: `{{if .Optional}}{{.Optional}}{{end}}`,
+		Link:    docsURL + "#Template.Option",
+	}},
+	{"wrong-args", regexp.MustCompile(`wrong number of args for`), explanation{
+		Text:    "The function was called with a different number of arguments than it accepts. Remember that in a pipeline the previous result is passed as the last argument.",
+		Example: `{{.Name | printf "%s"}} is printf "%s" .Name`,
+		Link:    docsURL + "#hdr-Pipelines",
+	}},
+	{"function-error", regexp.MustCompile(`error calling`), explanation{
+		Text:    "The function returned an error, which stops execution.",
+		Example: `{{index .Items 10}} when there are fewer than 11 items`,
+		Link:    docsURL + "#hdr-Functions",
+	}},
+	{"range-type", regexp.MustCompile(`range can't iterate over`), explanation{
+		Text:    "{{range}} only works on arrays, slices, maps, channels, functions returning an iterator and, in recent versions of go, integers.",
+		Example: `{{range $i, $item := .Items}}{{$i}}: {{$item}}{{end}}`,
+		Link:    docsURL + "#hdr-Actions",
+	}},
+	{"comparison-types", regexp.MustCompile(`incompatible types for comparison|invalid type for comparison`), explanation{
+		Text:    "Comparison functions need arguments of compatible basic types. JSON numbers are always float64, so compare them with floats or other JSON numbers.",
+		Example: `{{if eq .Count 1.0}}`,
+		Link:    docsURL + "#hdr-Functions",
+	}},
+	{"unexpected-token", regexp.MustCompile(`^unexpected .* in (operand|command|input)`), explanation{
+		Text:    "The parser found something it didn't expect here. Check for operators (templates have none), unbalanced parentheses or a missing space between arguments.",
+		Example: `{{if and (gt .A 1) (lt .A 5)}}`,
+		Link:    docsURL + "#hdr-Pipelines",
+	}},
+}
+
+// explainErrors sets the explanation of the errors recognized by the knowledge base
+func explainErrors(tplErrs []templateError) {
+	for i := range tplErrs {
+		tplErrs[i].Explanation = explain(tplErrs[i].Description)
+	}
+}
+
+func explain(description string) *explanation {
+	id := explanationID(description)
+	for _, entry := range knowledgeBase {
+		if entry.id == id {
+			e := entry.explanation
+			e.id = id
+			return &e
+		}
+	}
+	return nil
+}
+
+// explanationID returns the knowledge base entry describing an error, or an empty string if there's none
+func explanationID(description string) string {
+	// exec errors are prefixed with where they happened
+	if matches := executingRegex.FindStringSubmatchIndex(description); matches != nil {
+		description = description[matches[1]:]
+	}
+	for _, entry := range knowledgeBase {
+		if entry.pattern.MatchString(description) {
+			return entry.id
+		}
+	}
+	return ""
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestExplain(t *testing.T) {
+	for description, link := range map[string]string{
+		"unexpected EOF":             docsURL + "#hdr-Actions",
+		`function "foo" not defined`: docsURL + "#hdr-Functions",
+		`bad character U+005B '['`:   docsURL + "#hdr-Functions",
+		`bad character U+002B '+'`:   docsURL + "#hdr-Arguments",
+		`executing "base" at <.A.B>: nil pointer evaluating interface {}.B: ` + "`.A` is null, so it has no key `B`": docsURL + "#hdr-Actions",
+		`executing "base" at <.Name>: can't give argument to non-function .Name`:                                     docsURL + "#hdr-Arguments",
+	} {
+		e := explain(description)
+		if e == nil {
+			t.Errorf("no explanation for `%s`", description)
+			continue
+		}
+		if e.Link != link {
+			t.Errorf("wrong explanation for `%s`: %s", description, e.Text)
+		}
+	}
+}
+
+func TestExplainUnknown(t *testing.T) {
+	if e := explain("something new"); e != nil {
+		t.Errorf("unexpected explanation: %v", e)
+	}
+}
+
+func TestExplainErrors(t *testing.T) {
+	data := (&App{}).createData(context.Background(), "{{if .A}}", "", "", options{})
+	if len(data.Errors) != 1 || data.Errors[0].Explanation == nil {
+		t.Errorf("error isn't explained: %v", data.Errors)
+	}
+}
+
+func TestExplanationLine(t *testing.T) {
+	index, err := parseIndex()
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := &App{index: index}
+	form := url.Values{"from-raw-text": {"a\n{{foo}}"}}
+	r := httptest.NewRequest("POST", "/", strings.NewReader(form.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+	a.Post(w, r)
+	if body := w.Body.String(); !strings.Contains(body, `<summary>line 2: function &#34;foo&#34; not defined`) {
+		t.Errorf("expected the explanation of the error on the second line, actual %s", body)
+	}
+}
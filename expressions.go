@@ -0,0 +1,412 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	goToken "go/token"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+// maxExpressionCalls is the most expression functions a call may lead to, so ones calling each other forever stop
+const maxExpressionCalls = 1000
+
+// expressionRegex matches a line defining an expression function, like `double: x * 2` or `add(a, b): a + b`
+var expressionRegex = regexp.MustCompile(`^([A-Za-z_]\w*)\s*(\([^)]*\))?\s*:(.*)$`)
+
+// expressionFunction is a function defined by an expression of its parameters
+type expressionFunction struct {
+	name   string
+	params []string
+	body   ast.Expr
+}
+
+// expressionFunctions compiles src, lines like `double: x * 2` or `greet: "Hello " + name`, into functions for users
+// who don't want to write Go. Expressions have Go's syntax for literals, operators, fields like user.Name, indexes and
+// calls to len or the other functions. Without a parameter list, the parameters are the names the expression uses, in
+// the order they first appear.
+func expressionFunctions(src string) (template.FuncMap, error) {
+	defs := make(map[string]*expressionFunction)
+	var order []*expressionFunction
+	for i, line := range strings.Split(src, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		matches := expressionRegex.FindStringSubmatch(line)
+		if matches == nil {
+			return nil, fmt.Errorf("line %d: expected a function like `double: x * 2`", i+1)
+		}
+		body, err := parser.ParseExpr(matches[3])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", i+1, err)
+		}
+		fn := &expressionFunction{name: matches[1], body: body}
+		if matches[2] != "" {
+			fn.params = append([]string{}, splitList(strings.Trim(matches[2], "()"))...)
+		}
+		if _, ok := defs[fn.name]; ok {
+			return nil, fmt.Errorf("line %d: function %q is already defined", i+1, fn.name)
+		}
+		defs[fn.name] = fn
+		order = append(order, fn)
+	}
+
+	fns := make(template.FuncMap, len(order))
+	for _, fn := range order {
+		if fn.params == nil {
+			fn.params = freeNames(fn.body, defs)
+		}
+		fn := fn
+		fns[fn.name] = func(args ...interface{}) (interface{}, error) {
+			calls := 0
+			return fn.call(args, defs, &calls)
+		}
+	}
+	return fns, nil
+}
+
+// freeNames returns the names expr uses which aren't constants, len or functions, in the order they first appear
+func freeNames(expr ast.Expr, defs map[string]*expressionFunction) []string {
+	names := make([]string, 0)
+	seen := make(map[string]bool)
+	var visit func(n ast.Node) bool
+	visit = func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.SelectorExpr:
+			// the field isn't a name
+			ast.Inspect(n.X, visit)
+			return false
+		case *ast.CallExpr:
+			// nor is the function
+			for _, arg := range n.Args {
+				ast.Inspect(arg, visit)
+			}
+			return false
+		case *ast.Ident:
+			switch name := n.Name; {
+			case name == "true" || name == "false" || name == "nil" || defs[name] != nil:
+			case !seen[name]:
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+		return true
+	}
+	ast.Inspect(expr, visit)
+	return names
+}
+
+// call evaluates the function with args for its parameters
+func (fn *expressionFunction) call(args []interface{}, defs map[string]*expressionFunction, calls *int) (interface{}, error) {
+	if len(args) != len(fn.params) {
+		return nil, fmt.Errorf("%s expects %d arguments, got %d", fn.name, len(fn.params), len(args))
+	}
+	if *calls++; *calls > maxExpressionCalls {
+		return nil, fmt.Errorf("%s called more than %d functions", fn.name, maxExpressionCalls)
+	}
+	env := make(map[string]interface{}, len(args))
+	for i, param := range fn.params {
+		env[param] = args[i]
+	}
+	e := evaluator{env: env, defs: defs, calls: calls}
+	return e.eval(fn.body)
+}
+
+// evaluator evaluates the expression of a function called with env
+type evaluator struct {
+	env   map[string]interface{}
+	defs  map[string]*expressionFunction
+	calls *int
+}
+
+func (e evaluator) eval(expr ast.Expr) (interface{}, error) {
+	switch x := expr.(type) {
+	case *ast.BasicLit:
+		return literal(x)
+	case *ast.Ident:
+		switch x.Name {
+		case "true":
+			return true, nil
+		case "false":
+			return false, nil
+		case "nil":
+			return nil, nil
+		}
+		v, ok := e.env[x.Name]
+		if !ok {
+			return nil, fmt.Errorf("undefined: %s", x.Name)
+		}
+		return v, nil
+	case *ast.ParenExpr:
+		return e.eval(x.X)
+	case *ast.UnaryExpr:
+		v, err := e.eval(x.X)
+		if err != nil {
+			return nil, err
+		}
+		return unary(x.Op, v)
+	case *ast.BinaryExpr:
+		l, err := e.eval(x.X)
+		if err != nil {
+			return nil, err
+		}
+		// && and || don't evaluate their right operand if they don't need to
+		if b, ok := l.(bool); ok && (x.Op == goToken.LAND && !b || x.Op == goToken.LOR && b) {
+			return b, nil
+		}
+		r, err := e.eval(x.Y)
+		if err != nil {
+			return nil, err
+		}
+		return binary(x.Op, l, r)
+	case *ast.SelectorExpr:
+		v, err := e.eval(x.X)
+		if err != nil {
+			return nil, err
+		}
+		return field(v, x.Sel.Name)
+	case *ast.IndexExpr:
+		v, err := e.eval(x.X)
+		if err != nil {
+			return nil, err
+		}
+		i, err := e.eval(x.Index)
+		if err != nil {
+			return nil, err
+		}
+		return index(v, i)
+	case *ast.CallExpr:
+		ident, ok := x.Fun.(*ast.Ident)
+		if !ok {
+			return nil, errors.New("only functions can be called")
+		}
+		args := make([]interface{}, len(x.Args))
+		for i, arg := range x.Args {
+			v, err := e.eval(arg)
+			if err != nil {
+				return nil, err
+			}
+			args[i] = v
+		}
+		if ident.Name == "len" {
+			if len(args) != 1 {
+				return nil, fmt.Errorf("len expects 1 argument, got %d", len(args))
+			}
+			return length(args[0])
+		}
+		fn, ok := e.defs[ident.Name]
+		if !ok {
+			return nil, fmt.Errorf("undefined function: %s", ident.Name)
+		}
+		return fn.call(args, e.defs, e.calls)
+	}
+	return nil, fmt.Errorf("unsupported expression %T", expr)
+}
+
+func literal(lit *ast.BasicLit) (interface{}, error) {
+	switch lit.Kind {
+	case goToken.INT:
+		i, err := strconv.ParseInt(lit.Value, 0, 0)
+		return int(i), err
+	case goToken.FLOAT:
+		return strconv.ParseFloat(lit.Value, 64)
+	case goToken.CHAR:
+		r, _, _, err := strconv.UnquoteChar(lit.Value[1:len(lit.Value)-1], '\'')
+		return r, err
+	case goToken.STRING:
+		return strconv.Unquote(lit.Value)
+	}
+	return nil, fmt.Errorf("unsupported literal %s", lit.Value)
+}
+
+// number returns v as an int64 if it's an integer, or as a float64 if it's a float
+func number(v interface{}) (i int64, f float64, isInt, ok bool) {
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return rv.Int(), float64(rv.Int()), true, true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return int64(rv.Uint()), float64(rv.Uint()), true, true
+	case reflect.Float32, reflect.Float64:
+		return 0, rv.Float(), false, true
+	}
+	return 0, 0, false, false
+}
+
+func unary(op goToken.Token, v interface{}) (interface{}, error) {
+	if op == goToken.NOT {
+		b, ok := v.(bool)
+		if !ok {
+			return nil, fmt.Errorf("operator ! not defined on %v (%T)", v, v)
+		}
+		return !b, nil
+	}
+	i, f, isInt, ok := number(v)
+	if !ok || op != goToken.SUB && op != goToken.ADD {
+		return nil, fmt.Errorf("operator %s not defined on %v (%T)", op, v, v)
+	}
+	switch {
+	case op == goToken.ADD:
+		return v, nil
+	case isInt:
+		return int(-i), nil
+	}
+	return -f, nil
+}
+
+func binary(op goToken.Token, l, r interface{}) (interface{}, error) {
+	mismatched := fmt.Errorf("operator %s not defined on %v (%T) and %v (%T)", op, l, l, r, r)
+
+	if lb, ok := l.(bool); ok {
+		rb, ok := r.(bool)
+		switch {
+		case !ok:
+		case op == goToken.LAND:
+			return lb && rb, nil
+		case op == goToken.LOR:
+			return lb || rb, nil
+		case op == goToken.EQL:
+			return lb == rb, nil
+		case op == goToken.NEQ:
+			return lb != rb, nil
+		}
+		return nil, mismatched
+	}
+
+	if ls, ok := l.(string); ok {
+		rs, ok := r.(string)
+		if !ok {
+			return nil, mismatched
+		}
+		switch op {
+		case goToken.ADD:
+			return ls + rs, nil
+		case goToken.EQL:
+			return ls == rs, nil
+		case goToken.NEQ:
+			return ls != rs, nil
+		case goToken.LSS:
+			return ls < rs, nil
+		case goToken.LEQ:
+			return ls <= rs, nil
+		case goToken.GTR:
+			return ls > rs, nil
+		case goToken.GEQ:
+			return ls >= rs, nil
+		}
+		return nil, mismatched
+	}
+
+	li, lf, lInt, lok := number(l)
+	ri, rf, rInt, rok := number(r)
+	if !lok || !rok {
+		switch op {
+		case goToken.EQL:
+			return reflect.DeepEqual(l, r), nil
+		case goToken.NEQ:
+			return !reflect.DeepEqual(l, r), nil
+		}
+		return nil, mismatched
+	}
+	if lInt && rInt {
+		switch op {
+		case goToken.ADD:
+			return int(li + ri), nil
+		case goToken.SUB:
+			return int(li - ri), nil
+		case goToken.MUL:
+			return int(li * ri), nil
+		case goToken.QUO, goToken.REM:
+			if ri == 0 {
+				return nil, errors.New("division by zero")
+			}
+			if op == goToken.QUO {
+				return int(li / ri), nil
+			}
+			return int(li % ri), nil
+		}
+	}
+	switch op {
+	case goToken.ADD:
+		return lf + rf, nil
+	case goToken.SUB:
+		return lf - rf, nil
+	case goToken.MUL:
+		return lf * rf, nil
+	case goToken.QUO:
+		return lf / rf, nil
+	case goToken.EQL:
+		return lf == rf, nil
+	case goToken.NEQ:
+		return lf != rf, nil
+	case goToken.LSS:
+		return lf < rf, nil
+	case goToken.LEQ:
+		return lf <= rf, nil
+	case goToken.GTR:
+		return lf > rf, nil
+	case goToken.GEQ:
+		return lf >= rf, nil
+	}
+	return nil, mismatched
+}
+
+// field returns the field or map key name of v
+func field(v interface{}, name string) (interface{}, error) {
+	rv := reflect.Indirect(reflect.ValueOf(v))
+	switch rv.Kind() {
+	case reflect.Map:
+		if rv.Type().Key().Kind() == reflect.String {
+			if value := rv.MapIndex(reflect.ValueOf(name).Convert(rv.Type().Key())); value.IsValid() {
+				return value.Interface(), nil
+			}
+			return nil, nil
+		}
+	case reflect.Struct:
+		if f := rv.FieldByName(name); f.IsValid() && f.CanInterface() {
+			return f.Interface(), nil
+		}
+	}
+	return nil, fmt.Errorf("can't evaluate field %s of %v (%T)", name, v, v)
+}
+
+// index returns the element of v at i, which is a key for maps
+func index(v, i interface{}) (interface{}, error) {
+	rv := reflect.Indirect(reflect.ValueOf(v))
+	switch rv.Kind() {
+	case reflect.Map:
+		key := reflect.ValueOf(i)
+		if !key.IsValid() || !key.Type().ConvertibleTo(rv.Type().Key()) {
+			return nil, fmt.Errorf("can't index %T with %v (%T)", v, i, i)
+		}
+		if value := rv.MapIndex(key.Convert(rv.Type().Key())); value.IsValid() {
+			return value.Interface(), nil
+		}
+		return nil, nil
+	case reflect.Slice, reflect.Array, reflect.String:
+		n, _, isInt, _ := number(i)
+		if !isInt {
+			return nil, fmt.Errorf("can't index %T with %v (%T)", v, i, i)
+		}
+		if n < 0 || int(n) >= rv.Len() {
+			return nil, fmt.Errorf("index %d out of range for length %d", n, rv.Len())
+		}
+		return rv.Index(int(n)).Interface(), nil
+	}
+	return nil, fmt.Errorf("can't index %v (%T)", v, v)
+}
+
+func length(v interface{}) (interface{}, error) {
+	rv := reflect.Indirect(reflect.ValueOf(v))
+	switch rv.Kind() {
+	case reflect.Map, reflect.Slice, reflect.Array, reflect.String, reflect.Chan:
+		return rv.Len(), nil
+	}
+	return nil, fmt.Errorf("len of %v (%T)", v, v)
+}
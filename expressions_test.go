@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestExpressionFunctions(t *testing.T) {
+	fns, err := expressionFunctions(`double: x * 2
+greet: "Hello " + name
+# the parameters in order
+sub(a, b): a - b
+quadruple: double(double(n))
+adult: user.Age >= 18 && len(user.Name) > 0
+first: items[0]
+half: x / 2`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name     string
+		args     []interface{}
+		expected interface{}
+	}{
+		{"double", []interface{}{21}, 42},
+		{"double", []interface{}{1.5}, 3.0},
+		{"greet", []interface{}{"world"}, "Hello world"},
+		{"sub", []interface{}{5, 3}, 2},
+		{"quadruple", []interface{}{2}, 8},
+		{"adult", []interface{}{map[string]interface{}{"Age": 20.0, "Name": "Ann"}}, true},
+		{"adult", []interface{}{struct {
+			Age  int
+			Name string
+		}{Age: 10, Name: "Bob"}}, false},
+		{"first", []interface{}{[]string{"a", "b"}}, "a"},
+		{"half", []interface{}{5}, 2},
+	}
+	for _, test := range tests {
+		actual, err := fns[test.name].(func(...interface{}) (interface{}, error))(test.args...)
+		if err != nil || !reflect.DeepEqual(actual, test.expected) {
+			t.Errorf("%s%v: expected %v, actual %v (%v)", test.name, test.args, test.expected, actual, err)
+		}
+	}
+}
+
+func TestExpressionFunctionsErrors(t *testing.T) {
+	tests := map[string]string{
+		"double x * 2": "line 1: expected a function",
+		"double: x *":  "line 1: 1:5: expected operand",
+		"a: 1\na: 2":   `line 2: function "a" is already defined`,
+	}
+	for src, expected := range tests {
+		if _, err := expressionFunctions(src); err == nil || !strings.Contains(err.Error(), expected) {
+			t.Errorf("%q: expected an error containing %q, actual %v", src, expected, err)
+		}
+	}
+
+	fns, _ := expressionFunctions("loop: loop(x)\nsum: a + b\nbad: sum(1)\nmixed: s + 1\nzero: x / 0")
+	calls := map[string][]interface{}{
+		"loop":  {1},
+		"bad":   {},
+		"mixed": {"a"},
+		"zero":  {1},
+	}
+	expected := map[string]string{
+		"loop":  "called more than 1000 functions",
+		"bad":   "sum expects 2 arguments, got 1",
+		"mixed": "operator + not defined on a (string) and 1 (int)",
+		"zero":  "division by zero",
+	}
+	for name, args := range calls {
+		_, err := fns[name].(func(...interface{}) (interface{}, error))(args...)
+		if err == nil || !strings.Contains(err.Error(), expected[name]) {
+			t.Errorf("%s: expected an error containing %q, actual %v", name, expected[name], err)
+		}
+	}
+}
+
+func TestCreateDataExpressions(t *testing.T) {
+	opts := options{Expressions: `greet: "Hello " + name`}
+	data := (&App{}).createData(context.Background(), `{{greet .Name}}`, `{"Name": "world"}`, "", opts)
+	if len(data.Errors) != 0 || data.Output != "Hello world" {
+		t.Errorf("unexpected output %q and errors %+v", data.Output, data.Errors)
+	}
+
+	data = (&App{}).createData(context.Background(), "{{.}}", "", "", options{Expressions: "broken"})
+	if len(data.Errors) != 1 || !strings.HasPrefix(data.Errors[0].Description, "failed to understand expression functions: ") {
+		t.Errorf("unexpected errors %+v", data.Errors)
+	}
+}
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	osExec "os/exec"
+	"plugin"
+	"reflect"
+	"sync"
+	"text/template"
+	"time"
+)
+
+// extensionCallTimeout is how long an extension may take to answer a call before it's restarted
+const extensionCallTimeout = 10 * time.Second
+
+// extensionHello is the first line an extension writes, the functions it serves
+type extensionHello struct {
+	Functions []string `json:"functions"`
+}
+
+// extensionCall is a line asking an extension to call one of its functions
+type extensionCall struct {
+	Function string        `json:"function"`
+	Args     []interface{} `json:"args"`
+}
+
+// extensionResult is the line an extension answers a call with
+type extensionResult struct {
+	Result interface{} `json:"result"`
+	// Error fails the call if it isn't empty
+	Error string `json:"error"`
+}
+
+// extension is a process serving functions over its standard input and output, one JSON object a line: it starts
+// with an extensionHello, then answers each extensionCall with an extensionResult. Calls are made one at a time, and a
+// process which doesn't answer one in time, or fails to, is killed and started again.
+type extension struct {
+	command string
+	// timeout is how long a call may take, extensionCallTimeout except in tests
+	timeout time.Duration
+	mu      sync.Mutex
+	// cmd is nil once the process is stopped, until the next call starts it again
+	cmd    *osExec.Cmd
+	stdin  io.WriteCloser
+	stdout *bufio.Scanner
+}
+
+// loadExtensions returns the functions of the Go plugins at pluginPaths and of the extension commands, which are
+// started to be called while the server runs. Later ones replace the functions of the same names of earlier ones.
+func loadExtensions(pluginPaths, commands []string) (template.FuncMap, error) {
+	fns := make(template.FuncMap)
+	for _, path := range pluginPaths {
+		pluginFns, err := pluginFunctions(path)
+		if err != nil {
+			return nil, fmt.Errorf("plugin %s: %v", path, err)
+		}
+		for name, fn := range pluginFns {
+			fns[name] = fn
+		}
+	}
+	for _, command := range commands {
+		ext, names, err := startExtension(command)
+		if err != nil {
+			return nil, fmt.Errorf("extension %s: %v", command, err)
+		}
+		for _, name := range names {
+			fns[name] = ext.function(name)
+		}
+	}
+	return fns, nil
+}
+
+// pluginFunctions opens the Go plugin at path, which must export Funcs, a template.FuncMap or a function returning one
+func pluginFunctions(path string) (template.FuncMap, error) {
+	p, err := plugin.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	sym, err := p.Lookup("Funcs")
+	if err != nil {
+		return nil, err
+	}
+	var fns map[string]interface{}
+	switch funcs := sym.(type) {
+	case *template.FuncMap:
+		fns = *funcs
+	case *map[string]interface{}:
+		fns = *funcs
+	case func() template.FuncMap:
+		fns = funcs()
+	case func() map[string]interface{}:
+		fns = funcs()
+	default:
+		return nil, fmt.Errorf("Funcs is a %T, not a template.FuncMap or a function returning one", sym)
+	}
+	for name, fn := range fns {
+		t := reflect.TypeOf(fn)
+		if t == nil || t.Kind() != reflect.Func {
+			return nil, fmt.Errorf("%q is a %T, not a function", name, fn)
+		}
+		if err := checkFunctionResults(name, t); err != nil {
+			return nil, err
+		}
+	}
+	return fns, nil
+}
+
+// startExtension starts command, which is run by the shell, and returns the names of its functions
+func startExtension(command string) (*extension, []string, error) {
+	ext := &extension{command: command, timeout: extensionCallTimeout}
+	names, err := ext.start()
+	if err != nil {
+		return nil, nil, err
+	}
+	return ext, names, nil
+}
+
+// start starts the extension's process and returns the names of its functions
+func (e *extension) start() ([]string, error) {
+	cmd := osExec.Command("sh", "-c", e.command)
+	cmd.Stderr = os.Stderr
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	e.cmd, e.stdin, e.stdout = cmd, stdin, bufio.NewScanner(stdout)
+	e.stdout.Buffer(nil, maxRequestSize)
+
+	var hello extensionHello
+	if err := e.exchange(nil, &hello); err != nil {
+		return nil, fmt.Errorf("failed to read the functions it serves: %v", err)
+	}
+	return hello.Functions, nil
+}
+
+// function returns a function calling the extension's function name
+func (e *extension) function(name string) func(args ...interface{}) (interface{}, error) {
+	return func(args ...interface{}) (interface{}, error) {
+		if args == nil {
+			args = make([]interface{}, 0)
+		}
+		b, err := json.Marshal(extensionCall{Function: name, Args: args})
+		if err != nil {
+			return nil, err
+		}
+		e.mu.Lock()
+		defer e.mu.Unlock()
+		if e.cmd == nil {
+			if _, err := e.start(); err != nil {
+				return nil, fmt.Errorf("extension %s isn't running: %v", e.command, err)
+			}
+		}
+		var result extensionResult
+		if err := e.exchange(append(b, '\n'), &result); err != nil {
+			return nil, fmt.Errorf("extension %s: %v", e.command, err)
+		}
+		if result.Error != "" {
+			return nil, errors.New(result.Error)
+		}
+		return result.Result, nil
+	}
+}
+
+// exchange writes line, if there is one, to the extension and decodes the line it answers into v. An extension which
+// fails to answer, or doesn't within its timeout, is stopped, to be started again by the next call.
+func (e *extension) exchange(line []byte, v interface{}) error {
+	// the pipes are used in a goroutine of their own, which can stay blocked on a process which doesn't answer
+	done := make(chan error, 1)
+	go func(stdin io.Writer, stdout *bufio.Scanner) {
+		if line != nil {
+			if _, err := stdin.Write(line); err != nil {
+				done <- err
+				return
+			}
+		}
+		if !stdout.Scan() {
+			if err := stdout.Err(); err != nil {
+				done <- err
+				return
+			}
+			done <- io.ErrUnexpectedEOF
+			return
+		}
+		done <- json.Unmarshal(stdout.Bytes(), v)
+	}(e.stdin, e.stdout)
+
+	timer := time.NewTimer(e.timeout)
+	defer timer.Stop()
+	var err error
+	select {
+	case err = <-done:
+		if err == nil {
+			return nil
+		}
+	case <-timer.C:
+		err = fmt.Errorf("no answer within %v", e.timeout)
+	}
+	e.stdin.Close()
+	e.cmd.Process.Kill()
+	go e.cmd.Wait()
+	e.cmd = nil
+	return err
+}
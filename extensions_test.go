@@ -0,0 +1,89 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestExtensionProcess isn't a test, it's the extension the tests start
+func TestExtensionProcess(t *testing.T) {
+	if os.Getenv("GO_TEMPLATE_VALIDATOR_EXTENSION") == "" {
+		return
+	}
+	out := json.NewEncoder(os.Stdout)
+	out.Encode(extensionHello{Functions: []string{"shout", "fail", "hang"}})
+	in := bufio.NewScanner(os.Stdin)
+	for in.Scan() {
+		var call extensionCall
+		json.Unmarshal(in.Bytes(), &call)
+		switch call.Function {
+		case "shout":
+			out.Encode(extensionResult{Result: strings.ToUpper(fmt.Sprint(call.Args...)) + "!"})
+		case "hang":
+			// never answers, until its input is closed
+		default:
+			out.Encode(extensionResult{Error: "no " + call.Function})
+		}
+	}
+	os.Exit(0)
+}
+
+func extensionCommand() string {
+	return fmt.Sprintf("GO_TEMPLATE_VALIDATOR_EXTENSION=1 '%s' -test.run='^TestExtensionProcess$'", os.Args[0])
+}
+
+func TestLoadExtensions(t *testing.T) {
+	fns, err := loadExtensions(nil, []string{extensionCommand()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fns) != 3 {
+		t.Fatalf("expected shout, fail and hang, actual %v", fns)
+	}
+
+	a := &App{extensions: fns}
+	data := a.createData(context.Background(), `{{shout .Name}} {{fail}}`, `{"Name": "hi"}`, "", options{})
+	if data.Output != "HI! " {
+		t.Errorf("unexpected output %q", data.Output)
+	}
+	if len(data.Errors) != 1 || !strings.HasSuffix(data.Errors[0].Description, "error calling fail: no fail") {
+		t.Errorf("unexpected errors %+v", data.Errors)
+	}
+}
+
+func TestExtensionTimeout(t *testing.T) {
+	ext, _, err := startExtension(extensionCommand())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ext.timeout = 500 * time.Millisecond
+	if _, err := ext.function("hang")(); err == nil || !strings.HasSuffix(err.Error(), "no answer within 500ms") {
+		t.Errorf("unexpected error %v", err)
+	}
+	// the extension is started again
+	if result, err := ext.function("shout")("hi"); err != nil || result != "HI!" {
+		t.Errorf("unexpected result %v, error %v", result, err)
+	}
+}
+
+func TestLoadExtensionsErrors(t *testing.T) {
+	tests := map[string]string{
+		"echo not json": "extension echo not json: failed to read the functions it serves: invalid character",
+		"true":          "extension true: failed to read the functions it serves: unexpected EOF",
+	}
+	for command, expected := range tests {
+		if _, err := loadExtensions(nil, []string{command}); err == nil || !strings.HasPrefix(err.Error(), expected) {
+			t.Errorf("%s: expected an error starting with %q, actual %v", command, expected, err)
+		}
+	}
+
+	if _, err := loadExtensions([]string{"missing.so"}, nil); err == nil || !strings.HasPrefix(err.Error(), "plugin missing.so: ") {
+		t.Errorf("unexpected error %v", err)
+	}
+}
@@ -0,0 +1,257 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	// fetchTimeout is how long fetching a template may take
+	fetchTimeout = 30 * time.Second
+
+	// emptySHA256 is the hash of an empty body, which S3 needs for GET requests
+	emptySHA256 = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+)
+
+// awsCredentials sign requests to S3
+type awsCredentials struct {
+	accessKeyID     string
+	secretAccessKey string
+	// sessionToken is only set for temporary credentials
+	sessionToken string
+	region       string
+}
+
+// fetcher fetches templates from HTTP(S) URLs and s3:// and gs:// objects
+type fetcher struct {
+	client *http.Client
+	// hosts are the hosts templates can be fetched from over HTTP(S), "*" for any, as the server could otherwise be
+	// made to fetch from its own network
+	hosts []string
+	// buckets are the S3 and Google Cloud Storage buckets templates can be fetched from, "*" for any, as the
+	// credentials could otherwise be used to read any object they can
+	buckets []string
+	// tokens are sent as bearer tokens to the hosts they're for
+	tokens map[string]string
+	aws    awsCredentials
+	// gcsToken is an OAuth access token for Google Cloud Storage
+	gcsToken string
+
+	// s3Endpoint and gcsEndpoint are where objects are fetched from, except in tests
+	s3Endpoint  func(bucket, region string) string
+	gcsEndpoint string
+}
+
+// newFetcher returns a fetcher allowed to fetch from hosts and buckets, with the host=token pairs of tokens. S3 and
+// Google Cloud Storage credentials come from the usual environment variables.
+func newFetcher(hosts, buckets, tokens []string) (*fetcher, error) {
+	f := &fetcher{
+		hosts:   hosts,
+		buckets: buckets,
+		tokens:  make(map[string]string),
+		aws: awsCredentials{
+			accessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+			secretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+			sessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+			region:          os.Getenv("AWS_REGION"),
+		},
+		gcsToken: os.Getenv("GOOGLE_OAUTH_ACCESS_TOKEN"),
+		s3Endpoint: func(bucket, region string) string {
+			return fmt.Sprintf("https://%s.s3.%s.amazonaws.com", bucket, region)
+		},
+		gcsEndpoint: "https://storage.googleapis.com",
+	}
+	f.client = &http.Client{
+		Timeout: fetchTimeout,
+		// a host allowed could redirect to one which isn't
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= 10 {
+				return errors.New("stopped after 10 redirects")
+			}
+			if !f.allows(req.URL.Hostname()) {
+				return fmt.Errorf("templates can't be fetched from %s", req.URL.Hostname())
+			}
+			return nil
+		},
+	}
+	if f.aws.region == "" {
+		f.aws.region = "us-east-1"
+	}
+	for _, token := range tokens {
+		i := strings.Index(token, "=")
+		if i <= 0 {
+			return nil, fmt.Errorf("token %q isn't host=token", token)
+		}
+		f.tokens[token[:i]] = token[i+1:]
+	}
+	return f, nil
+}
+
+// fetch returns the template at rawURL
+func (f *fetcher) fetch(ctx context.Context, rawURL string) (string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", err
+	}
+	ctx, cancel := context.WithTimeout(ctx, fetchTimeout)
+	defer cancel()
+
+	var req *http.Request
+	switch u.Scheme {
+	case "http", "https":
+		if !f.allows(u.Hostname()) {
+			return "", fmt.Errorf("templates can't be fetched from %s", u.Hostname())
+		}
+		if req, err = http.NewRequestWithContext(ctx, "GET", u.String(), nil); err != nil {
+			return "", err
+		}
+		if token, ok := f.tokens[u.Hostname()]; ok {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+	case "s3":
+		if !allowed(f.buckets, u.Host) {
+			return "", fmt.Errorf("templates can't be fetched from the %s bucket", u.Host)
+		}
+		if f.aws.accessKeyID == "" {
+			return "", errors.New("fetching from S3 needs AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY")
+		}
+		endpoint := f.s3Endpoint(u.Host, f.aws.region) + "/" + uriEncode(strings.TrimPrefix(u.Path, "/"), false)
+		if req, err = http.NewRequestWithContext(ctx, "GET", endpoint, nil); err != nil {
+			return "", err
+		}
+		signV4(req, f.aws, "s3", time.Now())
+	case "gs":
+		if !allowed(f.buckets, u.Host) {
+			return "", fmt.Errorf("templates can't be fetched from the %s bucket", u.Host)
+		}
+		if f.gcsToken == "" {
+			return "", errors.New("fetching from Google Cloud Storage needs GOOGLE_OAUTH_ACCESS_TOKEN")
+		}
+		endpoint := f.gcsEndpoint + "/" + u.Host + "/" + uriEncode(strings.TrimPrefix(u.Path, "/"), false)
+		if req, err = http.NewRequestWithContext(ctx, "GET", endpoint, nil); err != nil {
+			return "", err
+		}
+		req.Header.Set("Authorization", "Bearer "+f.gcsToken)
+	default:
+		return "", fmt.Errorf("can't fetch %s URLs", u.Scheme)
+	}
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("fetching responded %s", resp.Status)
+	}
+	b, err := io.ReadAll(io.LimitReader(resp.Body, maxArchiveFileSize+1))
+	if err != nil {
+		return "", err
+	}
+	if len(b) > maxArchiveFileSize {
+		return "", fmt.Errorf("bigger than %d bytes", maxArchiveFileSize)
+	}
+	if !utf8.Valid(b) {
+		return "", errors.New("not a text file")
+	}
+	return string(b), nil
+}
+
+func (f *fetcher) allows(host string) bool {
+	return allowed(f.hosts, host)
+}
+
+// allowed returns whether name is in names, case insensitively, or names has "*"
+func allowed(names []string, name string) bool {
+	for _, n := range names {
+		if n == "*" || strings.EqualFold(n, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// signV4 signs req with AWS signature version 4, signing every header it has
+func signV4(req *http.Request, creds awsCredentials, service string, now time.Time) {
+	date := now.UTC().Format("20060102T150405Z")
+	req.Header.Set("X-Amz-Date", date)
+	if req.Header.Get("X-Amz-Content-Sha256") == "" {
+		req.Header.Set("X-Amz-Content-Sha256", emptySHA256)
+	}
+	if creds.sessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", creds.sessionToken)
+	}
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		uriEncode(req.URL.EscapedPath(), true),
+		req.URL.RawQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		req.Header.Get("X-Amz-Content-Sha256"),
+	}, "\n")
+	scope := fmt.Sprintf("%s/%s/%s/aws4_request", date[:8], creds.region, service)
+	hash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + date + "\n" + scope + "\n" + hex.EncodeToString(hash[:])
+
+	key := []byte("AWS4" + creds.secretAccessKey)
+	for _, part := range []string{date[:8], creds.region, service, "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		creds.accessKeyID, scope, signedHeaders, signature))
+}
+
+func hmacSHA256(key []byte, s string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(s))
+	return h.Sum(nil)
+}
+
+// uriEncode percent encodes everything in s but unreserved characters and slashes, the way AWS signatures need it. An
+// escaped s is decoded first so it isn't encoded twice.
+func uriEncode(s string, escaped bool) string {
+	if escaped {
+		if unescaped, err := url.PathUnescape(s); err == nil {
+			s = unescaped
+		}
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' || strings.IndexByte("-_.~/", c) >= 0 {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,265 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// builtinFunctions are the functions text/template predefines
+var builtinFunctions = functionNames(builtinFunctionDocs)
+
+var (
+	unclosedRegex      = regexp.MustCompile(`^unclosed (action|comment)(?: started at .*:(\d+))?$`)
+	unexpectedKeyRegex = regexp.MustCompile(`^unexpected {{(end|else)}}$`)
+	didYouMeanRegex    = regexp.MustCompile("^(?:`(.*?)` exists but|dot|the data) has no key `(.+?)`; did you mean `(.+?)`\\?$")
+	endOrElseRegex     = regexp.MustCompile(`^{{-?\s*(end|else)\s*-?}}$`)
+)
+
+// position is a line and character in the submitted text
+type position struct {
+	Line int
+	Char int
+}
+
+// quickFix is a suggested edit resolving an error, replacing the text between Start and End with Replacement
+type quickFix struct {
+	Description string
+	Start       position
+	End         position
+	Replacement string
+	// Safe fixes can't change what a correct template means, so they're applied automatically in fix mode
+	Safe bool
+
+	// byte offsets into the text while validating
+	start int
+	end   int
+}
+
+// suggestFixes attaches quick fixes to the errors it recognizes. Error locations must still be the ones the template
+// packages report. functions are the known function names besides the builtin ones.
+func suggestFixes(tplErrs []templateError, text string, functions []string) {
+	lines := SplitLines(text)
+	for i := range tplErrs {
+		tplErr := &tplErrs[i]
+		if tplErr.Line < 0 || tplErr.Line >= len(lines) {
+			continue
+		}
+		switch tplErr.Level {
+		case parseErrorLevel:
+			tplErr.Fix = suggestParseFix(*tplErr, text, lines, functions)
+		case execErrorLevel:
+			tplErr.Fix = suggestExecFix(*tplErr, errorOffset(text, tplErr.Line, 0), lines[tplErr.Line])
+		}
+	}
+}
+
+func suggestParseFix(tplErr templateError, text string, lines []string, functions []string) *quickFix {
+	lineStart := errorOffset(text, tplErr.Line, 0)
+	line := lines[tplErr.Line]
+
+	if matches := unclosedRegex.FindStringSubmatch(tplErr.Description); matches != nil {
+		closing := "}}"
+		if matches[1] == "comment" {
+			closing = "*/}}"
+		}
+		// close it on the line it was opened on, which newer versions of go report separately
+		openLine := tplErr.Line
+		if n, err := strconv.Atoi(matches[2]); err == nil && n > 0 && n <= len(lines) {
+			openLine = n - 1
+		}
+		at := errorOffset(text, openLine, 0) + len(strings.TrimRight(lines[openLine], " \t"))
+		return &quickFix{
+			Description: fmt.Sprintf("close the %s", matches[1]),
+			Replacement: closing,
+			start:       at,
+			end:         at,
+		}
+	}
+
+	if missingValueForCommandRegex.MatchString(tplErr.Description) && tplErr.Char >= 0 {
+		if loc := firstEmptyCommandRegex.FindStringIndex(line[tplErr.Char:]); loc != nil && loc[0] == 0 {
+			return &quickFix{
+				Description: "remove the empty action",
+				Safe:        true,
+				start:       lineStart + tplErr.Char,
+				end:         lineStart + tplErr.Char + loc[1],
+			}
+		}
+	}
+
+	if tplErr.Description == "unexpected EOF" || unclosedBlockRegex.MatchString(tplErr.Description) {
+		return &quickFix{
+			Description: "add the missing {{end}}",
+			Replacement: "{{end}}",
+			Safe:        true,
+			start:       len(text),
+			end:         len(text),
+		}
+	}
+
+	if matches := unexpectedKeyRegex.FindStringSubmatch(tplErr.Description); matches != nil {
+		for _, a := range findActions(line) {
+			if endOrElseRegex.MatchString(line[a.start:a.end]) && strings.Contains(line[a.start:a.end], matches[1]) {
+				return &quickFix{
+					Description: fmt.Sprintf("remove the {{%s}}", matches[1]),
+					start:       lineStart + a.start,
+					end:         lineStart + a.end,
+				}
+			}
+		}
+	}
+
+	if matches := functionNotFoundRegex.FindStringSubmatch(tplErr.Description); matches != nil && tplErr.Char >= 0 {
+		name := matches[1]
+		if !strings.HasPrefix(line[tplErr.Char:], name) {
+			return nil
+		}
+		suggestion := closestKey(name, append(append([]string{}, builtinFunctions...), functions...))
+		if suggestion == "" || suggestion == name {
+			return nil
+		}
+		return &quickFix{
+			Description: fmt.Sprintf("did you mean `%s`?", suggestion),
+			Replacement: suggestion,
+			start:       lineStart + tplErr.Char,
+			end:         lineStart + tplErr.Char + len(name),
+		}
+	}
+
+	return nil
+}
+
+func suggestExecFix(tplErr templateError, lineStart int, line string) *quickFix {
+	if tplErr.Char < 0 {
+		return nil
+	}
+	exprMatches := executingRegex.FindStringSubmatch(tplErr.Description)
+	if exprMatches == nil {
+		return nil
+	}
+	expr := exprMatches[2]
+	parts := strings.Split(tplErr.Description, ": ")
+	matches := didYouMeanRegex.FindStringSubmatch(parts[len(parts)-1])
+	if matches == nil {
+		return nil
+	}
+	walked, key, suggestion := matches[1], matches[2], matches[3]
+
+	// find the expression in the source text around where the error happened
+	exprStart := -1
+	for offset := 0; offset <= len(line)-len(expr); {
+		i := strings.Index(line[offset:], expr)
+		if i == -1 {
+			break
+		}
+		i += offset
+		if i <= tplErr.Char && tplErr.Char < i+len(expr) {
+			exprStart = i
+			break
+		}
+		offset = i + 1
+	}
+	if exprStart == -1 {
+		return nil
+	}
+
+	// the missing key is the one right after the part of the path that exists
+	existing := 0
+	if walked != "" {
+		existing = len(strings.Split(strings.TrimPrefix(walked, "$"), ".")) - 1
+	}
+	segments := strings.Split(expr, ".")
+	if existing+1 >= len(segments) || segments[existing+1] != key {
+		return nil
+	}
+	keyStart := exprStart + len(strings.Join(segments[:existing+1], ".")) + 1
+	return &quickFix{
+		Description: fmt.Sprintf("did you mean `%s`?", suggestion),
+		Replacement: suggestion,
+		// the data says this is the right key
+		Safe:  true,
+		start: lineStart + keyStart,
+		end:   lineStart + keyStart + len(key),
+	}
+}
+
+// locateFixes sets the positions of fixes from their offsets, the same way as error locations
+func locateFixes(tplErrs []templateError, text string, lines []string, unit ColumnUnit) {
+	for _, tplErr := range tplErrs {
+		if tplErr.Fix == nil {
+			continue
+		}
+		fix := tplErr.Fix
+		fix.Start = offsetPosition(text, lines, fix.start, unit)
+		fix.End = offsetPosition(text, lines, fix.end, unit)
+	}
+}
+
+func offsetPosition(text string, lines []string, offset int, unit ColumnUnit) position {
+	line, char := visualPosition(text, offset)
+	if unit != byteColumns && line < len(lines) {
+		char = column(lines[line], char, unit)
+	}
+	return position{Line: line, Char: char}
+}
+
+// applyFixes applies fixes to text, skipping any overlapping an earlier one
+func applyFixes(text string, fixes []*quickFix) string {
+	sort.SliceStable(fixes, func(i, j int) bool {
+		return fixes[i].start < fixes[j].start
+	})
+
+	var sb strings.Builder
+	offset := 0
+	for _, fix := range fixes {
+		if fix.start < offset {
+			continue
+		}
+		sb.WriteString(text[offset:fix.start])
+		sb.WriteString(fix.Replacement)
+		offset = fix.end
+	}
+	sb.WriteString(text[offset:])
+	return sb.String()
+}
+
+// fixOptions returns opts for the passes of autoFix finding fixes, which find every runtime error, including missing
+// keys which are likely typos, and only validate: benchmarks, fuzzing, screenshots and emails are left to the last pass.
+func fixOptions(opts options) options {
+	opts.AllExecErrors = true
+	opts.MissingKey = "error"
+	opts.Benchmark = 0
+	opts.Fuzz = false
+	opts.Screenshot = false
+	opts.Email = false
+	return opts
+}
+
+// autoFix validates text, applying its safe fixes until none are left, and returns the result of validating the fixed
+// text with a diff against the original
+func (a *App) autoFix(ctx context.Context, text, rawData, rawFns string, opts options) indexData {
+	fixOpts := fixOptions(opts)
+	fixed := text
+	for i := 0; i < maxFixes && ctx.Err() == nil; i++ {
+		data := a.forRequest().createData(ctx, fixed, rawData, rawFns, fixOpts)
+
+		var fixes []*quickFix
+		for _, tplErr := range data.Errors {
+			if tplErr.Fix != nil && tplErr.Fix.Safe {
+				fixes = append(fixes, tplErr.Fix)
+			}
+		}
+		if len(fixes) == 0 {
+			break
+		}
+		fixed = applyFixes(fixed, fixes)
+	}
+
+	data := a.forRequest().createData(ctx, fixed, rawData, rawFns, opts)
+	data.Diff = unifiedDiff("template", text, fixed)
+	return data
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"testing"
+)
+
+func assertFix(t *testing.T, expected quickFix, actual *quickFix) {
+	if actual == nil {
+		t.Fatalf("no fix suggested, expected `%s`", expected.Description)
+	}
+	if expected.Description != actual.Description {
+		t.Errorf("fix Description doesn't match: expected `%s`, actual `%s`", expected.Description, actual.Description)
+	}
+	if expected.Start != actual.Start {
+		t.Errorf("fix Start doesn't match: expected `%v`, actual `%v`", expected.Start, actual.Start)
+	}
+	if expected.End != actual.End {
+		t.Errorf("fix End doesn't match: expected `%v`, actual `%v`", expected.End, actual.End)
+	}
+	if expected.Replacement != actual.Replacement {
+		t.Errorf("fix Replacement doesn't match: expected `%s`, actual `%s`", expected.Replacement, actual.Replacement)
+	}
+}
+
+func TestFixUnclosedAction(t *testing.T) {
+	data := (&App{}).createData(context.Background(), "a\n{{.Foo  \n", "", "", options{})
+	if len(data.Errors) != 1 {
+		t.Fatalf("unexpected errors found: %v", data.Errors)
+	}
+	assertFix(t, quickFix{
+		Description: "close the action",
+		Start:       position{Line: 1, Char: 6},
+		End:         position{Line: 1, Char: 6},
+		Replacement: "}}",
+	}, data.Errors[0].Fix)
+}
+
+func TestFixUnexpectedEnd(t *testing.T) {
+	data := (&App{}).createData(context.Background(), "a\nb {{- end }}", "", "", options{})
+	if len(data.Errors) != 1 {
+		t.Fatalf("unexpected errors found: %v", data.Errors)
+	}
+	assertFix(t, quickFix{
+		Description: "remove the {{end}}",
+		Start:       position{Line: 1, Char: 2},
+		End:         position{Line: 1, Char: 12},
+	}, data.Errors[0].Fix)
+}
+
+func TestFixFunctionName(t *testing.T) {
+	data := (&App{}).createData(context.Background(), "兵 {{lenght}} {{formatDat}}", "", "formatDate", options{})
+	if len(data.Errors) != 2 {
+		t.Fatalf("unexpected errors found: %v", data.Errors)
+	}
+	assertFix(t, quickFix{
+		Description: "did you mean `len`?",
+		Start:       position{Line: 0, Char: 4},
+		End:         position{Line: 0, Char: 10},
+		Replacement: "len",
+	}, data.Errors[0].Fix)
+	assertFix(t, quickFix{
+		Description: "did you mean `formatDate`?",
+		Start:       position{Line: 0, Char: 15},
+		End:         position{Line: 0, Char: 24},
+		Replacement: "formatDate",
+	}, data.Errors[1].Fix)
+}
+
+func TestFixFieldName(t *testing.T) {
+	text := "{{range .Users}}{{.Profile.Nmae}}{{end}}"
+	errs := execJSON(t, text, `{"Users": [{"Profile": {"Name": "兵哥哥"}}]}`)
+	if len(errs) != 1 {
+		t.Fatalf("unexpected errs: %v", errs)
+	}
+	suggestFixes(errs, text, nil)
+	locateFixes(errs, text, SplitVisualLines(text), runeColumns)
+	assertFix(t, quickFix{
+		Description: "did you mean `Name`?",
+		Start:       position{Line: 0, Char: 27},
+		End:         position{Line: 0, Char: 31},
+		Replacement: "Name",
+	}, errs[0].Fix)
+}
+
+func TestFixNone(t *testing.T) {
+	data := (&App{}).createData(context.Background(), "{{if}}{{end}}", "", "", options{})
+	if len(data.Errors) != 1 {
+		t.Fatalf("unexpected errors found: %v", data.Errors)
+	}
+	if data.Errors[0].Fix != nil {
+		t.Errorf("unexpected fix: %v", data.Errors[0].Fix)
+	}
+}
+
+func TestAutoFix(t *testing.T) {
+	data := (&App{}).autoFix(context.Background(), "{{if .A}}\n{{.User.Nmae}} {{}}{{lenght}}\n", `{"A": 1, "User": {"Name": "兵哥哥"}}`, "", options{})
+	// the function name isn't a safe fix
+	if data.RawText != "{{if .A}}\n{{.User.Name}} {{lenght}}\n{{end}}" {
+		t.Errorf("unexpected fixed text: %q", data.RawText)
+	}
+	if len(data.Errors) != 1 {
+		t.Fatalf("unexpected errors found: %v", data.Errors)
+	}
+	expectedDiff := `--- template
++++ template (fixed)
+@@ -1,3 +1,3 @@
+ {{if .A}}
+-{{.User.Nmae}} {{}}{{lenght}}
+-
++{{.User.Name}} {{lenght}}
++{{end}}
+`
+	if data.Diff != expectedDiff {
+		t.Errorf("unexpected diff:\n%s", data.Diff)
+	}
+}
+
+func TestAutoFixNothing(t *testing.T) {
+	data := (&App{}).autoFix(context.Background(), "{{.A}}", "", "", options{})
+	if data.RawText != "{{.A}}" || data.Diff != "" {
+		t.Errorf("unexpected fix: %q\n%s", data.RawText, data.Diff)
+	}
+}
+
+func TestAutoFixOptions(t *testing.T) {
+	opts := fixOptions(options{Benchmark: 10, Fuzz: true, Screenshot: true, Email: true, TabWidth: 4})
+	if opts != (options{AllExecErrors: true, MissingKey: "error", TabWidth: 4}) {
+		t.Errorf("unexpected options %+v", opts)
+	}
+
+	data := (&App{}).autoFix(context.Background(), "{{.A}}{{}}", `{"A": 1}`, "", options{Benchmark: 2})
+	if data.RawText != "{{.A}}" || data.Benchmark == nil {
+		t.Errorf("expected the fixed template to be benchmarked: %q, %+v", data.RawText, data.Benchmark)
+	}
+}
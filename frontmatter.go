@@ -0,0 +1,85 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"text/template"
+	templateParse "text/template/parse"
+)
+
+// frontMatterLength returns the length of the front matter text starts with, including the line ending after it, 0 if
+// there's none. Front matter is YAML between --- lines, TOML between +++ lines, or a JSON object, like Hugo's.
+func frontMatterLength(text string) int {
+	for _, delim := range []string{"---", "+++"} {
+		first := lineEnd(text, 0)
+		if strings.TrimSuffix(text[:first], "\r") != delim {
+			continue
+		}
+		for start := first + 1; start < len(text); {
+			end := lineEnd(text, start)
+			if strings.TrimSuffix(text[start:end], "\r") == delim {
+				return min(end+1, len(text))
+			}
+			start = end + 1
+		}
+		return 0
+	}
+
+	// actions start with {{, a JSON object doesn't
+	if !strings.HasPrefix(text, "{") || strings.HasPrefix(text, "{{") {
+		return 0
+	}
+	dec := json.NewDecoder(strings.NewReader(text))
+	var object map[string]interface{}
+	if err := dec.Decode(&object); err != nil {
+		return 0
+	}
+	end := int(dec.InputOffset())
+	switch rest := text[end:]; {
+	case rest == "":
+		return end
+	case strings.HasPrefix(rest, "\n"):
+		return end + 1
+	case strings.HasPrefix(rest, "\r\n"):
+		return end + 2
+	}
+	return 0
+}
+
+// lineEnd returns the offset of the first "\n" in text from start, or the length of text if there's none
+func lineEnd(text string, start int) int {
+	if i := strings.IndexByte(text[start:], '\n'); i != -1 {
+		return start + i
+	}
+	return len(text)
+}
+
+// blankFrontMatter returns text with the front matter it starts with turned into spaces, keeping its line endings so
+// the positions of the rest are still those of text, and its length
+func blankFrontMatter(text string) (string, int) {
+	n := frontMatterLength(text)
+	if n == 0 {
+		return text, 0
+	}
+	blank := []byte(text[:n])
+	for i, b := range blank {
+		if b != '\n' && b != '\r' {
+			blank[i] = ' '
+		}
+	}
+	return string(blank) + text[n:], n
+}
+
+// stripFrontMatter removes the n bytes of blanked front matter from the text t outputs first, so it outputs the
+// template as if it had none
+func stripFrontMatter(t *template.Template, n int) {
+	if t == nil || t.Tree == nil || t.Tree.Root == nil || len(t.Tree.Root.Nodes) == 0 {
+		return
+	}
+	// a trim marker right after the front matter leaves no text before the first action
+	text, ok := t.Tree.Root.Nodes[0].(*templateParse.TextNode)
+	if !ok || text.Pos != 0 {
+		return
+	}
+	text.Text = text.Text[min(n, len(text.Text)):]
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+	"testing"
+)
+
+func TestFrontMatterLength(t *testing.T) {
+	tests := map[string]int{
+		"---\ntitle: x\n---\nbody":      17,
+		"+++\r\ntitle = 'x'\r\n+++\r\n": 23,
+		"{\"title\": \"x\"}\nbody":      15,
+		"{\"title\": \"x\"}":            14,
+		"---\ntitle: x\n":               0,
+		"----\n---\n":                   0,
+		"{{.X}}\n":                      0,
+		"{\"a\": {{.X}}}\n":             0,
+		"{\"a\": 1} {{.X}}":             0,
+		"body\n---\n":                   0,
+	}
+	for text, expected := range tests {
+		if actual := frontMatterLength(text); actual != expected {
+			t.Errorf("%q: expected %d, actual %d", text, expected, actual)
+		}
+	}
+}
+
+func TestCreateDataFrontMatter(t *testing.T) {
+	text := "---\ntitle: \"{{\"\n---\nHi {{.Name}}\n{{if}}\n"
+	data := (&App{}).createData(context.Background(), text, `{"Name": "x"}`, "", options{})
+	if len(data.Errors) == 0 {
+		t.Fatal("expected an error")
+	}
+	assertError(t, templateError{Line: 4, Char: -1, Description: "missing value for if", Level: parseErrorLevel},
+		data.Errors[0])
+
+	data = (&App{}).createData(context.Background(), "---\ntitle: x\n---\nHi {{.Name}}\n", `{"Name": "x"}`, "",
+		options{})
+	if len(data.Errors) > 0 || data.Output != "Hi x\n" {
+		t.Errorf("unexpected output %q, errors %+v", data.Output, data.Errors)
+	}
+
+	data = (&App{}).createData(context.Background(), "+++\n+++\n\n{{- .Name}}", `{"Name": "x"}`, "", options{})
+	if len(data.Errors) > 0 || data.Output != "x" {
+		t.Errorf("unexpected output %q, errors %+v", data.Output, data.Errors)
+	}
+}
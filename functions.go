@@ -0,0 +1,63 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// functionDoc documents a function templates can call
+type functionDoc struct {
+	Name        string `json:"name"`
+	Signature   string `json:"signature"`
+	Description string `json:"description"`
+	Example     string `json:"example"`
+	// Preset is the set of functions it belongs to
+	Preset string `json:"preset"`
+}
+
+// builtinFunctionDocs document the functions text/template predefines
+var builtinFunctionDocs = []functionDoc{
+	{"and", "and x y...", "Returns the first empty argument or the last argument. Arguments after it aren't evaluated, since Go 1.18.", `{{if and .User .User.Admin}}admin{{end}}`, "builtin"},
+	{"call", "call fn args...", "Returns the result of calling the first argument, which must be a function, with the rest as parameters.", `{{call .Format .Date}}`, "builtin"},
+	{"html", "html args...", "Returns the escaped HTML equivalent of the textual representation of its arguments.", `{{.Comment | html}}`, "builtin"},
+	{"index", "index item indexes...", "Returns the result of indexing its first argument by the following ones, which must be a map, slice or array.", `{{index .Items 0}}`, "builtin"},
+	{"slice", "slice item indexes...", "Returns the result of slicing its first argument by the following ones, like item[1:2].", `{{slice .Name 0 3}}`, "builtin"},
+	{"js", "js args...", "Returns the escaped JavaScript equivalent of the textual representation of its arguments.", `var name = "{{js .Name}}";`, "builtin"},
+	{"len", "len item", "Returns the length of its argument.", `{{len .Items}} items`, "builtin"},
+	{"not", "not x", "Returns the boolean negation of its single argument.", `{{if not .Hidden}}shown{{end}}`, "builtin"},
+	{"or", "or x y...", "Returns the first non-empty argument or the last argument. Arguments after it aren't evaluated, since Go 1.18.", `{{or .Nickname .Name}}`, "builtin"},
+	{"print", "print args...", "An alias for fmt.Sprint.", `{{print .A .B}}`, "builtin"},
+	{"printf", "printf format args...", "An alias for fmt.Sprintf.", `{{printf "%.2f" .Price}}`, "builtin"},
+	{"println", "println args...", "An alias for fmt.Sprintln.", `{{println .Line}}`, "builtin"},
+	{"urlquery", "urlquery args...", "Returns the escaped value of the textual representation of its arguments in a form suitable for embedding in a URL query.", `?q={{urlquery .Query}}`, "builtin"},
+	{"eq", "eq arg1 arg2...", "Returns the boolean truth of arg1 == arg2, or of arg1 equalling any of the others.", `{{if eq .Status "open" "pending"}}`, "builtin"},
+	{"ge", "ge arg1 arg2", "Returns the boolean truth of arg1 >= arg2.", `{{if ge .Count 10}}`, "builtin"},
+	{"gt", "gt arg1 arg2", "Returns the boolean truth of arg1 > arg2.", `{{if gt .Count 0}}`, "builtin"},
+	{"le", "le arg1 arg2", "Returns the boolean truth of arg1 <= arg2.", `{{if le .Count 10}}`, "builtin"},
+	{"lt", "lt arg1 arg2", "Returns the boolean truth of arg1 < arg2.", `{{if lt .Count 10}}`, "builtin"},
+	{"ne", "ne arg1 arg2", "Returns the boolean truth of arg1 != arg2.", `{{if ne .Status "closed"}}`, "builtin"},
+}
+
+// documentedFunctions are all the functions with documentation
+func documentedFunctions() []functionDoc {
+	docs := append([]functionDoc{}, builtinFunctionDocs...)
+	docs = append(docs, timeFunctionDocs...)
+	return append(docs, randomFunctionDocs...)
+}
+
+func functionNames(docs []functionDoc) []string {
+	names := make([]string, len(docs))
+	for i, doc := range docs {
+		names[i] = doc.Name
+	}
+	return names
+}
+
+// Functions serves the documentation of every documented function, and of the functions of the presets, as JSON
+func (a *App) Functions(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(append(documentedFunctions(), a.presetFunctionDocs()...)); err != nil {
+		http.Error(w, fmt.Sprintf("Encode error: %v", err), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+	textTemplate "text/template"
+)
+
+func TestBuiltinFunctionDocs(t *testing.T) {
+	// every documented builtin must exist
+	for _, doc := range builtinFunctionDocs {
+		text := "{{" + doc.Name + "}}"
+		if _, err := textTemplate.New("t").Parse(text); err != nil {
+			t.Errorf("%s: %v", doc.Name, err)
+		}
+	}
+}
+
+func TestFunctionsEndpoint(t *testing.T) {
+	w := httptest.NewRecorder()
+	(&App{}).Functions(w, httptest.NewRequest("GET", "/api/v1/functions", nil))
+	if ct := w.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("unexpected content type: %s", ct)
+	}
+	var docs []functionDoc
+	if err := json.Unmarshal(w.Body.Bytes(), &docs); err != nil {
+		t.Fatal(err)
+	}
+	if docs[0].Name != "and" || docs[0].Preset != "builtin" {
+		t.Errorf("unexpected docs: %v", docs)
+	}
+	include := functionDoc{Name: "include", Signature: "include args...", Preset: "helm",
+		Description: "Mocked by the helm preset, " + defaultFunctionPresets["helm"].Description + "."}
+	if !containsDoc(docs, include) {
+		t.Errorf("expected the docs of helm's include, actual %v", docs)
+	}
+
+	a := &App{functionPresets: map[string]functionPreset{"company-internal": {Description: "our FuncMap",
+		Functions: json.RawMessage(`{"lookupUser": {"args": ["string", "...int"]}}`)}}}
+	w = httptest.NewRecorder()
+	a.Functions(w, httptest.NewRequest("GET", "/api/v1/functions", nil))
+	docs = nil
+	if err := json.Unmarshal(w.Body.Bytes(), &docs); err != nil {
+		t.Fatal(err)
+	}
+	lookupUser := functionDoc{Name: "lookupUser", Signature: "lookupUser string ...int", Preset: "company-internal",
+		Description: "Mocked by the company-internal preset, our FuncMap."}
+	if len(docs) != len(documentedFunctions())+1 || !containsDoc(docs, lookupUser) {
+		t.Errorf("expected the docs of lookupUser, actual %v", docs)
+	}
+}
+
+func containsDoc(docs []functionDoc, doc functionDoc) bool {
+	for _, d := range docs {
+		if d == doc {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,275 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"text/template"
+	templateParse "text/template/parse"
+)
+
+const (
+	// maxFuzzPayloads is the most data payloads fuzzing executes
+	maxFuzzPayloads = 200
+	// hugeStringLength is how long the huge strings fuzzing tries are
+	hugeStringLength = 100000
+	// elementKey stands for every element of an array in a field path
+	elementKey = "[]"
+)
+
+// fuzzResult is a shape of data that makes executing fail
+type fuzzResult struct {
+	// Path is the field that was changed, like .Items[].Name
+	Path string
+	// Shape is what it was changed to
+	Shape string
+	Error string
+}
+
+// fuzzMutation changes a value into another shape, or reports that it doesn't apply to the value
+type fuzzMutation struct {
+	shape  string
+	mutate func(value interface{}) (interface{}, bool)
+}
+
+// deleted is the value of fields a mutation removes
+var deleted = new(struct{})
+
+var fuzzMutations = []fuzzMutation{
+	{"missing", func(interface{}) (interface{}, bool) { return deleted, true }},
+	{"null", func(value interface{}) (interface{}, bool) { return nil, value != nil }},
+	{"wrong type", func(value interface{}) (interface{}, bool) {
+		if _, ok := value.(string); ok {
+			return 1.0, true
+		}
+		return "x", true
+	}},
+	{"empty array", func(value interface{}) (interface{}, bool) {
+		a, ok := value.([]interface{})
+		return []interface{}{}, ok && len(a) > 0
+	}},
+	{"huge string", func(value interface{}) (interface{}, bool) {
+		_, ok := value.(string)
+		return strings.Repeat("x", hugeStringLength), ok
+	}},
+}
+
+// fuzz executes t with variations of data, changing each field t reads to each shape in fuzzMutations, and returns the
+// ones that make executing fail. Without data, data made up of every field t reads is varied instead.
+func fuzz(ctx context.Context, t *template.Template, data interface{}, opts options) []fuzzResult {
+	paths := fieldPaths(t)
+	if data == nil {
+		data = skeleton(paths)
+	}
+
+	var results []fuzzResult
+	payloads := 0
+	for _, path := range paths {
+		for _, m := range fuzzMutations {
+			if payloads == maxFuzzPayloads || ctx.Err() != nil {
+				return results
+			}
+			payload, ok := mutatePath(data, path, m.mutate)
+			if !ok {
+				continue
+			}
+			payloads++
+			if err := fuzzExec(ctx, t, payload, opts); err != "" {
+				results = append(results, fuzzResult{Path: formatPath(path), Shape: m.shape, Error: err})
+			}
+		}
+	}
+	return results
+}
+
+// fuzzExec executes t with data within the limits of opts, returning its error if it has one
+func fuzzExec(ctx context.Context, t *template.Template, data interface{}, opts options) string {
+	limitedT, err := limitTemplate(ctx, t, opts.MaxSteps, opts.MaxIterations)
+	if err != nil {
+		return ""
+	}
+	tplErrs := exec(limitedT, data, newCappedBuffer(opts.MaxOutput))
+	if len(tplErrs) == 0 {
+		return ""
+	}
+	describeLimitErrors(tplErrs)
+	return tplErrs[0].Description
+}
+
+// fieldPaths returns the paths from the data passed to t of every field t reads, following {{with}} and {{range}}
+// blocks over fields, in order
+func fieldPaths(t *template.Template) [][]string {
+	seen := make(map[string]bool)
+	var paths [][]string
+	for _, tree := range trees(t) {
+		for _, path := range treeFieldPaths(tree) {
+			if key := strings.Join(path, "."); !seen[key] {
+				seen[key] = true
+				paths = append(paths, path)
+			}
+		}
+	}
+	return paths
+}
+
+// treeFieldPaths returns the paths from the data passed to tree of every field it reads, and all their prefixes, in order
+func treeFieldPaths(tree *templateParse.Tree) [][]string {
+	seen := make(map[string]bool)
+	var paths [][]string
+	add := func(path []string) {
+		// every prefix is read too
+		for i := 1; i <= len(path); i++ {
+			if key := strings.Join(path[:i], "."); !seen[key] {
+				seen[key] = true
+				paths = append(paths, append([]string{}, path[:i]...))
+			}
+		}
+	}
+	walk(tree.Root, func(node templateParse.Node, ancestors []templateParse.Node) bool {
+		switch n := node.(type) {
+		case *templateParse.FieldNode:
+			if prefix, ok := dotPath(append(ancestors, node)); ok {
+				add(append(prefix, n.Ident...))
+			}
+		case *templateParse.VariableNode:
+			if n.Ident[0] == "$" && len(n.Ident) > 1 {
+				add(n.Ident[1:])
+			}
+		}
+		return true
+	})
+	return paths
+}
+
+// dotPath returns the path of dot at the innermost node of chain, like resolveDot does its values
+func dotPath(chain []templateParse.Node) ([]string, bool) {
+	var path []string
+	for i := 0; i < len(chain)-1; i++ {
+		var branch *templateParse.BranchNode
+		switch n := chain[i].(type) {
+		case *templateParse.WithNode:
+			branch = &n.BranchNode
+		case *templateParse.RangeNode:
+			branch = &n.BranchNode
+		default:
+			continue
+		}
+		if chain[i+1] != branch.List {
+			continue
+		}
+		if len(branch.Pipe.Cmds) != 1 || len(branch.Pipe.Cmds[0].Args) != 1 {
+			return nil, false
+		}
+		switch arg := branch.Pipe.Cmds[0].Args[0].(type) {
+		case *templateParse.DotNode:
+		case *templateParse.FieldNode:
+			path = append(path, arg.Ident...)
+		case *templateParse.VariableNode:
+			if arg.Ident[0] != "$" {
+				return nil, false
+			}
+			path = append([]string{}, arg.Ident[1:]...)
+		default:
+			return nil, false
+		}
+		if branch.NodeType == templateParse.NodeRange {
+			path = append(path, elementKey)
+		}
+	}
+	return path, true
+}
+
+// skeleton makes up data with a value for every path: objects, arrays of one element and strings
+func skeleton(paths [][]string) interface{} {
+	root := map[string]interface{}{}
+	for _, path := range paths {
+		var value interface{} = root
+		for i, key := range path {
+			if key == elementKey {
+				continue
+			}
+			m, ok := value.(map[string]interface{})
+			if !ok {
+				break
+			}
+			// a field is a string until a longer path reads inside it
+			if existing, ok := m[key]; !ok || existing == "x" {
+				switch {
+				case i == len(path)-1:
+					m[key] = "x"
+				case path[i+1] == elementKey:
+					m[key] = []interface{}{map[string]interface{}{}}
+				default:
+					m[key] = map[string]interface{}{}
+				}
+			}
+			value = m[key]
+			if a, ok := value.([]interface{}); ok && len(a) > 0 {
+				value = a[0]
+			}
+		}
+	}
+	return root
+}
+
+// mutatePath returns a copy of data with the value at path replaced by mutate, if there is one it applies to
+func mutatePath(data interface{}, path []string, mutate func(interface{}) (interface{}, bool)) (interface{}, bool) {
+	if len(path) == 0 {
+		return data, false
+	}
+	key := path[0]
+	switch value := data.(type) {
+	case map[string]interface{}:
+		if key == elementKey {
+			return data, false
+		}
+		old, ok := value[key]
+		if !ok {
+			return data, false
+		}
+		var changed interface{}
+		if len(path) == 1 {
+			changed, ok = mutate(old)
+		} else {
+			changed, ok = mutatePath(old, path[1:], mutate)
+		}
+		if !ok {
+			return data, false
+		}
+		result := make(map[string]interface{}, len(value))
+		for k, v := range value {
+			result[k] = v
+		}
+		if changed == deleted {
+			delete(result, key)
+		} else {
+			result[key] = changed
+		}
+		return result, true
+	case []interface{}:
+		if key != elementKey || len(path) == 1 {
+			return data, false
+		}
+		result := make([]interface{}, len(value))
+		mutated := false
+		for i, element := range value {
+			changed, ok := mutatePath(element, path[1:], mutate)
+			result[i] = changed
+			mutated = mutated || ok
+		}
+		return result, mutated
+	}
+	return data, false
+}
+
+func formatPath(path []string) string {
+	var sb strings.Builder
+	for _, key := range path {
+		if key == elementKey {
+			sb.WriteString(key)
+		} else {
+			fmt.Fprintf(&sb, ".%s", key)
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestFieldPaths(t *testing.T) {
+	tpl := mustParse(t, `{{.Title}}{{range .Items}}{{.Name}}{{with .Owner}}{{.Email}}{{end}}{{end}}{{$.User.ID}}`)
+	expected := [][]string{{"Title"}, {"Items"}, {"Items", "[]"}, {"Items", "[]", "Name"}, {"Items", "[]", "Owner"},
+		{"Items", "[]", "Owner", "Email"}, {"User"}, {"User", "ID"}}
+	if actual := fieldPaths(tpl); !reflect.DeepEqual(expected, actual) {
+		t.Errorf("expected %v, actual %v", expected, actual)
+	}
+}
+
+func TestSkeleton(t *testing.T) {
+	expected := map[string]interface{}{"Items": []interface{}{map[string]interface{}{"Name": "x"}}, "Title": "x"}
+	if actual := skeleton([][]string{{"Title"}, {"Items"}, {"Items", "[]", "Name"}}); !reflect.DeepEqual(expected, actual) {
+		t.Errorf("expected %v, actual %v", expected, actual)
+	}
+}
+
+func TestFuzz(t *testing.T) {
+	text := `{{range .Items}}{{.Name | len}}{{end}}`
+	data := (&App{}).createData(context.Background(), text, `{"Items": [{"Name": "a"}]}`, "", options{Fuzz: true})
+	if len(data.Errors) != 0 {
+		t.Fatalf("unexpected errors found: %v", data.Errors)
+	}
+	var shapes []string
+	for _, f := range data.Fuzz {
+		shapes = append(shapes, f.Path+" "+f.Shape)
+	}
+	expected := []string{".Items wrong type", ".Items[].Name missing", ".Items[].Name null", ".Items[].Name wrong type"}
+	if !reflect.DeepEqual(expected, shapes) {
+		t.Errorf("expected %v, actual %v (%v)", expected, shapes, data.Fuzz)
+	}
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	osExec "os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	// cloneTimeout is how long cloning a repository may take
+	cloneTimeout = time.Minute
+	// maxCloneSize is how much disk a clone may use, twice the templates of an archive for git's own files
+	maxCloneSize = 2 * maxArchiveSize
+	// diskUsageInterval is how often the disk a clone uses is checked
+	diskUsageInterval = 100 * time.Millisecond
+)
+
+// gitRepository is a directory of templates in a git repository
+type gitRepository struct {
+	URL string
+	// Ref is the branch or tag to clone, empty for the default branch
+	Ref string
+	// Dir is the subdirectory of the templates, empty for all of the repository
+	Dir string
+}
+
+// check makes sure the repository is cloned over HTTPS, as other transports can run commands or read the server's
+// files, from a host allowed, as the server could otherwise be made to clone from its own network
+func (g gitRepository) check(allows func(host string) bool) error {
+	u, err := url.Parse(g.URL)
+	if err != nil {
+		return err
+	}
+	if u.Scheme != "https" || u.Host == "" {
+		return fmt.Errorf("only https repository URLs can be cloned, not %q", g.URL)
+	}
+	if !allows(u.Hostname()) {
+		return fmt.Errorf("repositories can't be cloned from %s", u.Hostname())
+	}
+	if strings.HasPrefix(g.Ref, "-") {
+		return fmt.Errorf("bad ref %q", g.Ref)
+	}
+	if dir := path.Clean(strings.Trim(g.Dir, "/")); dir == ".." || strings.HasPrefix(dir, "../") {
+		return fmt.Errorf("directory %q is outside the repository", g.Dir)
+	}
+	return nil
+}
+
+// clone shallowly clones the repository from a host allowed and returns the template files in its directory, named by
+// their paths in it. Only the files of the directory are checked out, and cloning stops once it uses more than
+// maxCloneSize of disk.
+func (g gitRepository) clone(ctx context.Context, allows func(host string) bool) ([]source, error) {
+	if err := g.check(allows); err != nil {
+		return nil, err
+	}
+	tmp, err := os.MkdirTemp("", "go-template-validator-git")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmp)
+
+	ctx, cancel := context.WithTimeout(ctx, cloneTimeout)
+	defer cancel()
+	tooBig := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(diskUsageInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if diskUsage(tmp) > maxCloneSize {
+					close(tooBig)
+					cancel()
+					return
+				}
+			}
+		}
+	}()
+	git := func(args ...string) error {
+		// checking out fetches the blobs left out by the filter, so the protocols are restricted for every command, and
+		// redirects aren't followed, as a host allowed could redirect to one which isn't
+		args = append([]string{"-c", "protocol.allow=never", "-c", "protocol.https.allow=always",
+			"-c", "http.followRedirects=false", "-C", tmp}, args...)
+		cmd := osExec.CommandContext(ctx, "git", args...)
+		// nobody can answer prompts for credentials
+		cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+		if out, err := cmd.CombinedOutput(); err != nil {
+			select {
+			case <-tooBig:
+				return fmt.Errorf("cloning stopped: the repository is bigger than %d bytes", maxCloneSize)
+			default:
+			}
+			if ctx.Err() != nil {
+				return fmt.Errorf("cloning stopped: %v", ctx.Err())
+			}
+			return fmt.Errorf("cloning failed: %s", strings.TrimSpace(string(out)))
+		}
+		return nil
+	}
+
+	// the filter leaves out big blobs, which are only fetched if they are in the directory checked out
+	args := []string{"clone", "--quiet", "--depth", "1", "--single-branch", "--no-tags", "--no-checkout",
+		fmt.Sprintf("--filter=blob:limit=%d", maxArchiveFileSize)}
+	if g.Ref != "" {
+		args = append(args, "--branch", g.Ref)
+	}
+	if err := git(append(args, "--", g.URL, ".")...); err != nil {
+		return nil, err
+	}
+	if dir := strings.Trim(path.Clean("/"+g.Dir), "/"); dir != "" {
+		if err := git("sparse-checkout", "set", "--no-cone", "/"+dir+"/"); err != nil {
+			return nil, err
+		}
+	}
+	if err := git("checkout", "--quiet"); err != nil {
+		return nil, err
+	}
+
+	// the directory, or one it's in, could be a link to outside the repository
+	root, err := filepath.EvalSymlinks(tmp)
+	if err != nil {
+		return nil, err
+	}
+	dir, err := filepath.EvalSymlinks(filepath.Join(root, filepath.FromSlash(strings.Trim(g.Dir, "/"))))
+	if err != nil {
+		return nil, fmt.Errorf("directory %q isn't in the repository", g.Dir)
+	}
+	if dir != root && !strings.HasPrefix(dir, root+string(filepath.Separator)) {
+		return nil, fmt.Errorf("directory %q is outside the repository", g.Dir)
+	}
+	return readDirectory(dir)
+}
+
+// diskUsage returns the bytes of the files in dir, as far as it can read it
+func diskUsage(dir string) int64 {
+	var size int64
+	filepath.Walk(dir, func(_ string, info os.FileInfo, err error) error {
+		if err == nil && info.Mode().IsRegular() {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size
+}
+
+// readDirectory returns the template files in dir, named by their slash separated paths in it, with the limits of
+// archives. Hidden directories, like .git, are skipped.
+func readDirectory(dir string) ([]source, error) {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, errors.New("not a directory")
+	}
+	c := &sourceCollector{}
+	err = filepath.Walk(dir, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			if rel != "." && strings.HasPrefix(info.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		// links could point outside the directory
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		f, err := os.Open(p)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		return c.add(filepath.ToSlash(rel), f, info.Size())
+	})
+	if err != nil {
+		return nil, err
+	}
+	return c.result()
+}
+
+// ValidateGit validates the templates in the directory of a git repository as one set and serves the errors in each,
+// as JSON. The url, ref and dir form values are the repository.
+func (a *App) ValidateGit(w http.ResponseWriter, r *http.Request) {
+	repo := gitRepository{URL: r.FormValue("url"), Ref: r.FormValue("ref"), Dir: r.FormValue("dir")}
+	sources, err := repo.clone(r.Context(), a.fetcher.allows)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	rawData, err := getFormValue(r, "data")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	results := a.validateSet(r.Context(), sources, rawData, r.FormValue("functions"), getOptions(r))
+	if r.Context().Err() != nil {
+		return
+	}
+	writeJSON(w, results)
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestCheckGitRepository(t *testing.T) {
+	tests := []struct {
+		repo gitRepository
+		ok   bool
+	}{
+		{gitRepository{URL: "https://github.com/bingoohuang/go-template-validation"}, true},
+		{gitRepository{URL: "https://github.com/bingoohuang/go-template-validation", Ref: "main", Dir: "templates/"}, true},
+		{gitRepository{URL: "file:///etc"}, false},
+		{gitRepository{URL: "ext::sh -c touch% /tmp/pwned"}, false},
+		{gitRepository{URL: "/srv/repo.git"}, false},
+		{gitRepository{URL: "https://github.com/a/b", Ref: "--upload-pack=touch"}, false},
+		{gitRepository{URL: "https://github.com/a/b", Dir: "../.."}, false},
+		{gitRepository{URL: "https://169.254.169.254/latest"}, false},
+		{gitRepository{URL: "https://GitHub.com/a/b"}, true},
+	}
+	f := &fetcher{hosts: []string{"github.com"}}
+	for _, test := range tests {
+		if err := test.repo.check(f.allows); (err == nil) != test.ok {
+			t.Errorf("%+v: unexpected error %v", test.repo, err)
+		}
+	}
+}
+
+func TestDiskUsage(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, ".git", "objects"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	ioutil.WriteFile(filepath.Join(dir, "page.tmpl"), make([]byte, 100), 0644)
+	ioutil.WriteFile(filepath.Join(dir, ".git", "objects", "pack"), make([]byte, 1000), 0644)
+	if size := diskUsage(dir); size != 1100 {
+		t.Errorf("expected 1100 bytes, actual %d", size)
+	}
+}
+
+func TestReadDirectory(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"page.tmpl":             "{{.}}",
+		"partials/footer.tmpl":  "{{.Year}}",
+		".git/HEAD":             "ref: refs/heads/main",
+		"partials/.footer.swp":  "skipped",
+		"outside/../inside.txt": "hi",
+	}
+	for name, text := range files {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(p, []byte(text), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink("/etc/passwd", filepath.Join(dir, "passwd")); err != nil {
+		t.Fatal(err)
+	}
+
+	sources, err := readDirectory(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []source{
+		{name: "inside.txt", text: "hi"},
+		{name: "page.tmpl", text: "{{.}}"},
+		{name: "partials/footer.tmpl", text: "{{.Year}}"},
+	}
+	if !reflect.DeepEqual(expected, sources) {
+		t.Errorf("expected %v, actual %v", expected, sources)
+	}
+}
@@ -1,5 +1,27 @@
 module go-template-validator
 
-go 1.16
+go 1.21
 
-require github.com/go-chi/chi v1.5.4
+require (
+	cuelang.org/go v0.9.2
+	github.com/go-chi/chi v1.5.4
+	github.com/hashicorp/hcl/v2 v2.21.0
+	github.com/skip2/go-qrcode v0.0.0-20200617195104-da1b6568686e
+	github.com/traefik/yaegi v0.16.1
+	github.com/zclconf/go-cty v1.13.0
+	golang.org/x/net v0.25.0
+)
+
+require (
+	github.com/agext/levenshtein v1.2.1 // indirect
+	github.com/apparentlymart/go-textseg/v13 v13.0.0 // indirect
+	github.com/apparentlymart/go-textseg/v15 v15.0.0 // indirect
+	github.com/cockroachdb/apd/v3 v3.2.1 // indirect
+	github.com/google/uuid v1.6.0 // indirect
+	github.com/mitchellh/go-wordwrap v1.0.1 // indirect
+	golang.org/x/mod v0.17.0 // indirect
+	golang.org/x/sync v0.7.0 // indirect
+	golang.org/x/text v0.15.0 // indirect
+	golang.org/x/tools v0.21.0 // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
+)
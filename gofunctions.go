@@ -0,0 +1,164 @@
+package main
+
+import (
+	"context"
+	"embed"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/scanner"
+	goToken "go/token"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/traefik/yaegi/interp"
+	"github.com/traefik/yaegi/stdlib"
+)
+
+// goFunctionsTimeout is how long the top level of Go functions' source may take to evaluate. The interpreter can't stop
+// code that never returns, it's only abandoned.
+const goFunctionsTimeout = time.Second
+
+// goPackages are the standard packages Go functions can import, which can't reach the filesystem, network or processes
+var goPackages = []string{
+	"bytes", "encoding/base64", "encoding/hex", "encoding/json", "errors", "fmt", "html", "math", "math/rand", "net/url",
+	"path", "regexp", "sort", "strconv", "strings", "time", "unicode", "unicode/utf8",
+}
+
+// errGoFunctionsDisabled is returned for Go functions when the server doesn't interpret them
+var errGoFunctionsDisabled = errors.New("Go functions aren't enabled, start the server with -go-functions")
+
+// goFunctions interprets src, Go function declarations like `func add(a, b int) int { return a + b }` or variables
+// like `add := func(a, b int) int { return a + b }`, and returns the functions it declares so templates can call the
+// real thing instead of a mock
+func goFunctions(ctx context.Context, src string) (template.FuncMap, error) {
+	names, err := declaredNames(src)
+	if err != nil {
+		return nil, err
+	}
+
+	// an empty source filesystem, so only the allowed standard packages can be imported
+	i := interp.New(interp.Options{SourcecodeFilesystem: embed.FS{}, Stdout: io.Discard, Stderr: io.Discard})
+	symbols := make(interp.Exports)
+	for key, values := range stdlib.Symbols {
+		// keys are the package path followed by its name
+		slash := strings.LastIndex(key, "/")
+		for _, allowed := range goPackages {
+			if slash != -1 && key[:slash] == allowed {
+				symbols[key] = values
+			}
+		}
+	}
+	if err := i.Use(symbols); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, goFunctionsTimeout)
+	defer cancel()
+	if _, err := i.EvalWithContext(ctx, src); err != nil {
+		return nil, err
+	}
+
+	fns := make(template.FuncMap, len(names))
+	for _, name := range names {
+		if name == "_" {
+			continue
+		}
+		v, err := i.Eval(name)
+		if err != nil {
+			return nil, err
+		}
+		if v.Kind() != reflect.Func {
+			continue
+		}
+		if err := checkFunctionResults(name, v.Type()); err != nil {
+			return nil, err
+		}
+		fns[name] = v.Interface()
+	}
+	return fns, nil
+}
+
+// interpretFunctions returns the functions src declares, if the server interprets Go functions
+func (a *App) interpretFunctions(ctx context.Context, src string) (template.FuncMap, error) {
+	if !a.interpretGo {
+		return nil, errGoFunctionsDisabled
+	}
+	return goFunctions(ctx, src)
+}
+
+// declaredNames returns the names src declares at its top level, sorted. It's either a file without a package clause,
+// or statements.
+func declaredNames(src string) ([]string, error) {
+	var names []string
+	fset := goToken.NewFileSet()
+	f, err := parser.ParseFile(fset, "", "package main\n"+src, 0)
+	if err == nil {
+		for _, decl := range f.Decls {
+			switch d := decl.(type) {
+			case *ast.FuncDecl:
+				if d.Recv == nil {
+					names = append(names, d.Name.Name)
+				}
+			case *ast.GenDecl:
+				names = append(names, specNames(d)...)
+			}
+		}
+		sort.Strings(names)
+		return names, nil
+	}
+
+	f, stmtErr := parser.ParseFile(fset, "", "package main\nfunc _() {\n"+src+"\n}", 0)
+	if stmtErr != nil {
+		// the error as declarations, on the lines of the source as written
+		if list, ok := err.(scanner.ErrorList); ok && len(list) > 0 {
+			return nil, fmt.Errorf("%d:%d: %s", list[0].Pos.Line-1, list[0].Pos.Column, list[0].Msg)
+		}
+		return nil, err
+	}
+	for _, stmt := range f.Decls[0].(*ast.FuncDecl).Body.List {
+		switch s := stmt.(type) {
+		case *ast.AssignStmt:
+			if s.Tok == goToken.DEFINE {
+				for _, lhs := range s.Lhs {
+					if ident, ok := lhs.(*ast.Ident); ok {
+						names = append(names, ident.Name)
+					}
+				}
+			}
+		case *ast.DeclStmt:
+			if d, ok := s.Decl.(*ast.GenDecl); ok {
+				names = append(names, specNames(d)...)
+			}
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// specNames returns the names of the variables a declaration declares
+func specNames(d *ast.GenDecl) []string {
+	var names []string
+	for _, spec := range d.Specs {
+		if v, ok := spec.(*ast.ValueSpec); ok && d.Tok == goToken.VAR {
+			for _, ident := range v.Names {
+				names = append(names, ident.Name)
+			}
+		}
+	}
+	return names
+}
+
+// checkFunctionResults checks a function returns what templates can call, which Funcs panics about otherwise
+func checkFunctionResults(name string, t reflect.Type) error {
+	errorType := reflect.TypeOf((*error)(nil)).Elem()
+	if t.NumOut() == 1 || t.NumOut() == 2 && t.Out(1) == errorType {
+		return nil
+	}
+	return fmt.Errorf("function %q must return one value, or a value and an error", name)
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestGoFunctions(t *testing.T) {
+	tests := map[string]string{
+		"declarations": `import "strings"
+
+func add(a, b int) int { return a + b }
+
+var shout = func(s string) string { return strings.ToUpper(s) + "!" }`,
+		"statements": `add := func(a, b int) int { return a + b }
+shout := func(s string) string { return s + "!" }`,
+	}
+	for name, src := range tests {
+		fns, err := goFunctions(context.Background(), src)
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		if sum := reflect.ValueOf(fns["add"]).Call([]reflect.Value{reflect.ValueOf(1), reflect.ValueOf(2)}); sum[0].Int() != 3 {
+			t.Errorf("%s: expected add to return 3, actual %v", name, sum[0])
+		}
+	}
+}
+
+func TestGoFunctionsErrors(t *testing.T) {
+	tests := map[string]string{
+		`import "os"
+
+func rm() error { return os.RemoveAll("/") }`: "unable to find source related to",
+		`func two() (int, int) { return 1, 2 }`: `function "two" must return one value, or a value and an error`,
+		"func broken( {":                        "1:14:",
+	}
+	for src, expected := range tests {
+		if _, err := goFunctions(context.Background(), src); err == nil || !strings.Contains(err.Error(), expected) {
+			t.Errorf("%q: expected an error containing %q, actual %v", src, expected, err)
+		}
+	}
+}
+
+func TestCreateDataGoFunctions(t *testing.T) {
+	opts := options{GoFunctions: "func add(a, b int) int { return a + b }"}
+	data := (&App{interpretGo: true}).createData(context.Background(), "{{add 1 2}}", "", "", opts)
+	if len(data.Errors) != 0 || data.Output != "3" {
+		t.Errorf("unexpected output %q and errors %+v", data.Output, data.Errors)
+	}
+
+	data = (&App{}).createData(context.Background(), "{{add 1 2}}", "", "add", opts)
+	if len(data.Errors) != 1 || data.Errors[0].Description != "failed to understand Go functions: "+errGoFunctionsDisabled.Error() {
+		t.Errorf("unexpected errors %+v", data.Errors)
+	}
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+	"text/template"
+	templateParse "text/template/parse"
+)
+
+const (
+	// oldestGoVersion is the minor version of the oldest Go templates can be validated for, the last without
+	// assignments to variables
+	oldestGoVersion = 10
+	// the minor versions of Go which changed what templates can do
+	assignVersion       = 11
+	multilineVersion    = 16
+	breakVersion        = 18
+	shortCircuitVersion = 18
+	elseWithVersion     = 23
+	rangeIntVersion     = 24
+
+	// goVersionFunction is added to the end of the pipeline of every range of a template validated for a Go before
+	// rangeIntVersion, to fail on what that Go can't iterate over like it would
+	goVersionFunction = "_goVersion"
+)
+
+var (
+	goVersionRegex = regexp.MustCompile(`^(?:go)?1\.(\d+)(?:\.\d+)?$`)
+	// goVersionErrorRegex matches what text/template says around an error of goVersionFunction
+	goVersionErrorRegex = regexp.MustCompile(`at <` + goVersionFunction + ` "\d+">: error calling ` +
+		goVersionFunction + `: `)
+)
+
+// currentGoVersion is the minor version of the Go the validator is built with, whose templates it validates
+var currentGoVersion = func() int {
+	if matches := regexp.MustCompile(`go1\.(\d+)`).FindStringSubmatch(runtime.Version()); matches != nil {
+		if minor, err := strconv.Atoi(matches[1]); err == nil {
+			return minor
+		}
+	}
+	return rangeIntVersion
+}()
+
+// parseGoVersion returns the minor version of a Go version like 1.17 or go1.17.3, the current one if it's empty
+func parseGoVersion(version string) (int, error) {
+	if version == "" {
+		return currentGoVersion, nil
+	}
+	matches := goVersionRegex.FindStringSubmatch(strings.TrimSpace(version))
+	if matches == nil {
+		return currentGoVersion, fmt.Errorf("%q isn't a Go version like 1.17", version)
+	}
+	minor, _ := strconv.Atoi(matches[1])
+	if minor < oldestGoVersion || minor > currentGoVersion {
+		return currentGoVersion, fmt.Errorf("go1.%d isn't between go1.%d and go1.%d", minor, oldestGoVersion,
+			currentGoVersion)
+	}
+	return minor, nil
+}
+
+// goVersions returns the versions templates can be validated for, from the newest
+func goVersions() []string {
+	versions := make([]string, 0, currentGoVersion-oldestGoVersion+1)
+	for minor := currentGoVersion; minor >= oldestGoVersion; minor-- {
+		versions = append(versions, fmt.Sprintf("1.%d", minor))
+	}
+	return versions
+}
+
+// goVersionSyntaxErrors scans text, which is parsed as the template named name, for the syntax the Go of the minor
+// version can't parse yet, as it would fail to parse the template
+func goVersionSyntaxErrors(name, text string, minor int) []templateError {
+	text, _ = blankFrontMatter(text)
+	var tplErrs []templateError
+	errorAt := func(offset int, syntax string, since int) {
+		tplErrs = append(tplErrs, templateError{File: name, Line: lineOf(text, offset), Char: columnOf(text, offset),
+			Description: fmt.Sprintf("%s needs go1.%d, go1.%d fails to parse it", syntax, since, minor),
+			Level:       parseErrorLevel})
+	}
+
+	inAction := false
+	// previous is the keyword before the current token of the action, if it's the first one
+	previous := ""
+	for _, tok := range tokenize(text) {
+		word := text[tok.Start:tok.End]
+		switch tok.Type {
+		case leftDelimToken:
+			inAction, previous = true, ""
+			continue
+		case rightDelimToken:
+			inAction = false
+			continue
+		case spaceToken:
+			if inAction && strings.Contains(word, "\n") && minor < multilineVersion {
+				errorAt(tok.Start, "a newline inside an action", multilineVersion)
+			}
+			continue
+		case keywordToken:
+			switch {
+			case (word == "break" || word == "continue") && minor < breakVersion:
+				errorAt(tok.Start, "{{"+word+"}}", breakVersion)
+			case word == "with" && previous == "else" && minor < elseWithVersion:
+				errorAt(tok.Start, "{{else with}}", elseWithVersion)
+			}
+		case assignToken:
+			if minor < assignVersion {
+				errorAt(tok.Start, "assigning to a variable", assignVersion)
+			}
+		}
+		previous = ""
+		if tok.Type == keywordToken {
+			previous = word
+		}
+	}
+	return tplErrs
+}
+
+// goVersionFunctions returns the functions which replace the builtin ones for templates executed like the Go of the
+// minor version executes them: before shortCircuitVersion, and and or evaluate all of their arguments
+func goVersionFunctions(minor int) template.FuncMap {
+	if minor >= shortCircuitVersion {
+		return nil
+	}
+	return template.FuncMap{
+		"and": func(arg0 interface{}, args ...interface{}) interface{} {
+			for _, arg := range args {
+				if truth, _ := template.IsTrue(arg0); !truth {
+					break
+				}
+				arg0 = arg
+			}
+			return arg0
+		},
+		"or": func(arg0 interface{}, args ...interface{}) interface{} {
+			for _, arg := range args {
+				if truth, _ := template.IsTrue(arg0); truth {
+					break
+				}
+				arg0 = arg
+			}
+			return arg0
+		},
+	}
+}
+
+// goVersionTemplate returns t, or a copy of it whose ranges fail on integers and functions, which the Go of the minor
+// version can't iterate over
+func goVersionTemplate(t *template.Template, minor int) (*template.Template, error) {
+	if minor >= rangeIntVersion {
+		return t, nil
+	}
+	t, err := copyTemplate(t)
+	if err != nil {
+		return nil, err
+	}
+	for _, tree := range trees(t) {
+		walk(tree.Root, func(node templateParse.Node, _ []templateParse.Node) bool {
+			if n, ok := node.(*templateParse.RangeNode); ok {
+				call := callAt(tree, n, goVersionFunction, checkRange, strconv.Itoa(minor)).(*templateParse.ActionNode)
+				n.Pipe.Cmds = append(n.Pipe.Cmds, call.Pipe.Cmds...)
+			}
+			return true
+		})
+	}
+	return t.Funcs(template.FuncMap{goVersionFunction: checkRange}), nil
+}
+
+// checkRange is goVersionFunction, it returns v unless the Go of the minor version can't range over it
+func checkRange(minor string, v interface{}) (interface{}, error) {
+	switch reflect.ValueOf(v).Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64, reflect.Uint, reflect.Uint8,
+		reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr, reflect.Func:
+		return nil, fmt.Errorf("range can't iterate over %v in go1.%s, only since go1.%d", v, minor, rangeIntVersion)
+	}
+	return v, nil
+}
+
+// describeGoVersionErrors removes what text/template says about goVersionFunction from the errors of ranges it failed
+func describeGoVersionErrors(tplErrs []templateError) {
+	for i := range tplErrs {
+		tplErrs[i].Description = goVersionErrorRegex.ReplaceAllString(tplErrs[i].Description, "at <range>: ")
+	}
+}
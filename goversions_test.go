@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestParseGoVersion(t *testing.T) {
+	for version, expected := range map[string]int{"": currentGoVersion, "1.17": 17, "go1.17.3": 17, " 1.10 ": 10} {
+		if minor, err := parseGoVersion(version); err != nil || minor != expected {
+			t.Errorf("%q: expected %d, actual %d, error %v", version, expected, minor, err)
+		}
+	}
+	for _, version := range []string{"1.9", "2.0", "1.x", "1.100"} {
+		if _, err := parseGoVersion(version); err == nil {
+			t.Errorf("%q: expected an error", version)
+		}
+	}
+}
+
+func TestGoVersionSyntaxErrors(t *testing.T) {
+	text := "{{range .}}{{break}}{{end}}\n{{with .A}}a{{else with .B}}b{{end}}{{$x := 1}}{{$x = 2}}{{/*\n*/}}{{.A\n.B}}"
+	var actual []string
+	for _, tplErr := range goVersionSyntaxErrors("input", text, 10) {
+		actual = append(actual, fmt.Sprintf("%d:%d %s", tplErr.Line, tplErr.Char, tplErr.Description))
+	}
+	expected := []string{
+		"0:13 {{break}} needs go1.18, go1.10 fails to parse it",
+		"1:19 {{else with}} needs go1.23, go1.10 fails to parse it",
+		"1:52 assigning to a variable needs go1.11, go1.10 fails to parse it",
+		"2:8 a newline inside an action needs go1.16, go1.10 fails to parse it",
+	}
+	if strings.Join(actual, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("expected\n%s\nactual\n%s", strings.Join(expected, "\n"), strings.Join(actual, "\n"))
+	}
+
+	if tplErrs := goVersionSyntaxErrors("input", text, 23); len(tplErrs) != 0 {
+		t.Errorf("unexpected errors %+v", tplErrs)
+	}
+}
+
+func TestCreateDataGoVersion(t *testing.T) {
+	data := (&App{}).createData(context.Background(), "{{range .}}{{continue}}{{end}}", "[1]", "",
+		options{GoVersion: "1.17"})
+	if len(data.Errors) != 1 || data.Errors[0].Description != "{{continue}} needs go1.18, go1.17 fails to parse it" {
+		t.Errorf("unexpected errors %+v", data.Errors)
+	}
+
+	// before go1.18, and evaluates all of its arguments
+	text := "{{if and .User .User.Name}}{{.User.Name}}{{end}}"
+	data = (&App{}).createData(context.Background(), text, `{"User": null}`, "", options{})
+	if len(data.Errors) != 0 {
+		t.Errorf("unexpected errors %+v", data.Errors)
+	}
+	data = (&App{}).createData(context.Background(), text, `{"User": null}`, "", options{GoVersion: "1.17"})
+	if len(data.Errors) != 1 || !strings.Contains(data.Errors[0].Description, "nil pointer evaluating") {
+		t.Errorf("unexpected errors %+v", data.Errors)
+	}
+
+	data = (&App{}).createData(context.Background(), "{{range 3}}{{.}}{{end}}", "", "", options{GoVersion: "1.23"})
+	expected := "range can't iterate over 3 in go1.23, only since go1.24"
+	if len(data.Errors) != 1 || !strings.HasSuffix(data.Errors[0].Description, "at <range>: "+expected) {
+		t.Errorf("unexpected errors %+v", data.Errors)
+	}
+	if data.Errors[0].Line != 0 || data.Errors[0].Char != 8 {
+		t.Errorf("unexpected location %d:%d", data.Errors[0].Line, data.Errors[0].Char)
+	}
+
+	data = (&App{}).createData(context.Background(), "{{.}}", "", "", options{GoVersion: "1.5"})
+	expected = "failed to understand the Go version: go1.5 isn't between go1.10 and go1."
+	if len(data.Errors) != 1 || !strings.HasPrefix(data.Errors[0].Description, expected) {
+		t.Errorf("unexpected errors %+v", data.Errors)
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"net/http"
+	"sort"
+)
+
+// grammar describes the syntax of templates for editors
+type grammar struct {
+	Delimiters delimiters `json:"delimiters"`
+	// Keywords are the identifiers reserved for actions
+	Keywords []string    `json:"keywords"`
+	Actions  []actionDoc `json:"actions"`
+	Snippets []snippet   `json:"snippets"`
+}
+
+// delimiters are the markers around actions and comments
+type delimiters struct {
+	Left  string `json:"left"`
+	Right string `json:"right"`
+	// TrimLeft and TrimRight are the delimiters which trim the whitespace before and after the action
+	TrimLeft     string `json:"trimLeft"`
+	TrimRight    string `json:"trimRight"`
+	CommentStart string `json:"commentStart"`
+	CommentEnd   string `json:"commentEnd"`
+}
+
+// actionDoc documents a kind of action
+type actionDoc struct {
+	// Keyword is the keyword it starts with, empty for a pipeline
+	Keyword     string `json:"keyword"`
+	Syntax      string `json:"syntax"`
+	Description string `json:"description"`
+	// Block is whether it opens a block closed by {{end}}
+	Block bool `json:"block"`
+	// Within are the blocks it's only allowed in, by keyword
+	Within []string `json:"within,omitempty"`
+}
+
+// snippet is a template for an action to insert, with placeholders in the syntax of LSP and VS Code snippets
+type snippet struct {
+	Name        string `json:"name"`
+	Prefix      string `json:"prefix"`
+	Body        string `json:"body"`
+	Description string `json:"description"`
+}
+
+// actionDocs document the actions of text/template
+var actionDocs = []actionDoc{
+	{"", "{{pipeline}}", "The default textual representation of the value of the pipeline is copied to the output.", false, nil},
+	{"if", "{{if pipeline}} T1 {{end}}", "If the value of the pipeline is empty, no output is generated, otherwise T1 is executed.", true, nil},
+	{"else", "{{if pipeline}} T1 {{else}} T0 {{end}}", "T0 is executed instead when the value of the pipeline is empty. {{else if}} and {{else with}} chain another condition.", false, []string{"if", "range", "with"}},
+	{"range", "{{range pipeline}} T1 {{end}}", "The value of the pipeline must be an array, slice, map, channel, integer or iterator function. T1 is executed for each element with dot set to it, and {{else}} if there are none.", true, nil},
+	{"break", "{{break}}", "The innermost {{range}} stops early.", false, []string{"range"}},
+	{"continue", "{{continue}}", "The current iteration of the innermost {{range}} stops early.", false, []string{"range"}},
+	{"with", "{{with pipeline}} T1 {{end}}", "If the value of the pipeline is empty, no output is generated, otherwise dot is set to it and T1 is executed.", true, nil},
+	{"define", `{{define "name"}} T1 {{end}}`, "Defines the template named name, which isn't executed where it's defined.", true, nil},
+	{"template", `{{template "name" pipeline}}`, "The template named name is executed with dot set to the value of the pipeline, or nil if there is none.", false, nil},
+	{"block", `{{block "name" pipeline}} T1 {{end}}`, "Defines the template named name and executes it in place, shorthand for {{define}} followed by {{template}}. Other templates can redefine it.", true, nil},
+	{"end", "{{end}}", "Closes the innermost block.", false, []string{"if", "range", "with", "define", "block"}},
+}
+
+// snippets are canonical forms of the actions
+var snippets = []snippet{
+	{"if", "if", "{{if ${1:pipeline}}}$0{{end}}", "Execute when the pipeline isn't empty"},
+	{"if else", "ifelse", "{{if ${1:pipeline}}}$2{{else}}$0{{end}}", "Execute one of two branches"},
+	{"range", "range", "{{range ${1:pipeline}}}$0{{end}}", "Execute for each element"},
+	{"range with index", "rangei", "{{range \\$${1:i}, \\$${2:item} := ${3:pipeline}}}$0{{end}}", "Execute for each element, with its index and value"},
+	{"range else", "rangeelse", "{{range ${1:pipeline}}}$2{{else}}$0{{end}}", "Execute for each element, or once if there are none"},
+	{"with", "with", "{{with ${1:pipeline}}}$0{{end}}", "Execute with dot set to the pipeline if it isn't empty"},
+	{"define", "define", "{{define \"${1:name}\"}}$0{{end}}", "Define a named template"},
+	{"template", "template", "{{template \"${1:name}\" ${2:.}}}", "Execute a named template"},
+	{"block", "block", "{{block \"${1:name}\" ${2:.}}}$0{{end}}", "Define a named template and execute it in place"},
+	{"variable", "var", "{{\\$${1:name} := ${2:pipeline}}}", "Declare a variable"},
+	{"comment", "comment", "{{/* $0 */}}", "A comment, which produces no output"},
+	{"trimmed", "trim", "{{- ${1:pipeline} -}}", "An action trimming the whitespace around it"},
+}
+
+// templateGrammar returns the grammar of templates
+func templateGrammar() grammar {
+	names := make([]string, 0, len(keywords))
+	for keyword := range keywords {
+		names = append(names, keyword)
+	}
+	sort.Strings(names)
+	return grammar{
+		Delimiters: delimiters{Left: "{{", Right: "}}", TrimLeft: "{{- ", TrimRight: " -}}", CommentStart: "/*",
+			CommentEnd: "*/"},
+		Keywords: names,
+		Actions:  actionDocs,
+		Snippets: snippets,
+	}
+}
+
+// Grammar serves the grammar of templates, with snippets, as JSON
+func (a *App) Grammar(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, templateGrammar())
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+	"text/template"
+)
+
+func TestGrammarKeywords(t *testing.T) {
+	documented := make(map[string]bool)
+	for _, doc := range actionDocs {
+		documented[doc.Keyword] = true
+	}
+	for _, keyword := range templateGrammar().Keywords {
+		if !documented[keyword] {
+			t.Errorf("keyword %s isn't documented", keyword)
+		}
+	}
+}
+
+func TestSnippetsParse(t *testing.T) {
+	placeholderRegex := regexp.MustCompile(`\$\{\d+:([^}]*)\}|\$\d+`)
+	for _, s := range snippets {
+		body := placeholderRegex.ReplaceAllString(s.Body, "$1")
+		body = strings.ReplaceAll(body, `\$`, "$")
+		tpl := template.New("t").Funcs(template.FuncMap{"pipeline": mockFunction})
+		if _, err := tpl.Parse(body); err != nil {
+			t.Errorf("snippet %s expands to %q, which doesn't parse: %v", s.Name, body, err)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"text/template"
+	templateParse "text/template/parse"
+)
+
+// dependencyGraph is which templates of a set include which with {{template}} and {{block}}
+type dependencyGraph struct {
+	// Templates are the names of the defined templates, sorted
+	Templates []string    `json:"templates"`
+	Edges     []graphEdge `json:"edges"`
+}
+
+// graphEdge is From including To, which may not be defined
+type graphEdge struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+	// Calls is how many times From includes To
+	Calls int `json:"calls"`
+}
+
+// templateGraph returns the dependency graph of the templates in t
+func templateGraph(t *template.Template) dependencyGraph {
+	g := dependencyGraph{Templates: make([]string, 0), Edges: make([]graphEdge, 0)}
+	calls := make(map[graphEdge]int)
+	for _, tree := range trees(t) {
+		g.Templates = append(g.Templates, tree.Name)
+		walk(tree.Root, func(node templateParse.Node, _ []templateParse.Node) bool {
+			if n, ok := node.(*templateParse.TemplateNode); ok {
+				calls[graphEdge{From: tree.Name, To: n.Name}]++
+			}
+			return true
+		})
+	}
+	for edge, n := range calls {
+		edge.Calls = n
+		g.Edges = append(g.Edges, edge)
+	}
+	sort.Strings(g.Templates)
+	sort.Slice(g.Edges, func(i, j int) bool {
+		if g.Edges[i].From != g.Edges[j].From {
+			return g.Edges[i].From < g.Edges[j].From
+		}
+		return g.Edges[i].To < g.Edges[j].To
+	})
+	return g
+}
+
+// defined is whether the template named name is in the graph
+func (g dependencyGraph) defined(name string) bool {
+	i := sort.SearchStrings(g.Templates, name)
+	return i < len(g.Templates) && g.Templates[i] == name
+}
+
+// DOT formats the graph in the Graphviz DOT language, with templates that aren't defined dashed
+func (g dependencyGraph) DOT() string {
+	var b strings.Builder
+	b.WriteString("digraph templates {\n")
+	for _, name := range g.Templates {
+		fmt.Fprintf(&b, "\t%q;\n", name)
+	}
+	undefined := make(map[string]bool)
+	for _, edge := range g.Edges {
+		if !g.defined(edge.To) && !undefined[edge.To] {
+			undefined[edge.To] = true
+			fmt.Fprintf(&b, "\t%q [style=dashed];\n", edge.To)
+		}
+	}
+	for _, edge := range g.Edges {
+		if edge.Calls > 1 {
+			fmt.Fprintf(&b, "\t%q -> %q [label=%d];\n", edge.From, edge.To, edge.Calls)
+		} else {
+			fmt.Fprintf(&b, "\t%q -> %q;\n", edge.From, edge.To)
+		}
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
+
+// Graph serves the dependency graph of the templates in the uploaded archive, or the template form value, as JSON or,
+// with the format form value dot, Graphviz DOT
+func (a *App) Graph(w http.ResponseWriter, r *http.Request) {
+	presetFns, err := a.presetFunctions(r.FormValue("function-preset"))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed to understand the function preset: %v", err), http.StatusBadRequest)
+		return
+	}
+	t, _ := newSetTemplate(inputTemplateName, presetFns, r.FormValue("functions"), "")
+	sources, err := getArchive(r)
+	switch {
+	case err == http.ErrMissingFile:
+		text, err := getFormValue(r, "template")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		parse(r.Context(), text, t)
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	default:
+		parseSet(r.Context(), sources, t)
+	}
+
+	g := templateGraph(t)
+	if r.FormValue("format") == "dot" {
+		w.Header().Set("Content-Type", "text/vnd.graphviz")
+		fmt.Fprint(w, g.DOT())
+		return
+	}
+	writeJSON(w, g)
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const graphText = `{{define "layout"}}{{template "header" .}}{{block "content" .}}{{end}}{{template "footer"}}{{end}}
+{{define "header"}}{{template "nav"}}{{template "nav"}}{{end}}
+{{define "footer"}}{{end}}`
+
+func TestTemplateGraph(t *testing.T) {
+	expected := dependencyGraph{
+		Templates: []string{"base", "content", "footer", "header", "layout"},
+		Edges: []graphEdge{
+			{From: "header", To: "nav", Calls: 2},
+			{From: "layout", To: "content", Calls: 1},
+			{From: "layout", To: "footer", Calls: 1},
+			{From: "layout", To: "header", Calls: 1},
+		},
+	}
+	if actual := templateGraph(mustParse(t, graphText)); !reflect.DeepEqual(expected, actual) {
+		t.Errorf("expected %+v, actual %+v", expected, actual)
+	}
+}
+
+func TestGraphDOT(t *testing.T) {
+	g := dependencyGraph{
+		Templates: []string{"footer", "layout"},
+		Edges:     []graphEdge{{From: "layout", To: "footer", Calls: 2}, {From: "layout", To: "nav", Calls: 1}},
+	}
+	expected := `digraph templates {
+	"footer";
+	"layout";
+	"nav" [style=dashed];
+	"layout" -> "footer" [label=2];
+	"layout" -> "nav";
+}
+`
+	if actual := g.DOT(); actual != expected {
+		t.Errorf("expected %s, actual %s", expected, actual)
+	}
+}
+
+func TestGraph(t *testing.T) {
+	form := url.Values{"template": {`{{define "a"}}{{template "b"}}{{end}}`}, "format": {"dot"}}
+	r := httptest.NewRequest("POST", "/api/v1/graph", strings.NewReader(form.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+	(&App{}).Graph(w, r)
+	if !strings.Contains(w.Body.String(), `"a" -> "b";`) {
+		t.Errorf("unexpected graph %s", w.Body)
+	}
+
+	form.Del("format")
+	r = httptest.NewRequest("POST", "/api/v1/graph", strings.NewReader(form.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w = httptest.NewRecorder()
+	(&App{}).Graph(w, r)
+	var g dependencyGraph
+	if err := json.NewDecoder(w.Body).Decode(&g); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(g.Edges, []graphEdge{{From: "a", To: "b", Calls: 1}}) {
+		t.Errorf("unexpected graph %+v", g)
+	}
+}
+
+func TestCreateDataGraph(t *testing.T) {
+	data := (&App{}).createData(context.Background(), graphText, "", "", options{})
+	if data.Graph == nil || len(data.Graph.Edges) != 4 {
+		t.Errorf("unexpected graph %+v", data.Graph)
+	}
+}
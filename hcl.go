@@ -0,0 +1,102 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	ctyJSON "github.com/zclconf/go-cty/cty/json"
+)
+
+// decodeHCL decodes the HCL raw, like Terraform variable files or Nomad job specifications, within maxDataSize. Its
+// attributes are fields, and its blocks objects of the fields of their bodies, in fields named by their types and then
+// their labels, like variable "region" {} is .variable.region. Blocks of the same names are an array in their field.
+// Values can't refer to variables or call functions, since there's nothing to evaluate them with.
+func decodeHCL(raw string) (interface{}, error) {
+	if len(raw) > maxDataSize {
+		return nil, fmt.Errorf("data is bigger than %d bytes", maxDataSize)
+	}
+	file, diags := hclsyntax.ParseConfig([]byte(raw), dataFile, hcl.InitialPos)
+	if diags.HasErrors() {
+		return nil, hclDataErrors(diags)
+	}
+	data, diags := hclObject(file.Body.(*hclsyntax.Body))
+	if diags.HasErrors() {
+		return nil, hclDataErrors(diags)
+	}
+	return data, nil
+}
+
+// hclObject returns the fields of body, in the order they're in it
+func hclObject(body *hclsyntax.Body) (map[string]interface{}, hcl.Diagnostics) {
+	object := make(map[string]interface{}, len(body.Attributes)+len(body.Blocks))
+	var diags hcl.Diagnostics
+	attrs := make([]*hclsyntax.Attribute, 0, len(body.Attributes))
+	for _, attr := range body.Attributes {
+		attrs = append(attrs, attr)
+	}
+	sort.Slice(attrs, func(i, j int) bool { return attrs[i].SrcRange.Start.Byte < attrs[j].SrcRange.Start.Byte })
+	for _, attr := range attrs {
+		v, valueDiags := attr.Expr.Value(nil)
+		if diags = append(diags, valueDiags...); valueDiags.HasErrors() {
+			continue
+		}
+		// JSON has every kind of value HCL has, and is what the data is like
+		b, err := ctyJSON.Marshal(v, v.Type())
+		if err != nil {
+			diags = append(diags, &hcl.Diagnostic{Severity: hcl.DiagError, Summary: err.Error(),
+				Subject: attr.Expr.Range().Ptr()})
+			continue
+		}
+		value, err := decodeData(string(b))
+		if err != nil {
+			return nil, append(diags, &hcl.Diagnostic{Severity: hcl.DiagError, Summary: err.Error(),
+				Subject: attr.Expr.Range().Ptr()})
+		}
+		object[attr.Name] = value
+	}
+
+	for _, block := range body.Blocks {
+		value, blockDiags := hclObject(block.Body)
+		diags = append(diags, blockDiags...)
+		parent, key := object, block.Type
+		for _, label := range block.Labels {
+			child, ok := parent[key].(map[string]interface{})
+			if !ok {
+				child = make(map[string]interface{})
+				parent[key] = child
+			}
+			parent, key = child, label
+		}
+		switch existing := parent[key].(type) {
+		case []interface{}:
+			parent[key] = append(existing, value)
+		case map[string]interface{}:
+			parent[key] = []interface{}{existing, value}
+		default:
+			parent[key] = value
+		}
+	}
+	return object, diags
+}
+
+// hclDataErrors returns the errors of diags, at their positions in the data
+func hclDataErrors(diags hcl.Diagnostics) error {
+	var list dataErrors
+	for _, diag := range diags {
+		if diag.Severity != hcl.DiagError {
+			continue
+		}
+		message := diag.Summary
+		if diag.Detail != "" {
+			message += "; " + diag.Detail
+		}
+		offset := -1
+		if diag.Subject != nil {
+			offset = diag.Subject.Start.Byte
+		}
+		list = append(list, &dataError{offset: offset, err: fmt.Errorf("%s", message)})
+	}
+	return list
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDecodeHCL(t *testing.T) {
+	data, err := decodeHCL(`
+region = "eu-west-1"
+tags   = { team = "infra", cost = 3 }
+zones  = ["a", "b"]
+
+variable "size" {
+  default = 2
+}
+
+variable "name" {}
+
+service {
+  port = 80
+}
+
+service {
+  port = 443
+}
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]interface{}{
+		"region": "eu-west-1",
+		"tags":   map[string]interface{}{"team": "infra", "cost": 3.0},
+		"zones":  []interface{}{"a", "b"},
+		"variable": map[string]interface{}{
+			"size": map[string]interface{}{"default": 2.0},
+			"name": map[string]interface{}{},
+		},
+		"service": []interface{}{
+			map[string]interface{}{"port": 80.0},
+			map[string]interface{}{"port": 443.0},
+		},
+	}
+	if !reflect.DeepEqual(data, expected) {
+		t.Errorf("expected %v, got %v", expected, data)
+	}
+
+	tests := []struct {
+		raw         string
+		line, char  int
+		description string
+	}{
+		{"a = 1\nb = var.region\n", 1, 4,
+			"failed to understand data: Variables not allowed; Variables may not be used here."},
+		{"a = {\n", 1, 0,
+			"failed to understand data: Missing expression; Expected the start of an expression, but found the end of the file."},
+	}
+	for _, test := range tests {
+		_, err := decodeHCL(test.raw)
+		tplErrs := dataTemplateErrors(test.raw, err)
+		if len(tplErrs) != 1 {
+			t.Errorf("expected one error, got %+v", tplErrs)
+			continue
+		}
+		assertError(t, templateError{File: dataFile, Line: test.line, Char: test.char, Column: -1,
+			Description: test.description, Level: dataErrorLevel}, tplErrs[0])
+	}
+}
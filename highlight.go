@@ -0,0 +1,27 @@
+package main
+
+import "strings"
+
+// highlight is the part of a token on one line, which the UI colors by its type
+type highlight struct {
+	Type tokenType
+	Text string
+}
+
+// highlightLines splits the tokens of text into the lines of SplitVisualLines, a token spanning lines, like a comment,
+// in a part for each of them
+func highlightLines(text string) [][]highlight {
+	text = normalizeLineEndings(text)
+	lines := [][]highlight{{}}
+	for _, tok := range tokenize(text) {
+		for i, part := range strings.Split(text[tok.Start:tok.End], "\n") {
+			if i > 0 {
+				lines = append(lines, []highlight{})
+			}
+			if part != "" {
+				lines[len(lines)-1] = append(lines[len(lines)-1], highlight{Type: tok.Type, Text: part})
+			}
+		}
+	}
+	return lines
+}
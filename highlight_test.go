@@ -0,0 +1,18 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestHighlightLines(t *testing.T) {
+	expected := [][]highlight{
+		{{textToken, "a "}, {commentToken, "{{/* b"}},
+		{},
+		{{commentToken, "c */}}"}, {leftDelimToken, "{{"}, {fieldToken, ".D"}, {rightDelimToken, "}}"}},
+		{},
+	}
+	if actual := highlightLines("a {{/* b\r\n\r\nc */}}{{.D}}\r"); !reflect.DeepEqual(expected, actual) {
+		t.Errorf("expected %+v, actual %+v", expected, actual)
+	}
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Language is what error descriptions and explanations are written in
+type Language string
+
+const (
+	english           Language = "en"
+	simplifiedChinese Language = "zh"
+)
+
+// getLanguage picks the language from the lang form value, falling back to the Accept-Language header
+func getLanguage(r *http.Request) Language {
+	if lang := parseLanguage(r.FormValue("lang")); lang != "" {
+		return lang
+	}
+	return acceptLanguage(r.Header.Get("Accept-Language"))
+}
+
+// simplifiedChineseTags are the tags of Chinese written with simplified characters, unlike zh-TW, zh-HK or zh-Hant
+var simplifiedChineseTags = map[string]bool{"zh": true, "zh-cn": true, "zh-sg": true, "zh-hans": true}
+
+// parseLanguage returns the supported language of a tag like en-US or zh-CN, none if it isn't supported
+func parseLanguage(tag string) Language {
+	tag = strings.ToLower(strings.TrimSpace(tag))
+	switch {
+	case strings.SplitN(tag, "-", 2)[0] == string(english):
+		return english
+	case simplifiedChineseTags[tag] || strings.HasPrefix(tag, "zh-hans-"):
+		return simplifiedChinese
+	}
+	return ""
+}
+
+// acceptLanguage returns the supported language the client prefers most, defaulting to english
+func acceptLanguage(header string) Language {
+	type preference struct {
+		lang Language
+		q    float64
+	}
+	var preferences []preference
+	for _, part := range strings.Split(header, ",") {
+		fields := strings.Split(part, ";")
+		lang := parseLanguage(fields[0])
+		if lang == "" {
+			continue
+		}
+		q := 1.0
+		for _, param := range fields[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				if v, err := strconv.ParseFloat(param[2:], 64); err == nil {
+					q = v
+				}
+			}
+		}
+		preferences = append(preferences, preference{lang: lang, q: q})
+	}
+	sort.SliceStable(preferences, func(i, j int) bool {
+		return preferences[i].q > preferences[j].q
+	})
+	if len(preferences) == 0 || preferences[0].q <= 0 {
+		return english
+	}
+	return preferences[0].lang
+}
+
+type translation struct {
+	pattern     *regexp.Regexp
+	replacement string
+}
+
+// descriptionTranslations translate the parts of error descriptions, as split by ": ", using regexp replacement
+// templates
+var descriptionTranslations = map[Language][]translation{
+	simplifiedChinese: {
+		{regexp.MustCompile(`^executing "(.*)" at <(.*)>$`), `执行模板 "$1" 于 <$2>`},
+		{regexp.MustCompile(`^unexpected EOF$`), `意外的文件结尾`},
+		{regexp.MustCompile(`^function "(.*)" not defined$`), `函数 "$1" 未定义`},
+		{regexp.MustCompile(`^template "(.*)" not defined$`), `模板 "$1" 未定义`},
+		{regexp.MustCompile(`^function "(.*)" is forbidden by the server's policy$`), `服务器的策略禁止调用函数 "$1"`},
+		{regexp.MustCompile(`^function "(.*)" isn't allowed to be mocked by the server's policy$`), `服务器的策略不允许模拟函数 "$1"`},
+		{regexp.MustCompile(`^missing value for command$`), `命令缺少值`},
+		{regexp.MustCompile(`^missing value for (.*)$`), `$1 缺少值`},
+		{regexp.MustCompile(`^unexpected ({{.*}})$`), `意外的 $1`},
+		{regexp.MustCompile(`^unexpected (.*) in (.*)$`), `$2 中出现意外的 $1`},
+		{regexp.MustCompile(`^bad character (.*)$`), `非法字符 $1`},
+		{regexp.MustCompile(`^unrecognized character in action (.*)$`), `动作中无法识别的字符 $1`},
+		{regexp.MustCompile(`^undefined variable (.*)$`), `未定义的变量 $1`},
+		{regexp.MustCompile(`^unclosed action started at (.*)$`), `未闭合的动作，开始于 $1`},
+		{regexp.MustCompile(`^unclosed action$`), `未闭合的动作`},
+		{regexp.MustCompile(`^unclosed comment$`), `未闭合的注释`},
+		{regexp.MustCompile(`^comment ends before closing delimiter$`), `注释在结束分隔符之前结束`},
+		{regexp.MustCompile(`^unterminated quoted string$`), `未结束的字符串`},
+		{regexp.MustCompile(`^unterminated raw quoted string$`), `未结束的原始字符串`},
+		{regexp.MustCompile(`^unterminated character constant$`), `未结束的字符常量`},
+		{regexp.MustCompile(`^({{.*}}) has no matching {{end}}$`), `$1 没有对应的 {{end}}`},
+		{regexp.MustCompile(`^({{break}}|{{continue}}) outside {{range}}$`), `$1 不在 {{range}} 内`},
+		{regexp.MustCompile(`^non executable command in pipeline stage (\d+)$`), `管道第 $1 段不是可执行的命令`},
+		{regexp.MustCompile(`^can't give argument to non-function (.*)$`), `不能向非函数 $1 传递参数`},
+		{regexp.MustCompile(`^nil pointer evaluating (.*)$`), `对空指针求值 $1`},
+		{regexp.MustCompile(`^can't evaluate field (.*) in type (.*)$`), `无法在类型 $2 中求值字段 $1`},
+		{regexp.MustCompile(`^map has no entry for key (.*)$`), `映射中没有键 $1`},
+		{regexp.MustCompile(`^wrong number of args for (.*): want (.*) got (.*)$`), `$1 的参数个数错误：需要 $2 个，实际 $3 个`},
+		{regexp.MustCompile(`^error calling (.*)$`), `调用 $1 出错`},
+		{regexp.MustCompile(`^range can't iterate over (.*)$`), `range 无法遍历 $1`},
+		{regexp.MustCompile(`^incompatible types for comparison$`), `比较的类型不兼容`},
+		{regexp.MustCompile(`^invalid type for comparison$`), `比较的类型无效`},
+		{regexp.MustCompile(`^failed to understand data$`), `无法理解数据`},
+		{regexp.MustCompile(`^bad function name provided: (.*)$`), `提供的函数名无效：$1`},
+		// explanations of the data path
+		{regexp.MustCompile("^`(.*)` exists but has no key `(.*)`; did you mean `(.*)`\\?$"), "`$1` 存在，但没有键 `$2`；你是不是想写 `$3`？"},
+		{regexp.MustCompile("^`(.*)` exists but has no key `(.*)`$"), "`$1` 存在，但没有键 `$2`"},
+		{regexp.MustCompile("^(dot|the data) has no key `(.*)`; did you mean `(.*)`\\?$"), "当前值没有键 `$2`；你是不是想写 `$3`？"},
+		{regexp.MustCompile("^(dot|the data) has no key `(.*)`$"), "当前值没有键 `$2`"},
+		{regexp.MustCompile("^(.*) is null, so it has no key `(.*)`$"), "$1 为 null，因此没有键 `$2`"},
+		{regexp.MustCompile("^(.*) is an? (array|string|number|boolean), not an object with key `(.*)`$"), "$1 是 $2，不是带有键 `$3` 的对象"},
+	},
+}
+
+// fixTranslations translate quick fix descriptions
+var fixTranslations = map[Language][]translation{
+	simplifiedChinese: {
+		{regexp.MustCompile(`^close the action$`), `闭合动作`},
+		{regexp.MustCompile(`^close the comment$`), `闭合注释`},
+		{regexp.MustCompile(`^remove the empty action$`), `删除空动作`},
+		{regexp.MustCompile(`^add the missing {{end}}$`), `补上缺少的 {{end}}`},
+		{regexp.MustCompile(`^remove the ({{.*}})$`), `删除 $1`},
+		{regexp.MustCompile("^did you mean `(.*)`\\?$"), "你是不是想写 `$1`？"},
+	},
+}
+
+// explanationTranslations translate the text of knowledge base entries, by id
+var explanationTranslations = map[Language]map[string]string{
+	simplifiedChinese: {
+		"unclosed-block":           "模板结束时还有块没有关闭。每个 {{if}}、{{range}}、{{with}}、{{define}} 和 {{block}} 都需要对应的 {{end}}。",
+		"undefined-function":       "只能调用内置函数和解析前通过 Funcs 添加的函数。校验器会模拟未知函数以便继续检查，把函数名加入函数列表即可消除此错误。",
+		"function-policy":          "运行验证器的服务器不允许模板调用这个函数，或不模拟这个名称的未知函数，因此模板没有被执行。请联系服务器的管理员，或用命令行在本地验证。",
+		"undefined-template":       "模板集合中没有定义这个名称的模板。模板只有一起解析，或用 {{define}}、{{block}} 定义，才会相互关联，否则执行到这次调用时就会失败。",
+		"empty-action":             "动作是空的。动作中必须有管道，例如字段、变量或函数调用。",
+		"missing-block-value":      "这个块需要一个用于判断或遍历的管道。",
+		"unexpected-end":           "没有与之对应的已打开的块。它可能是多余的，或者块的开始动作缺失或拼写错误。",
+		"index-syntax":             "模板不支持用方括号取下标，请使用 index 函数。",
+		"bad-character":            "动作中不允许出现这个字符。动作只支持字段、变量、常量、函数调用和管道，没有运算符。",
+		"undefined-variable":       "变量必须先用 := 声明才能使用，并且只在声明所在块的 {{end}} 之前有效。",
+		"unclosed-action":          "动作用 {{ 打开后没有用 }} 关闭。",
+		"bad-comment":              "注释必须写成 {{/* ... */}}，*/ 和 }} 之间除了可选的空白修剪标记外不能有其他内容。",
+		"unterminated-string":      "动作中的字符串常量缺少结束引号。",
+		"break-outside-range":      "{{break}} 和 {{continue}} 只能在 {{range}} 中使用。",
+		"non-executable-command":   "管道中第一段之后的每一段都会把上一段的结果作为最后一个参数，所以必须是函数或方法调用。",
+		"argument-to-non-function": "把参数传给了不是函数或方法的东西，例如字段或映射的键。",
+		"nil-pointer":              "字段链中的某个值为 nil（JSON 中的 null），所以无法继续查找后面的字段。请用 {{with}} 或 {{if}} 保护访问。",
+		"unknown-field":            "这个值没有该名称的字段或方法。结构体字段必须是导出的；JSON 数据结构不对（例如需要对象的地方是数组）也会导致此错误。",
+		"missing-key":              "设置了 missingkey=error 选项，而映射中不存在这个键。",
+		"wrong-args":               "调用函数时的参数个数与函数接受的不同。注意在管道中，上一段的结果会作为最后一个参数传入。",
+		"function-error":           "函数返回了错误，执行因此停止。",
+		"range-type":               "{{range}} 只能用于数组、切片、映射、通道、返回迭代器的函数，以及较新 go 版本中的整数。",
+		"comparison-types":         "比较函数需要类型兼容的基本类型参数。JSON 中的数字总是 float64，请与浮点数或其他 JSON 数字比较。",
+		"unexpected-token":         "解析器在这里遇到了意料之外的内容。请检查是否使用了运算符（模板没有运算符）、括号是否配对，以及参数之间是否缺少空格。",
+	},
+}
+
+// localizeErrors translates the descriptions, fixes and explanations of errors into lang. Anything without a
+// translation is left in english.
+func localizeErrors(tplErrs []templateError, lang Language) {
+	if lang == "" || lang == english {
+		return
+	}
+	for i := range tplErrs {
+		tplErr := &tplErrs[i]
+		parts := strings.Split(tplErr.Description, ": ")
+		for j, part := range parts {
+			parts[j] = translate(part, descriptionTranslations[lang])
+		}
+		tplErr.Description = strings.Join(parts, ": ")
+
+		if tplErr.Fix != nil {
+			tplErr.Fix.Description = translate(tplErr.Fix.Description, fixTranslations[lang])
+		}
+		if tplErr.Explanation != nil {
+			if text, ok := explanationTranslations[lang][tplErr.Explanation.id]; ok {
+				tplErr.Explanation.Text = text
+			}
+		}
+	}
+}
+
+func translate(s string, translations []translation) string {
+	for _, t := range translations {
+		if t.pattern.MatchString(s) {
+			return t.pattern.ReplaceAllString(s, t.replacement)
+		}
+	}
+	return s
+}
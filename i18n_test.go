@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"testing"
+)
+
+func TestAcceptLanguage(t *testing.T) {
+	for header, expected := range map[string]Language{
+		"":                              english,
+		"zh-CN,zh;q=0.9,en;q=0.8":       simplifiedChinese,
+		"en-US,en;q=0.9,zh-CN;q=0.8":    english,
+		"fr-FR, zh;q=0.5, en;q=0.3":     simplifiedChinese,
+		"de":                            english,
+		"en;q=0.1, zh-Hans-CN;q=0.7, *": simplifiedChinese,
+		"zh;q=0":                        english,
+		"zh-TW,zh;q=0.5":                simplifiedChinese,
+		"zh-HK, en;q=0.5":               english,
+		"zh-Hant-TW":                    english,
+		"zh-SG":                         simplifiedChinese,
+	} {
+		if actual := acceptLanguage(header); actual != expected {
+			t.Errorf("`%s`: expected `%s`, actual `%s`", header, expected, actual)
+		}
+	}
+}
+
+func TestLocalizeErrors(t *testing.T) {
+	data := (&App{}).createData(context.Background(), "{{lenght}}\n{{.A.B}}", `{"A": null}`, "", options{Language: simplifiedChinese})
+	if len(data.Errors) != 2 {
+		t.Fatalf("unexpected errors found: %v", data.Errors)
+	}
+	assertError(t, templateError{
+		Line:        0,
+		Char:        2,
+		Level:       parseErrorLevel,
+		Description: `函数 "lenght" 未定义`,
+	}, data.Errors[0])
+	if data.Errors[0].Fix.Description != "你是不是想写 `len`？" {
+		t.Errorf("fix isn't translated: %s", data.Errors[0].Fix.Description)
+	}
+	if data.Errors[0].Explanation.Text != explanationTranslations[simplifiedChinese]["undefined-function"] {
+		t.Errorf("explanation isn't translated: %s", data.Errors[0].Explanation.Text)
+	}
+	assertError(t, templateError{
+		Line:        1,
+		Char:        4,
+		Level:       execErrorLevel,
+		Description: "执行模板 \"input template\" 于 <.A.B>: 对空指针求值 interface {}.B: `.A` 为 null，因此没有键 `B`",
+	}, data.Errors[1])
+}
+
+func TestExplanationTranslationsComplete(t *testing.T) {
+	for lang, translations := range explanationTranslations {
+		for _, entry := range knowledgeBase {
+			if _, ok := translations[entry.id]; !ok {
+				t.Errorf("%s is missing a translation of %s", lang, entry.id)
+			}
+		}
+	}
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// decodeINI decodes the INI raw within maxDataSize. Keys before the first section are fields of the data, the others
+// fields of objects in the fields named by their sections, with dots nesting them, so the keys of [server.http] are
+// .server.http.Key. Values are strings, without the quotes around them. Comments start with ; or #.
+func decodeINI(raw string) (interface{}, error) {
+	if len(raw) > maxDataSize {
+		return nil, fmt.Errorf("data is bigger than %d bytes", maxDataSize)
+	}
+	data := make(map[string]interface{})
+	section := data
+	var list dataErrors
+	for start := 0; start < len(raw); {
+		end := lineEnd(raw, start)
+		indented := strings.TrimSuffix(raw[start:end], "\r")
+		line := strings.TrimSpace(indented)
+		// errors are at the line's text, past its indentation
+		offset := start + len(indented) - len(strings.TrimLeft(indented, " \t"))
+		start = end + 1
+		switch {
+		case line == "" || line[0] == ';' || line[0] == '#':
+		case line[0] == '[':
+			if !strings.HasSuffix(line, "]") {
+				list = append(list, &dataError{offset: offset, err: fmt.Errorf("section %s isn't closed by ]", line)})
+				continue
+			}
+			var err error
+			if section, err = iniSection(data, strings.TrimSpace(line[1:len(line)-1])); err != nil {
+				list = append(list, &dataError{offset: offset, err: err})
+				// its keys go nowhere, not in the section before
+				section = make(map[string]interface{})
+			}
+		default:
+			i := strings.IndexAny(line, "=:")
+			if i <= 0 {
+				list = append(list, &dataError{offset: offset, err: fmt.Errorf("expected key = value, found %q", line)})
+				continue
+			}
+			key, value := strings.TrimSpace(line[:i]), strings.TrimSpace(line[i+1:])
+			if _, ok := section[key].(map[string]interface{}); ok {
+				list = append(list, &dataError{offset: offset, err: fmt.Errorf("key %s is a section too", key)})
+				continue
+			}
+			section[key] = unquoteINI(value)
+		}
+	}
+	if len(list) > 0 {
+		return nil, list
+	}
+	return data, nil
+}
+
+// iniSection returns the object of the section name in data, adding it and the sections it's in if they're not in it
+func iniSection(data map[string]interface{}, name string) (map[string]interface{}, error) {
+	if name == "" {
+		return nil, fmt.Errorf("section has no name")
+	}
+	section := data
+	for _, part := range strings.Split(name, ".") {
+		part = strings.TrimSpace(part)
+		switch child := section[part].(type) {
+		case map[string]interface{}:
+			section = child
+		case nil:
+			next := make(map[string]interface{})
+			section[part] = next
+			section = next
+		default:
+			return nil, fmt.Errorf("section %s is a key too", name)
+		}
+	}
+	return section, nil
+}
+
+// unquoteINI returns value without the quotes around it, if there are any
+func unquoteINI(value string) string {
+	if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+		if value[0] == '"' {
+			if unquoted, err := strconv.Unquote(value); err == nil {
+				return unquoted
+			}
+		}
+		return value[1 : len(value)-1]
+	}
+	return value
+}
+
+// decodeProperties decodes the Java properties raw within maxDataSize into the data of its keys and string values.
+// Keys are separated from values by =, : or whitespace, lines ending with a backslash go on on the next line, and
+// comments start with # or !. Keys aren't nested, so a key like db.url is read with index.
+func decodeProperties(raw string) (interface{}, error) {
+	if len(raw) > maxDataSize {
+		return nil, fmt.Errorf("data is bigger than %d bytes", maxDataSize)
+	}
+	data := make(map[string]interface{})
+	for start := 0; start < len(raw); {
+		end := lineEnd(raw, start)
+		indented := strings.TrimSuffix(raw[start:end], "\r")
+		line := strings.TrimLeft(indented, " \t\f")
+		offset := start + len(indented) - len(line)
+		start = end + 1
+		if line == "" || line[0] == '#' || line[0] == '!' {
+			continue
+		}
+		// an odd number of backslashes at the end continues the line
+		for strings.HasSuffix(line, "\\") && (len(line)-len(strings.TrimRight(line, "\\")))%2 == 1 && start < len(raw) {
+			end = lineEnd(raw, start)
+			line = line[:len(line)-1] + strings.TrimLeft(strings.TrimSuffix(raw[start:end], "\r"), " \t\f")
+			start = end + 1
+		}
+		key, value := splitProperty(line)
+		unescapedKey, err := unescapeProperty(key)
+		if err != nil {
+			return nil, &dataError{offset: offset, err: err}
+		}
+		unescapedValue, err := unescapeProperty(value)
+		if err != nil {
+			return nil, &dataError{offset: offset, err: err}
+		}
+		data[unescapedKey] = unescapedValue
+	}
+	return data, nil
+}
+
+// splitProperty splits line into its key and value, at the first unescaped =, : or whitespace
+func splitProperty(line string) (string, string) {
+	for i := 0; i < len(line); i++ {
+		switch line[i] {
+		case '\\':
+			i++
+		case '=', ':':
+			return line[:i], strings.TrimLeft(line[i+1:], " \t\f")
+		case ' ', '\t', '\f':
+			rest := strings.TrimLeft(line[i:], " \t\f")
+			// whitespace around an = or : is part of the separator
+			if rest != "" && (rest[0] == '=' || rest[0] == ':') {
+				rest = strings.TrimLeft(rest[1:], " \t\f")
+			}
+			return line[:i], rest
+		}
+	}
+	return line, ""
+}
+
+// unescapeProperty replaces the escapes in s, like \t and \u00e9, with what they stand for
+func unescapeProperty(s string) (string, error) {
+	if !strings.Contains(s, "\\") {
+		return s, nil
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i+1 == len(s) {
+			b.WriteByte(s[i])
+			continue
+		}
+		i++
+		switch s[i] {
+		case 't':
+			b.WriteByte('\t')
+		case 'n':
+			b.WriteByte('\n')
+		case 'r':
+			b.WriteByte('\r')
+		case 'f':
+			b.WriteByte('\f')
+		case 'u':
+			if i+5 > len(s) {
+				return "", fmt.Errorf("bad escape %s", s[i-1:])
+			}
+			r, err := strconv.ParseUint(s[i+1:i+5], 16, 16)
+			if err != nil {
+				return "", fmt.Errorf("bad escape %s", s[i-1:i+5])
+			}
+			b.WriteRune(rune(r))
+			i += 4
+		default:
+			// other escaped characters are themselves, like \= and \:
+			b.WriteByte(s[i])
+		}
+	}
+	return b.String(), nil
+}
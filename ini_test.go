@@ -0,0 +1,79 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDecodeINI(t *testing.T) {
+	data, err := decodeINI(`; the defaults
+name = "web server"
+
+[server]
+port = 8080
+host: example.com
+
+[server.http]
+# not a key
+timeout = 30s
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]interface{}{
+		"name": "web server",
+		"server": map[string]interface{}{
+			"port": "8080",
+			"host": "example.com",
+			"http": map[string]interface{}{"timeout": "30s"},
+		},
+	}
+	if !reflect.DeepEqual(data, expected) {
+		t.Errorf("expected %v, got %v", expected, data)
+	}
+
+	raw := "[server\nport = 1\n  no value\n[port]\n"
+	_, err = decodeINI(raw)
+	tplErrs := dataTemplateErrors(raw, err)
+	if len(tplErrs) != 3 {
+		t.Fatalf("expected 3 errors, got %+v", tplErrs)
+	}
+	assertError(t, templateError{File: dataFile, Line: 0, Char: 0, Column: -1, Level: dataErrorLevel,
+		Description: "failed to understand data: section [server isn't closed by ]"}, tplErrs[0])
+	assertError(t, templateError{File: dataFile, Line: 2, Char: 2, Column: -1, Level: dataErrorLevel,
+		Description: `failed to understand data: expected key = value, found "no value"`}, tplErrs[1])
+	assertError(t, templateError{File: dataFile, Line: 3, Char: 0, Column: -1, Level: dataErrorLevel,
+		Description: "failed to understand data: section port is a key too"}, tplErrs[2])
+}
+
+func TestDecodeProperties(t *testing.T) {
+	data, err := decodeProperties(`# the database
+! also a comment
+db.url = jdbc:postgresql://localhost/shop
+db.user:admin
+greeting Hello, \
+    world
+path=c:\\temp
+key\=with\:separators = caf\u00e9
+empty
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]interface{}{
+		"db.url":              "jdbc:postgresql://localhost/shop",
+		"db.user":             "admin",
+		"greeting":            "Hello, world",
+		"path":                `c:\temp`,
+		"key=with:separators": "café",
+		"empty":               "",
+	}
+	if !reflect.DeepEqual(data, expected) {
+		t.Errorf("expected %v, got %v", expected, data)
+	}
+
+	raw := "a = 1\nb = \\u12\n"
+	_, err = decodeProperties(raw)
+	assertError(t, templateError{File: dataFile, Line: 1, Char: 0, Column: -1, Level: dataErrorLevel,
+		Description: `failed to understand data: bad escape \u12`}, dataTemplateError(raw, err))
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var (
+	styleBlockRegex = regexp.MustCompile(`(?is)<style[^>]*>(.*?)</style>`)
+	cssCommentRegex = regexp.MustCompile(`(?s)/\*.*?\*/`)
+	// cssRuleRegex matches rules, and at-rules like @media with their nested braces
+	cssRuleRegex   = regexp.MustCompile(`(?s)(@[^{]+\{(?:[^{}]*\{[^{}]*\})*[^{}]*\}|[^{}@]+\{[^{}]*\})`)
+	startTagRegex  = regexp.MustCompile(`<([a-zA-Z][a-zA-Z0-9]*)((?:\s+[^\s"'>/=]+(?:\s*=\s*(?:"[^"]*"|'[^']*'|[^\s"'>]+))?)*)\s*(/?)>`)
+	attributeRegex = regexp.MustCompile(`\s+([^\s"'>/=]+)(?:\s*=\s*("[^"]*"|'[^']*'|[^\s"'>]+))?`)
+	// simpleSelectorRegex matches the selectors that can be inlined: a tag, classes and an id
+	simpleSelectorRegex = regexp.MustCompile(`^([a-zA-Z][a-zA-Z0-9]*)?((?:[.#][a-zA-Z_-][a-zA-Z0-9_-]*)*)$`)
+	selectorPartRegex   = regexp.MustCompile(`[.#][a-zA-Z_-][a-zA-Z0-9_-]*`)
+)
+
+// cssRule is a rule of a style block whose selector is simple enough to match elements by their tag and attributes
+type cssRule struct {
+	tag          string
+	id           string
+	classes      []string
+	declarations string
+	// specificity and order decide which declarations win, like in browsers
+	specificity int
+	order       int
+}
+
+func (r cssRule) matches(tag string, id string, classes map[string]bool) bool {
+	if r.tag != "" && !strings.EqualFold(r.tag, tag) {
+		return false
+	}
+	if r.id != "" && r.id != id {
+		return false
+	}
+	for _, class := range r.classes {
+		if !classes[class] {
+			return false
+		}
+	}
+	return true
+}
+
+// inlineCSS moves the rules of html's style blocks into the style attributes of the elements they match, like
+// premailer, since many email clients ignore style blocks. Rules it can't inline, like @media queries or selectors
+// with combinators, stay in the style block.
+func inlineCSS(html string) string {
+	var rules []cssRule
+	html = styleBlockRegex.ReplaceAllStringFunc(html, func(block string) string {
+		css := styleBlockRegex.FindStringSubmatch(block)[1]
+		var kept []string
+		for _, rule := range cssRuleRegex.FindAllString(cssCommentRegex.ReplaceAllString(css, ""), -1) {
+			parsed, ok := parseCSSRule(rule, len(rules))
+			if !ok {
+				kept = append(kept, strings.TrimSpace(rule))
+				continue
+			}
+			rules = append(rules, parsed...)
+		}
+		if len(kept) == 0 {
+			return ""
+		}
+		return "<style>\n" + strings.Join(kept, "\n") + "\n</style>"
+	})
+	if len(rules) == 0 {
+		return html
+	}
+	sort.SliceStable(rules, func(i, j int) bool {
+		if rules[i].specificity != rules[j].specificity {
+			return rules[i].specificity < rules[j].specificity
+		}
+		return rules[i].order < rules[j].order
+	})
+
+	return startTagRegex.ReplaceAllStringFunc(html, func(tag string) string {
+		m := startTagRegex.FindStringSubmatch(tag)
+		name, attributes, selfClosing := m[1], m[2], m[3]
+		id, style := "", ""
+		classes := map[string]bool{}
+		var others []string
+		for _, a := range attributeRegex.FindAllStringSubmatch(attributes, -1) {
+			value := strings.Trim(a[2], `"'`)
+			switch strings.ToLower(a[1]) {
+			case "id":
+				id = value
+			case "class":
+				for _, class := range strings.Fields(value) {
+					classes[class] = true
+				}
+			case "style":
+				style = value
+				continue
+			}
+			others = append(others, a[0])
+		}
+
+		var declarations []string
+		for _, rule := range rules {
+			if rule.matches(name, id, classes) {
+				declarations = append(declarations, rule.declarations)
+			}
+		}
+		if len(declarations) == 0 {
+			return tag
+		}
+		// inline styles win over style blocks
+		if style != "" {
+			declarations = append(declarations, strings.TrimSuffix(strings.TrimSpace(style), ";"))
+		}
+		return "<" + name + strings.Join(others, "") + ` style="` + strings.ReplaceAll(strings.Join(declarations, "; "), `"`, "'") + `"` + selfClosing + ">"
+	})
+}
+
+// parseCSSRule parses a rule with simple selectors, one for each selector
+func parseCSSRule(rule string, order int) ([]cssRule, bool) {
+	open := strings.Index(rule, "{")
+	if strings.HasPrefix(rule, "@") || open == -1 {
+		return nil, false
+	}
+	declarations := strings.TrimSuffix(strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(rule[open+1:]), "}")), ";")
+	var rules []cssRule
+	for _, selector := range strings.Split(rule[:open], ",") {
+		selector = strings.TrimSpace(selector)
+		m := simpleSelectorRegex.FindStringSubmatch(selector)
+		if m == nil || selector == "" {
+			return nil, false
+		}
+		r := cssRule{tag: m[1], declarations: declarations, order: order}
+		if r.tag != "" {
+			r.specificity++
+		}
+		for _, part := range selectorPartRegex.FindAllString(m[2], -1) {
+			if part[0] == '#' {
+				r.id = part[1:]
+				r.specificity += 100
+			} else {
+				r.classes = append(r.classes, part[1:])
+				r.specificity += 10
+			}
+		}
+		rules = append(rules, r)
+	}
+	return rules, true
+}
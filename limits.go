@@ -0,0 +1,210 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template"
+	templateParse "text/template/parse"
+)
+
+const (
+	defaultMaxSteps      = 100000
+	defaultMaxIterations = 10000
+
+	// limitFunction is called before every action of a limited template, it can't clash with a function a template
+	// could call because it starts with an underscore
+	limitFunction = "_limit"
+)
+
+// clampLimit returns limit, def if it's not set, at most ceiling, which is def too if it's not set
+func clampLimit(limit, def, ceiling int) int {
+	if ceiling <= 0 {
+		ceiling = def
+	}
+	if limit <= 0 {
+		limit = def
+	}
+	return min(limit, ceiling)
+}
+
+// limitErrorRegex matches what text/template says around an error of limitFunction, which names the node it is for
+var limitErrorRegex = regexp.MustCompile(`at <` + limitFunction + ` "\d+">: error calling ` + limitFunction + `: <`)
+
+// execLimiter counts the actions a template executes and the iterations of each of its ranges
+type execLimiter struct {
+	// ctx is cancelled when executing should stop early
+	ctx           context.Context
+	maxSteps      int
+	maxIterations int
+
+	steps int
+	// targets are what each call to limitFunction is for, by its argument
+	targets []*limitTarget
+}
+
+// limitTarget is a node a call to limitFunction is for. Ranges have two calls: one before the range resets its
+// iterations and one at the start of every iteration counts them.
+type limitTarget struct {
+	node        templateParse.Node
+	isRange     bool
+	isIteration bool
+	iterations  *int
+}
+
+// limitTemplate returns a copy of t which fails to execute once it has executed more than maxSteps actions in total, or
+// a range has iterated more than maxIterations times in a row, or ctx is cancelled
+func limitTemplate(ctx context.Context, t *template.Template, maxSteps, maxIterations int) (*template.Template, error) {
+	t, _, err := newLimitedTemplate(ctx, t, maxSteps, maxIterations)
+	return t, err
+}
+
+// newLimitedTemplate is limitTemplate, also returning the limiter of the copy, which can be reset to execute it again
+func newLimitedTemplate(ctx context.Context, t *template.Template, maxSteps, maxIterations int) (*template.Template,
+	*execLimiter, error) {
+	t, err := copyTemplate(t)
+	if err != nil {
+		return nil, nil, err
+	}
+	l := &execLimiter{ctx: ctx, maxSteps: maxSteps, maxIterations: maxIterations}
+	for _, tree := range trees(t) {
+		l.instrument(tree, tree.Root)
+	}
+	return t.Funcs(template.FuncMap{limitFunction: l.limit}), l, nil
+}
+
+// reset forgets the actions executed so far, so executing again is limited like the first time. Ranges reset their
+// iterations as they start.
+func (l *execLimiter) reset() {
+	l.steps = 0
+}
+
+// instrument adds calls to limitFunction before every action in list and its descendants, and at the start of every
+// iteration of a range
+func (l *execLimiter) instrument(tree *templateParse.Tree, list *templateParse.ListNode) {
+	if list == nil {
+		return
+	}
+	nodes := make([]templateParse.Node, 0, 2*len(list.Nodes))
+	for _, node := range list.Nodes {
+		switch n := node.(type) {
+		case *templateParse.ActionNode, *templateParse.TemplateNode:
+			nodes = append(nodes, l.call(tree, &limitTarget{node: node}))
+		case *templateParse.IfNode:
+			nodes = append(nodes, l.call(tree, &limitTarget{node: node}))
+			l.instrument(tree, n.List)
+			l.instrument(tree, n.ElseList)
+		case *templateParse.WithNode:
+			nodes = append(nodes, l.call(tree, &limitTarget{node: node}))
+			l.instrument(tree, n.List)
+			l.instrument(tree, n.ElseList)
+		case *templateParse.RangeNode:
+			iterations := new(int)
+			nodes = append(nodes, l.call(tree, &limitTarget{node: node, isRange: true, iterations: iterations}))
+			l.instrument(tree, n.List)
+			l.instrument(tree, n.ElseList)
+			iteration := l.call(tree, &limitTarget{node: node, isIteration: true, iterations: iterations})
+			n.List.Nodes = append([]templateParse.Node{iteration}, n.List.Nodes...)
+		}
+		nodes = append(nodes, node)
+	}
+	list.Nodes = nodes
+}
+
+// call returns an action calling limitFunction for target, found in tree, at the position of target
+func (l *execLimiter) call(tree *templateParse.Tree, target *limitTarget) templateParse.Node {
+	arg := strconv.Itoa(len(l.targets))
+	l.targets = append(l.targets, target)
+	return callAt(tree, target.node, limitFunction, l.limit, arg)
+}
+
+// callAt returns an action calling the function name, which is fn, with the string arg, at the position of node in
+// tree. Nodes need a tree to be printed and located, but trees can't make nodes outside of parsing, so the action is
+// parsed on its own from text which puts it where node is: at the same offset, line and character.
+func callAt(tree *templateParse.Tree, node templateParse.Node, name string, fn interface{}, arg string) templateParse.Node {
+	pos := int(node.Position())
+	loc, _ := tree.ErrorContext(node)
+	line, char := parseLocation(loc)
+	prefix := strings.Repeat(" ", pos-line-char) + strings.Repeat("\n", line) + strings.Repeat(" ", char)
+
+	callTree := templateParse.New(tree.ParseName)
+	fns := map[string]interface{}{name: fn}
+	if _, err := callTree.Parse(prefix+"{{"+name+" "+strconv.Quote(arg)+"}}", "{{", "}}", map[string]*templateParse.Tree{}, fns); err != nil {
+		panic(err)
+	}
+	action := callTree.Root.Nodes[len(callTree.Root.Nodes)-1]
+	// errors are at the command, which should be where node is too
+	walk(action, func(n templateParse.Node, _ []templateParse.Node) bool {
+		switch n := n.(type) {
+		case *templateParse.PipeNode:
+			n.Pos = node.Position()
+		case *templateParse.CommandNode:
+			n.Pos = node.Position()
+		case *templateParse.IdentifierNode:
+			n.Pos = node.Position()
+		case *templateParse.StringNode:
+			n.Pos = node.Position()
+		}
+		return true
+	})
+	return action
+}
+
+// limit is limitFunction, it outputs nothing
+func (l *execLimiter) limit(arg string) (string, error) {
+	i, err := strconv.Atoi(arg)
+	if err != nil || i < 0 || i >= len(l.targets) {
+		return "", fmt.Errorf("unknown limit %q", arg)
+	}
+	target := l.targets[i]
+	if err := l.ctx.Err(); err != nil {
+		return "", fmt.Errorf("<%s>: stopped: %v", targetString(target.node), err)
+	}
+	switch {
+	case target.isRange:
+		*target.iterations = 0
+	case target.isIteration:
+		*target.iterations++
+		if *target.iterations > l.maxIterations {
+			return "", fmt.Errorf("<%s>: range stopped after %d iterations, the most allowed",
+				targetString(target.node), l.maxIterations)
+		}
+	default:
+		l.steps++
+		if l.steps > l.maxSteps {
+			return "", fmt.Errorf("<%s>: stopped after executing %d actions, the most allowed",
+				targetString(target.node), l.maxSteps)
+		}
+	}
+	return "", nil
+}
+
+// targetString describes a node the way text/template names the node an error is at
+func targetString(node templateParse.Node) string {
+	switch n := node.(type) {
+	case *templateParse.ActionNode:
+		return n.Pipe.String()
+	case *templateParse.IfNode:
+		return n.Pipe.String()
+	case *templateParse.WithNode:
+		return n.Pipe.String()
+	case *templateParse.RangeNode:
+		return n.Pipe.String()
+	case *templateParse.TemplateNode:
+		return fmt.Sprintf("template %q", n.Name)
+	}
+	return node.String()
+}
+
+// describeLimitErrors makes errors of limitFunction name the node the limit was hit at instead of limitFunction
+func describeLimitErrors(tplErrs []templateError) {
+	for i := range tplErrs {
+		tplErrs[i].Description = limitErrorRegex.ReplaceAllString(tplErrs[i].Description, "at <")
+	}
+}
+
+func isLimitError(tplErr templateError) bool {
+	return limitErrorRegex.MatchString(tplErr.Description)
+}
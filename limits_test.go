@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestIterationLimit(t *testing.T) {
+	text := "{{range .Outer}}\n{{range $.Inner}}{{.}}{{end}}{{end}}"
+	data := (&App{}).createData(context.Background(), text, `{"Outer": [1, 2, 3], "Inner": [1, 2, 3]}`, "", options{MaxIterations: 3})
+	if len(data.Errors) != 0 {
+		t.Fatalf("unexpected errors found: %v", data.Errors)
+	}
+	if expected := "\n123\n123\n123"; data.Output != expected {
+		t.Errorf("expected `%s`, actual `%s`", expected, data.Output)
+	}
+
+	data = (&App{}).createData(context.Background(), text, `{"Outer": [1], "Inner": [1, 2, 3, 4]}`, "", options{MaxIterations: 3})
+	if len(data.Errors) != 1 {
+		t.Fatalf("unexpected errors found: %v", data.Errors)
+	}
+	assertError(t, templateError{Line: 1, Char: 8, Level: execErrorLevel,
+		Description: `executing "input template" at <$.Inner>: range stopped after 3 iterations, the most allowed`}, data.Errors[0])
+}
+
+func TestStepLimit(t *testing.T) {
+	text := "{{range .}}{{if .}}{{.}}{{end}}{{end}}"
+	data := (&App{}).createData(context.Background(), text, "[1, 2, 3, 4]", "", options{MaxSteps: 5, AllExecErrors: true})
+	if len(data.Errors) != 1 {
+		t.Fatalf("unexpected errors found: %v", data.Errors)
+	}
+	assertError(t, templateError{Line: 0, Char: 21, Level: execErrorLevel,
+		Description: `executing "input template" at <.>: stopped after executing 5 actions, the most allowed`}, data.Errors[0])
+	if data.Output != "12" {
+		t.Errorf("unexpected output: %s", data.Output)
+	}
+}
+
+func TestClampedLimits(t *testing.T) {
+	text := "{{range .}}{{if .}}{{.}}{{end}}{{end}}"
+	a := &App{maxSteps: 5, maxIterations: 2}
+	data := a.createData(context.Background(), text, "[1, 2, 3, 4]", "", options{MaxSteps: 2147483647, AllExecErrors: true})
+	if len(data.Errors) != 1 || data.Output != "12" {
+		t.Errorf("expected the steps to be clamped to 5: %q, %v", data.Output, data.Errors)
+	}
+	data = a.createData(context.Background(), "{{range .}}{{.}}{{end}}", "[1, 2, 3]", "", options{MaxIterations: 1000})
+	if len(data.Errors) == 0 || !strings.HasSuffix(data.Errors[0].Description, "range stopped after 2 iterations, the most allowed") {
+		t.Errorf("expected the iterations to be clamped to 2: %v", data.Errors)
+	}
+
+	for _, test := range [][4]int{{0, 10, 0, 10}, {20, 10, 0, 10}, {5, 10, 0, 5}, {0, 10, 50, 10}, {20, 10, 50, 20},
+		{-1, 10, 5, 5}} {
+		if actual := clampLimit(test[0], test[1], test[2]); actual != test[3] {
+			t.Errorf("clampLimit(%d, %d, %d): expected %d, actual %d", test[0], test[1], test[2], test[3], actual)
+		}
+	}
+}
+
+func TestCancelledExecution(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	data := (&App{}).createData(ctx, `{{range .}}{{.}}{{end}}`, "[1, 2]", "", options{AllExecErrors: true})
+	if len(data.Errors) != 1 {
+		t.Fatalf("unexpected errors found: %v", data.Errors)
+	}
+	assertError(t, templateError{Line: 0, Char: 8, Level: execErrorLevel,
+		Description: `executing "input template" at <.>: stopped: context canceled`}, data.Errors[0])
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+)
+
+const (
+	// sourcePageLines are how many lines of a template the page shows at once, so huge templates don't make huge pages
+	sourcePageLines = 1000
+	// maxErrorJumps are how many lines with errors on other pages the page links to
+	maxErrorJumps = 100
+	// maxLinesLimit are the most lines served at once
+	maxLinesLimit = 10 * sourcePageLines
+)
+
+// sourceLine is a line of a template, with its tokens and the errors found in it
+type sourceLine struct {
+	Line   int             `json:"line"`
+	Text   string          `json:"text"`
+	Tokens []highlight     `json:"tokens"`
+	Errors []templateError `json:"errors,omitempty"`
+}
+
+// linesPage is a window of the lines of a template
+type linesPage struct {
+	// Total is how many lines the template has
+	Total  int          `json:"total"`
+	Offset int          `json:"offset"`
+	Lines  []sourceLine `json:"lines"`
+	// ErrorLines are the lines with errors, in all the template, to jump to them
+	ErrorLines []int `json:"error_lines"`
+}
+
+// newLinesPage returns the limit lines of data from offset
+func newLinesPage(data indexData, offset, limit int) linesPage {
+	page := linesPage{Total: len(data.TextLines), Offset: offset, Lines: make([]sourceLine, 0), ErrorLines: make([]int, 0)}
+	end := min(offset+limit, len(data.TextLines))
+	for i := offset; i < end; i++ {
+		line := sourceLine{Line: i, Text: data.TextLines[i], Tokens: make([]highlight, 0)}
+		if i < len(data.Highlighted) {
+			line.Tokens = append(line.Tokens, data.Highlighted[i]...)
+		}
+		page.Lines = append(page.Lines, line)
+	}
+	seen := make(map[int]bool)
+	for _, tplErr := range data.Errors {
+		if tplErr.Line < 0 || tplErr.Level == dataErrorLevel {
+			continue
+		}
+		if !seen[tplErr.Line] {
+			seen[tplErr.Line] = true
+			page.ErrorLines = append(page.ErrorLines, tplErr.Line)
+		}
+		if tplErr.Line >= offset && tplErr.Line < end {
+			line := &page.Lines[tplErr.Line-offset]
+			line.Errors = append(line.Errors, tplErr)
+		}
+	}
+	sort.Ints(page.ErrorLines)
+	return page
+}
+
+// Lines validates the template form value with the data and functions form values, with the same options as the
+// page, and serves a window of its lines with their tokens and errors, as JSON, for showing huge templates a page at a
+// time. The offset form value is the first line, from 0, and limit how many lines, sourcePageLines by default.
+func (a *App) Lines(w http.ResponseWriter, r *http.Request) {
+	text, err := getFormValue(r, "template")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	rawData, err := getFormValue(r, "data")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	offset := 0
+	if s := r.FormValue("offset"); s != "" {
+		if offset, err = strconv.Atoi(s); err != nil || offset < 0 {
+			http.Error(w, fmt.Sprintf("failed to understand offset %q, it must be a line number from 0", s), http.StatusBadRequest)
+			return
+		}
+	}
+	limit := sourcePageLines
+	if s := r.FormValue("limit"); s != "" {
+		if limit, err = strconv.Atoi(s); err != nil || limit < 1 || limit > maxLinesLimit {
+			http.Error(w, fmt.Sprintf("failed to understand limit %q, it must be from 1 to %d", s, maxLinesLimit), http.StatusBadRequest)
+			return
+		}
+	}
+	data := a.forRequest().createData(r.Context(), text, rawData, r.FormValue("functions"), getOptions(r))
+	if r.Context().Err() != nil {
+		return
+	}
+	writeJSON(w, newLinesPage(data, offset, limit))
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestNewSourceView(t *testing.T) {
+	lines := make([]string, 2*sourcePageLines+10)
+	tplErrs := []templateError{
+		{Line: 5, Level: parseErrorLevel},
+		{Line: sourcePageLines + 3, Level: lintErrorLevel},
+		{Line: sourcePageLines + 3, Level: lintErrorLevel},
+		{Line: 2*sourcePageLines + 1, Level: execErrorLevel},
+		{Line: 7, Level: dataErrorLevel},
+		{Line: -1, Char: -1, Level: misunderstoodError},
+	}
+
+	view := newSourceView("line-", lines, nil, tplErrs, 0)
+	if view.First != 0 || view.Last != sourcePageLines-1 || view.Pages != 3 || !view.Navigable {
+		t.Errorf("unexpected view %d to %d of %d", view.First, view.Last, view.Pages)
+	}
+	if len(view.Errors) != 1 || view.Errors[0].Line != 5 {
+		t.Errorf("unexpected errors: %+v", view.Errors)
+	}
+	expected := []lineJump{{Line: sourcePageLines + 3, Page: 1}, {Line: 2*sourcePageLines + 1, Page: 2}}
+	if !reflect.DeepEqual(expected, view.Jumps) {
+		t.Errorf("expected jumps %+v, actual %+v", expected, view.Jumps)
+	}
+
+	// pages past the last are the last one
+	view = newSourceView("line-", lines, nil, tplErrs, 7)
+	if view.Page != 2 || view.First != 2*sourcePageLines || view.Last != len(lines)-1 || len(view.Errors) != 1 {
+		t.Errorf("unexpected view %d to %d of page %d: %+v", view.First, view.Last, view.Page, view.Errors)
+	}
+	if view := newSourceView("file-0-line-", lines, nil, nil, -1); view.Page != 0 || view.Navigable {
+		t.Errorf("unexpected view of page %d", view.Page)
+	}
+	if view := newSourceView("line-", []string{}, nil, nil, 0); view.Pages != 1 || view.Last != -1 {
+		t.Errorf("unexpected view of nothing %d to %d of %d", view.First, view.Last, view.Pages)
+	}
+}
+
+func TestHugeTemplatePage(t *testing.T) {
+	index, err := parseIndex()
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := &App{index: index}
+	text := strings.Repeat("{{.Name}}\n", 3*sourcePageLines) + "{{if}}"
+	post := func(page string) string {
+		form := url.Values{"from-raw-text": {text}, "line-page": {page}}
+		r := httptest.NewRequest("POST", "/", strings.NewReader(form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		a.Post(w, r)
+		return w.Body.String()
+	}
+
+	body := post("")
+	if !strings.Contains(body, `id="line-999"`) || strings.Contains(body, `id="line-1000"`) {
+		t.Error("expected only the first page of lines")
+	}
+	if !strings.Contains(body, `formaction="/#line-3000" name="line-page" value="3"`) ||
+		!strings.Contains(body, `name="line-page" value="1">Next lines`) {
+		t.Errorf("expected links to the other pages and the error")
+	}
+	body = post("3")
+	if !strings.Contains(body, `id="line-3000"`) || strings.Contains(body, `id="line-2999"`) ||
+		!strings.Contains(body, "missing value for if") {
+		t.Error("expected the last page with the error")
+	}
+}
+
+func TestLines(t *testing.T) {
+	a := &App{}
+	get := func(query url.Values) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		a.Lines(w, httptest.NewRequest("GET", "/api/v1/lines?"+query.Encode(), nil))
+		return w
+	}
+
+	text := "a\n{{.Name}}\n{{.Name.First}}\nb\n{{if}}"
+	w := get(url.Values{"template": {text}, "data": {`{"Name": "Bob"}`}, "offset": {"1"}, "limit": {"2"}})
+	var page linesPage
+	if err := json.Unmarshal(w.Body.Bytes(), &page); err != nil {
+		t.Fatalf("unexpected response: %d %s", w.Code, w.Body)
+	}
+	if page.Total != 5 || page.Offset != 1 || len(page.Lines) != 2 || !reflect.DeepEqual(page.ErrorLines, []int{2, 4}) {
+		t.Errorf("unexpected page: %+v", page)
+	}
+	if page.Lines[0].Line != 1 || page.Lines[0].Text != "{{.Name}}" || len(page.Lines[0].Tokens) == 0 {
+		t.Errorf("unexpected line: %+v", page.Lines[0])
+	}
+	if len(page.Lines[0].Errors) != 0 || len(page.Lines[1].Errors) != 1 || page.Lines[1].Errors[0].Level != execErrorLevel {
+		t.Errorf("unexpected errors: %+v %+v", page.Lines[0].Errors, page.Lines[1].Errors)
+	}
+
+	w = get(url.Values{"template": {text}, "offset": {"3"}})
+	if err := json.Unmarshal(w.Body.Bytes(), &page); err != nil || len(page.Lines) != 2 || len(page.Lines[1].Errors) == 0 {
+		t.Errorf("unexpected response: %d %s", w.Code, w.Body)
+	}
+	for _, query := range []url.Values{{"offset": {"-1"}}, {"limit": {"0"}}, {"limit": {"100000"}}} {
+		if w := get(query); w.Code != http.StatusBadRequest {
+			t.Errorf("unexpected response to %v: %d %s", query, w.Code, w.Body)
+		}
+	}
+}
@@ -0,0 +1,591 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	templateParse "text/template/parse"
+)
+
+const lintErrorLevel ErrorLevel = "lint"
+
+// Severity is how serious a lint error is
+type Severity string
+
+const (
+	offSeverity     Severity = "off"
+	infoSeverity    Severity = "info"
+	warningSeverity Severity = "warning"
+	errorSeverity   Severity = "error"
+)
+
+// lintConfig changes the severities of lint rules and adds custom ones
+type lintConfig struct {
+	// severities of rules by name, rules not in it keep their default
+	severities map[string]Severity
+	custom     []lintRule
+	// entryPoints are templates executed besides the one being linted, which count as used
+	entryPoints []string
+	// functions are those the request provides, from presets, extensions or Go code, any others called were mocked
+	functions []string
+}
+
+// customRule is a user defined lint rule reporting every match of Pattern in the template source. Message can refer to
+// the pattern's groups, like regexp replacement templates.
+type customRule struct {
+	Pattern  string
+	Message  string
+	Severity Severity
+}
+
+var (
+	definitionRegex = regexp.MustCompile("^{{-?\\s*(?:define|block)\\s+(\"(?:[^\"\\\\]|\\\\.)*\"|`[^`]*`)")
+	emptyBodyRegex  = regexp.MustCompile(`^\s*{{-?\s*end\s*-?}}`)
+)
+
+// source is a named template text parsed into the template being linted
+type source struct {
+	name string
+	text string
+}
+
+// lintRule checks parsed templates, and the sources they were parsed from, for likely mistakes that aren't errors to
+// the template packages
+type lintRule struct {
+	name     string
+	severity Severity
+	check    func(t *template.Template, sources []source, config lintConfig) []templateError
+}
+
+var lintRules = []lintRule{
+	{"unused-variable", warningSeverity, lintUnusedVariables},
+	{"unused-define", warningSeverity, lintUnusedDefines},
+	{"duplicate-define", warningSeverity, lintDuplicateDefines},
+	{"shadowed-variable", infoSeverity, lintShadowedVariables},
+	{"dot-rebinding", warningSeverity, lintDotRebinding},
+	{"recursive-template", errorSeverity, lintRecursiveTemplates},
+	{"printf", warningSeverity, lintPrintf},
+	{"mocked-arity", warningSeverity, lintMockedArity},
+	{"other-delimiters", warningSeverity, lintOtherDelimiters},
+}
+
+// parseLintConfig parses a JSON object of rule names to either the severity of a builtin rule or a custom rule
+func parseLintConfig(raw string) (lintConfig, error) {
+	var entries map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(raw), &entries); err != nil {
+		return lintConfig{}, err
+	}
+	names := make([]string, 0, len(entries))
+	for name := range entries {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	config := lintConfig{severities: make(map[string]Severity)}
+	for _, name := range names {
+		var severity Severity
+		if err := json.Unmarshal(entries[name], &severity); err == nil {
+			if !builtinRule(name) {
+				return lintConfig{}, fmt.Errorf("unknown rule %q", name)
+			}
+			if !validSeverity(severity) {
+				return lintConfig{}, fmt.Errorf("unknown severity %q for rule %q", severity, name)
+			}
+			config.severities[name] = severity
+			continue
+		}
+
+		var custom customRule
+		if err := json.Unmarshal(entries[name], &custom); err != nil {
+			return lintConfig{}, fmt.Errorf("rule %q must be a severity or an object with a pattern, message and severity", name)
+		}
+		if builtinRule(name) {
+			return lintConfig{}, fmt.Errorf("custom rule %q has the name of a builtin rule", name)
+		}
+		rule, err := custom.lintRule(name)
+		if err != nil {
+			return lintConfig{}, err
+		}
+		config.custom = append(config.custom, rule)
+	}
+	return config, nil
+}
+
+func builtinRule(name string) bool {
+	for _, rule := range lintRules {
+		if rule.name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func validSeverity(severity Severity) bool {
+	switch severity {
+	case offSeverity, infoSeverity, warningSeverity, errorSeverity:
+		return true
+	}
+	return false
+}
+
+// lintRule compiles a custom rule, which defaults to warnings
+func (c customRule) lintRule(name string) (lintRule, error) {
+	if c.Pattern == "" {
+		return lintRule{}, fmt.Errorf("custom rule %q has no pattern", name)
+	}
+	regex, err := regexp.Compile(c.Pattern)
+	if err != nil {
+		return lintRule{}, fmt.Errorf("custom rule %q: %v", name, err)
+	}
+	severity := c.Severity
+	if severity == "" {
+		severity = warningSeverity
+	}
+	if !validSeverity(severity) {
+		return lintRule{}, fmt.Errorf("unknown severity %q for rule %q", severity, name)
+	}
+	message := c.Message
+	if message == "" {
+		message = fmt.Sprintf("matches the pattern of rule %s", name)
+	}
+
+	return lintRule{name: name, severity: severity, check: func(_ *template.Template, sources []source, _ lintConfig) []templateError {
+		var tplErrs []templateError
+		for _, src := range sources {
+			for _, match := range regex.FindAllStringSubmatchIndex(src.text, -1) {
+				tplErrs = append(tplErrs, templateError{
+					File:        src.name,
+					Line:        lineOf(src.text, match[0]),
+					Char:        columnOf(src.text, match[0]),
+					Description: string(regex.ExpandString(nil, message, src.text, match)),
+				})
+			}
+		}
+		return tplErrs
+	}}, nil
+}
+
+// lint runs the lint rules config doesn't turn off against every template in t, which was parsed from sources in order
+func lint(t *template.Template, sources []source, config lintConfig) []templateError {
+	tplErrs := make([]templateError, 0)
+	for _, rule := range append(append([]lintRule{}, lintRules...), config.custom...) {
+		severity := rule.severity
+		if s, ok := config.severities[rule.name]; ok {
+			severity = s
+		}
+		if severity == offSeverity {
+			continue
+		}
+		ruleErrs := rule.check(t, sources, config)
+		for i := range ruleErrs {
+			ruleErrs[i].Level = lintErrorLevel
+			ruleErrs[i].Rule = rule.name
+			ruleErrs[i].Severity = severity
+		}
+		tplErrs = append(tplErrs, ruleErrs...)
+	}
+	return sortErrors(tplErrs)
+}
+
+// trees returns the parse trees of every template in t with something in them
+func trees(t *template.Template) []*templateParse.Tree {
+	var result []*templateParse.Tree
+	for _, tt := range t.Templates() {
+		if tt.Tree != nil && tt.Tree.Root != nil {
+			result = append(result, tt.Tree)
+		}
+	}
+	return result
+}
+
+// nodeError creates an error located at node
+func nodeError(tree *templateParse.Tree, node templateParse.Node, description string) templateError {
+	loc, _ := tree.ErrorContext(node)
+	line, char := parseLocation(loc)
+	return templateError{File: tree.ParseName, Line: line, Char: char, Description: description}
+}
+
+// variable is a declaration of a template variable
+type variable struct {
+	node *templateParse.VariableNode
+	used bool
+}
+
+// scopeWalker walks a tree keeping track of the variables in scope. Like the parser, a variable is in scope until the
+// end of the control structure it's declared in.
+type scopeWalker struct {
+	vars []*variable
+	// declared is called for every variable declaration, just before it's in scope
+	declared func(v *variable)
+	// read is called for every variable being read, with the declaration it refers to or nil for $ and undeclared ones
+	read func(node *templateParse.VariableNode, v *variable)
+}
+
+// lookup returns the innermost declaration of name, or nil
+func (w *scopeWalker) lookup(name string) *variable {
+	for i := len(w.vars) - 1; i >= 0; i-- {
+		if w.vars[i].node.Ident[0] == name {
+			return w.vars[i]
+		}
+	}
+	return nil
+}
+
+func (w *scopeWalker) walk(node templateParse.Node) {
+	switch n := node.(type) {
+	case *templateParse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, child := range n.Nodes {
+			w.walk(child)
+		}
+	case *templateParse.ActionNode:
+		w.pipe(n.Pipe)
+	case *templateParse.TemplateNode:
+		w.pipe(n.Pipe)
+	case *templateParse.IfNode:
+		w.branch(&n.BranchNode)
+	case *templateParse.RangeNode:
+		w.branch(&n.BranchNode)
+	case *templateParse.WithNode:
+		w.branch(&n.BranchNode)
+	case *templateParse.CommandNode:
+		for _, arg := range n.Args {
+			w.walk(arg)
+		}
+	case *templateParse.PipeNode:
+		w.pipe(n)
+	case *templateParse.ChainNode:
+		w.walk(n.Node)
+	case *templateParse.VariableNode:
+		if w.read != nil {
+			w.read(n, w.lookup(n.Ident[0]))
+		}
+	}
+}
+
+func (w *scopeWalker) pipe(p *templateParse.PipeNode) {
+	if p == nil {
+		return
+	}
+	// commands are evaluated before the variables they're assigned to are declared
+	for _, cmd := range p.Cmds {
+		w.walk(cmd)
+	}
+	if p.IsAssign {
+		// assigning to a variable doesn't read it
+		return
+	}
+	for _, decl := range p.Decl {
+		v := &variable{node: decl}
+		if w.declared != nil {
+			w.declared(v)
+		}
+		w.vars = append(w.vars, v)
+	}
+}
+
+func (w *scopeWalker) branch(n *templateParse.BranchNode) {
+	depth := len(w.vars)
+	w.pipe(n.Pipe)
+	w.walk(n.List)
+	w.walk(n.ElseList)
+	w.vars = w.vars[:depth]
+}
+
+// lintUnusedVariables warns about variables that are declared but never read
+func lintUnusedVariables(t *template.Template, _ []source, _ lintConfig) []templateError {
+	var tplErrs []templateError
+	for _, tree := range trees(t) {
+		var declared []*variable
+		w := &scopeWalker{
+			declared: func(v *variable) { declared = append(declared, v) },
+			read: func(_ *templateParse.VariableNode, v *variable) {
+				if v != nil {
+					v.used = true
+				}
+			},
+		}
+		w.walk(tree.Root)
+
+		for _, v := range declared {
+			if !v.used {
+				tplErrs = append(tplErrs, nodeError(tree, v.node, fmt.Sprintf("variable %s is declared but never used", v.node.Ident[0])))
+			}
+		}
+	}
+	return tplErrs
+}
+
+// lintUnusedDefines warns about defined templates that can't be reached with {{template}} from the ones executed. A
+// {{block}} defines a template and invokes it, so it's always used.
+func lintUnusedDefines(t *template.Template, _ []source, config lintConfig) []templateError {
+	reached := map[string]bool{t.Name(): true}
+	queue := []string{t.Name()}
+	for _, name := range config.entryPoints {
+		if !reached[name] {
+			reached[name] = true
+			queue = append(queue, name)
+		}
+	}
+	for len(queue) > 0 {
+		tt := t.Lookup(queue[0])
+		queue = queue[1:]
+		if tt == nil || tt.Tree == nil || tt.Tree.Root == nil {
+			continue
+		}
+		walk(tt.Tree.Root, func(node templateParse.Node, _ []templateParse.Node) bool {
+			if n, ok := node.(*templateParse.TemplateNode); ok && !reached[n.Name] {
+				reached[n.Name] = true
+				queue = append(queue, n.Name)
+			}
+			return true
+		})
+	}
+
+	var tplErrs []templateError
+	for _, tree := range trees(t) {
+		if !reached[tree.Name] {
+			tplErrs = append(tplErrs, nodeError(tree, tree.Root, fmt.Sprintf("template %q is defined but never used", tree.Name)))
+		}
+	}
+	return tplErrs
+}
+
+// definition is where a template is defined in a source
+type definition struct {
+	source string
+	line   int
+	char   int
+	// empty definitions never replace others
+	empty bool
+}
+
+// findDefinitions lexically finds the {{define}} and {{block}} actions in text by template name
+func findDefinitions(src source) map[string][]definition {
+	definitions := make(map[string][]definition)
+	for _, a := range findActions(src.text) {
+		matches := definitionRegex.FindStringSubmatch(src.text[a.start:a.end])
+		if matches == nil {
+			continue
+		}
+		name, err := strconv.Unquote(matches[1])
+		if err != nil {
+			continue
+		}
+		definitions[name] = append(definitions[name], definition{
+			source: src.name,
+			line:   lineOf(src.text, a.start),
+			char:   columnOf(src.text, a.start),
+			empty:  emptyBodyRegex.MatchString(src.text[a.end:]),
+		})
+	}
+	return definitions
+}
+
+// lintDuplicateDefines warns about templates defined in more than one source, where text/template silently keeps the
+// last definition. Defining a template twice in the same source is already a parse error.
+func lintDuplicateDefines(_ *template.Template, sources []source, _ lintConfig) []templateError {
+	var tplErrs []templateError
+	previous := make(map[string]definition)
+	for _, src := range sources {
+		definitions := findDefinitions(src)
+		for name, defs := range definitions {
+			for _, def := range defs {
+				if def.empty {
+					continue
+				}
+				prev, ok := previous[name]
+				if ok && prev.source != src.name {
+					tplErrs = append(tplErrs,
+						templateError{File: prev.source, Line: prev.line, Char: prev.char, Description: fmt.Sprintf(
+							"template %q is defined again at %s:%d, which replaces this definition", name, def.source, def.line+1)},
+						templateError{File: def.source, Line: def.line, Char: def.char, Description: fmt.Sprintf(
+							"template %q replaces the definition at %s:%d", name, prev.source, prev.line+1)})
+				}
+				previous[name] = def
+			}
+		}
+	}
+	return tplErrs
+}
+
+// lintShadowedVariables warns about variables declared with the same name as one already in scope, which hides the
+// outer one until the end of the block
+func lintShadowedVariables(t *template.Template, _ []source, _ lintConfig) []templateError {
+	var tplErrs []templateError
+	for _, tree := range trees(t) {
+		w := &scopeWalker{}
+		w.declared = func(v *variable) {
+			name := v.node.Ident[0]
+			if prev := w.lookup(name); prev != nil {
+				prevErr := nodeError(tree, prev.node, "")
+				tplErrs = append(tplErrs, nodeError(tree, v.node, fmt.Sprintf(
+					"variable %s shadows the %s declared on line %d", name, name, prevErr.Line+1)))
+			}
+		}
+		w.walk(tree.Root)
+	}
+	return tplErrs
+}
+
+// lintDotRebinding warns about fields inside a {{with}} or {{range}} that repeat the path dot was rebound to, like
+// .User.Name inside {{with .User}}, which looks up .User inside the user
+func lintDotRebinding(t *template.Template, _ []source, _ lintConfig) []templateError {
+	var tplErrs []templateError
+	for _, tree := range trees(t) {
+		walk(tree.Root, func(node templateParse.Node, ancestors []templateParse.Node) bool {
+			field, ok := node.(*templateParse.FieldNode)
+			if !ok {
+				return true
+			}
+			branch, rebound, outermost := dotBinding(append(ancestors, node))
+			if rebound == nil || len(field.Ident) < len(rebound.Ident) {
+				return true
+			}
+			for i, ident := range rebound.Ident {
+				if field.Ident[i] != ident {
+					return true
+				}
+			}
+
+			var suggestions []string
+			if rest := field.Ident[len(rebound.Ident):]; len(rest) > 0 {
+				suggestions = append(suggestions, "`."+strings.Join(rest, ".")+"`")
+			} else if !outermost {
+				suggestions = append(suggestions, "`.`")
+			}
+			if outermost {
+				suggestions = append(suggestions, "`$"+field.String()+"`")
+			}
+			dot := fmt.Sprintf("`%s`", rebound)
+			if _, ok := branch.(*templateParse.RangeNode); ok {
+				dot = "each element of " + dot
+			}
+
+			tplErr := nodeError(tree, field, fmt.Sprintf("dot is %s here, so `%s` is looked up inside it; did you mean %s?",
+				dot, field, strings.Join(suggestions, " or ")))
+			// fields are located at their last identifier
+			if tplErr.Char >= 0 {
+				tplErr.Char -= len(field.String()) - len(field.Ident[len(field.Ident)-1]) - 1
+			}
+			tplErrs = append(tplErrs, tplErr)
+			return true
+		})
+	}
+	return tplErrs
+}
+
+// dotBinding returns the innermost {{with}} or {{range}} whose body the last node of chain is in, along with the field
+// it binds dot to, if its pipeline is just a field. outermost reports whether dot is the data outside of it.
+func dotBinding(chain []templateParse.Node) (branch templateParse.Node, field *templateParse.FieldNode, outermost bool) {
+	rebinds := func(i int) *templateParse.BranchNode {
+		var b *templateParse.BranchNode
+		switch n := chain[i].(type) {
+		case *templateParse.WithNode:
+			b = &n.BranchNode
+		case *templateParse.RangeNode:
+			b = &n.BranchNode
+		default:
+			return nil
+		}
+		// dot is only rebound in the body, not in the pipeline or the else branch
+		if chain[i+1] != templateParse.Node(b.List) {
+			return nil
+		}
+		return b
+	}
+
+	for i := len(chain) - 2; i >= 0; i-- {
+		b := rebinds(i)
+		if b == nil {
+			continue
+		}
+		outermost = true
+		for j := i - 1; j >= 0; j-- {
+			if rebinds(j) != nil {
+				outermost = false
+			}
+		}
+		if b.Pipe != nil && len(b.Pipe.Cmds) == 1 && len(b.Pipe.Cmds[0].Args) == 1 {
+			field, _ = b.Pipe.Cmds[0].Args[0].(*templateParse.FieldNode)
+		}
+		return chain[i], field, outermost
+	}
+	return nil, nil, false
+}
+
+// call is a {{template}} invocation that always runs when the template it's in does
+type call struct {
+	tree *templateParse.Tree
+	node *templateParse.TemplateNode
+}
+
+// lintRecursiveTemplates warns about templates invoking themselves, directly or through others, without any
+// {{if}}, {{range}} or {{with}} around the invocations that could end the recursion. Executing them exhausts the stack.
+func lintRecursiveTemplates(t *template.Template, _ []source, _ lintConfig) []templateError {
+	calls := make(map[string][]call)
+	var names []string
+	for _, tree := range trees(t) {
+		names = append(names, tree.Name)
+		walk(tree.Root, func(node templateParse.Node, ancestors []templateParse.Node) bool {
+			switch n := node.(type) {
+			case *templateParse.IfNode, *templateParse.RangeNode, *templateParse.WithNode:
+				// anything inside might not run
+				return false
+			case *templateParse.TemplateNode:
+				calls[tree.Name] = append(calls[tree.Name], call{tree: tree, node: n})
+			}
+			return true
+		})
+	}
+	sort.Strings(names)
+
+	var tplErrs []templateError
+	reported := make(map[string]bool)
+	for _, name := range names {
+		if reported[name] {
+			continue
+		}
+		cycle := findCycle(name, calls)
+		if cycle == nil {
+			continue
+		}
+		path := []string{fmt.Sprintf("%q", name)}
+		for _, c := range cycle {
+			reported[c.node.Name] = true
+			path = append(path, fmt.Sprintf("%q", c.node.Name))
+		}
+		tplErrs = append(tplErrs, nodeError(cycle[0].tree, cycle[0].node, fmt.Sprintf(
+			"template %q always invokes itself, so executing it never ends: %s", name, strings.Join(path, " → "))))
+	}
+	return tplErrs
+}
+
+// findCycle returns the calls leading from the template name back to itself, or nil if there are none
+func findCycle(name string, calls map[string][]call) []call {
+	visited := make(map[string]bool)
+	var search func(from string, path []call) []call
+	search = func(from string, path []call) []call {
+		for _, c := range calls[from] {
+			next := append(path[:len(path):len(path)], c)
+			if c.node.Name == name {
+				return next
+			}
+			if visited[c.node.Name] {
+				continue
+			}
+			visited[c.node.Name] = true
+			if cycle := search(c.node.Name, next); cycle != nil {
+				return cycle
+			}
+		}
+		return nil
+	}
+	return search(name, nil)
+}
@@ -0,0 +1,291 @@
+package main
+
+import (
+	"context"
+	"testing"
+	textTemplate "text/template"
+)
+
+func mustParse(t *testing.T, text string) *textTemplate.Template {
+	parsedT, errs := parse(context.Background(), text, textTemplate.New("base"))
+	if len(errs) != 0 {
+		t.Fatalf("unexpected parse errors: %v", errs)
+	}
+	return parsedT
+}
+
+func lintText(t *testing.T, text string) []templateError {
+	return lint(mustParse(t, text), []source{{name: "base", text: text}}, lintConfig{})
+}
+
+func TestLintUnusedVariable(t *testing.T) {
+	errs := lintText(t, "a\n{{$name := .Name}}b")
+	if len(errs) != 1 {
+		t.Fatalf("unexpected errors found: %v", errs)
+	}
+	assertError(t, templateError{
+		Line:        1,
+		Char:        2,
+		Description: "variable $name is declared but never used",
+		Level:       lintErrorLevel,
+	}, errs[0])
+	if errs[0].Rule != "unused-variable" {
+		t.Errorf("unexpected rule: %s", errs[0].Rule)
+	}
+}
+
+func TestLintUsedVariable(t *testing.T) {
+	errs := lintText(t, "{{$name := .Name}}{{if .Show}}{{$name | printf \"%s\"}}{{end}}")
+	if len(errs) != 0 {
+		t.Errorf("unexpected errors found: %v", errs)
+	}
+}
+
+func TestLintUnusedRangeVariables(t *testing.T) {
+	errs := lintText(t, "{{range $i, $e := .Items}}{{$e}}{{end}}")
+	if len(errs) != 1 {
+		t.Fatalf("unexpected errors found: %v", errs)
+	}
+	assertError(t, templateError{
+		Line:        0,
+		Char:        8,
+		Description: "variable $i is declared but never used",
+		Level:       lintErrorLevel,
+	}, errs[0])
+}
+
+func TestLintUnusedShadowedVariable(t *testing.T) {
+	// the inner declaration is read, the outer one isn't
+	errs := lintText(t, "{{$x := 1}}{{with .A}}{{$x := 2}}{{$x}}{{end}}")
+	if len(errs) != 2 || errs[1].Rule != "shadowed-variable" {
+		t.Fatalf("unexpected errors found: %v", errs)
+	}
+	assertError(t, templateError{
+		Line:        0,
+		Char:        2,
+		Description: "variable $x is declared but never used",
+		Level:       lintErrorLevel,
+	}, errs[0])
+}
+
+func TestLintAssignmentIsNotUse(t *testing.T) {
+	errs := lintText(t, "{{$x := 1}}{{$x = 2}}")
+	if len(errs) != 1 {
+		t.Fatalf("unexpected errors found: %v", errs)
+	}
+	assertError(t, templateError{
+		Line:        0,
+		Char:        2,
+		Description: "variable $x is declared but never used",
+		Level:       lintErrorLevel,
+	}, errs[0])
+}
+
+func TestLintVariableInDefine(t *testing.T) {
+	errs := lintText(t, "{{define \"a\"}}{{$y := .}}{{end}}{{template \"a\" .}}")
+	if len(errs) != 1 {
+		t.Fatalf("unexpected errors found: %v", errs)
+	}
+	if errs[0].Description != "variable $y is declared but never used" {
+		t.Errorf("unexpected error: %v", errs[0])
+	}
+}
+
+func TestLintUnusedDefine(t *testing.T) {
+	errs := lintText(t, "a\n{{define \"a\"}}x{{end}}{{define \"b\"}}{{template \"c\"}}{{end}}{{define \"c\"}}c{{end}}")
+	if len(errs) != 3 {
+		t.Fatalf("unexpected errors found: %v", errs)
+	}
+	// a template only used by an unused one is unused too
+	assertError(t, templateError{
+		Line:        1,
+		Char:        14,
+		Description: `template "a" is defined but never used`,
+		Level:       lintErrorLevel,
+	}, errs[0])
+	assertError(t, templateError{
+		Line:        1,
+		Char:        73,
+		Description: `template "c" is defined but never used`,
+		Level:       lintErrorLevel,
+	}, errs[2])
+}
+
+func TestLintUsedDefines(t *testing.T) {
+	errs := lintText(t, "{{define \"a\"}}{{template \"b\"}}{{end}}{{define \"b\"}}b{{end}}{{template \"a\"}}{{block \"c\" .}}c{{end}}")
+	if len(errs) != 0 {
+		t.Errorf("unexpected errors found: %v", errs)
+	}
+}
+
+func TestLintDuplicateDefines(t *testing.T) {
+	sources := []source{
+		{name: "a.tmpl", text: "{{define \"x\"}}a{{end}}{{define \"empty\"}}{{end}}"},
+		{name: "b.tmpl", text: "b\n{{- define \"x\" -}}\nb{{end}}{{define \"empty\"}}b{{end}}"},
+	}
+	errs := lintDuplicateDefines(nil, sources, lintConfig{})
+	if len(errs) != 2 {
+		t.Fatalf("unexpected errors found: %v", errs)
+	}
+	errs = sortErrors(errs)
+	assertError(t, templateError{
+		Line:        0,
+		Char:        0,
+		Description: `template "x" is defined again at b.tmpl:2, which replaces this definition`,
+	}, errs[0])
+	assertError(t, templateError{
+		Line:        1,
+		Char:        0,
+		Description: `template "x" replaces the definition at a.tmpl:1`,
+	}, errs[1])
+}
+
+func TestLintDefinesInOneSource(t *testing.T) {
+	errs := lintText(t, "{{define \"x\"}}{{end}}{{define \"x\"}}x{{end}}")
+	if len(errs) != 1 || errs[0].Rule != "unused-define" {
+		t.Errorf("unexpected errors found: %v", errs)
+	}
+}
+
+func TestLintShadowedVariable(t *testing.T) {
+	errs := lintText(t, "{{$x := 1}}\n{{with .A}}{{$x := 2}}{{$x}}{{end}}{{$x}}")
+	if len(errs) != 1 {
+		t.Fatalf("unexpected errors found: %v", errs)
+	}
+	assertError(t, templateError{
+		Line:        1,
+		Char:        13,
+		Description: "variable $x shadows the $x declared on line 1",
+		Level:       lintErrorLevel,
+	}, errs[0])
+}
+
+func TestLintWithRebinding(t *testing.T) {
+	errs := lintText(t, "{{with .User}}{{.User.Name}} {{.Name}}{{else}}{{.User}}{{end}}")
+	if len(errs) != 1 {
+		t.Fatalf("unexpected errors found: %v", errs)
+	}
+	assertError(t, templateError{
+		Line:        0,
+		Char:        16,
+		Description: "dot is `.User` here, so `.User.Name` is looked up inside it; did you mean `.Name` or `$.User.Name`?",
+		Level:       lintErrorLevel,
+	}, errs[0])
+}
+
+func TestLintRangeRebinding(t *testing.T) {
+	errs := lintText(t, "{{range .Items}}{{with .A}}{{.A}}{{end}}{{len .Items}}{{end}}")
+	if len(errs) != 2 {
+		t.Fatalf("unexpected errors found: %v", errs)
+	}
+	assertError(t, templateError{
+		Line:        0,
+		Char:        29,
+		Description: "dot is `.A` here, so `.A` is looked up inside it; did you mean `.`?",
+		Level:       lintErrorLevel,
+	}, errs[0])
+	assertError(t, templateError{
+		Line:        0,
+		Char:        46,
+		Description: "dot is each element of `.Items` here, so `.Items` is looked up inside it; did you mean `$.Items`?",
+		Level:       lintErrorLevel,
+	}, errs[1])
+}
+
+func TestLintRecursiveTemplates(t *testing.T) {
+	errs := lintText(t, "{{define \"a\"}}{{template \"b\" .}}{{end}}\n"+
+		"{{define \"b\"}}x{{template \"a\" .}}{{end}}\n"+
+		"{{define \"c\"}}{{template \"c\"}}{{end}}\n"+
+		"{{define \"list\"}}{{range .}}{{template \"list\" .Children}}{{end}}{{end}}\n"+
+		"{{template \"a\"}}{{template \"c\"}}{{template \"list\"}}")
+	if len(errs) != 2 {
+		t.Fatalf("unexpected errors found: %v", errs)
+	}
+	assertError(t, templateError{
+		Line:        0,
+		Char:        25,
+		Description: `template "a" always invokes itself, so executing it never ends: "a" → "b" → "a"`,
+		Level:       lintErrorLevel,
+	}, errs[0])
+	assertError(t, templateError{
+		Line:        2,
+		Char:        25,
+		Description: `template "c" always invokes itself, so executing it never ends: "c" → "c"`,
+		Level:       lintErrorLevel,
+	}, errs[1])
+}
+
+func TestLintConfig(t *testing.T) {
+	config, err := parseLintConfig(`{"unused-variable": "off", "printf": "error"}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	text := "{{$x := 1}}{{printf \"%d\"}}{{$y := 1}}{{$y := 2}}{{$y}}"
+	errs := lint(mustParse(t, text), []source{{name: "base", text: text}}, config)
+	if len(errs) != 2 {
+		t.Fatalf("unexpected errors found: %v", errs)
+	}
+	if errs[0].Rule != "printf" || errs[0].Severity != errorSeverity {
+		t.Errorf("unexpected error: %v", errs[0])
+	}
+	if errs[1].Rule != "shadowed-variable" || errs[1].Severity != infoSeverity {
+		t.Errorf("unexpected error: %v", errs[1])
+	}
+}
+
+func TestBadLintConfig(t *testing.T) {
+	for _, raw := range []string{`[]`, `{"unused": "off"}`, `{"printf": "fatal"}`} {
+		data := (&App{}).createData(context.Background(), "a", "", "", options{LintConfig: raw})
+		if len(data.Errors) != 1 || data.Errors[0].Level != misunderstoodError {
+			t.Errorf("%s: unexpected errors found: %v", raw, data.Errors)
+		}
+	}
+}
+
+func TestCustomLintRules(t *testing.T) {
+	config, err := parseLintConfig(`{
+		"no-secret": {"pattern": "\\.Secret\\b", "message": "secrets must not be rendered", "severity": "error"},
+		"escape-bio": {"pattern": "(\\.\\w*Bio)\\s*}}", "message": "pipe $1 through html"},
+		"unused-variable": "off"
+	}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	text := "{{$x := .Secret}}\n{{.UserBio}} {{.UserBio | html}}"
+	errs := lint(mustParse(t, text), []source{{name: "base", text: text}}, config)
+	if len(errs) != 2 {
+		t.Fatalf("unexpected errors found: %v", errs)
+	}
+	assertError(t, templateError{
+		Line:        0,
+		Char:        8,
+		Description: "secrets must not be rendered",
+		Level:       lintErrorLevel,
+	}, errs[0])
+	if errs[0].Rule != "no-secret" || errs[0].Severity != errorSeverity {
+		t.Errorf("unexpected error: %v", errs[0])
+	}
+	assertError(t, templateError{
+		Line:        1,
+		Char:        2,
+		Description: "pipe .UserBio through html",
+		Level:       lintErrorLevel,
+	}, errs[1])
+	if errs[1].Severity != warningSeverity {
+		t.Errorf("unexpected severity: %s", errs[1].Severity)
+	}
+}
+
+func TestBadCustomLintRules(t *testing.T) {
+	for _, raw := range []string{
+		`{"printf": {"pattern": "x"}}`,
+		`{"custom": {"pattern": "("}}`,
+		`{"custom": {"message": "no pattern"}}`,
+		`{"custom": {"pattern": "x", "severity": "fatal"}}`,
+		`{"custom": 1}`,
+	} {
+		if _, err := parseLintConfig(raw); err == nil {
+			t.Errorf("%s: expected an error", raw)
+		}
+	}
+}
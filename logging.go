@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-chi/chi/middleware"
+)
+
+// debugPaths are requested for every keystroke of editors, so they're logged at debug level rather than info
+var debugPaths = map[string]bool{"/api/v1/complete": true, "/api/v1/tokens": true}
+
+// newLogger returns a logger writing to w at level, debug, info, warn or error, in format, text or json. Only one of
+// every sample debug records is kept, if it's above 1, as they're the numerous ones.
+func newLogger(w io.Writer, level, format string, sample int) (*slog.Logger, error) {
+	var l slog.Level
+	if err := l.UnmarshalText([]byte(level)); err != nil {
+		return nil, fmt.Errorf("unknown log level %q, it can be debug, info, warn or error", level)
+	}
+	opts := &slog.HandlerOptions{Level: l}
+	var handler slog.Handler
+	switch format {
+	case "text":
+		handler = slog.NewTextHandler(w, opts)
+	case "json":
+		handler = slog.NewJSONHandler(w, opts)
+	default:
+		return nil, fmt.Errorf("unknown log format %q, it can be text or json", format)
+	}
+	if sample > 1 {
+		handler = &samplingHandler{Handler: handler, sample: uint64(sample), count: new(uint64)}
+	}
+	return slog.New(handler), nil
+}
+
+// samplingHandler drops all but one of every sample debug records
+type samplingHandler struct {
+	slog.Handler
+	sample uint64
+	// count is shared with the handlers derived from it, so they sample together
+	count *uint64
+}
+
+func (h *samplingHandler) Handle(ctx context.Context, record slog.Record) error {
+	if record.Level <= slog.LevelDebug && (atomic.AddUint64(h.count, 1)-1)%h.sample != 0 {
+		return nil
+	}
+	return h.Handler.Handle(ctx, record)
+}
+
+func (h *samplingHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &samplingHandler{Handler: h.Handler.WithAttrs(attrs), sample: h.sample, count: h.count}
+}
+
+func (h *samplingHandler) WithGroup(name string) slog.Handler {
+	return &samplingHandler{Handler: h.Handler.WithGroup(name), sample: h.sample, count: h.count}
+}
+
+// requestStats are the outcome of the validations of a request, for its access log
+type requestStats struct {
+	mu          sync.Mutex
+	validations int
+	errors      int
+	// templateBytes is the size of the templates validated
+	templateBytes int
+	validating    time.Duration
+}
+
+type requestStatsKey struct{}
+
+// recordValidation adds a validation of a template of size bytes, which found errors and took took, to the stats of
+// the request of ctx, if it has any
+func recordValidation(ctx context.Context, size, errors int, took time.Duration) {
+	stats, ok := ctx.Value(requestStatsKey{}).(*requestStats)
+	if !ok {
+		return
+	}
+	stats.mu.Lock()
+	defer stats.mu.Unlock()
+	stats.validations++
+	stats.errors += errors
+	stats.templateBytes += size
+	stats.validating += took
+}
+
+// accessLog logs every request to logger with its status, size and duration, and the outcome of its validations
+func accessLog(logger *slog.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			stats := &requestStats{}
+			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+			next.ServeHTTP(ww, r.WithContext(context.WithValue(r.Context(), requestStatsKey{}, stats)))
+
+			status := ww.Status()
+			if status == 0 {
+				// nothing was written, which is a 200
+				status = http.StatusOK
+			}
+			level := slog.LevelInfo
+			switch {
+			case status >= http.StatusInternalServerError:
+				level = slog.LevelError
+			case debugPaths[r.URL.Path]:
+				level = slog.LevelDebug
+			}
+			attrs := []slog.Attr{
+				slog.String("method", r.Method),
+				slog.String("path", r.URL.Path),
+				slog.String("remote", r.RemoteAddr),
+				slog.Int("status", status),
+				slog.Int("bytes", ww.BytesWritten()),
+				slog.Duration("duration", time.Since(start)),
+			}
+			stats.mu.Lock()
+			if stats.validations > 0 {
+				attrs = append(attrs, slog.Group("validation",
+					slog.Int("count", stats.validations),
+					slog.Int("errors", stats.errors),
+					slog.Int("template_bytes", stats.templateBytes),
+					slog.Duration("duration", stats.validating)))
+			}
+			stats.mu.Unlock()
+			logger.LogAttrs(r.Context(), level, "request", attrs...)
+		})
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestNewLogger(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := newLogger(&buf, "debug", "json", 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 7; i++ {
+		logger.Debug("often", "i", i)
+	}
+	logger.With("component", "test").Info("rarely")
+	var kept []int
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var record map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			t.Fatalf("expected JSON: %v", err)
+		}
+		if record["msg"] == "often" {
+			kept = append(kept, int(record["i"].(float64)))
+		}
+	}
+	// one of every 3 debug records is kept, but every other
+	if len(kept) != 3 || kept[0] != 0 || kept[1] != 3 || kept[2] != 6 || !strings.Contains(buf.String(), `"component":"test"`) {
+		t.Errorf("unexpected logs: %s", buf.String())
+	}
+
+	buf.Reset()
+	logger, _ = newLogger(&buf, "warn", "text", 1)
+	logger.Info("hidden")
+	logger.Warn("shown")
+	if !strings.Contains(buf.String(), "shown") || strings.Contains(buf.String(), "hidden") {
+		t.Errorf("unexpected logs: %s", buf.String())
+	}
+
+	if _, err := newLogger(&buf, "loud", "text", 1); err == nil {
+		t.Error("expected the level to be rejected")
+	}
+	if _, err := newLogger(&buf, "info", "xml", 1); err == nil {
+		t.Error("expected the format to be rejected")
+	}
+}
+
+func TestAccessLog(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelInfo}))
+	a := &App{}
+	handler := accessLog(logger)(http.HandlerFunc(a.Render))
+
+	form := url.Values{"template": {"\n{{.Name.First}}"}, "data": {`{"Name": "Bob"}`}}
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/api/v1/render?"+form.Encode(), nil))
+	var record struct {
+		Msg        string
+		Path       string
+		Status     int
+		Validation struct {
+			Count         int
+			Errors        int
+			TemplateBytes int `json:"template_bytes"`
+		}
+	}
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatalf("expected JSON: %v, %s", err, buf.String())
+	}
+	if record.Msg != "request" || record.Path != "/api/v1/render" || record.Status != http.StatusUnprocessableEntity ||
+		record.Validation.Count != 1 || record.Validation.Errors != 1 || record.Validation.TemplateBytes != 16 {
+		t.Errorf("unexpected log: %s", buf.String())
+	}
+
+	// completions are logged at debug level
+	buf.Reset()
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/api/v1/complete", nil))
+	if buf.Len() != 0 {
+		t.Errorf("unexpected log: %s", buf.String())
+	}
+}
@@ -2,15 +2,23 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"embed"
-	"encoding/json"
+	"encoding/base64"
+	"errors"
+	"flag"
 	"fmt"
 	htmlTemplate "html/template"
 	"io"
 	"log"
+	"log/slog"
 	"net/http"
+	"os"
+	"sort"
+	"strconv"
 	"strings"
 	textTemplate "text/template"
+	"time"
 
 	"github.com/go-chi/chi"
 	"github.com/go-chi/chi/middleware"
@@ -18,7 +26,57 @@ import (
 
 const port = 8080
 
-//go:embed index.html
+// inputTemplateName is what the template being validated is named
+const inputTemplateName = "input template"
+
+var (
+	suitesFlag       = flag.String("suites", "suites", "`directory` the server keeps saved suites in")
+	sharesFlag       = flag.String("shares", "shares", "`directory` the server keeps shared sessions in until they expire")
+	shareTTLFlag     = flag.Duration("share-ttl", 7*24*time.Hour, "longest `duration` shared sessions are kept, which is also how long they're kept by default")
+	sessionsFlag     = flag.Int("edit-sessions", 1000, "most `number` of sessions of templates being edited kept in memory for incremental validation")
+	sessionTTLFlag   = flag.Duration("edit-session-ttl", 30*time.Minute, "`duration` sessions of templates being edited are kept since they were last edited")
+	sealKeyFlag      = flag.String("encryption-key", "", "`file` of a secret saved suites are encrypted with, they're saved in plaintext without one")
+	webhookFlag      = flag.String("webhook", "", "`URL` to post a summary to when a suite finishes running")
+	asyncSuitesFlag  = flag.Int("async-suites", 4, "most `suites` run in the background at once, for runs with the async form value")
+	asyncTimeoutFlag = flag.Duration("async-suite-timeout", 5*time.Minute, "longest `duration` a suite runs in the background")
+	telegramFlag     = flag.String("telegram-token", "", "`token` of a Telegram bot to reply to templates sent to it")
+	slackFlag        = flag.String("slack-signing-secret", "", "signing `secret` of the Slack app whose slash command is served at /slack/command")
+	fetchHostsFlag   = flag.String("fetch-hosts", "", "comma separated `hosts` batches may fetch templates from over HTTP(S), * for any")
+	fetchBucketsFlag = flag.String("fetch-buckets", "", "comma separated S3 and Google Cloud Storage `buckets` batches may fetch templates from, * for any")
+	fetchAuthFlag    = flag.String("fetch-tokens", "", "comma separated `host=token` pairs of bearer tokens to fetch templates with")
+	chromeFlag       = flag.String("chrome", "", "`path` of a Chrome or Chromium binary to take screenshots of HTML output with")
+	browsersFlag     = flag.Int("browsers", 2, "most `browsers` taking screenshots at once, more are refused")
+	goServeFlag      = flag.Bool("go-functions", false, "interpret the Go functions validations define, which runs their code on the server")
+	pluginsFlag      = flag.String("plugins", "", "comma separated Go plugin `files` exporting Funcs, a template.FuncMap of functions to add")
+	extensionsFlag   = flag.String("extensions", "", "comma separated `commands` serving functions to add over their standard input and output")
+	denyFlag         = flag.String("deny-functions", "", "comma separated `functions`, or presets like time, templates may not call")
+	profilesFlag     = flag.String("profiles", "", "JSON `file` of named profiles of options requests can select, besides exploratory, standard and ci-strict")
+	presetsFlag      = flag.String("function-presets", "", "JSON `file` of named presets of functions requests can select, besides stdlib, sprig and helm")
+	previewFlag      = flag.Int("preview-iterations", 0, "only execute the first `N` iterations of ranges, unless a request asks for full execution, 0 to execute all")
+	mockableFlag     = flag.String("mockable-functions", "", "comma separated `patterns`, like lookup*, of the only unknown functions which are mocked")
+
+	smtpFlag         = flag.String("smtp", "", "`host:port` of an SMTP relay to send test emails through")
+	smtpFromFlag     = flag.String("smtp-from", "", "`address` test emails are sent from")
+	smtpToFlag       = flag.String("smtp-to", "", "comma separated `addresses`, or @domains, test emails may be sent to")
+	smtpUserFlag     = flag.String("smtp-user", "", "`user` to authenticate to the SMTP relay as, if it needs it")
+	smtpPasswordFlag = flag.String("smtp-password", "", "`password` to authenticate to the SMTP relay with")
+
+	readHeaderTimeoutFlag = flag.Duration("read-header-timeout", 5*time.Second, "longest `duration` to read the headers of a request")
+	readTimeoutFlag       = flag.Duration("read-timeout", time.Minute, "longest `duration` to read a request, with its body")
+	writeTimeoutFlag      = flag.Duration("write-timeout", 2*time.Minute, "longest `duration` from reading the headers of a request to writing the response, which includes validating")
+	idleTimeoutFlag       = flag.Duration("idle-timeout", 2*time.Minute, "longest `duration` to keep an idle connection open")
+	logLevelFlag          = flag.String("log-level", "info", "`level` of the least severe logs written, debug, info, warn or error")
+	logFormatFlag         = flag.String("log-format", "text", "`format` of the logs, text or json")
+	logSampleFlag         = flag.Int("log-debug-sample", 1, "only write one of every `N` debug logs, like the access logs of completions and tokens")
+	logFileFlag           = flag.String("log-file", "", "`file` to write the logs to instead of stderr, which is rotated")
+	logMaxSizeFlag        = flag.Int("log-max-size", 100, "`megabytes` the log file is rotated after, 0 to not rotate by size")
+	logMaxAgeFlag         = flag.Duration("log-max-age", 24*time.Hour, "`duration` the log file is rotated after, 0 to not rotate by age")
+	logBackupsFlag        = flag.Int("log-backups", 7, "`number` of rotated log files kept")
+	sentryFlag            = flag.String("sentry-dsn", "", "`DSN` of a Sentry or GlitchTip project to report panics and errors in formats the validator doesn't understand to")
+	h2cFlag               = flag.Bool("h2c", false, "serve HTTP/2 without TLS too, for proxies which speak it")
+)
+
+//go:embed index.html embed.html
 var indexHtml embed.FS
 
 // ErrorLevel is the type of error found
@@ -31,21 +89,203 @@ const (
 )
 
 type templateError struct {
+	File        string // the name the template it's in was parsed with, empty if it isn't in one
 	Line        int
 	Char        int
+	Column      int // where Char is displayed, with tabs expanded
 	Description string
 	Level       ErrorLevel
+	Rule        string   // the lint rule that found it, if any
+	Severity    Severity // how serious a lint error is
+	Fix         *quickFix
+	Explanation *explanation
+}
+
+// options are the settings of a validation which aren't part of the template itself
+type options struct {
+	// AllExecErrors keeps executing after runtime errors, up to maxExecFixes times, to find more than the first one
+	AllExecErrors bool
+	// Profile names the profile of options to apply where the others aren't set, empty for none
+	Profile string
+	// FunctionPreset names the preset of functions to mock besides those of the request, empty for none
+	FunctionPreset string
+	// Strict reports every call to an unknown function and doesn't execute templates calling them, instead of
+	// mocking them to keep going
+	Strict bool
+	// GoVersion is the version of Go, like 1.17, whose templates to validate like, empty for the validator's
+	GoVersion string
+	// ColumnUnit is what error characters are counted in
+	ColumnUnit ColumnUnit
+	// TabWidth is the distance between tab stops used to find the display column of errors
+	TabWidth int
+	// MissingKey is the template's missingkey option, empty for the default
+	MissingKey string
+	// Language is what recognized errors are described in
+	Language Language
+	// LintConfig is a JSON object of lint rule names to severities, "off" disables a rule
+	LintConfig string
+	// Now is the RFC 3339 time the time functions use as the current time, empty for defaultNow
+	Now string
+	// Seed is the seed of the random functions, empty for a random one
+	Seed string
+	// MaxSteps is the most actions executing may run, 0 for defaultMaxSteps
+	MaxSteps int
+	// MaxIterations is the most times a range may iterate in a row, 0 for defaultMaxIterations
+	MaxIterations int
+	// MaxOutput is the most bytes of output kept, 0 for defaultMaxOutput
+	MaxOutput int
+	// Benchmark is how many times to execute the template to time it, 0 to not
+	Benchmark int
+	// Fuzz executes the template with variations of the data to find the shapes of data it fails with
+	Fuzz bool
+	// Assertions is a JSON array of properties the output must have
+	Assertions string
+	// Email renders the subject, html and text templates as an email
+	Email bool
+	// Screenshot renders the output, or the email's HTML, in a headless browser
+	Screenshot bool
+	// Entry is the name of the template to execute, empty for the root one
+	Entry string
+	// Dot is the field path of the data to execute with, like .Page, empty for all of it
+	Dot string
+	// GoFunctions is Go source declaring functions to call instead of mocking them, if the server interprets them
+	GoFunctions string
+	// Expressions defines functions by expressions of their parameters, a line like `double: x * 2` each
+	Expressions string
+	// ParseGlob names the templates of a set by their base file names, in the order of their paths, like ParseGlob and
+	// ParseFS do
+	ParseGlob bool
+	// ShowWhitespace shows markers in place of the spaces, tabs and line endings in the output
+	ShowWhitespace bool
+	// PreviewIterations are how many iterations ranges execute before outputting how many more they'd have executed, 0
+	// for the server's default
+	PreviewIterations int
+	// FullExecution executes every iteration of ranges, even if the server previews them by default
+	FullExecution bool
+	// SourceMap finds the node of the template each part of the output is from
+	SourceMap bool
+	// Overrides are comma separated path patterns of the files of a set which are each parsed after the others,
+	// redefining their blocks like pages of a layout
+	Overrides string
+	// NDJSON reads the data as newline delimited JSON, executing the template with each line
+	NDJSON bool
+	// DataFormat is the format of the data, one of dataFormats, empty for JSON
+	DataFormat string
 }
+
 type indexData struct {
-	RawText        string
-	RawData        string
-	RawFunctions   string
-	TextLines      []string
-	Output         string
+	RawText      string
+	RawData      string
+	RawFunctions string
+	// ProtobufMessage is the type of the protobuf message the data was uploaded as, if it was
+	ProtobufMessage string
+	Options         options
+	TextLines       []string
+	Highlighted     [][]highlight // the tokens of each of TextLines
+	Output          string
+	// SourceMap is the output split into the parts each node output, if it was asked for
+	SourceMap      []outputSpan
 	Errors         []templateError
 	LineNumSpacing int
+	// Diff is the change made by fix mode
+	Diff string
+	// Stats are the size of the text
+	Stats templateStats
+	// Metrics measure the complexity of each template
+	Metrics []templateMetrics
+	// Templates are the templates defined in the text
+	Templates []templateDefinition
+	// Graph is which templates include which, if there's more than one
+	Graph *dependencyGraph
+	// Benchmark is how long executing took, if it was benchmarked
+	Benchmark *benchmark
+	// Fuzz are the shapes of data executing fails with, if the template was fuzzed
+	Fuzz []fuzzResult
+	// Records are what executing with each record of newline delimited JSON data output
+	Records []recordResult
+	// Email is the rendered email, in email mode
+	Email *emailPreview
+	// Screenshot is a base64 encoded PNG thumbnail of the output, if one was taken
+	Screenshot string
+	// CanScreenshot is whether a browser is configured to take screenshots with
+	CanScreenshot bool
+	// CanInterpretGo is whether the server interprets Go functions
+	CanInterpretGo bool
+	// Profiles are the names of the profiles of options which can be selected
+	Profiles []string
+	// FunctionPresets are the names of the presets of functions which can be selected
+	FunctionPresets []string
+	// GoVersions are the versions of Go templates can be validated like, from the newest
+	GoVersions []string
+	// CanSendEmail is whether an SMTP relay is configured to send test emails through
+	CanSendEmail bool
+	// SendTo is the address test emails are sent to
+	SendTo string
+	// EmailSent describes the test email sent, if one was
+	EmailSent string
+	// Suites are the names of the saved suites
+	Suites []string
+	// Functions are the functions the template calls
+	Functions []functionUsage
+	// Mocked are the unknown functions mocked to execute the template, whose output is missing what they'd return
+	Mocked []autoMockedFunction
+	// Files are the errors in each file of an uploaded archive, or of files uploaded together
+	Files []fileResult
+	// Share is the link of the share the page is of, if it is
+	Share string
+	// LinePage is the page of TextLines shown
+	LinePage int
+}
+
+// sourceView is what the page shows of a template: a page of its lines, with the errors found in them below them
+type sourceView struct {
+	// ID starts the ids of the lines, so the lines of several templates on a page can be linked to
+	ID          string
+	Lines       []string
+	Highlighted [][]highlight
+	// Errors are those in the lines shown
+	Errors []templateError
+	// First and Last are the lines shown, the Page of Pages of sourcePageLines
+	First, Last int
+	Page, Pages int
+	// Navigable is whether the page's form can be posted again for the other pages
+	Navigable bool
+	// Jumps are the first lines with errors on other pages
+	Jumps []lineJump
+}
+
+// lineJump is a line with an error and the page it's on
+type lineJump struct {
+	Line, Page int
+}
+
+// newSourceView returns the view of the page of lines, with those of tplErrs which are in it. Pages from -1 are the
+// first one, without links to the others.
+func newSourceView(id string, lines []string, highlighted [][]highlight, tplErrs []templateError, page int) sourceView {
+	view := sourceView{ID: id, Lines: lines, Highlighted: highlighted, Navigable: page >= 0,
+		Pages: max(1, (len(lines)+sourcePageLines-1)/sourcePageLines)}
+	view.Page = min(max(page, 0), view.Pages-1)
+	view.First = view.Page * sourcePageLines
+	view.Last = min(view.First+sourcePageLines, len(lines)) - 1
+	jumped := make(map[int]bool)
+	for _, tplErr := range tplErrs {
+		switch {
+		case tplErr.Level == dataErrorLevel || tplErr.Line < 0:
+		case tplErr.Line >= view.First && tplErr.Line <= view.Last:
+			view.Errors = append(view.Errors, tplErr)
+		case !jumped[tplErr.Line] && len(view.Jumps) < maxErrorJumps:
+			jumped[tplErr.Line] = true
+			view.Jumps = append(view.Jumps, lineJump{Line: tplErr.Line, Page: tplErr.Line / sourcePageLines})
+		}
+	}
+	sort.Slice(view.Jumps, func(i, j int) bool { return view.Jumps[i].Line < view.Jumps[j].Line })
+	return view
 }
 
+// Previous and Next are the pages before and after the one shown
+func (v sourceView) Previous() int { return v.Page - 1 }
+func (v sourceView) Next() int     { return v.Page + 1 }
+
 func getText(r *http.Request) (string, error) {
 	file, _, err := r.FormFile("from-file")
 	if err != nil {
@@ -57,29 +297,340 @@ func getText(r *http.Request) (string, error) {
 	return buf.String(), nil
 }
 
+// getFormValue returns the form value key or, if there's a key_b64 form value, it decoded from base64, so clients don't
+// have to escape templates in what they post
+func getFormValue(r *http.Request, key string) (string, error) {
+	encoded := r.FormValue(key + "_b64")
+	if encoded == "" {
+		return r.FormValue(key), nil
+	}
+	b, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", fmt.Errorf("failed to understand %s_b64: %v", key, err)
+	}
+	return string(b), nil
+}
+
+// getFiles returns the files uploaded together as the from-file form file, named by their file names, when there are
+// several to validate as a set, it's http.ErrMissingFile otherwise
+func getFiles(r *http.Request) ([]source, error) {
+	if r.MultipartForm == nil || len(r.MultipartForm.File["from-file"]) < 2 {
+		return nil, http.ErrMissingFile
+	}
+	headers := r.MultipartForm.File["from-file"]
+	sources := make([]source, 0, len(headers))
+	uploaded := make(map[string]bool, len(headers))
+	for _, header := range headers {
+		if uploaded[header.Filename] {
+			return nil, fmt.Errorf("%s is uploaded twice", header.Filename)
+		}
+		uploaded[header.Filename] = true
+		file, err := header.Open()
+		if err != nil {
+			return nil, err
+		}
+		var buf bytes.Buffer
+		_, err = io.Copy(&buf, file)
+		file.Close()
+		if err != nil {
+			return nil, err
+		}
+		sources = append(sources, source{name: header.Filename, text: buf.String()})
+	}
+	return sources, nil
+}
+
+// getProtobufData returns the protobuf message uploaded as the protobuf form file as JSON data, decoded with the
+// FileDescriptorSet or .proto file uploaded as the descriptor form file. It's http.ErrMissingFile if there's none.
+func getProtobufData(r *http.Request) (string, error) {
+	message, _, err := readUpload(r, "protobuf")
+	if err != nil {
+		return "", err
+	}
+	schema, name, err := readUpload(r, "descriptor")
+	if err == http.ErrMissingFile {
+		return "", errors.New("its descriptor set or .proto file isn't uploaded")
+	} else if err != nil {
+		return "", err
+	}
+	return protobufJSON(schema, strings.HasSuffix(name, ".proto"), r.FormValue("protobuf-message"), message)
+}
+
+// readUpload returns the contents and name of the file uploaded as the form file key
+func readUpload(r *http.Request, key string) ([]byte, string, error) {
+	file, header, err := r.FormFile(key)
+	if err != nil {
+		return nil, "", http.ErrMissingFile
+	}
+	defer file.Close()
+	var buf bytes.Buffer
+	if _, err := io.Copy(&buf, file); err != nil {
+		return nil, "", err
+	}
+	return buf.Bytes(), header.Filename, nil
+}
+
+func getOptions(r *http.Request) options {
+	// bad tab widths become the default
+	tabWidth, _ := strconv.Atoi(r.FormValue("tab-width"))
+	// bad limits become the defaults in createData, which also caps them at the server's
+	maxSteps, _ := strconv.Atoi(r.FormValue("max-steps"))
+	maxIterations, _ := strconv.Atoi(r.FormValue("max-iterations"))
+	maxOutput, _ := strconv.Atoi(r.FormValue("max-output"))
+	runs, _ := strconv.Atoi(r.FormValue("benchmark"))
+	previewIterations, _ := strconv.Atoi(r.FormValue("preview-iterations"))
+	return options{
+		AllExecErrors:     r.FormValue("all-exec-errors") != "",
+		Profile:           r.FormValue("profile"),
+		FunctionPreset:    r.FormValue("function-preset"),
+		Strict:            r.FormValue("strict") != "",
+		GoVersion:         r.FormValue("go-version"),
+		ColumnUnit:        parseColumnUnit(r.FormValue("columns")),
+		TabWidth:          clampTabWidth(tabWidth),
+		MissingKey:        parseMissingKey(r.FormValue("missingkey")),
+		Language:          getLanguage(r),
+		LintConfig:        r.FormValue("lint"),
+		Now:               r.FormValue("now"),
+		Seed:              r.FormValue("seed"),
+		MaxSteps:          maxSteps,
+		MaxIterations:     maxIterations,
+		MaxOutput:         maxOutput,
+		Benchmark:         runs,
+		Fuzz:              r.FormValue("fuzz") != "",
+		NDJSON:            r.FormValue("ndjson") != "",
+		DataFormat:        r.FormValue("data-format"),
+		Assertions:        r.FormValue("assertions"),
+		Email:             r.FormValue("email") != "",
+		Screenshot:        r.FormValue("screenshot") != "",
+		Entry:             r.FormValue("entry"),
+		ParseGlob:         r.FormValue("parse-glob") != "",
+		Overrides:         r.FormValue("overrides"),
+		SourceMap:         r.FormValue("source-map") != "",
+		PreviewIterations: previewIterations,
+		FullExecution:     r.FormValue("full-execution") != "",
+		ShowWhitespace:    r.FormValue("show-whitespace") != "",
+		Dot:               r.FormValue("dot"),
+		GoFunctions:       r.FormValue("go-functions"),
+		Expressions:       r.FormValue("expressions"),
+	}
+}
+
+func parseMissingKey(s string) string {
+	switch s {
+	case "zero", "error":
+		return s
+	}
+	return ""
+}
+
+// indexFunctions are the functions of the pages
+var indexFunctions = htmlTemplate.FuncMap{
+	"intRange": intRange,
+	"padding":  padding,
+	// inc turns zero indexed lines into the one indexed ones errors are shown with
+	"inc":   func(i int) int { return i + 1 },
+	"nl":    nl,
+	"split": split,
+	// formatError lists errors like the command line does
+	"formatError": formatError,
+	"source":      newSourceView,
+	// showWhitespace marks invisible characters
+	"showWhitespace": showWhitespace,
+}
+
+// parseIndex parses the pages, index.html and the others it executes or which are executed instead, like embed.html
+func parseIndex() (*htmlTemplate.Template, error) {
+	return htmlTemplate.New("index.html").Funcs(indexFunctions).ParseFS(indexHtml, "*")
+}
+
 func main() {
-	fns := htmlTemplate.FuncMap{
-		"intRange": intRange,
-		"nl":       nl,
-		"split":    split,
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: %s [flags] [template files, - for stdin]\n\nwithout template files, serves the web UI on port %d\n\n", os.Args[0], port)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() > 0 {
+		os.Exit(runCLI(flag.Args(), os.Stdin, os.Stdout))
 	}
-	index, err := htmlTemplate.New("index.html").Funcs(fns).ParseFS(indexHtml, "*")
+
+	index, err := parseIndex()
 	if err != nil {
 		panic(err)
 	}
 
 	r := chi.NewRouter()
-	r.Use(middleware.Logger)
+	var logOutput io.Writer = os.Stderr
+	if *logFileFlag != "" {
+		file, err := newRotatingFile(*logFileFlag, int64(*logMaxSizeFlag)<<20, *logMaxAgeFlag, *logBackupsFlag)
+		if err != nil {
+			log.Fatal(err)
+		}
+		logOutput = file
+	}
+	logger, err := newLogger(logOutput, *logLevelFlag, *logFormatFlag, *logSampleFlag)
+	if err != nil {
+		log.Fatal(err)
+	}
+	// what's logged with the log package is logged by it too, at info level
+	slog.SetDefault(logger)
+	if *sentryFlag != "" {
+		if errorReporter, err = newSentryReporter(*sentryFlag); err != nil {
+			log.Fatal(err)
+		}
+	}
+	r.Use(accessLog(logger))
 	r.Use(middleware.Recoverer)
+	r.Use(reportPanics)
+
+	relay, err := newSMTPRelay(*smtpFlag, *smtpFromFlag, splitList(*smtpToFlag), *smtpUserFlag, *smtpPasswordFlag)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	fetcher, err := newFetcher(splitList(*fetchHostsFlag), splitList(*fetchBucketsFlag), splitList(*fetchAuthFlag))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	extensions, err := loadExtensions(splitList(*pluginsFlag), splitList(*extensionsFlag))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var mockable []string
+	if *mockableFlag != "" {
+		mockable = splitList(*mockableFlag)
+	}
+	policy, err := newFunctionPolicy(splitList(*denyFlag), mockable)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	profiles, err := loadProfiles(*profilesFlag)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	functionPresets, err := loadFunctionPresets(*presetsFlag)
+	if err != nil {
+		log.Fatal(err)
+	}
 
-	a := &App{index: index}
+	sealKey, err := loadSealKey(*sealKeyFlag)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	a := &App{index: index, parseCache: newParseCache(parseCacheSize), suites: newSuiteStore(*suitesFlag, sealKey),
+		shares: newShareStore(*sharesFlag, sealKey, *shareTTLFlag), sessions: newEditSessions(*sessionsFlag, *sessionTTLFlag),
+		webhook: *webhookFlag, asyncSuites: newAsyncSuites(*asyncSuitesFlag, *asyncTimeoutFlag), slackSecret: *slackFlag, smtp: relay, browser: newBrowser(*chromeFlag, *browsersFlag),
+		fetcher: fetcher, interpretGo: *goServeFlag, extensions: extensions, policy: policy,
+		profiles: profiles, functionPresets: functionPresets, previewIterations: *previewFlag,
+		maxSteps: *maxStepsFlag, maxIterations: *maxItersFlag, maxOutput: *maxOutputFlag}
 	r.Post("/", a.Post)
+	r.Post("/preferences", a.SavePreferences)
 	r.Get("/", a.Get)
+	r.Post("/validate.txt", a.ValidateText)
+	r.Get("/api/v1/functions", a.Functions)
+	r.Get("/api/v1/function-presets", a.FunctionPresets)
+	r.Get("/api/v1/grammar", a.Grammar)
+	r.Post("/api/v1/complete", a.Complete)
+	r.Post("/api/v1/archive", a.ValidateArchive)
+	r.Post("/api/v1/git", a.ValidateGit)
+	r.Post("/api/v1/batch", a.ValidateBatch)
+	r.Post("/api/v1/graph", a.Graph)
+	r.Post("/api/v1/templates", a.Templates)
+	r.Post("/api/v1/tokens", a.Tokens)
+	r.Get("/api/v1/lines", a.Lines)
+	r.Post("/api/v1/lines", a.Lines)
+	r.Get("/api/v1/render", a.Render)
+	r.Post("/api/v1/render", a.Render)
+	r.Get("/api/v1/cache", a.CacheStats)
+	r.Get("/api/v1/suites", a.Suites)
+	r.Get("/api/v1/suites/{name}", a.Suite)
+	r.Put("/api/v1/suites/{name}", a.SaveSuite)
+	r.Post("/api/v1/suites/{name}/run", a.RunSuite)
+	r.Post("/api/v1/shares", a.CreateShare)
+	r.Post("/api/v1/sessions", a.CreateSession)
+	r.Patch("/api/v1/sessions/{id}", a.EditSession)
+	r.Get("/s/{id}", a.Share)
+	r.Post("/s/{id}", a.Share)
+	r.Get("/s/{id}/qr.png", a.ShareQR)
+	r.Get("/embed", a.Embed)
+	if a.slackSecret != "" {
+		r.Post("/slack/command", a.SlackCommand)
+	}
+
+	// shares are purged when they expire, not only when they're viewed after
+	go a.shares.purgeEvery(time.Minute)
 
+	if *telegramFlag != "" {
+		go newTelegramBot(*telegramFlag, a).run(context.Background())
+	}
+
+	server := newServer(fmt.Sprintf(":%d", port), r, serverConfig{ReadHeaderTimeout: *readHeaderTimeoutFlag,
+		ReadTimeout: *readTimeoutFlag, WriteTimeout: *writeTimeoutFlag, IdleTimeout: *idleTimeoutFlag, H2C: *h2cFlag})
 	log.Printf("starting on port %d\n", port)
-	log.Fatal(http.ListenAndServe(fmt.Sprintf(":%d", port), r))
+	log.Fatal(server.ListenAndServe())
 }
 
+// mockFunctions mocks the comma separated functions rawFns in t, or the functions of a JSON object of their
+// specifications. This'll happen automatically as they're found, but errors will be output and there's a max limit.
+func mockFunctions(t *textTemplate.Template, rawFns string) (*textTemplate.Template, []string, []templateError) {
+	var tplErrs []templateError
+	var functions []string
+	mocks := make(map[string]interface{})
+	if isFunctionSpecs(rawFns) {
+		specs, err := parseFunctionSpecs(rawFns)
+		if err != nil {
+			tplErrs = append(tplErrs, templateError{Line: -1, Char: -1, Level: misunderstoodError,
+				Description: fmt.Sprintf("failed to understand function specifications: %v", err)})
+		}
+		for _, name := range sortedSpecNames(specs) {
+			functions = append(functions, name)
+			mocks[name] = specs[name].mock(name)
+		}
+	} else if rawFns != "" {
+		functions = strings.Split(rawFns, ",")
+	}
+	for i, fn := range functions {
+		fn = strings.TrimSpace(fn)
+		functions[i] = fn
+		mock, ok := mocks[fn]
+		if !ok {
+			mock = mockFunction
+		}
+		// wrap in func so we can catch panics on bad function names
+		func() {
+			defer func() {
+				if r := recover(); r != nil {
+					tplErrs = append(tplErrs, templateError{Line: -1, Char: -1, Level: misunderstoodError,
+						Description: fmt.Sprintf(`bad function name provided: "%s"`, fn)})
+				}
+			}()
+			t = t.Funcs(textTemplate.FuncMap{fn: recoverPanics(mock)})
+		}()
+	}
+	return t, functions, tplErrs
+}
+
+// splitList splits a comma separated list, without spaces around or empty items
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// maxPadding is the most spaces the pages indent an error with, so a huge column can't make a huge page
+const maxPadding = 1000
+
+// padding returns n spaces, up to maxPadding
+func padding(n int) string { return strings.Repeat(" ", max(0, min(n, maxPadding))) }
+
 func nl() string              { return "\n" }
 func split(s string) []string { return strings.Split(s, ": ") }
 
@@ -95,6 +646,62 @@ func intRange(start, end int) []int {
 type App struct {
 	index   *htmlTemplate.Template
 	tplErrs []templateError
+	// parseCache is nil unless parsed templates are reused
+	parseCache *parseCache
+	suites     *suiteStore
+	shares     *shareStore
+	// sessions are the templates being edited, validated incrementally
+	sessions *editSessions
+	// webhook is the URL notified when suites finish running, if any
+	webhook string
+	// asyncSuites are the suites running in the background, nil if they can't
+	asyncSuites *asyncSuites
+	// slackSecret verifies requests from Slack
+	slackSecret string
+	// smtp is nil unless test emails can be sent
+	smtp *smtpRelay
+	// browser is nil unless screenshots can be taken
+	browser *browser
+	// fetcher fetches the templates of batches
+	fetcher *fetcher
+	// interpretGo is whether the Go functions of validations are interpreted
+	interpretGo bool
+	// extensions are the functions of plugins and extension commands, called instead of mocks
+	extensions textTemplate.FuncMap
+	// policy is what templates may call
+	policy functionPolicy
+	// profiles are the profiles of options requests can select, nil for the default ones
+	profiles map[string]profile
+	// functionPresets are the presets of functions requests can select, nil for the default ones
+	functionPresets map[string]functionPreset
+	// previewIterations are how many iterations ranges execute for requests which don't say, 0 for all of them
+	previewIterations int
+	// localBenchmarks are run on the command line, which has the process to itself, rather than by the shared server
+	localBenchmarks bool
+	// maxSteps, maxIterations and maxOutput are the most requests can set their limits to, defaultMaxSteps,
+	// defaultMaxIterations and defaultMaxOutput if they're 0
+	maxSteps      int
+	maxIterations int
+	maxOutput     int
+}
+
+// previewIterationsFor returns how many iterations ranges execute for a request with opts, 0 for all of them
+func (a *App) previewIterationsFor(opts options) int {
+	switch {
+	case opts.FullExecution:
+		return 0
+	case opts.PreviewIterations > 0:
+		return opts.PreviewIterations
+	}
+	return a.previewIterations
+}
+
+// forRequest returns an App sharing a's configuration and cache, collecting the errors of one validation
+func (a *App) forRequest() *App {
+	return &App{parseCache: a.parseCache, interpretGo: a.interpretGo, extensions: a.extensions, policy: a.policy,
+		profiles: a.profiles, functionPresets: a.functionPresets, previewIterations: a.previewIterations,
+		localBenchmarks: a.localBenchmarks, browser: a.browser, maxSteps: a.maxSteps, maxIterations: a.maxIterations,
+		maxOutput: a.maxOutput}
 }
 
 var indexDataSamples = []indexData{
@@ -108,10 +715,12 @@ var indexDataSamples = []indexData{
 }
 
 func (a *App) Get(w http.ResponseWriter, r *http.Request) {
-	a.tplErrs = make([]templateError, 0)
-
 	for _, v := range indexDataSamples {
-		data := a.createData(v.RawText, v.RawData, v.RawFunctions)
+		opts := v.Options
+		opts.Language = getLanguage(r)
+		opts, rawFns := applyPreferences(r, opts, v.RawFunctions)
+		data := a.forRequest().createData(r.Context(), v.RawText, v.RawData, rawFns, opts)
+		data.Suites, _ = a.suites.names()
 		w.Header().Add("X-XSS-Protection", "0")
 		if err := a.index.Execute(w, data); err != nil {
 			http.Error(w, fmt.Sprintf("Execute error: %v", err), http.StatusForbidden)
@@ -127,26 +736,83 @@ func (a *App) Get(w http.ResponseWriter, r *http.Request) {
 const maxRequestSize = 32 << 20
 
 func (a *App) Post(w http.ResponseWriter, r *http.Request) {
-	if err := r.ParseMultipartForm(maxRequestSize); err != nil {
+	// plain URL encoded forms, like curl -d posts, have no files
+	if err := r.ParseMultipartForm(maxRequestSize); err != nil && err != http.ErrNotMultipart {
 		http.Error(w, fmt.Sprintf("ParseMultipartForm error: %v", err), http.StatusForbidden)
 		return
 	}
 
-	a.tplErrs = make([]templateError, 0)
-
+	// the errors of the form come first, before those of validating it
+	var tplErrs []templateError
 	text, err := getText(r)
 	if err == http.ErrMissingFile {
-		a.tplErrs = append(a.tplErrs, templateError{Line: -1, Char: -1, Description: "couldn't accept file"})
+		tplErrs = append(tplErrs, templateError{Line: -1, Char: -1, Description: "couldn't accept file"})
 	} else if err != nil {
 		panic(err)
 	}
 
-	rawData := r.FormValue("data")
+	rawData, err := getFormValue(r, "data")
+	if err != nil {
+		tplErrs = append(tplErrs, templateError{Line: -1, Char: -1, Level: misunderstoodError,
+			Description: err.Error()})
+	}
+	if protobufData, err := getProtobufData(r); err == nil {
+		rawData = protobufData
+	} else if err != http.ErrMissingFile {
+		tplErrs = append(tplErrs, templateError{Line: -1, Char: -1, Level: misunderstoodError,
+			Description: fmt.Sprintf("failed to understand the protobuf data: %v", err)})
+	}
 	rawFns := r.FormValue("functions")
+	opts := getOptions(r)
 
 	// outputs html into the textarea, so chrome gets worried
 	// https://stackoverflow.com/a/17815577/2178159
-	data := a.createData(text, rawData, rawFns)
+	var data indexData
+	if sources, err := getArchive(r); err != http.ErrMissingFile {
+		data = indexData{RawFunctions: rawFns, Options: opts}
+		if err != nil {
+			data.Errors = []templateError{{Line: -1, Char: -1, Level: misunderstoodError,
+				Description: fmt.Sprintf("failed to understand archive: %v", err)}}
+		} else {
+			data.Files = a.validateSet(r.Context(), sources, rawData, rawFns, opts)
+		}
+	} else if sources, err := getFiles(r); err != http.ErrMissingFile {
+		data = indexData{RawFunctions: rawFns, Options: opts}
+		if err != nil {
+			data.Errors = []templateError{{Line: -1, Char: -1, Level: misunderstoodError,
+				Description: fmt.Sprintf("failed to understand the files: %v", err)}}
+		} else {
+			data.Files = a.validateSet(r.Context(), sources, rawData, rawFns, opts)
+		}
+	} else if r.FormValue("fix") != "" {
+		data = a.autoFix(r.Context(), text, rawData, rawFns, opts)
+	} else {
+		req := a.forRequest()
+		req.tplErrs = tplErrs
+		data = req.createData(r.Context(), text, rawData, rawFns, opts)
+	}
+	if r.Context().Err() != nil {
+		// nobody is waiting for the result
+		return
+	}
+	data.Suites, _ = a.suites.names()
+	data.CanSendEmail = a.smtp != nil
+	data.SendTo = r.FormValue("send-to")
+	if r.FormValue("send") != "" {
+		if err := a.smtp.send(data.SendTo, data.Email); err != nil {
+			data.Errors = append(data.Errors, templateError{Line: -1, Char: -1, Level: misunderstoodError,
+				Description: fmt.Sprintf("failed to send email: %v", err)})
+		} else {
+			data.EmailSent = fmt.Sprintf("sent %q to %s", data.Email.Subject, data.SendTo)
+		}
+	}
+	data.ProtobufMessage = r.FormValue("protobuf-message")
+	data.LinePage, _ = strconv.Atoi(r.FormValue("line-page"))
+	w.Header().Add("Vary", "Accept")
+	if acceptsJSON(r) {
+		writeJSON(w, newValidationResult(data))
+		return
+	}
 	w.Header().Add("X-XSS-Protection", "0")
 	if err := a.index.Execute(w, data); err != nil {
 		http.Error(w, fmt.Sprintf("Execute error: %v", err), http.StatusForbidden)
@@ -154,51 +820,313 @@ func (a *App) Post(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-func (a *App) createData(text, rawData, rawFns string) indexData {
+func (a *App) createData(ctx context.Context, text, rawData, rawFns string, opts options) indexData {
+	start := time.Now()
+	defer func() {
+		recordValidation(ctx, len(text), len(a.tplErrs), time.Since(start))
+	}()
+	if opts.TabWidth <= 0 {
+		opts.TabWidth = defaultTabWidth
+	}
+	opts.MaxSteps = clampLimit(opts.MaxSteps, defaultMaxSteps, a.maxSteps)
+	opts.MaxIterations = clampLimit(opts.MaxIterations, defaultMaxIterations, a.maxIterations)
+	opts.MaxOutput = clampLimit(opts.MaxOutput, defaultMaxOutput, a.maxOutput)
+	// the form keeps what was asked for, not what the profile added
+	formOpts := opts
+	opts, err := a.withProfile(opts)
+	if err != nil {
+		a.tplErrs = append(a.tplErrs, templateError{Line: -1, Char: -1, Level: misunderstoodError,
+			Description: fmt.Sprintf("failed to understand the profile: %v", err)})
+	}
+
 	var data interface{}
-	if rawData != "" {
-		if err := json.Unmarshal([]byte(rawData), &data); err != nil {
+	var records []dataRecord
+	if rawData != "" && opts.NDJSON {
+		var err error
+		if records, err = decodeRecords(rawData); err != nil {
+			a.tplErrs = append(a.tplErrs, dataTemplateError(rawData, err))
+		} else {
+			// the first record is the one everything else is looked at with
+			data = records[0].Data
+		}
+	} else if rawData != "" {
+		var err error
+		if data, err = decodeDataFormat(rawData, opts.DataFormat); err != nil {
+			a.tplErrs = append(a.tplErrs, dataTemplateErrors(rawData, err)...)
+		}
+	}
+
+	if opts.Dot != "" {
+		dot, err := selectDot(data, opts.Dot)
+		if err != nil {
 			a.tplErrs = append(a.tplErrs, templateError{Line: -1, Char: -1, Level: misunderstoodError,
-				Description: fmt.Sprintf("failed to understand data: %v", err)})
+				Description: fmt.Sprintf("failed to understand dot: %v", err)})
 		}
+		data = dot
 	}
 
-	t := textTemplate.New("input template")
+	var config lintConfig
+	if opts.LintConfig != "" {
+		var err error
+		if config, err = parseLintConfig(opts.LintConfig); err != nil {
+			a.tplErrs = append(a.tplErrs, templateError{Line: -1, Char: -1, Level: misunderstoodError,
+				Description: fmt.Sprintf("failed to understand lint config: %v", err)})
+		}
+	}
 
-	// mock template functions - this'll happen automatically as they're found, but errors will be output and there's a max limit
-	var functions []string
-	if rawFns != "" {
-		functions = strings.Split(rawFns, ",")
+	var assertions []assertion
+	if opts.Assertions != "" {
+		var err error
+		if assertions, err = parseAssertions(opts.Assertions); err != nil {
+			a.tplErrs = append(a.tplErrs, templateError{Line: -1, Char: -1, Level: misunderstoodError,
+				Description: fmt.Sprintf("failed to understand assertions: %v", err)})
+		}
 	}
-	for _, fn := range functions {
-		fn = strings.TrimSpace(fn)
-		// wrap in func so we can catch panics on bad function names
-		func() {
-			defer func() {
-				if r := recover(); r != nil {
-					a.tplErrs = append(a.tplErrs, templateError{Line: -1, Char: -1, Level: misunderstoodError,
-						Description: fmt.Sprintf(`bad function name provided: "%s"`, fn)})
-				}
-			}()
-			t = t.Funcs(textTemplate.FuncMap{fn: func() error { return nil }})
-		}()
+
+	now, err := parseNow(opts.Now)
+	if err != nil {
+		now = defaultNow
+		a.tplErrs = append(a.tplErrs, templateError{Line: -1, Char: -1, Level: misunderstoodError,
+			Description: fmt.Sprintf("failed to understand the current time: %v", err)})
 	}
 
-	parsedT, parseTplErrs := parse(text, t)
+	seed, err := parseSeed(opts.Seed)
+	if err != nil {
+		seed, _ = parseSeed("")
+		a.tplErrs = append(a.tplErrs, templateError{Line: -1, Char: -1, Level: misunderstoodError,
+			Description: fmt.Sprintf("failed to understand the random seed: %v", err)})
+	}
+
+	goVersion, err := parseGoVersion(opts.GoVersion)
+	if err != nil {
+		a.tplErrs = append(a.tplErrs, templateError{Line: -1, Char: -1, Level: misunderstoodError,
+			Description: fmt.Sprintf("failed to understand the Go version: %v", err)})
+	}
+
+	t := textTemplate.New(inputTemplateName).Funcs(timeFunctions(now)).Funcs(randomFunctions(seed))
+	// an older Go's builtin functions, which the request's functions still replace
+	t = t.Funcs(goVersionFunctions(goVersion))
+	if opts.MissingKey != "" {
+		t = t.Option("missingkey=" + opts.MissingKey)
+	}
+
+	presetFns, err := a.presetFunctions(opts.FunctionPreset)
+	if err != nil {
+		a.tplErrs = append(a.tplErrs, templateError{Line: -1, Char: -1, Level: misunderstoodError,
+			Description: fmt.Sprintf("failed to understand the function preset: %v", err)})
+	}
+	// the request's functions replace the preset's of the same names
+	t, presetFunctions, presetTplErrs := mockFunctions(t, presetFns)
+	a.tplErrs = append(a.tplErrs, presetTplErrs...)
+	t, functions, fnTplErrs := mockFunctions(t, rawFns)
+	a.tplErrs = append(a.tplErrs, fnTplErrs...)
+	functions = append(presetFunctions, functions...)
+	for _, name := range sortedFunctionNames(a.extensions) {
+		t = t.Funcs(textTemplate.FuncMap{name: recoverPanics(a.extensions[name])})
+		functions = append(functions, name)
+	}
+	keyFunctions := functions
+	if isFunctionSpecs(rawFns) || isFunctionSpecs(presetFns) {
+		// templates parsed with other canned results can't be reused
+		keyFunctions = append(append([]string{}, functions...), "spec:"+rawFns, "preset:"+presetFns)
+	}
+	if opts.GoFunctions != "" {
+		goFns, err := a.interpretFunctions(ctx, opts.GoFunctions)
+		if err != nil {
+			a.tplErrs = append(a.tplErrs, templateError{Line: -1, Char: -1, Level: misunderstoodError,
+				Description: fmt.Sprintf("failed to understand Go functions: %v", err)})
+		}
+		for _, name := range sortedFunctionNames(goFns) {
+			t = t.Funcs(textTemplate.FuncMap{name: recoverPanics(goFns[name])})
+			functions = append(functions, name)
+		}
+		// templates parsed with other functions of the same names can't be reused
+		keyFunctions = append(append([]string{}, keyFunctions...), "go:"+opts.GoFunctions)
+	}
+	if opts.Expressions != "" {
+		exprFns, err := expressionFunctions(opts.Expressions)
+		if err != nil {
+			a.tplErrs = append(a.tplErrs, templateError{Line: -1, Char: -1, Level: misunderstoodError,
+				Description: fmt.Sprintf("failed to understand expression functions: %v", err)})
+		}
+		for _, name := range sortedFunctionNames(exprFns) {
+			t = t.Funcs(textTemplate.FuncMap{name: exprFns[name]})
+			functions = append(functions, name)
+		}
+		keyFunctions = append(append([]string{}, keyFunctions...), "expr:"+opts.Expressions)
+	}
+
+	if goVersion < shortCircuitVersion {
+		// templates parsed with the builtin and and or can't be reused
+		keyFunctions = append(append([]string{}, keyFunctions...), "go-version:and,or")
+	}
+
+	key := newParseKey(text, keyFunctions, opts.MissingKey)
+	parsedT, parseTplErrs, cached := a.parseCache.get(key)
+	if cached {
+		// the time and random functions aren't part of the key
+		parsedT = parsedT.Funcs(timeFunctions(now)).Funcs(randomFunctions(seed))
+	} else {
+		parsedT, parseTplErrs = parse(ctx, text, t)
+		if len(parseTplErrs) > 0 {
+			parseTplErrs = refineParseErrors(parseTplErrs, precheck(inputTemplateName, text))
+		}
+		// parsing a cancelled request may have stopped early
+		if ctx.Err() == nil {
+			a.parseCache.add(key, parsedT, parseTplErrs)
+		}
+	}
 	a.tplErrs = append(a.tplErrs, parseTplErrs...)
+	undefinedTplErrs := undefinedTemplates(parsedT)
+	a.tplErrs = append(a.tplErrs, undefinedTplErrs...)
+	policyTplErrs := a.policy.check(parsedT, parseTplErrs)
+	a.tplErrs = append(a.tplErrs, policyTplErrs...)
+	// what the Go validated for fails to parse isn't executed, like by that Go
+	goVersionTplErrs := goVersionSyntaxErrors(inputTemplateName, text, goVersion)
+	a.tplErrs = append(a.tplErrs, goVersionTplErrs...)
+	mocked := autoMocked(parseTplErrs)
+	if opts.Strict {
+		a.tplErrs = append(a.tplErrs, unmockedCalls(parsedT, mocked)...)
+	}
 
-	var buf bytes.Buffer
-	execTplErrs := exec(parsedT, data, &buf)
-	a.tplErrs = append(a.tplErrs, execTplErrs...)
+	buf := newCappedBuffer(opts.MaxOutput)
+	execRetries := 0
+	if opts.AllExecErrors {
+		execRetries = maxExecFixes
+	}
+	// ranges fail on what the Go validated for can't iterate over
+	versionT, err := goVersionTemplate(parsedT, goVersion)
+	if err != nil {
+		versionT = parsedT
+	}
+	// ranges over huge data can stop early for a quicker look
+	previewT := versionT
+	if n := a.previewIterationsFor(opts); n > 0 {
+		if t, err := previewTemplate(versionT, n); err == nil {
+			previewT = t
+		}
+	}
+	// templates which loop forever, or nearly, are stopped
+	limitedT, err := limitTemplate(ctx, previewT, opts.MaxSteps, opts.MaxIterations)
+	if err != nil {
+		limitedT = previewT
+	}
+	var recordResults []recordResult
+	// a named template of the set can be executed instead of the root
+	entryT, previewEntryT, limitedEntryT := parsedT, previewT, limitedT
+	if opts.Entry != "" {
+		entryT, previewEntryT, limitedEntryT = parsedT.Lookup(opts.Entry), previewT.Lookup(opts.Entry),
+			limitedT.Lookup(opts.Entry)
+	}
+	if entryT == nil || limitedEntryT == nil {
+		a.tplErrs = append(a.tplErrs, templateError{Line: -1, Char: -1, Level: misunderstoodError,
+			Description: fmt.Sprintf("failed to understand the template to execute: %q isn't defined", opts.Entry)})
+	} else if len(policyTplErrs) == 0 && len(goVersionTplErrs) == 0 && !(opts.Strict && len(mocked) > 0) {
+		execTplErrs := withoutReported(execCollect(ctx, limitedEntryT, data, buf, execRetries), undefinedTplErrs)
+		describeLimitErrors(execTplErrs)
+		describeGoVersionErrors(execTplErrs)
+		a.tplErrs = append(a.tplErrs, execTplErrs...)
+		if len(records) > 0 && len(parseTplErrs) == 0 {
+			recordResults = executeRecords(ctx, previewEntryT, records, opts)
+		}
+	}
 
-	lines := SplitLines(text)
+	var email *emailPreview
+	if opts.Email && len(parseTplErrs) == 0 {
+		var emailTplErrs []templateError
+		email, emailTplErrs = renderEmail(ctx, parsedT, data, opts)
+		a.tplErrs = append(a.tplErrs, emailTplErrs...)
+	}
+
+	// only templates which execute are worth checking, timing, fuzzing or looking at
+	var bench *benchmark
+	var fuzzResults []fuzzResult
+	var screenshot string
+	var sourceMap []outputSpan
+	if len(a.tplErrs) == 0 {
+		a.tplErrs = append(a.tplErrs, checkAssertions(assertions, buf.kept(), buf.size)...)
+		if opts.Benchmark > 0 {
+			// like executing, with the limits and the Go version's behavior
+			benchT := versionT
+			if opts.Entry != "" {
+				benchT = versionT.Lookup(opts.Entry)
+			}
+			if bench, err = runBenchmark(ctx, benchT, data, opts.Benchmark, opts.MaxSteps, opts.MaxIterations,
+				a.localBenchmarks); err != nil {
+				a.tplErrs = append(a.tplErrs, templateError{Line: -1, Char: -1, Level: misunderstoodError,
+					Description: fmt.Sprintf("failed to benchmark: %v", err)})
+			}
+		}
+		if opts.Fuzz {
+			fuzzResults = fuzz(ctx, entryT, data, opts)
+		}
+		if opts.SourceMap {
+			sourceMap = mapSource(previewEntryT, data, text, buf)
+		}
+		if opts.Screenshot {
+			html := buf.kept()
+			if email != nil && email.HTML != "" {
+				html = email.HTML
+			}
+			if screenshot, err = a.browser.preview(ctx, html); err != nil {
+				a.tplErrs = append(a.tplErrs, templateError{Line: -1, Char: -1, Level: misunderstoodError,
+					Description: fmt.Sprintf("failed to take a screenshot: %v", err)})
+			}
+		}
+	}
+	if opts.Email {
+		config.entryPoints = append(config.entryPoints, emailSubjectTemplate, emailHTMLTemplate, emailTextTemplate)
+	}
+	if opts.Entry != "" {
+		config.entryPoints = append(config.entryPoints, opts.Entry)
+	}
+	config.functions = functions
+	a.tplErrs = append(a.tplErrs, lint(parsedT, []source{{name: parsedT.Name(), text: text}}, config)...)
+
+	suggestFixes(a.tplErrs, text, functions)
+	explainErrors(a.tplErrs)
+
+	lines := SplitVisualLines(text)
+	toVisualLocations(a.tplErrs, text)
+	convertColumns(a.tplErrs, lines, opts.ColumnUnit, opts.TabWidth)
+	locateFixes(a.tplErrs, text, lines, opts.ColumnUnit)
+	var graph *dependencyGraph
+	if g := templateGraph(parsedT); len(g.Templates) > 1 {
+		graph = &g
+	}
+	usages := functionUsages(parsedT, functions)
+	locateCalls(usages, text, lines, opts.ColumnUnit)
+	var mockedFns []autoMockedFunction
+	if !opts.Strict {
+		mockedFns = autoMockedFunctions(usages, mocked)
+	}
+	localizeErrors(a.tplErrs, opts.Language)
 	return indexData{
-		RawText:        text,
-		RawData:        rawData,
-		RawFunctions:   rawFns,
-		Output:         buf.String(),
-		Errors:         a.tplErrs,
-		TextLines:      lines,
-		LineNumSpacing: CountDigits(len(lines)),
+		RawText:         text,
+		RawData:         rawData,
+		RawFunctions:    rawFns,
+		Options:         formOpts,
+		Output:          buf.String(),
+		SourceMap:       sourceMap,
+		Errors:          a.tplErrs,
+		TextLines:       lines,
+		Highlighted:     highlightLines(text),
+		LineNumSpacing:  CountDigits(len(lines)),
+		Stats:           statistics(text),
+		Metrics:         measure(parsedT),
+		Templates:       outline(parsedT, text),
+		Graph:           graph,
+		Benchmark:       bench,
+		Fuzz:            fuzzResults,
+		Records:         recordResults,
+		Email:           email,
+		Screenshot:      screenshot,
+		CanScreenshot:   a.browser != nil,
+		CanInterpretGo:  a.interpretGo,
+		Profiles:        profileNames(a.profilesOrDefault()),
+		FunctionPresets: presetNames(a.functionPresetsOrDefault()),
+		GoVersions:      goVersions(),
+		Functions:       usages,
+		Mocked:          mockedFns,
 	}
 }
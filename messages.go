@@ -0,0 +1,66 @@
+package main
+
+import (
+	"regexp"
+	"strconv"
+)
+
+// errorMessage is what the message of an error of the template packages says about where it is
+type errorMessage struct {
+	// File is the name the template was parsed with, empty if the message doesn't say
+	File string
+	// Line is zero indexed, -1 if the message doesn't say, and Char -1 if it doesn't say either
+	Line        int
+	Char        int
+	Description string
+}
+
+// messageFormat is a way the template packages format errors, with the submatches of the name, line, character and
+// description, 0 for the parts it doesn't have
+type messageFormat struct {
+	regex                   *regexp.Regexp
+	file, line, char, descr int
+}
+
+// messageFormats are the formats of errors, the first matching one wins. text/template's are `template: name:line:col:
+// msg`, or without the column for lexer errors, or without position for errors about a whole template, like
+// `template: name: "name" is an incomplete or empty template`, or about the set, like `template: no template "x"
+// associated with template "y"`. html/template's escaping errors are `html/template:name:line:col: msg`, with
+// fewer parts as it knows less, the same as text/template's when it reports them.
+var messageFormats = []messageFormat{
+	{regexp.MustCompile(`(?s)^html/template:(.*?):(\d+):(\d+): (.*)`), 1, 2, 3, 4},
+	{regexp.MustCompile(`(?s)^html/template:(.*?):(\d+): (.*)`), 1, 2, 0, 3},
+	{regexp.MustCompile(`(?s)template: (.*?):(\d+):(\d+): (.*)`), 1, 2, 3, 4},
+	{regexp.MustCompile(`(?s)template: (.*?):(\d+): (.*)`), 1, 2, 0, 3},
+	{regexp.MustCompile(`(?s)^html/template:(.*?): (.*)`), 1, 0, 0, 2},
+	{regexp.MustCompile(`(?s)^html/template: (.*)`), 0, 0, 0, 1},
+	{regexp.MustCompile(`(?s)^template: (no template ".*" associated with template ".*")$`), 0, 0, 0, 1},
+	{regexp.MustCompile(`(?s)^template: (.*?): (.*)`), 1, 0, 0, 2},
+	{regexp.MustCompile(`(?s)^template: (.*)`), 0, 0, 0, 1},
+}
+
+// parseErrorMessage finds where the error with the message msg is, and whether it's in a format it knows
+func parseErrorMessage(msg string) (errorMessage, bool) {
+	for _, format := range messageFormats {
+		matches := format.regex.FindStringSubmatch(msg)
+		if matches == nil {
+			continue
+		}
+		m := errorMessage{Line: -1, Char: -1, Description: matches[format.descr]}
+		if format.file != 0 {
+			m.File = matches[format.file]
+		}
+		if format.line != 0 {
+			if line, err := strconv.Atoi(matches[format.line]); err == nil {
+				m.Line = line - 1
+			}
+		}
+		if format.char != 0 && m.Line != -1 {
+			if char, err := strconv.Atoi(matches[format.char]); err == nil {
+				m.Char = char
+			}
+		}
+		return m, true
+	}
+	return errorMessage{Line: -1, Char: -1, Description: msg}, false
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	htmlTemplate "html/template"
+	"io/ioutil"
+	"testing"
+	textTemplate "text/template"
+)
+
+func TestParseErrorMessage(t *testing.T) {
+	tests := map[string]errorMessage{
+		"template: page:2: unclosed action":                {File: "page", Line: 1, Char: -1, Description: "unclosed action"},
+		"template: page:1: missing value for if":           {File: "page", Line: 0, Char: -1, Description: "missing value for if"},
+		"template: input template:3:7: unexpected {{end}}": {File: "input template", Line: 2, Char: 7, Description: "unexpected {{end}}"},
+		`template: page:1:2: executing "page" at <index . 5>: error calling index: index out of range: 5`: {
+			File: "page", Line: 0, Char: 2,
+			Description: `executing "page" at <index . 5>: error calling index: index out of range: 5`},
+		`template: partials/nav.tmpl:1:18: executing "nav" at <.A.B>: can't evaluate field B in type interface {}`: {
+			File: "partials/nav.tmpl", Line: 0, Char: 18,
+			Description: `executing "nav" at <.A.B>: can't evaluate field B in type interface {}`},
+		`template: page: "page" is an incomplete or empty template`: {
+			File: "page", Line: -1, Char: -1, Description: `"page" is an incomplete or empty template`},
+		`template: "page" is an incomplete or empty template`: {
+			Line: -1, Char: -1, Description: `"page" is an incomplete or empty template`},
+		`template: no template "missing" associated with template "page"`: {
+			Line: -1, Char: -1, Description: `no template "missing" associated with template "page"`},
+		`html/template:page:1:11: no such template "y"`: {File: "page", Line: 0, Char: 11, Description: `no such template "y"`},
+		"html/template:page:1:5: {{if}} branches end in different contexts: {stateTag}, {stateText}": {
+			File: "page", Line: 0, Char: 5, Description: "{{if}} branches end in different contexts: {stateTag}, {stateText}"},
+		"html/template:page:4: on range loop re-entry: {stateTag}": {
+			File: "page", Line: 3, Char: -1, Description: "on range loop re-entry: {stateTag}"},
+		"html/template:page: ends in a non-text context: {stateURL delimDoubleQuote}": {
+			File: "page", Line: -1, Char: -1, Description: "ends in a non-text context: {stateURL delimDoubleQuote}"},
+		`html/template: "page" is an incomplete template`: {Line: -1, Char: -1, Description: `"page" is an incomplete template`},
+		"template: page:3:5: executing \"page\" at <panic>: a\nmultiline message": {
+			File: "page", Line: 2, Char: 5, Description: "executing \"page\" at <panic>: a\nmultiline message"},
+	}
+	for msg, expected := range tests {
+		actual, ok := parseErrorMessage(msg)
+		if !ok || actual != expected {
+			t.Errorf("%q: expected %+v, actual %+v (understood %v)", msg, expected, actual, ok)
+		}
+	}
+
+	if _, ok := parseErrorMessage("unexpected end of JSON input"); ok {
+		t.Error("expected an error of another package not to be understood")
+	}
+}
+
+func TestParseErrorMessageOfPackages(t *testing.T) {
+	var errs []error
+	_, err := textTemplate.New("page").Parse("a\n{{.X")
+	errs = append(errs, err)
+	errs = append(errs, textTemplate.New("page").Execute(ioutil.Discard, nil))
+	errs = append(errs, textTemplate.Must(textTemplate.New("page").Parse("x")).ExecuteTemplate(ioutil.Discard, "y", nil))
+	errs = append(errs, htmlTemplate.Must(htmlTemplate.New("page").Parse(`<a href="{{.}}`)).Execute(ioutil.Discard, nil))
+	errs = append(errs, htmlTemplate.Must(htmlTemplate.New("page").Parse(`{{if .}}<a{{else}}<b>{{end}}`)).Execute(ioutil.Discard, nil))
+	errs = append(errs, htmlTemplate.Must(htmlTemplate.New("page").Parse(`{{template "y"}}`)).Execute(ioutil.Discard, nil))
+	errs = append(errs, htmlTemplate.New("page").Execute(ioutil.Discard, nil))
+	for _, err := range errs {
+		if err == nil {
+			t.Error("expected an error")
+		} else if tplErr := createTemplateError(err, parseErrorLevel); tplErr.Level == misunderstoodError {
+			t.Errorf("%q: misunderstood", err)
+		}
+	}
+}
+
+func TestCreateTemplateErrorStructured(t *testing.T) {
+	err := htmlTemplate.Must(htmlTemplate.New("page").Parse("a\n  <a href=\"{{. | html | print}}\">")).Execute(ioutil.Discard, nil)
+	expected := templateError{File: "page", Line: 1, Char: 13, Description: `predefined escaper "html" disallowed in template`,
+		Level: parseErrorLevel}
+	if actual := createTemplateError(err, parseErrorLevel); actual != expected {
+		t.Errorf("expected %+v, actual %+v", expected, actual)
+	}
+	// the message of a wrapped error doesn't start as the package's do, its location is still known
+	if actual := createTemplateError(fmt.Errorf("rendering: %w", err), parseErrorLevel); actual != expected {
+		t.Errorf("wrapped: expected %+v, actual %+v", expected, actual)
+	}
+
+	execErr := textTemplate.ExecError{Name: "page", Err: errors.New("something odd")}
+	expected = templateError{Line: -1, Char: -1, Description: "something odd", Level: execErrorLevel}
+	if actual := createTemplateError(execErr, execErrorLevel); actual != expected {
+		t.Errorf("expected %+v, actual %+v", expected, actual)
+	}
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"sort"
+	"strings"
+	"text/template"
+	templateParse "text/template/parse"
+)
+
+// templateMetrics measures how complex a template is
+type templateMetrics struct {
+	Name string
+	// NestingDepth is the most {{if}}, {{range}} and {{with}} blocks inside each other
+	NestingDepth int
+	// Actions counts everything between delimiters except comments, {{else}} and {{end}}
+	Actions int
+	// Fields counts the distinct field paths read, like .User.Name
+	Fields int
+	// Branches is one more than the number of decisions made by blocks, like cyclomatic complexity
+	Branches int
+	// LongestPipeline is the most commands in one pipeline
+	LongestPipeline int
+}
+
+// templateStats are the size of a template's text, to track it growing over time
+type templateStats struct {
+	Lines int `json:"lines"`
+	Bytes int `json:"bytes"`
+	// Actions and NestingDepth are those of templateMetrics, of all the templates the text defines
+	Actions      int `json:"actions"`
+	NestingDepth int `json:"nesting_depth"`
+	// Defines counts the templates defined by {{define}} and {{block}}
+	Defines int `json:"defines"`
+}
+
+// statistics returns the stats of text. It's parsed on its own, without checking functions, so they're those of the
+// text whichever templates it's validated with. Only lines, bytes and defines are counted if it doesn't parse.
+func statistics(text string) templateStats {
+	stats := templateStats{Lines: countLines(text), Bytes: len(text), Defines: len(findDefinitions(source{text: text}))}
+	tree := templateParse.New("")
+	tree.Mode = templateParse.SkipFuncCheck
+	treeSet := make(map[string]*templateParse.Tree)
+	if _, err := tree.Parse(text, "", "", treeSet); err != nil {
+		return stats
+	}
+	for _, t := range treeSet {
+		m := measureTree(t)
+		stats.Actions += m.Actions
+		stats.NestingDepth = max(stats.NestingDepth, m.NestingDepth)
+	}
+	return stats
+}
+
+// countLines counts the lines of text, the last one even if it doesn't end with a newline
+func countLines(text string) int {
+	text = normalizeLineEndings(text)
+	if text == "" {
+		return 0
+	}
+	n := strings.Count(text, "\n")
+	if !strings.HasSuffix(text, "\n") {
+		n++
+	}
+	return n
+}
+
+// measure returns the metrics of every template in t, ordered by name
+func measure(t *template.Template) []templateMetrics {
+	var result []templateMetrics
+	for _, tree := range trees(t) {
+		result = append(result, measureTree(tree))
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+	return result
+}
+
+func measureTree(tree *templateParse.Tree) templateMetrics {
+	m := templateMetrics{Name: tree.Name, Branches: 1}
+	fields := make(map[string]bool)
+	walk(tree.Root, func(node templateParse.Node, ancestors []templateParse.Node) bool {
+		switch n := node.(type) {
+		case *templateParse.IfNode, *templateParse.RangeNode, *templateParse.WithNode:
+			m.Actions++
+			m.Branches++
+			if depth := nestingDepth(append(ancestors, node)); depth > m.NestingDepth {
+				m.NestingDepth = depth
+			}
+		case *templateParse.ActionNode, *templateParse.TemplateNode, *templateParse.BreakNode, *templateParse.ContinueNode:
+			m.Actions++
+		case *templateParse.PipeNode:
+			if len(n.Cmds) > m.LongestPipeline {
+				m.LongestPipeline = len(n.Cmds)
+			}
+		case *templateParse.FieldNode:
+			fields[n.String()] = true
+		}
+		return true
+	})
+	m.Fields = len(fields)
+	return m
+}
+
+// nestingDepth counts the blocks in chain, where {{else if}} and {{else with}} continue the block they're in
+func nestingDepth(chain []templateParse.Node) int {
+	depth := 0
+	for i, node := range chain {
+		var b *templateParse.BranchNode
+		switch n := node.(type) {
+		case *templateParse.IfNode:
+			b = &n.BranchNode
+		case *templateParse.RangeNode:
+			b = &n.BranchNode
+		case *templateParse.WithNode:
+			b = &n.BranchNode
+		default:
+			continue
+		}
+		depth++
+		if i+2 < len(chain) && b.ElseList != nil && chain[i+1] == templateParse.Node(b.ElseList) && len(b.ElseList.Nodes) == 1 && chain[i+2] == b.ElseList.Nodes[0] {
+			switch chain[i+2].(type) {
+			case *templateParse.IfNode, *templateParse.WithNode:
+				depth--
+			}
+		}
+	}
+	return depth
+}
@@ -0,0 +1,45 @@
+package main
+
+import "testing"
+
+func TestMeasure(t *testing.T) {
+	metrics := measure(mustParse(t, `{{define "row"}}{{.Name}}{{end}}`+
+		`{{if .A}}{{range .Items}}{{with .B}}{{.C | printf "%s" | html}}{{end}}{{end}}`+
+		`{{else if .D}}{{.A}}{{else}}{{template "row" .}}{{end}}`))
+	if len(metrics) != 2 {
+		t.Fatalf("unexpected metrics: %v", metrics)
+	}
+	expected := templateMetrics{
+		Name:            "base",
+		NestingDepth:    3,
+		Actions:         7,
+		Fields:          5,
+		Branches:        5,
+		LongestPipeline: 3,
+	}
+	if metrics[0] != expected {
+		t.Errorf("expected %+v, actual %+v", expected, metrics[0])
+	}
+	if metrics[1] != (templateMetrics{Name: "row", Actions: 1, Fields: 1, Branches: 1, LongestPipeline: 1}) {
+		t.Errorf("unexpected metrics for row: %+v", metrics[1])
+	}
+}
+
+func TestStatistics(t *testing.T) {
+	text := "{{define \"row\"}}{{.Name}}{{end}}\r\n" +
+		"{{range .Items}}{{if .A}}{{template \"row\" .}}{{end}}{{end}}\n" +
+		"{{block \"footer\" .}}{{mystery .}}{{end}}\n"
+	expected := templateStats{Lines: 3, Bytes: len(text), Actions: 6, NestingDepth: 2, Defines: 2}
+	if stats := statistics(text); stats != expected {
+		t.Errorf("expected %+v, actual %+v", expected, stats)
+	}
+
+	// what doesn't parse is only counted
+	expected = templateStats{Lines: 2, Bytes: 26, Defines: 1}
+	if stats := statistics("{{define \"a\"}}{{end}}\n{{if"); stats != expected {
+		t.Errorf("expected %+v, actual %+v", expected, stats)
+	}
+	if stats := statistics(""); stats != (templateStats{}) {
+		t.Errorf("unexpected stats of nothing: %+v", stats)
+	}
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+// functionSpec specifies a mocked function, so pipelines consuming its result get something realistic to work with
+type functionSpec struct {
+	// Args are the types of the arguments, like "string", checked if they're given. The last can be variadic, like
+	// "...int".
+	Args []string `json:"args"`
+	// Returns is the value the function returns, nil if there isn't one
+	Returns json.RawMessage `json:"returns"`
+	// Error fails calls to the function with it, if it isn't empty
+	Error string `json:"error"`
+}
+
+// argKinds are the kinds of values each argument type accepts
+var argKinds = map[string][]reflect.Kind{
+	"string": {reflect.String},
+	"int": {reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64, reflect.Uint, reflect.Uint8,
+		reflect.Uint16, reflect.Uint32, reflect.Uint64},
+	"float":  {reflect.Float32, reflect.Float64},
+	"bool":   {reflect.Bool},
+	"map":    {reflect.Map, reflect.Struct},
+	"slice":  {reflect.Slice, reflect.Array},
+	"number": nil,
+	"any":    nil,
+}
+
+// isFunctionSpecs is whether rawFns is a JSON object of function specifications instead of a list of names
+func isFunctionSpecs(rawFns string) bool {
+	return strings.HasPrefix(strings.TrimSpace(rawFns), "{")
+}
+
+// parseFunctionSpecs parses a JSON object of function names to their specifications, like
+// {"lookupUser": {"args": ["string"], "returns": {"Name": "demo"}}}
+func parseFunctionSpecs(rawFns string) (map[string]functionSpec, error) {
+	var specs map[string]functionSpec
+	if err := json.Unmarshal([]byte(rawFns), &specs); err != nil {
+		return nil, err
+	}
+	for _, name := range sortedSpecNames(specs) {
+		for i, arg := range specs[name].Args {
+			if variadic := strings.HasPrefix(arg, "..."); variadic && i != len(specs[name].Args)-1 {
+				return nil, fmt.Errorf("only the last argument of %s can be variadic", name)
+			}
+			if _, ok := argKinds[strings.TrimPrefix(arg, "...")]; !ok {
+				return nil, fmt.Errorf("unknown type %q of argument %d of %s", arg, i+1, name)
+			}
+		}
+	}
+	return specs, nil
+}
+
+func sortedSpecNames(specs map[string]functionSpec) []string {
+	names := make([]string, 0, len(specs))
+	for name := range specs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// mock returns a function named name which checks its arguments and returns what the specification says
+func (s functionSpec) mock(name string) interface{} {
+	return func(args ...interface{}) (interface{}, error) {
+		if err := s.checkArgs(name, args); err != nil {
+			return nil, err
+		}
+		if s.Error != "" {
+			return nil, errors.New(s.Error)
+		}
+		if len(s.Returns) == 0 {
+			return nil, nil
+		}
+		// decoded for each call, so changes to a result don't leak into the next
+		var result interface{}
+		err := json.Unmarshal(s.Returns, &result)
+		return result, err
+	}
+}
+
+// checkArgs checks args are as many as the specification's, and of their types
+func (s functionSpec) checkArgs(name string, args []interface{}) error {
+	if s.Args == nil {
+		return nil
+	}
+	variadic := len(s.Args) > 0 && strings.HasPrefix(s.Args[len(s.Args)-1], "...")
+	switch {
+	case variadic && len(args) < len(s.Args)-1:
+		return fmt.Errorf("%s expects at least %d arguments, got %d", name, len(s.Args)-1, len(args))
+	case !variadic && len(args) != len(s.Args):
+		return fmt.Errorf("%s expects %d arguments, got %d", name, len(s.Args), len(args))
+	}
+	for i, arg := range args {
+		typ := s.Args[len(s.Args)-1]
+		if i < len(s.Args) {
+			typ = s.Args[i]
+		}
+		typ = strings.TrimPrefix(typ, "...")
+		if !isArgType(typ, arg) {
+			return fmt.Errorf("%s expects a %s as argument %d, got %v (%T)", name, typ, i+1, arg, arg)
+		}
+	}
+	return nil
+}
+
+// isArgType is whether arg is of the argument type typ
+func isArgType(typ string, arg interface{}) bool {
+	switch typ {
+	case "any":
+		return true
+	case "number":
+		return isArgType("int", arg) || isArgType("float", arg)
+	}
+	v := reflect.Indirect(reflect.ValueOf(arg))
+	if typ == "int" && (v.Kind() == reflect.Float32 || v.Kind() == reflect.Float64) {
+		// JSON data only has floats
+		return v.Float() == math.Trunc(v.Float())
+	}
+	for _, kind := range argKinds[typ] {
+		if v.Kind() == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// sortedFunctionNames returns the names of fns, sorted so they're added to templates and cache keys in the same order
+func sortedFunctionNames(fns template.FuncMap) []string {
+	names := make([]string, 0, len(fns))
+	for name := range fns {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// autoMocked returns the names of the unknown functions parsing mocked, in the order parseTplErrs found them
+func autoMocked(parseTplErrs []templateError) []string {
+	var names []string
+	for _, tplErr := range parseTplErrs {
+		if matches := functionNotFoundRegex.FindStringSubmatch(tplErr.Description); matches != nil {
+			names = append(names, matches[1])
+		}
+	}
+	return names
+}
+
+// unmockedCalls reports the calls in t to the mocked functions after the first of each, which parsing already reported
+func unmockedCalls(t *template.Template, mocked []string) []templateError {
+	isMocked := make(map[string]bool, len(mocked))
+	for _, name := range mocked {
+		isMocked[name] = true
+	}
+	var tplErrs []templateError
+	for _, usage := range functionUsages(t, nil) {
+		if !isMocked[usage.Name] {
+			continue
+		}
+		for _, c := range usage.Calls[1:] {
+			tplErr := nodeError(c.tree, c.node, fmt.Sprintf("function %q not defined", usage.Name))
+			tplErr.Level = parseErrorLevel
+			tplErrs = append(tplErrs, tplErr)
+		}
+	}
+	return tplErrs
+}
+
+// autoMockedFunction is an unknown function parsing mocked, so calls to it return nothing
+type autoMockedFunction struct {
+	Name string
+	// At is its first call
+	At position
+}
+
+// autoMockedFunctions returns the functions of usages, which were located, the template only got because they were mocked
+func autoMockedFunctions(usages []functionUsage, mocked []string) []autoMockedFunction {
+	var result []autoMockedFunction
+	for _, name := range mocked {
+		for _, usage := range usages {
+			if usage.Name == name && len(usage.Calls) > 0 {
+				result = append(result, autoMockedFunction{Name: name, At: usage.Calls[0].At})
+			}
+		}
+	}
+	return result
+}
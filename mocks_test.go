@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCreateDataFunctionSpecs(t *testing.T) {
+	rawFns := `{"lookupUser": {"args": ["string"], "returns": {"Name": "demo", "Roles": ["admin"]}},
+		"join": {"args": ["string", "...string"], "returns": "a-b"}, "untyped": {}, "broken": {"error": "unavailable"}}`
+	data := (&App{}).createData(context.Background(), `{{(lookupUser "ann").Name}} {{index (lookupUser .ID).Roles 0}} {{join "-" "a" "b"}} {{untyped 1 2}}`,
+		`{"ID": "ann"}`, rawFns, options{})
+	if len(data.Errors) != 0 || data.Output != "demo admin a-b <no value>" {
+		t.Errorf("unexpected output %q and errors %+v", data.Output, data.Errors)
+	}
+
+	tests := map[string]string{
+		`{{lookupUser}}`:     "lookupUser expects 1 arguments, got 0",
+		`{{lookupUser 1}}`:   "lookupUser expects a string as argument 1, got 1 (int)",
+		`{{join}}`:           "join expects at least 1 arguments, got 0",
+		`{{join "-" "a" 2}}`: "join expects a string as argument 3, got 2 (int)",
+		`{{broken}}`:         "unavailable",
+	}
+	for text, expected := range tests {
+		data := (&App{}).createData(context.Background(), text, "", rawFns, options{})
+		if len(data.Errors) != 1 || !strings.HasSuffix(data.Errors[0].Description, expected) {
+			t.Errorf("%s: expected an error ending with %q, actual %+v", text, expected, data.Errors)
+		}
+	}
+}
+
+func TestParseFunctionSpecsErrors(t *testing.T) {
+	tests := map[string]string{
+		`{"f": {"args": ["text"]}}`:             `unknown type "text" of argument 1 of f`,
+		`{"f": {"args": ["...int", "string"]}}`: "only the last argument of f can be variadic",
+		`{"f": []}`:                             "json: cannot unmarshal array",
+	}
+	for raw, expected := range tests {
+		if _, err := parseFunctionSpecs(raw); err == nil || !strings.HasPrefix(err.Error(), expected) {
+			t.Errorf("%s: expected an error starting with %q, actual %v", raw, expected, err)
+		}
+	}
+}
+
+func TestIsArgType(t *testing.T) {
+	tests := []struct {
+		typ      string
+		arg      interface{}
+		expected bool
+	}{
+		{"int", 1, true},
+		{"int", 1.0, true},
+		{"int", 1.5, false},
+		{"float", 1.5, true},
+		{"number", 2, true},
+		{"bool", "true", false},
+		{"map", map[string]interface{}{}, true},
+		{"slice", []interface{}{}, true},
+		{"any", nil, true},
+	}
+	for _, test := range tests {
+		if actual := isArgType(test.typ, test.arg); actual != test.expected {
+			t.Errorf("%s %v: expected %v, actual %v", test.typ, test.arg, test.expected, actual)
+		}
+	}
+}
+
+func TestCreateDataStrict(t *testing.T) {
+	text := "{{lookup 1}}\n{{lookup 2}} {{upper .}}"
+	data := (&App{}).createData(context.Background(), text, `"a"`, "upper", options{Strict: true})
+	expected := []templateError{
+		{Line: 0, Char: 2, Level: parseErrorLevel, Description: `function "lookup" not defined`},
+		{Line: 1, Char: 2, Level: parseErrorLevel, Description: `function "lookup" not defined`},
+	}
+	if len(data.Errors) != len(expected) {
+		t.Fatalf("expected %d errors, actual %+v", len(expected), data.Errors)
+	}
+	for i := range expected {
+		assertError(t, expected[i], data.Errors[i])
+	}
+	if data.Output != "" {
+		t.Errorf("expected the template not to be executed, actual output %q", data.Output)
+	}
+
+	data = (&App{}).createData(context.Background(), text, `"a"`, "upper", options{})
+	if len(data.Errors) != 1 || data.Output == "" {
+		t.Errorf("expected mocking to keep going, actual output %q and errors %+v", data.Output, data.Errors)
+	}
+}
+
+func TestCreateDataMocked(t *testing.T) {
+	data := (&App{}).createData(context.Background(), "{{upper .}}\n{{lookup 1}} {{lookup 2}}", `"a"`, "upper", options{})
+	expected := []autoMockedFunction{{Name: "lookup", At: position{Line: 1, Char: 2}}}
+	if !reflect.DeepEqual(data.Mocked, expected) {
+		t.Errorf("expected %+v, actual %+v", expected, data.Mocked)
+	}
+	if formatted := formatMocked("page.tmpl", data.Mocked[0]); formatted != "page.tmpl:2:3: function lookup was mocked, its calls return nothing [warning]" {
+		t.Errorf("unexpected warning %q", formatted)
+	}
+}
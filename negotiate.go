@@ -0,0 +1,67 @@
+package main
+
+import (
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// validationResult is the result of validating the page's form, for clients asking for JSON rather than the page
+type validationResult struct {
+	Output string          `json:"output"`
+	Errors []templateError `json:"errors"`
+	// Mocked are the unknown functions mocked to execute the template, whose output is missing what they'd return
+	Mocked    []autoMockedFunction `json:"mocked,omitempty"`
+	Functions []functionUsage      `json:"functions,omitempty"`
+	Templates []templateDefinition `json:"templates,omitempty"`
+	Graph     *dependencyGraph     `json:"graph,omitempty"`
+	Stats     templateStats        `json:"stats"`
+	Metrics   []templateMetrics    `json:"metrics,omitempty"`
+	SourceMap []outputSpan         `json:"source_map,omitempty"`
+	Records   []recordResult       `json:"records,omitempty"`
+	Files     []fileResult         `json:"files,omitempty"`
+	// Diff is the change made by fix mode
+	Diff      string        `json:"diff,omitempty"`
+	Benchmark *benchmark    `json:"benchmark,omitempty"`
+	Fuzz      []fuzzResult  `json:"fuzz,omitempty"`
+	Email     *emailPreview `json:"email,omitempty"`
+	// Screenshot is a base64 encoded PNG thumbnail of the output
+	Screenshot string `json:"screenshot,omitempty"`
+	EmailSent  string `json:"email_sent,omitempty"`
+}
+
+func newValidationResult(data indexData) validationResult {
+	errs := data.Errors
+	if errs == nil {
+		errs = []templateError{}
+	}
+	return validationResult{Output: data.Output, Errors: errs, Mocked: data.Mocked, Functions: data.Functions,
+		Templates: data.Templates, Graph: data.Graph, Stats: data.Stats, Metrics: data.Metrics, SourceMap: data.SourceMap,
+		Records: data.Records, Files: data.Files, Diff: data.Diff, Benchmark: data.Benchmark, Fuzz: data.Fuzz,
+		Email: data.Email, Screenshot: data.Screenshot, EmailSent: data.EmailSent}
+}
+
+// acceptsJSON returns whether the Accept header of r prefers JSON to HTML. Browsers accept anything, but HTML first.
+func acceptsJSON(r *http.Request) bool {
+	var jsonQ, htmlQ float64
+	for _, accepted := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(accepted))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if s, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(s, 64); err != nil {
+				continue
+			}
+		}
+		switch mediaType {
+		case "application/json":
+			jsonQ = q
+		case "text/html":
+			htmlQ = q
+		}
+	}
+	return jsonQ > 0 && jsonQ > htmlQ
+}
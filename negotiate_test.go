@@ -0,0 +1,85 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	htmlTemplate "html/template"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestAcceptsJSON(t *testing.T) {
+	for accept, expected := range map[string]bool{
+		"":                                  false,
+		"application/json":                  true,
+		"application/json, text/plain":      true,
+		"text/html, application/json;q=0.9": false,
+		"text/html;q=0.5, application/json": true,
+		"application/json;q=0":              false,
+		"*/*":                               false,
+		// what browsers send
+		"text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8": false,
+	} {
+		r := httptest.NewRequest("POST", "/", nil)
+		r.Header.Set("Accept", accept)
+		if actual := acceptsJSON(r); actual != expected {
+			t.Errorf("%q: expected %v, actual %v", accept, expected, actual)
+		}
+	}
+}
+
+func TestPostJSON(t *testing.T) {
+	a := &App{index: htmlTemplate.Must(htmlTemplate.New("index.html").Parse("{{.Output}}"))}
+	post := func(accept string) *httptest.ResponseRecorder {
+		form := url.Values{"from-raw-text": {"Hi {{.Name}}\n{{.Name.First}}"}, "data": {`{"Name": "Bob"}`}}
+		r := httptest.NewRequest("POST", "/", strings.NewReader(form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		r.Header.Set("Accept", accept)
+		w := httptest.NewRecorder()
+		a.Post(w, r)
+		return w
+	}
+
+	w := post("application/json")
+	var result validationResult
+	if err := json.Unmarshal(w.Body.Bytes(), &result); err != nil || w.Header().Get("Content-Type") != "application/json" {
+		t.Fatalf("unexpected response: %s %s, %v", w.Header().Get("Content-Type"), w.Body, err)
+	}
+	if result.Output != "Hi Bob\n" || len(result.Errors) != 1 || result.Errors[0].Line != 1 {
+		t.Errorf("unexpected result: %+v", result)
+	}
+	if result.Stats != (templateStats{Lines: 2, Bytes: 28, Actions: 2}) {
+		t.Errorf("unexpected stats: %+v", result.Stats)
+	}
+	if w := post("text/html"); w.Body.String() != "Hi Bob\n" {
+		t.Errorf("expected the page, actual %s", w.Body)
+	}
+}
+
+func TestPostConcurrently(t *testing.T) {
+	a := &App{index: htmlTemplate.Must(htmlTemplate.New("index.html").Parse("{{.Output}}"))}
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			form := url.Values{"from-raw-text": {fmt.Sprintf("{{.X%d}}", i)}, "data": {"{}"}}
+			if i%2 == 0 {
+				form.Set("fix", "1")
+			}
+			r := httptest.NewRequest("POST", "/", strings.NewReader(form.Encode()))
+			r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			r.Header.Set("Accept", "application/json")
+			w := httptest.NewRecorder()
+			a.Post(w, r)
+			var result validationResult
+			if err := json.Unmarshal(w.Body.Bytes(), &result); err != nil || len(result.Errors) != 0 {
+				t.Errorf("%d: unexpected response %s, %v", i, w.Body, err)
+			}
+		}(i)
+	}
+	wg.Wait()
+}
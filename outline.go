@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+// templateDefinition is a template defined in a text, with what it expects of its dot
+type templateDefinition struct {
+	Name string `json:"name"`
+	// Line is the zero indexed line the template is defined at, 0 for the root template
+	Line int `json:"line"`
+	// Dot are the field paths of dot the template reads, like .User.Name, without the ones only read on the way to
+	// others
+	Dot []string `json:"dot"`
+}
+
+// outline returns the templates of t, which was parsed from text, the root one first and then in the order they're
+// defined
+func outline(t *template.Template, text string) []templateDefinition {
+	definitions := findDefinitions(source{text: text})
+	result := make([]templateDefinition, 0)
+	for _, tree := range trees(t) {
+		def := templateDefinition{Name: tree.Name, Dot: make([]string, 0)}
+		if defs := definitions[tree.Name]; len(defs) > 0 {
+			// the last definition is the one kept
+			def.Line = defs[len(defs)-1].line
+		}
+		paths := treeFieldPaths(tree)
+		for i, path := range paths {
+			if !isPrefixOfAny(path, paths[i+1:]) {
+				def.Dot = append(def.Dot, formatPath(path))
+			}
+		}
+		result = append(result, def)
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		if root := t.Name(); result[i].Name == root || result[j].Name == root {
+			return result[i].Name == root && result[j].Name != root
+		}
+		if result[i].Line != result[j].Line {
+			return result[i].Line < result[j].Line
+		}
+		return result[i].Name < result[j].Name
+	})
+	return result
+}
+
+// isPrefixOfAny is whether path is the start of one of paths, which come after it as prefixes are added first
+func isPrefixOfAny(path []string, paths [][]string) bool {
+	key := strings.Join(path, ".") + "."
+	for _, p := range paths {
+		if strings.HasPrefix(strings.Join(p, ".")+".", key) {
+			return true
+		}
+	}
+	return false
+}
+
+// Templates serves the templates defined in the template form value, with their lines and what they read of their
+// dot, as JSON
+func (a *App) Templates(w http.ResponseWriter, r *http.Request) {
+	text, err := getFormValue(r, "template")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	presetFns, err := a.presetFunctions(r.FormValue("function-preset"))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed to understand the function preset: %v", err), http.StatusBadRequest)
+		return
+	}
+	t, _ := newSetTemplate(inputTemplateName, presetFns, r.FormValue("functions"), "")
+	t, _ = parse(r.Context(), text, t)
+	writeJSON(w, outline(t, text))
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestOutline(t *testing.T) {
+	text := `{{.Title}}
+{{define "user"}}{{.User.Name}} {{.User.Email}}{{end}}
+{{define "empty"}}{{end}}`
+	expected := []templateDefinition{
+		{Name: "base", Line: 0, Dot: []string{".Title"}},
+		{Name: "user", Line: 1, Dot: []string{".User.Name", ".User.Email"}},
+		{Name: "empty", Line: 2, Dot: []string{}},
+	}
+	if actual := outline(mustParse(t, text), text); !reflect.DeepEqual(expected, actual) {
+		t.Errorf("expected %+v, actual %+v", expected, actual)
+	}
+}
+
+func TestTemplates(t *testing.T) {
+	form := url.Values{"template": {"{{define \"a\"}}{{.A | upper}}{{end}}"}, "functions": {"upper"}}
+	r := httptest.NewRequest("POST", "/api/v1/templates", strings.NewReader(form.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+	(&App{}).Templates(w, r)
+
+	var actual []templateDefinition
+	if err := json.NewDecoder(w.Body).Decode(&actual); err != nil {
+		t.Fatal(err)
+	}
+	expected := []templateDefinition{{Name: inputTemplateName, Dot: []string{}}, {Name: "a", Dot: []string{".A"}}}
+	if !reflect.DeepEqual(expected, actual) {
+		t.Errorf("expected %+v, actual %+v", expected, actual)
+	}
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	htmlTemplate "html/template"
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+// defaultMaxOutput is how many bytes of output are kept unless another limit is set
+const defaultMaxOutput = 1 << 20
+
+// execOutput is where templates are executed to, it's reset before executing again
+type execOutput interface {
+	io.Writer
+	Reset()
+}
+
+// cappedBuffer keeps the first max bytes written to it and only counts the rest, so templates that output a lot can't
+// use up the server's memory
+type cappedBuffer struct {
+	buf  bytes.Buffer
+	max  int
+	size int
+}
+
+func newCappedBuffer(max int) *cappedBuffer {
+	return &cappedBuffer{max: max}
+}
+
+func (b *cappedBuffer) Write(p []byte) (int, error) {
+	b.size += len(p)
+	room := b.max - b.buf.Len()
+	if room >= len(p) {
+		return b.buf.Write(p)
+	}
+	// don't keep part of a character
+	for room > 0 && !utf8.RuneStart(p[room]) {
+		room--
+	}
+	if room > 0 {
+		b.buf.Write(p[:room])
+	}
+	return len(p), nil
+}
+
+func (b *cappedBuffer) Reset() {
+	b.buf.Reset()
+	b.size = 0
+}
+
+// truncated is whether more was written than was kept
+func (b *cappedBuffer) truncated() bool {
+	return b.size > b.buf.Len()
+}
+
+// kept returns what was kept, without saying if there was more
+func (b *cappedBuffer) kept() string {
+	return b.buf.String()
+}
+
+// String returns what was kept, followed by how much was written if that's more
+func (b *cappedBuffer) String() string {
+	if !b.truncated() {
+		return b.buf.String()
+	}
+	return b.buf.String() + fmt.Sprintf("\n… output truncated, showing %d of %d bytes", b.buf.Len(), b.size)
+}
+
+// whitespaceMarkers are what the output view shows in place of invisible characters, newlines are still shown after
+// theirs
+var whitespaceMarkers = map[rune]string{
+	' ':      "·",
+	'\t':     "→",
+	'\n':     "↵",
+	'\r':     "␍",
+	'\u00a0': "⍽",
+}
+
+// showWhitespace returns s as HTML with markers in place of its spaces, tabs, non-breaking spaces and line endings
+func showWhitespace(s string) htmlTemplate.HTML {
+	var b strings.Builder
+	for _, r := range s {
+		marker, ok := whitespaceMarkers[r]
+		if !ok {
+			b.WriteString(htmlTemplate.HTMLEscapeString(string(r)))
+			continue
+		}
+		b.WriteString(`<span class="whitespace">` + marker + `</span>`)
+		if r == '\n' {
+			b.WriteRune(r)
+		}
+	}
+	return htmlTemplate.HTML(b.String())
+}
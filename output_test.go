@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"testing"
+)
+
+func TestCappedBuffer(t *testing.T) {
+	buf := newCappedBuffer(5)
+	fmt.Fprint(buf, "abc")
+	if buf.truncated() || buf.String() != "abc" {
+		t.Errorf("unexpected output: %s", buf.String())
+	}
+	fmt.Fprint(buf, "d兵")
+	if expected := "abcd\n… output truncated, showing 4 of 7 bytes"; buf.String() != expected {
+		t.Errorf("expected `%s`, actual `%s`", expected, buf.String())
+	}
+	buf.Reset()
+	if buf.truncated() || buf.String() != "" {
+		t.Errorf("unexpected output after reset: %s", buf.String())
+	}
+}
+
+func TestMaxOutputClamped(t *testing.T) {
+	data := (&App{maxOutput: 3}).createData(context.Background(), `{{range .}}{{.}}{{end}}`, "[1, 2, 3, 4, 5]", "",
+		options{MaxOutput: 4000000000})
+	if expected := "123\n… output truncated, showing 3 of 5 bytes"; data.Output != expected {
+		t.Errorf("expected `%s`, actual `%s`", expected, data.Output)
+	}
+}
+
+func TestMaxOutput(t *testing.T) {
+	data := (&App{}).createData(context.Background(), `{{range .}}{{.}}{{end}}`, "[1, 2, 3, 4, 5]", "", options{MaxOutput: 3})
+	if len(data.Errors) != 0 {
+		t.Fatalf("unexpected errors found: %v", data.Errors)
+	}
+	if expected := "123\n… output truncated, showing 3 of 5 bytes"; data.Output != expected {
+		t.Errorf("expected `%s`, actual `%s`", expected, data.Output)
+	}
+}
+
+func TestShowWhitespace(t *testing.T) {
+	expected := `<span class="whitespace">·</span>a<span class="whitespace">→</span>&lt;b&gt;<span class="whitespace">⍽</span>` +
+		`<span class="whitespace">␍</span><span class="whitespace">↵</span>` + "\n"
+	if actual := showWhitespace(" a\t<b>\u00a0\r\n"); string(actual) != expected {
+		t.Errorf("expected %s, actual %s", expected, actual)
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"runtime"
+	"strings"
+)
+
+// maxPanicFrames is how much of the stack of a panic in a function is described
+const maxPanicFrames = 3
+
+// functionPanic is a panic in a template function along with where it happened
+type functionPanic struct {
+	value interface{}
+	stack []string
+}
+
+func (p functionPanic) Error() string {
+	if len(p.stack) == 0 {
+		return fmt.Sprintf("panic: %v", p.value)
+	}
+	return fmt.Sprintf("panic: %v (in %s)", p.value, strings.Join(p.stack, ", called by "))
+}
+
+// recoverPanics wraps a template function so panics inside it say where they happened. text/template already turns
+// panics in functions into errors at the calling action, but only keeps the panic's value.
+func recoverPanics(fn interface{}) interface{} {
+	v := reflect.ValueOf(fn)
+	if v.Kind() != reflect.Func {
+		return fn
+	}
+	return reflect.MakeFunc(v.Type(), func(args []reflect.Value) []reflect.Value {
+		defer func() {
+			if r := recover(); r != nil {
+				panic(functionPanic{value: r, stack: panicStack()})
+			}
+		}()
+		if v.Type().IsVariadic() {
+			return v.CallSlice(args)
+		}
+		return v.Call(args)
+	}).Interface()
+}
+
+// panicStack summarizes the frames a panic being recovered passed through, innermost first, leaving out the runtime
+func panicStack() []string {
+	pcs := make([]uintptr, 32)
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(0, pcs)])
+
+	// the frames of wrapped functions are named after recoverPanics
+	wrapper := runtime.FuncForPC(reflect.ValueOf(recoverPanics).Pointer()).Name()
+
+	var stack []string
+	panicking := false
+	for {
+		frame, more := frames.Next()
+		switch {
+		case frame.Function == "runtime.gopanic":
+			panicking = true
+		case panicking && strings.HasPrefix(frame.Function, wrapper):
+			// the rest is the template package calling the function
+			return stack
+		case panicking && !strings.HasPrefix(frame.Function, "runtime.") && !strings.HasPrefix(frame.Function, "reflect."):
+			if len(stack) == maxPanicFrames {
+				return stack
+			}
+			stack = append(stack, fmt.Sprintf("%s (%s:%d)", frame.Function, shortFile(frame.File), frame.Line))
+		}
+		if !more {
+			return stack
+		}
+	}
+}
+
+func shortFile(file string) string {
+	if i := strings.LastIndex(file, "/"); i != -1 {
+		return file[i+1:]
+	}
+	return file
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	textTemplate "text/template"
+)
+
+func TestFunctionPanic(t *testing.T) {
+	boom := func(s string) string {
+		var m map[string]int
+		m[s] = 1
+		return s
+	}
+	parsedT := textTemplate.Must(textTemplate.New("base").Funcs(textTemplate.FuncMap{
+		"boom": recoverPanics(boom),
+	}).Parse("a\n{{boom \"x\"}}"))
+
+	var buf bytes.Buffer
+	errs := exec(parsedT, nil, &buf)
+	if len(errs) != 1 {
+		t.Fatalf("unexpected errors found: %v", errs)
+	}
+	if errs[0].Line != 1 || errs[0].Char != 2 || errs[0].Level != execErrorLevel {
+		t.Errorf("unexpected error location: %+v", errs[0])
+	}
+	prefix := `executing "base" at <boom "x">: error calling boom: panic: assignment to entry in nil map (in `
+	if !strings.HasPrefix(errs[0].Description, prefix) || !strings.HasSuffix(errs[0].Description, "TestFunctionPanic.func1 (panics_test.go:13))") {
+		t.Errorf("unexpected description: %s", errs[0].Description)
+	}
+}
+
+func TestRecoverPanicsVariadic(t *testing.T) {
+	fn := recoverPanics(mockFunction).(func(...interface{}) error)
+	if err := fn(1, "a"); err != nil {
+		t.Error(err)
+	}
+}
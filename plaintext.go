@@ -0,0 +1,70 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+)
+
+// ValidateText validates the template in the raw body and lists the errors as plain text, one per line like the
+// command line does, so it can be used with curl --data-binary. The data and functions are the data and functions
+// query parameters, or the X-Data and X-Functions headers, and options are query parameters like the form values.
+// Failing validations respond with 422 Unprocessable Entity.
+func (a *App) ValidateText(w http.ResponseWriter, r *http.Request) {
+	b, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxRequestSize))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed to read template: %v", err), http.StatusBadRequest)
+		return
+	}
+	// the body is the template, even when curl says it's a form
+	r.Body = ioutil.NopCloser(bytes.NewReader(nil))
+	r.Header.Del("Content-Type")
+
+	name := r.FormValue("name")
+	if name == "" {
+		name = "template"
+	}
+	rawData, err := getFormValue(r, "data")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if rawData == "" {
+		rawData = r.Header.Get("X-Data")
+	}
+	rawFns := r.FormValue("functions")
+	if rawFns == "" {
+		rawFns = r.Header.Get("X-Functions")
+	}
+
+	data := a.forRequest().createData(r.Context(), string(b), rawData, rawFns, getOptions(r))
+	if r.Context().Err() != nil {
+		return
+	}
+	var out bytes.Buffer
+	for _, tplErr := range data.Errors {
+		fmt.Fprintln(&out, formatError(name, tplErr))
+		if tplErr.Fix != nil {
+			fmt.Fprintf(&out, "\tfix: %s\n", tplErr.Fix.Description)
+		}
+	}
+	for _, m := range data.Mocked {
+		fmt.Fprintln(&out, formatMocked(name, m))
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	if failing(data.Errors) {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+	}
+	w.Write(out.Bytes())
+}
+
+// failing is whether any of the errors fails a validation
+func failing(tplErrs []templateError) bool {
+	for _, tplErr := range tplErrs {
+		if isFailure(tplErr) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestValidateText(t *testing.T) {
+	r := httptest.NewRequest("POST", "/validate.txt?name=page.tmpl&functions=upper", strings.NewReader("{{.Name | upper}}\n{{if .X}}"))
+	// what curl --data-binary sends
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	r.Header.Set("X-Data", `{"Name": "x"}`)
+	w := httptest.NewRecorder()
+	(&App{}).ValidateText(w, r)
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("expected status %d, actual %d", http.StatusUnprocessableEntity, w.Code)
+	}
+	expected := "page.tmpl:2:1: {{if}} has no matching {{end}} [parse]\n\tfix: add the missing {{end}}\n"
+	if actual := w.Body.String(); actual != expected {
+		t.Errorf("expected %q, actual %q", expected, actual)
+	}
+
+	r = httptest.NewRequest("POST", "/validate.txt?data=%7B%7D", strings.NewReader("{{.}}"))
+	w = httptest.NewRecorder()
+	(&App{}).ValidateText(w, r)
+	if w.Code != http.StatusOK || w.Body.String() != "" {
+		t.Errorf("unexpected response %d %q", w.Code, w.Body)
+	}
+}
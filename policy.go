@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"path"
+	"text/template"
+)
+
+// functionPolicy is what the server lets templates call. The zero value allows everything.
+type functionPolicy struct {
+	// denied are the functions templates may not call
+	denied map[string]bool
+	// mockable are the patterns, like lookup*, of the unknown functions which may be mocked, nil for any
+	mockable []string
+}
+
+// newFunctionPolicy returns a policy denying the functions deny, which can name presets like time for all of their
+// functions, and only mocking the unknown functions matching one of the patterns mockable, if there are any
+func newFunctionPolicy(deny, mockable []string) (functionPolicy, error) {
+	p := functionPolicy{denied: make(map[string]bool), mockable: mockable}
+	for _, name := range deny {
+		preset := false
+		for _, doc := range documentedFunctions() {
+			if doc.Preset == name {
+				p.denied[doc.Name] = true
+				preset = true
+			}
+		}
+		if !preset {
+			p.denied[name] = true
+		}
+	}
+	for _, pattern := range mockable {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return functionPolicy{}, fmt.Errorf("bad pattern %q of functions to mock: %v", pattern, err)
+		}
+	}
+	return p, nil
+}
+
+// check reports every call in t to a denied function, and the unknown functions parseTplErrs found which may not be
+// mocked. Templates breaking the policy shouldn't be executed.
+func (p functionPolicy) check(t *template.Template, parseTplErrs []templateError) []templateError {
+	var tplErrs []templateError
+	if len(p.denied) > 0 {
+		for _, usage := range functionUsages(t, nil) {
+			if !p.denied[usage.Name] {
+				continue
+			}
+			for _, c := range usage.Calls {
+				tplErr := nodeError(c.tree, c.node, fmt.Sprintf("function %q is forbidden by the server's policy", usage.Name))
+				tplErr.Level = parseErrorLevel
+				tplErrs = append(tplErrs, tplErr)
+			}
+		}
+	}
+	if p.mockable != nil {
+		for _, tplErr := range parseTplErrs {
+			matches := functionNotFoundRegex.FindStringSubmatch(tplErr.Description)
+			if matches == nil || p.mayMock(matches[1]) {
+				continue
+			}
+			tplErr.Description = fmt.Sprintf("function %q isn't allowed to be mocked by the server's policy", matches[1])
+			tplErrs = append(tplErrs, tplErr)
+		}
+	}
+	return sortErrors(tplErrs)
+}
+
+// mayMock is whether the unknown function name may be mocked
+func (p functionPolicy) mayMock(name string) bool {
+	for _, pattern := range p.mockable {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
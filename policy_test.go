@@ -0,0 +1,41 @@
+package main
+
+import (
+	"context"
+	"testing"
+)
+
+func TestFunctionPolicy(t *testing.T) {
+	policy, err := newFunctionPolicy([]string{"random", "shout"}, []string{"lookup*"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := &App{policy: policy}
+	data := a.createData(context.Background(), "{{lookupUser 1}}\n{{randInt 1 6}} {{shout}}\n{{fetch}}", "", "shout", options{})
+	expected := []templateError{
+		{Line: 0, Char: 2, Level: parseErrorLevel, Description: `function "lookupUser" not defined`},
+		{Line: 2, Char: 2, Level: parseErrorLevel, Description: `function "fetch" not defined`},
+		{Line: 1, Char: 2, Level: parseErrorLevel, Description: `function "randInt" is forbidden by the server's policy`},
+		{Line: 1, Char: 18, Level: parseErrorLevel, Description: `function "shout" is forbidden by the server's policy`},
+		{Line: 2, Char: 2, Level: parseErrorLevel, Description: `function "fetch" isn't allowed to be mocked by the server's policy`},
+	}
+	if len(data.Errors) != len(expected) {
+		t.Fatalf("expected %d errors, actual %+v", len(expected), data.Errors)
+	}
+	for i := range expected {
+		assertError(t, expected[i], data.Errors[i])
+	}
+	if data.Output != "" {
+		t.Errorf("expected the template not to be executed, actual output %q", data.Output)
+	}
+}
+
+func TestFunctionPolicyAllowsEverything(t *testing.T) {
+	data := (&App{}).createData(context.Background(), "{{randInt 1 2}}", "", "", options{})
+	if len(data.Errors) != 0 || data.Output != "1" {
+		t.Errorf("unexpected output %q and errors %+v", data.Output, data.Errors)
+	}
+	if _, err := newFunctionPolicy(nil, []string{"["}); err == nil {
+		t.Error("expected a bad pattern to fail")
+	}
+}
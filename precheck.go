@@ -0,0 +1,168 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var (
+	unclosedBlockRegex = regexp.MustCompile(`^{{(\w+)}} has no matching {{end}}$`)
+	keywordRegex       = regexp.MustCompile(`^(\w+)\b`)
+)
+
+// opener is an action starting a block which needs an {{end}}
+type opener struct {
+	keyword string
+	offset  int
+}
+
+// precheck scans text, which is parsed as the template named name, for unbalanced delimiters and blocks,
+// unterminated strings and {{end}}s or {{else}}s without a block, without parsing it. The parser reports some of these
+// far from the mistake, unclosed blocks only as an "unexpected EOF" at the end, but the scanner knows where they start.
+// Like the lexer it stops at the first unclosed action, comment or string, and only checks that blocks are closed if
+// it gets to the end.
+func precheck(name, text string) []templateError {
+	text, _ = blankFrontMatter(text)
+	var tplErrs []templateError
+	errorAt := func(offset int, description string) {
+		tplErrs = append(tplErrs, templateError{File: name, Line: lineOf(text, offset), Char: columnOf(text, offset),
+			Description: description, Level: parseErrorLevel})
+	}
+
+	var open []opener
+	offset := 0
+	for {
+		start := strings.Index(text[offset:], "{{")
+		if start == -1 {
+			break
+		}
+		start += offset
+		inside := start + 2
+		if strings.HasPrefix(text[inside:], "- ") {
+			inside += 2
+		}
+
+		if strings.HasPrefix(text[inside:], "/*") {
+			end := strings.Index(text[inside+2:], "*/")
+			if end == -1 {
+				errorAt(start, "unclosed comment")
+				return tplErrs
+			}
+			offset = inside + 2 + end + 2
+			continue
+		}
+
+		end, problem, at := scanAction(text, inside)
+		if problem != "" {
+			if problem == "unclosed action" {
+				at = start
+			}
+			errorAt(at, problem)
+			return tplErrs
+		}
+		offset = end
+
+		body := strings.TrimSpace(strings.TrimSuffix(strings.TrimPrefix(text[start+2:end-2], "-"), "-"))
+		switch keyword := keywordRegex.FindString(body); keyword {
+		case "if", "range", "with", "define", "block":
+			open = append(open, opener{keyword: keyword, offset: start})
+		case "else":
+			if len(open) == 0 {
+				errorAt(start, "unexpected {{else}}")
+			}
+		case "end":
+			if len(open) == 0 {
+				errorAt(start, "unexpected {{end}}")
+			} else {
+				open = open[:len(open)-1]
+			}
+		}
+	}
+
+	for _, o := range open {
+		errorAt(o.offset, fmt.Sprintf("{{%s}} has no matching {{end}}", o.keyword))
+	}
+	return tplErrs
+}
+
+// scanAction scans the inside of an action from offset to the end of its closing delimiter. If it isn't closed, the
+// problem is returned with where it is.
+func scanAction(text string, offset int) (end int, problem string, at int) {
+	for i := offset; i < len(text); i++ {
+		switch text[i] {
+		case '"', '\'':
+			quote := text[i]
+			j := i + 1
+			for ; j < len(text) && text[j] != quote && text[j] != '\n'; j++ {
+				if text[j] == '\\' {
+					j++
+				}
+			}
+			if j >= len(text) || text[j] != quote {
+				if quote == '\'' {
+					return 0, "unterminated character constant", i
+				}
+				return 0, "unterminated quoted string", i
+			}
+			i = j
+		case '`':
+			j := strings.IndexByte(text[i+1:], '`')
+			if j == -1 {
+				return 0, "unterminated raw quoted string", i
+			}
+			i += j + 1
+		case '{':
+			if strings.HasPrefix(text[i:], "{{") {
+				return 0, "unclosed action", offset
+			}
+		case '}':
+			if strings.HasPrefix(text[i:], "}}") {
+				return i + 2, "", 0
+			}
+		}
+	}
+	return 0, "unclosed action", offset
+}
+
+// refineParseErrors replaces the errors of the parser which the prechecked errors locate better: "unexpected EOF"s when
+// a block isn't closed, and unclosed actions, comments, strings and unexpected {{end}}s or {{else}}s on the same line
+func refineParseErrors(parseTplErrs, prechecked []templateError) []templateError {
+	if len(prechecked) == 0 {
+		return parseTplErrs
+	}
+	unclosedBlocks := false
+	unclosed := make(map[string]bool)
+	for _, tplErr := range prechecked {
+		if unclosedBlockRegex.MatchString(tplErr.Description) {
+			unclosedBlocks = true
+		} else if matches := unclosedRegex.FindStringSubmatch(tplErr.Description); matches != nil {
+			unclosed[matches[1]] = true
+		}
+	}
+
+	result := make([]templateError, 0, len(parseTplErrs)+len(prechecked))
+	for _, tplErr := range parseTplErrs {
+		if tplErr.Description == "unexpected EOF" && unclosedBlocks {
+			continue
+		}
+		if matches := unclosedRegex.FindStringSubmatch(tplErr.Description); matches != nil && unclosed[matches[1]] {
+			continue
+		}
+		if locatedBy(tplErr, prechecked) {
+			continue
+		}
+		result = append(result, tplErr)
+	}
+	return sortErrors(append(result, prechecked...))
+}
+
+// locatedBy is whether one of prechecked is tplErr, on the same line, with a character
+func locatedBy(tplErr templateError, prechecked []templateError) bool {
+	for _, p := range prechecked {
+		if p.Line == tplErr.Line && p.Description == tplErr.Description {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"testing"
+)
+
+func TestPrecheck(t *testing.T) {
+	tests := []struct {
+		text     string
+		expected []templateError
+	}{
+		{"{{if .A}}\n{{range .B}}\n{{end}}\nx", []templateError{
+			{Line: 0, Char: 0, Description: "{{if}} has no matching {{end}}"},
+		}},
+		{"a\n  {{.A\nb\n{{.B}}", []templateError{{Line: 1, Char: 2, Description: "unclosed action"}}},
+		{"a\n{{printf \"x}}\nb {{.C}}", []templateError{{Line: 1, Char: 9, Description: "unterminated quoted string"}}},
+		{"{{ `a\nb}}", []templateError{{Line: 0, Char: 3, Description: "unterminated raw quoted string"}}},
+		{"a {{/* x\n\nb}}", []templateError{{Line: 0, Char: 2, Description: "unclosed comment"}}},
+		{"a\n{{- end -}}\n{{with .}}{{else}}{{end}} {{else}}", []templateError{
+			{Line: 1, Char: 0, Description: "unexpected {{end}}"},
+			{Line: 2, Char: 26, Description: "unexpected {{else}}"},
+		}},
+		{"{{define \"x\"}}{{if eq . \"}}{{end}}\"}}{{/* {{end}} */}}{{end}}{{end}}", nil},
+	}
+	for _, test := range tests {
+		actual := precheck("base", test.text)
+		if len(actual) != len(test.expected) {
+			t.Errorf("%q: expected %d errors, actual %+v", test.text, len(test.expected), actual)
+			continue
+		}
+		for i, tplErr := range test.expected {
+			tplErr.Level = parseErrorLevel
+			assertError(t, tplErr, actual[i])
+		}
+	}
+}
+
+func TestRefineParseErrors(t *testing.T) {
+	data := (&App{}).createData(context.Background(), "{{if .}}\n  {{with .}}\na\n{{end}}", "{}", "", options{})
+	if len(data.Errors) != 1 {
+		t.Fatalf("expected 1 error, actual %+v", data.Errors)
+	}
+	assertError(t, templateError{Line: 0, Char: 0, Level: parseErrorLevel, Description: "{{if}} has no matching {{end}}"},
+		data.Errors[0])
+	if data.Errors[0].Fix == nil || data.Errors[0].Fix.Replacement != "{{end}}" {
+		t.Errorf("expected a fix adding the {{end}}, actual %+v", data.Errors[0].Fix)
+	}
+
+	data = (&App{}).createData(context.Background(), "a\n{{printf \"x}}\nb", "{}", "", options{})
+	if len(data.Errors) != 1 {
+		t.Fatalf("expected 1 error, actual %+v", data.Errors)
+	}
+	assertError(t, templateError{Line: 1, Char: 9, Level: parseErrorLevel, Description: "unterminated quoted string"},
+		data.Errors[0])
+}
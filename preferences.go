@@ -0,0 +1,108 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+const (
+	// preferencesCookie is the cookie the preferences of a browser are remembered in
+	preferencesCookie = "preferences"
+	// preferencesMaxAge is how long preferences are remembered since they were last saved
+	preferencesMaxAge = 365 * 24 * time.Hour
+	// maxPreferencesSize is about the most a browser keeps of a cookie
+	maxPreferencesSize = 4000
+)
+
+// preferences are the settings a browser remembers, which the page starts with instead of the defaults
+type preferences struct {
+	Functions      string   `json:"functions,omitempty"`
+	FunctionPreset string   `json:"function_preset,omitempty"`
+	MissingKey     string   `json:"missingkey,omitempty"`
+	Profile        string   `json:"profile,omitempty"`
+	GoVersion      string   `json:"go_version,omitempty"`
+	LintConfig     string   `json:"lint,omitempty"`
+	Language       Language `json:"lang,omitempty"`
+	TabWidth       int      `json:"tab_width,omitempty"`
+	Strict         bool     `json:"strict,omitempty"`
+}
+
+// getPreferences returns the preferences remembered by the client of r, none if it has none or they're not understood
+func getPreferences(r *http.Request) preferences {
+	var prefs preferences
+	cookie, err := r.Cookie(preferencesCookie)
+	if err != nil {
+		return prefs
+	}
+	b, err := base64.RawURLEncoding.DecodeString(cookie.Value)
+	if err != nil || json.Unmarshal(b, &prefs) != nil {
+		return preferences{}
+	}
+	prefs.MissingKey = parseMissingKey(prefs.MissingKey)
+	prefs.Language = parseLanguage(string(prefs.Language))
+	return prefs
+}
+
+// applyPreferences returns opts and rawFns with the preferences of the client of r instead, where it has them. A lang
+// form value still chooses the language.
+func applyPreferences(r *http.Request, opts options, rawFns string) (options, string) {
+	prefs := getPreferences(r)
+	if prefs.Functions != "" && rawFns == "" {
+		rawFns = prefs.Functions
+	}
+	if prefs.FunctionPreset != "" {
+		opts.FunctionPreset = prefs.FunctionPreset
+	}
+	if prefs.MissingKey != "" {
+		opts.MissingKey = prefs.MissingKey
+	}
+	if prefs.Profile != "" {
+		opts.Profile = prefs.Profile
+	}
+	if prefs.GoVersion != "" {
+		opts.GoVersion = prefs.GoVersion
+	}
+	if prefs.LintConfig != "" {
+		opts.LintConfig = prefs.LintConfig
+	}
+	if prefs.Language != "" && r.FormValue("lang") == "" {
+		opts.Language = prefs.Language
+	}
+	if prefs.TabWidth > 0 {
+		opts.TabWidth = clampTabWidth(prefs.TabWidth)
+	}
+	opts.Strict = opts.Strict || prefs.Strict
+	return opts, rawFns
+}
+
+// SavePreferences remembers the settings of the page's form in a cookie, or forgets them with the forget form value,
+// and then validates the form like Post.
+func (a *App) SavePreferences(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseMultipartForm(maxRequestSize); err != nil && err != http.ErrNotMultipart {
+		http.Error(w, fmt.Sprintf("ParseMultipartForm error: %v", err), http.StatusBadRequest)
+		return
+	}
+	cookie := &http.Cookie{Name: preferencesCookie, Path: "/", MaxAge: -1, HttpOnly: true, SameSite: http.SameSiteLaxMode}
+	if r.FormValue("forget") == "" {
+		opts := getOptions(r)
+		prefs := preferences{Functions: r.FormValue("functions"), FunctionPreset: opts.FunctionPreset,
+			MissingKey: opts.MissingKey, Profile: opts.Profile, GoVersion: opts.GoVersion, LintConfig: opts.LintConfig,
+			Language: opts.Language, TabWidth: opts.TabWidth, Strict: opts.Strict}
+		b, err := json.Marshal(prefs)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("failed to save preferences: %v", err), http.StatusInternalServerError)
+			return
+		}
+		cookie.Value = base64.RawURLEncoding.EncodeToString(b)
+		if len(cookie.Value) > maxPreferencesSize {
+			http.Error(w, "the settings are too big to remember, like a long lint configuration", http.StatusBadRequest)
+			return
+		}
+		cookie.MaxAge = int(preferencesMaxAge / time.Second)
+	}
+	http.SetCookie(w, cookie)
+	a.Post(w, r)
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	htmlTemplate "html/template"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestPreferences(t *testing.T) {
+	a := &App{index: htmlTemplate.Must(htmlTemplate.New("index.html").Parse(
+		"{{.Output}}|{{.RawFunctions}}|{{.Options.MissingKey}}|{{.Options.Language}}|{{.Options.TabWidth}}|{{.Options.Strict}}"))}
+	post := func(form url.Values) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("POST", "/preferences", strings.NewReader(form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		a.SavePreferences(w, r)
+		return w
+	}
+	get := func(cookies []*http.Cookie, query string) string {
+		r := httptest.NewRequest("GET", "/"+query, nil)
+		for _, c := range cookies {
+			r.AddCookie(c)
+		}
+		w := httptest.NewRecorder()
+		a.Get(w, r)
+		return w.Body.String()
+	}
+
+	w := post(url.Values{"from-raw-text": {"Hi"}, "functions": {"lookup"}, "missingkey": {"error"}, "lang": {"zh"},
+		"tab-width": {"4"}, "strict": {"on"}})
+	cookies := w.Result().Cookies()
+	if !strings.HasPrefix(w.Body.String(), "Hi|lookup|error|zh|4|true") || len(cookies) != 1 || cookies[0].MaxAge <= 0 {
+		t.Fatalf("expected the form validated and its settings remembered: %s %v", w.Body, cookies)
+	}
+	if body := get(cookies, ""); !strings.HasSuffix(body, "|lookup|error|zh|4|true") {
+		t.Errorf("expected the page to start with the preferences: %s", body)
+	}
+	if body := get(cookies, "?lang=en"); !strings.HasSuffix(body, "|lookup|error|en|4|true") {
+		t.Errorf("expected the lang form value to choose the language: %s", body)
+	}
+	if body := get(nil, ""); !strings.HasSuffix(body, "||en|8|false") {
+		t.Errorf("unexpected page without preferences: %s", body)
+	}
+	if body := get([]*http.Cookie{{Name: preferencesCookie, Value: "!!"}}, ""); !strings.HasSuffix(body, "||en|8|false") {
+		t.Errorf("expected preferences which aren't understood to be ignored: %s", body)
+	}
+
+	w = post(url.Values{"from-raw-text": {"Hi"}, "forget": {"1"}})
+	if cookies := w.Result().Cookies(); len(cookies) != 1 || cookies[0].MaxAge >= 0 {
+		t.Errorf("expected the preferences to be forgotten: %v", cookies)
+	}
+	if w := post(url.Values{"lint": {strings.Repeat("x", maxPreferencesSize)}}); w.Code != http.StatusBadRequest {
+		t.Errorf("unexpected response: %d %s", w.Code, w.Body)
+	}
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// functionPreset is a named set of functions, like those of a library an application's FuncMap adds, which requests
+// can select instead of listing them
+type functionPreset struct {
+	Description string `json:"description"`
+	// Functions are the names of the functions, or a JSON object of their specifications like the functions option
+	Functions json.RawMessage `json:"functions"`
+}
+
+// sprigFunctions are the functions of Sprig, github.com/Masterminds/sprig, which aren't documented ones, like its time
+// and random functions the validator has
+var sprigFunctions = []string{
+	// strings
+	"trim", "trimAll", "trimPrefix", "trimSuffix", "upper", "lower", "title", "untitle", "repeat", "substr", "nospace",
+	"trunc", "abbrev", "abbrevboth", "initials", "randAscii", "wrap", "wrapWith", "contains", "hasPrefix", "hasSuffix",
+	"quote", "squote", "cat", "indent", "nindent", "replace", "plural", "snakecase", "camelcase", "kebabcase",
+	"swapcase", "shuffle", "regexMatch", "regexFind", "regexFindAll", "regexReplaceAll", "regexReplaceAllLiteral",
+	"regexSplit", "regexQuoteMeta", "toString", "toStrings", "split", "splitList", "splitn", "join", "sortAlpha",
+	// numbers
+	"atoi", "int", "int64", "float64", "toDecimal", "add", "add1", "sub", "div", "mod", "mul", "max", "min", "floor",
+	"ceil", "round", "addf", "add1f", "subf", "divf", "mulf", "maxf", "minf", "seq", "until", "untilStep",
+	// lists and dicts
+	"list", "first", "rest", "last", "initial", "append", "prepend", "concat", "reverse", "uniq", "without", "has",
+	"compact", "chunk", "dict", "get", "set", "unset", "hasKey", "pluck", "dig", "merge", "mergeOverwrite", "keys",
+	"pick", "omit", "values", "deepCopy",
+	// defaults and types
+	"default", "empty", "coalesce", "all", "any", "ternary", "fromJson", "toJson", "toPrettyJson", "toRawJson",
+	"typeOf", "typeIs", "kindOf", "kindIs", "deepEqual",
+	// encoding, crypto and the rest
+	"b64enc", "b64dec", "b32enc", "b32dec", "sha1sum", "sha256sum", "adler32sum", "htpasswd", "derivePassword",
+	"genPrivateKey", "encryptAES", "decryptAES", "uuidv4", "ago", "dateModify", "duration", "durationRound",
+	"htmlDate", "htmlDateInZone", "toDate", "base", "dir", "clean", "ext", "isAbs", "semver", "semverCompare",
+	"urlParse", "urlJoin", "getHostByName", "env", "expandenv", "fail",
+}
+
+// helmFunctions are the functions of Helm charts, Sprig's without those reading the environment and Helm's own
+var helmFunctions = append(without(sprigFunctions, "env", "expandenv"), "include", "tpl", "required", "toYaml",
+	"toYamlPretty", "fromYaml", "fromYamlArray", "fromJsonArray", "toToml", "fromToml", "lookup")
+
+// defaultFunctionPresets are the presets every server has, besides its own
+var defaultFunctionPresets = map[string]functionPreset{
+	"stdlib": presetOf("only the functions text/template predefines", nil),
+	"sprig":  presetOf("the functions of Sprig, like upper, default and toJson", sprigFunctions),
+	"helm":   presetOf("the functions of Helm charts, Sprig's with include, tpl, required, toYaml and lookup", helmFunctions),
+}
+
+func presetOf(description string, names []string) functionPreset {
+	b, _ := json.Marshal(append([]string{}, names...))
+	return functionPreset{Description: description, Functions: b}
+}
+
+func without(names []string, excluded ...string) []string {
+	var result []string
+	for _, name := range names {
+		if !containsString(excluded, name) {
+			result = append(result, name)
+		}
+	}
+	return result
+}
+
+func containsString(items []string, item string) bool {
+	for _, i := range items {
+		if i == item {
+			return true
+		}
+	}
+	return false
+}
+
+// rawFunctions returns the functions of p like the functions option, a comma separated list or a JSON object of
+// specifications. Documented functions are left out of lists, as the validator has them.
+func (p functionPreset) rawFunctions() (string, error) {
+	switch trimmed := strings.TrimSpace(string(p.Functions)); {
+	case strings.HasPrefix(trimmed, "["):
+		var names []string
+		if err := json.Unmarshal(p.Functions, &names); err != nil {
+			return "", err
+		}
+		return strings.Join(without(names, functionNames(documentedFunctions())...), ","), nil
+	case isFunctionSpecs(trimmed):
+		if _, err := parseFunctionSpecs(trimmed); err != nil {
+			return "", err
+		}
+		return trimmed, nil
+	}
+	return "", fmt.Errorf("functions must be an array of names or an object of specifications")
+}
+
+// functions returns the names of the functions of p, which aren't documented ones, with their specifications if p
+// has them
+func (p functionPreset) functions() ([]string, map[string]functionSpec) {
+	rawFns, _ := p.rawFunctions()
+	if isFunctionSpecs(rawFns) {
+		specs, _ := parseFunctionSpecs(rawFns)
+		return sortedSpecNames(specs), specs
+	}
+	return splitList(rawFns), nil
+}
+
+// presetFunctionDocs document the functions of the presets requests can select, by preset
+func (a *App) presetFunctionDocs() []functionDoc {
+	presets := a.functionPresetsOrDefault()
+	var docs []functionDoc
+	for _, preset := range presetNames(presets) {
+		names, specs := presets[preset].functions()
+		for _, name := range names {
+			signature := name + " args..."
+			if spec, ok := specs[name]; ok {
+				signature = strings.TrimSpace(name + " " + strings.Join(spec.Args, " "))
+			}
+			docs = append(docs, functionDoc{Name: name, Signature: signature,
+				Description: fmt.Sprintf("Mocked by the %s preset, %s.", preset, presets[preset].Description),
+				Preset:      preset})
+		}
+	}
+	return docs
+}
+
+// loadFunctionPresets returns the default presets with those of the JSON object of names to presets in the file at
+// path, if there's one, like {"company-internal": {"description": "...", "functions": ["lookupUser"]}}
+func loadFunctionPresets(path string) (map[string]functionPreset, error) {
+	presets := make(map[string]functionPreset, len(defaultFunctionPresets))
+	for name, p := range defaultFunctionPresets {
+		presets[name] = p
+	}
+	if path == "" {
+		return presets, nil
+	}
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var custom map[string]functionPreset
+	if err := json.Unmarshal(b, &custom); err != nil {
+		return nil, fmt.Errorf("function presets %s: %v", path, err)
+	}
+	for name, p := range custom {
+		if _, err := p.rawFunctions(); err != nil {
+			return nil, fmt.Errorf("function presets %s: %s: %v", path, name, err)
+		}
+		presets[name] = p
+	}
+	return presets, nil
+}
+
+// presetNames returns the names of presets, sorted
+func presetNames(presets map[string]functionPreset) []string {
+	names := make([]string, 0, len(presets))
+	for name := range presets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// presetFunctions returns the functions of the preset name like the functions option, none if name is empty
+func (a *App) presetFunctions(name string) (string, error) {
+	if name == "" {
+		return "", nil
+	}
+	presets := a.functionPresetsOrDefault()
+	p, ok := presets[name]
+	if !ok {
+		return "", fmt.Errorf("%q isn't one of %v", name, presetNames(presets))
+	}
+	return p.rawFunctions()
+}
+
+// functionPresetsOrDefault returns the function presets requests can select
+func (a *App) functionPresetsOrDefault() map[string]functionPreset {
+	if a.functionPresets == nil {
+		return defaultFunctionPresets
+	}
+	return a.functionPresets
+}
+
+// presetSummary describes a preset requests can select
+type presetSummary struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	// Functions are the names of its functions
+	Functions []string `json:"functions"`
+}
+
+// FunctionPresets serves the presets of functions requests can select, with the names of their functions, as JSON
+func (a *App) FunctionPresets(w http.ResponseWriter, r *http.Request) {
+	presets := a.functionPresetsOrDefault()
+	summaries := make([]presetSummary, 0, len(presets))
+	for _, name := range presetNames(presets) {
+		names, _ := presets[name].functions()
+		summary := presetSummary{Name: name, Description: presets[name].Description,
+			Functions: append(make([]string, 0), names...)}
+		summaries = append(summaries, summary)
+	}
+	writeJSON(w, summaries)
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPresetRawFunctions(t *testing.T) {
+	rawFns, err := presetOf("", []string{"upper", "now", "lookupUser"}).rawFunctions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rawFns != "upper,lookupUser" {
+		t.Errorf("expected documented functions to be left out, actual %q", rawFns)
+	}
+
+	specs := functionPreset{Functions: json.RawMessage(`{"lookupUser": {"returns": "string"}}`)}
+	if rawFns, err := specs.rawFunctions(); err != nil || rawFns != `{"lookupUser": {"returns": "string"}}` {
+		t.Errorf("unexpected functions %q, error %v", rawFns, err)
+	}
+
+	if _, err := (functionPreset{Functions: json.RawMessage(`"upper"`)}).rawFunctions(); err == nil {
+		t.Error("expected an error for functions which are neither names nor specifications")
+	}
+}
+
+func TestLoadFunctionPresets(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "presets.json")
+	ioutil.WriteFile(path, []byte(`{"company-internal": {"description": "our FuncMap", "functions": ["lookupUser"]}}`),
+		0600)
+	presets, err := loadFunctionPresets(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if names := strings.Join(presetNames(presets), ","); names != "company-internal,helm,sprig,stdlib" {
+		t.Errorf("unexpected presets %s", names)
+	}
+
+	ioutil.WriteFile(path, []byte(`{"typo": {"functions": {"lookupUser": {"args": ["strnig"]}}}}`), 0600)
+	if _, err := loadFunctionPresets(path); err == nil || !strings.Contains(err.Error(), `typo: unknown type "strnig"`) {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+func TestCreateDataFunctionPreset(t *testing.T) {
+	data := (&App{}).createData(context.Background(), `{{upper "a"}}{{include "b" .}}`, "", "", options{})
+	if len(data.Errors) == 0 {
+		t.Error("expected an error for functions which aren't mocked")
+	}
+
+	data = (&App{}).createData(context.Background(), `{{upper "a"}}{{include "b" .}}`, "", "",
+		options{FunctionPreset: "helm"})
+	if len(data.Errors) != 0 {
+		t.Errorf("unexpected errors %+v", data.Errors)
+	}
+
+	data = (&App{}).createData(context.Background(), `{{include "b" .}}`, "", "", options{FunctionPreset: "sprig"})
+	if len(data.Errors) != 1 || !strings.Contains(data.Errors[0].Description, `function "include" not defined`) {
+		t.Errorf("unexpected errors %+v", data.Errors)
+	}
+
+	data = (&App{}).createData(context.Background(), "{{.}}", "", "", options{FunctionPreset: "jinja"})
+	expected := `failed to understand the function preset: "jinja" isn't one of [helm sprig stdlib]`
+	if len(data.Errors) != 1 || data.Errors[0].Description != expected {
+		t.Errorf("unexpected errors %+v", data.Errors)
+	}
+}
+
+func TestFunctionPresets(t *testing.T) {
+	a := &App{functionPresets: map[string]functionPreset{
+		"company-internal": presetOf("our FuncMap", []string{"lookupUser", "upper"}),
+		"stdlib":           defaultFunctionPresets["stdlib"],
+	}}
+	w := httptest.NewRecorder()
+	a.FunctionPresets(w, httptest.NewRequest("GET", "/api/v1/function-presets", nil))
+	expected := `[{"name":"company-internal","description":"our FuncMap","functions":["lookupUser","upper"]},` +
+		`{"name":"stdlib","description":"only the functions text/template predefines","functions":[]}]`
+	if actual := strings.TrimSpace(w.Body.String()); actual != expected {
+		t.Errorf("expected %s, actual %s", expected, actual)
+	}
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"text/template"
+	templateParse "text/template/parse"
+)
+
+const (
+	// previewFunction is added to the end of the pipeline of every range of a previewed template, to cut what it
+	// iterates over short
+	previewFunction = "_preview"
+	// elidedFunction is called after every range of a previewed template, it outputs how many iterations were cut
+	elidedFunction = "_elided"
+)
+
+// previewer cuts the ranges of a template short, for data too big to wait for
+type previewer struct {
+	iterations int
+	// elided are how many iterations the last execution of each range skipped, by the argument of its calls
+	elided []int
+}
+
+// previewTemplate returns a copy of t whose ranges only iterate over the first iterations elements, or keys of maps,
+// and then output how many more they'd have iterated over
+func previewTemplate(t *template.Template, iterations int) (*template.Template, error) {
+	t, err := copyTemplate(t)
+	if err != nil {
+		return nil, err
+	}
+	p := &previewer{iterations: iterations}
+	for _, tree := range trees(t) {
+		p.instrument(tree, tree.Root)
+	}
+	return t.Funcs(template.FuncMap{previewFunction: p.preview, elidedFunction: p.elidedNote}), nil
+}
+
+// instrument cuts the ranges in list and its descendants short
+func (p *previewer) instrument(tree *templateParse.Tree, list *templateParse.ListNode) {
+	if list == nil {
+		return
+	}
+	nodes := make([]templateParse.Node, 0, len(list.Nodes))
+	for _, node := range list.Nodes {
+		nodes = append(nodes, node)
+		switch n := node.(type) {
+		case *templateParse.IfNode:
+			p.instrument(tree, n.List)
+			p.instrument(tree, n.ElseList)
+		case *templateParse.WithNode:
+			p.instrument(tree, n.List)
+			p.instrument(tree, n.ElseList)
+		case *templateParse.RangeNode:
+			p.instrument(tree, n.List)
+			p.instrument(tree, n.ElseList)
+			arg := strconv.Itoa(len(p.elided))
+			p.elided = append(p.elided, 0)
+			// the range's pipeline is piped into previewFunction, which is where its errors are
+			preview := callAt(tree, n, previewFunction, p.preview, arg).(*templateParse.ActionNode)
+			n.Pipe.Cmds = append(n.Pipe.Cmds, preview.Pipe.Cmds...)
+			nodes = append(nodes, callAt(tree, n, elidedFunction, p.elidedNote, arg))
+		}
+	}
+	list.Nodes = nodes
+}
+
+// preview is previewFunction, it returns the first iterations elements of v if it's a slice, array or map with string
+// or integer keys, and v itself otherwise
+func (p *previewer) preview(arg string, v interface{}) (interface{}, error) {
+	i, err := strconv.Atoi(arg)
+	if err != nil || i < 0 || i >= len(p.elided) {
+		return nil, fmt.Errorf("unknown range %q", arg)
+	}
+	p.elided[i] = 0
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		if rv.Len() <= p.iterations {
+			return v, nil
+		}
+		p.elided[i] = rv.Len() - p.iterations
+		kept := reflect.MakeSlice(reflect.SliceOf(rv.Type().Elem()), p.iterations, p.iterations)
+		reflect.Copy(kept, rv.Slice3(0, p.iterations, p.iterations))
+		return kept.Interface(), nil
+	case reflect.Map:
+		if rv.Len() <= p.iterations {
+			return v, nil
+		}
+		keys, ok := sortedMapKeys(rv)
+		if !ok {
+			return v, nil
+		}
+		p.elided[i] = rv.Len() - p.iterations
+		kept := reflect.MakeMapWithSize(rv.Type(), p.iterations)
+		// ranges iterate over maps in the order of their keys, so the first are kept
+		for _, key := range keys[:p.iterations] {
+			kept.SetMapIndex(key, rv.MapIndex(key))
+		}
+		return kept.Interface(), nil
+	}
+	return v, nil
+}
+
+// sortedMapKeys returns the keys of the map m in the order ranges iterate over them, if they're strings or integers
+func sortedMapKeys(m reflect.Value) ([]reflect.Value, bool) {
+	keys := m.MapKeys()
+	switch m.Type().Key().Kind() {
+	case reflect.String:
+		sort.Slice(keys, func(i, j int) bool { return keys[i].String() < keys[j].String() })
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		sort.Slice(keys, func(i, j int) bool { return keys[i].Int() < keys[j].Int() })
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		sort.Slice(keys, func(i, j int) bool { return keys[i].Uint() < keys[j].Uint() })
+	default:
+		return nil, false
+	}
+	return keys, true
+}
+
+// elidedNote is elidedFunction, it outputs how many iterations the range skipped, if it skipped any
+func (p *previewer) elidedNote(arg string) (string, error) {
+	i, err := strconv.Atoi(arg)
+	if err != nil || i < 0 || i >= len(p.elided) {
+		return "", fmt.Errorf("unknown range %q", arg)
+	}
+	if p.elided[i] == 0 {
+		return "", nil
+	}
+	iterations := "iterations"
+	if p.elided[i] == 1 {
+		iterations = "iteration"
+	}
+	return fmt.Sprintf("… %s more %s elided", thousands(p.elided[i]), iterations), nil
+}
+
+// thousands formats n with commas between its thousands, like 9,990
+func thousands(n int) string {
+	s := strconv.Itoa(n)
+	for i := len(s) - 3; i > 0; i -= 3 {
+		s = s[:i] + "," + s[i:]
+	}
+	return s
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"testing"
+)
+
+func TestCreateDataPreview(t *testing.T) {
+	tests := []struct {
+		text, data string
+		opts       options
+		expected   string
+	}{
+		{"{{range .}}{{.}},{{end}}", `[1, 2, 3, 4, 5]`, options{PreviewIterations: 2}, "1,2,… 3 more iterations elided"},
+		{"{{range $k, $v := .}}{{$k}}{{$v}}{{end}}", `{"c": 1, "a": 2, "b": 3}`, options{PreviewIterations: 2},
+			"a2b3… 1 more iteration elided"},
+		{"{{range .}}{{range .}}{{.}}{{end}};{{end}}", `[[1, 2, 3], [4]]`, options{PreviewIterations: 2},
+			"12… 1 more iteration elided;4;"},
+		{"{{range .}}{{.}}{{end}}", `[1, 2, 3]`, options{PreviewIterations: 3}, "123"},
+		{"{{range .}}{{.}}{{end}}", `[1, 2, 3]`, options{PreviewIterations: 1, FullExecution: true}, "123"},
+	}
+	for _, test := range tests {
+		data := (&App{}).createData(context.Background(), test.text, test.data, "", test.opts)
+		if len(data.Errors) > 0 || data.Output != test.expected {
+			t.Errorf("%s: expected %q, actual %q, errors %+v", test.text, test.expected, data.Output, data.Errors)
+		}
+	}
+
+	a := &App{previewIterations: 1}
+	if data := a.createData(context.Background(), "{{range .}}{{.}}{{end}}", `[1, 2]`, "", options{}); data.Output != "1… 1 more iteration elided" {
+		t.Errorf("unexpected output %q", data.Output)
+	}
+	// the logic is still validated
+	data := a.createData(context.Background(), "{{range .}}{{.X}}{{end}}", `[{"X": 1}, 2]`, "", options{PreviewIterations: 1})
+	if len(data.Errors) != 0 {
+		t.Errorf("unexpected errors %+v", data.Errors)
+	}
+	data = a.createData(context.Background(), "{{range .}}{{.X}}{{end}}", `[2]`, "", options{PreviewIterations: 1})
+	if len(data.Errors) != 1 || data.Errors[0].Level != execErrorLevel {
+		t.Errorf("unexpected errors %+v", data.Errors)
+	}
+
+	if actual := thousands(9990); actual != "9,990" {
+		t.Errorf("unexpected %s", actual)
+	}
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+	templateParse "text/template/parse"
+)
+
+// printfVerbTypes are the verbs accepting each type of constant that can be written in a template
+var printfVerbTypes = map[string]string{
+	"int":    "bcdoOqxXUv",
+	"float":  "beEfFgGxXv",
+	"string": "sqxXv",
+	"bool":   "tv",
+	"nil":    "v",
+}
+
+// printfVerbs are all the verbs fmt understands
+const printfVerbs = "bcdoOqxXUeEfFgGstvTp"
+
+// directive is a formatting directive in a printf format
+type directive struct {
+	text string
+	verb rune
+	// stars is the number of args read for the width and precision
+	stars int
+}
+
+// parseFormat returns the directives of a printf format, or false if they use explicit argument indexes and the args they
+// read can't be counted
+func parseFormat(format string) ([]directive, bool) {
+	var directives []directive
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' {
+			continue
+		}
+		start := i
+		d := directive{}
+		for i++; i < len(format); i++ {
+			c := format[i]
+			if c == '[' {
+				return nil, false
+			}
+			if c == '*' {
+				d.stars++
+				continue
+			}
+			if strings.IndexByte("+-# 0.123456789", c) == -1 {
+				break
+			}
+		}
+		if i == len(format) {
+			d.text = format[start:]
+			directives = append(directives, d)
+			break
+		}
+		d.verb = rune(format[i])
+		d.text = format[start : i+1]
+		if d.verb != '%' {
+			directives = append(directives, d)
+		}
+	}
+	return directives, true
+}
+
+// constantType returns the type of a constant argument, or an empty string if it isn't known before executing
+func constantType(node templateParse.Node) string {
+	switch n := node.(type) {
+	case *templateParse.StringNode:
+		return "string"
+	case *templateParse.BoolNode:
+		return "bool"
+	case *templateParse.NilNode:
+		return "nil"
+	case *templateParse.NumberNode:
+		if n.IsInt {
+			return "int"
+		}
+		if n.IsFloat {
+			return "float"
+		}
+	}
+	return ""
+}
+
+// lintPrintf checks the format of printf calls against their arguments, the way go vet does
+func lintPrintf(t *template.Template, _ []source, _ lintConfig) []templateError {
+	var tplErrs []templateError
+	for _, tree := range trees(t) {
+		walk(tree.Root, func(node templateParse.Node, ancestors []templateParse.Node) bool {
+			cmd, ok := node.(*templateParse.CommandNode)
+			if !ok || len(cmd.Args) < 2 {
+				return true
+			}
+			ident, ok := cmd.Args[0].(*templateParse.IdentifierNode)
+			if !ok || ident.Ident != "printf" {
+				return true
+			}
+			format, ok := cmd.Args[1].(*templateParse.StringNode)
+			if !ok {
+				return true
+			}
+			args := append([]templateParse.Node{}, cmd.Args[2:]...)
+			// later stages of a pipeline get the previous result as their last argument
+			if pipe, ok := ancestors[len(ancestors)-1].(*templateParse.PipeNode); ok && pipe.Cmds[0] != cmd {
+				args = append(args, nil)
+			}
+
+			if description := checkPrintf(format.Text, args); description != "" {
+				tplErrs = append(tplErrs, nodeError(tree, ident, description))
+			}
+			return true
+		})
+	}
+	return tplErrs
+}
+
+// checkPrintf describes the first problem with calling printf with format and args, where nil args are ones whose type
+// isn't known
+func checkPrintf(format string, args []templateParse.Node) string {
+	directives, ok := parseFormat(format)
+	if !ok {
+		return ""
+	}
+	next := 0
+	for _, d := range directives {
+		if d.verb == 0 {
+			return fmt.Sprintf("printf format %s is missing verb at end of string", d.text)
+		}
+		if !strings.ContainsRune(printfVerbs, d.verb) {
+			return fmt.Sprintf("printf format %s has unknown verb %c", d.text, d.verb)
+		}
+		reads := d.stars + 1
+		if next+reads > len(args) {
+			return fmt.Sprintf("printf format %s reads arg #%d, but call has %s", d.text, next+reads, countArgs(len(args)))
+		}
+		for _, star := range args[next : next+d.stars] {
+			if typ := constantType(star); typ != "" && typ != "int" {
+				return fmt.Sprintf("printf format %s uses non-int %s as argument of *", d.text, star)
+			}
+		}
+		arg := args[next+d.stars]
+		if typ := constantType(arg); typ != "" && d.verb != 'T' && !strings.ContainsRune(printfVerbTypes[typ], d.verb) {
+			return fmt.Sprintf("printf format %s has arg %s of wrong type %s", d.text, arg, typ)
+		}
+		next += reads
+	}
+	if next < len(args) {
+		if len(directives) == 0 {
+			return "printf call has arguments but no formatting directives"
+		}
+		return fmt.Sprintf("printf call needs %s but has %s", countArgs(next), countArgs(len(args)))
+	}
+	return ""
+}
+
+// countArgs describes a number of arguments
+func countArgs(n int) string {
+	if n == 1 {
+		return "1 arg"
+	}
+	return fmt.Sprintf("%d args", n)
+}
@@ -0,0 +1,49 @@
+package main
+
+import "testing"
+
+func TestLintPrintf(t *testing.T) {
+	errs := lintText(t, "{{printf \"%s: %d\" .Name}}\n{{.Count | printf \"%s: %d\" .Name}}")
+	if len(errs) != 1 {
+		t.Fatalf("unexpected errors found: %v", errs)
+	}
+	assertError(t, templateError{
+		Line:        0,
+		Char:        2,
+		Description: "printf format %d reads arg #2, but call has 1 arg",
+		Level:       lintErrorLevel,
+	}, errs[0])
+	if errs[0].Rule != "printf" {
+		t.Errorf("unexpected rule: %s", errs[0].Rule)
+	}
+}
+
+func TestCheckPrintf(t *testing.T) {
+	tests := []struct {
+		text     string
+		expected string
+	}{
+		{`{{printf "%d%%" .A}}`, ""},
+		{`{{printf "%*.*f" 3 2 .A}}`, ""},
+		{`{{printf "%[2]s %[1]s" .A}}`, ""},
+		{`{{printf "%T" "a"}}`, ""},
+		{`{{printf "%d" "a"}}`, `printf format %d has arg "a" of wrong type string`},
+		{`{{printf "%s" 1}}`, "printf format %s has arg 1 of wrong type int"},
+		{`{{printf "%d" 1.5}}`, "printf format %d has arg 1.5 of wrong type float"},
+		{`{{printf "%*d" "a" 1}}`, `printf format %*d uses non-int "a" as argument of *`},
+		{`{{printf "%y" .A}}`, "printf format %y has unknown verb y"},
+		{`{{printf "a %" .A}}`, "printf format % is missing verb at end of string"},
+		{`{{printf "%s" .A .B}}`, "printf call needs 1 arg but has 2 args"},
+		{`{{printf "hello" .A}}`, "printf call has arguments but no formatting directives"},
+	}
+	for _, test := range tests {
+		errs := lintPrintf(mustParse(t, test.text), nil, lintConfig{})
+		actual := ""
+		if len(errs) > 0 {
+			actual = errs[0].Description
+		}
+		if actual != test.expected {
+			t.Errorf("%s: expected `%s`, actual `%s`", test.text, test.expected, actual)
+		}
+	}
+}
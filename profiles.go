@@ -0,0 +1,150 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sort"
+)
+
+// profile is a named bundle of the options deciding how strict a validation is
+type profile struct {
+	// MissingKey is the missingkey option, empty for the default
+	MissingKey string `json:"missingkey"`
+	// Strict reports unknown functions instead of mocking them
+	Strict bool `json:"strict"`
+	// FunctionPreset is the preset of functions, unless the request selects one
+	FunctionPreset string `json:"functionPreset"`
+	// GoVersion is the version of Go to validate like, unless the request selects one
+	GoVersion string `json:"goVersion"`
+	// AllExecErrors keeps executing after runtime errors
+	AllExecErrors bool `json:"allExecErrors"`
+	// Lint is a lint config, like the lint option, which the request's rules override
+	Lint json.RawMessage `json:"lint"`
+	// Assertions are assertions the output must pass, besides the request's
+	Assertions json.RawMessage `json:"assertions"`
+}
+
+// defaultProfiles are the profiles every server has, unless its own profiles replace them
+var defaultProfiles = map[string]profile{
+	"exploratory": {AllExecErrors: true, Lint: json.RawMessage(`{"unused-variable": "info", "unused-define": "info",
+		"duplicate-define": "info", "shadowed-variable": "info", "dot-rebinding": "info", "printf": "info",
+		"mocked-arity": "info", "other-delimiters": "info"}`)},
+	"standard": {},
+	"ci-strict": {MissingKey: "error", Strict: true, Lint: json.RawMessage(`{"unused-variable": "error",
+		"unused-define": "error", "duplicate-define": "error", "shadowed-variable": "error", "dot-rebinding": "error",
+		"printf": "error", "mocked-arity": "error", "other-delimiters": "error"}`)},
+}
+
+// loadProfiles returns the default profiles with those of the JSON object of names to profiles in the file at path,
+// if there's one
+func loadProfiles(path string) (map[string]profile, error) {
+	profiles := make(map[string]profile, len(defaultProfiles))
+	for name, p := range defaultProfiles {
+		profiles[name] = p
+	}
+	if path == "" {
+		return profiles, nil
+	}
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var custom map[string]profile
+	if err := json.Unmarshal(b, &custom); err != nil {
+		return nil, fmt.Errorf("profiles %s: %v", path, err)
+	}
+	for name, p := range custom {
+		if p.MissingKey != "" && parseMissingKey(p.MissingKey) == "" {
+			return nil, fmt.Errorf("profiles %s: unknown missingkey %q of %s", path, p.MissingKey, name)
+		}
+		opts, err := p.apply(options{})
+		if err == nil && opts.LintConfig != "" {
+			_, err = parseLintConfig(opts.LintConfig)
+		}
+		if err == nil && opts.Assertions != "" {
+			_, err = parseAssertions(opts.Assertions)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("profiles %s: %s: %v", path, name, err)
+		}
+		profiles[name] = p
+	}
+	return profiles, nil
+}
+
+// profileNames returns the names of profiles, sorted
+func profileNames(profiles map[string]profile) []string {
+	names := make([]string, 0, len(profiles))
+	for name := range profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// apply returns opts with the profile's options where opts doesn't set them. The lint rules and assertions of both
+// apply.
+func (p profile) apply(opts options) (options, error) {
+	if opts.MissingKey == "" {
+		opts.MissingKey = p.MissingKey
+	}
+	opts.Strict = opts.Strict || p.Strict
+	if opts.FunctionPreset == "" {
+		opts.FunctionPreset = p.FunctionPreset
+	}
+	if opts.GoVersion == "" {
+		opts.GoVersion = p.GoVersion
+	}
+	opts.AllExecErrors = opts.AllExecErrors || p.AllExecErrors
+
+	if len(p.Lint) > 0 {
+		rules := make(map[string]json.RawMessage)
+		if err := json.Unmarshal(p.Lint, &rules); err != nil {
+			return opts, fmt.Errorf("lint: %v", err)
+		}
+		var requested map[string]json.RawMessage
+		// a bad lint config of the request is left for createData to report
+		if opts.LintConfig == "" || json.Unmarshal([]byte(opts.LintConfig), &requested) == nil {
+			for name, rule := range requested {
+				rules[name] = rule
+			}
+			b, _ := json.Marshal(rules)
+			opts.LintConfig = string(b)
+		}
+	}
+
+	if len(p.Assertions) > 0 {
+		var assertions []json.RawMessage
+		if err := json.Unmarshal(p.Assertions, &assertions); err != nil {
+			return opts, fmt.Errorf("assertions: %v", err)
+		}
+		var requested []json.RawMessage
+		if opts.Assertions == "" || json.Unmarshal([]byte(opts.Assertions), &requested) == nil {
+			b, _ := json.Marshal(append(assertions, requested...))
+			opts.Assertions = string(b)
+		}
+	}
+	return opts, nil
+}
+
+// withProfile returns opts with the options of its profile applied
+func (a *App) withProfile(opts options) (options, error) {
+	if opts.Profile == "" {
+		return opts, nil
+	}
+	profiles := a.profilesOrDefault()
+	p, ok := profiles[opts.Profile]
+	if !ok {
+		return opts, fmt.Errorf("%q isn't one of %v", opts.Profile, profileNames(profiles))
+	}
+	return p.apply(opts)
+}
+
+// profilesOrDefault returns the profiles requests can select
+func (a *App) profilesOrDefault() map[string]profile {
+	if a.profiles == nil {
+		return defaultProfiles
+	}
+	return a.profiles
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestProfileApply(t *testing.T) {
+	p := profile{MissingKey: "error", Strict: true, Lint: []byte(`{"printf": "error", "unused-variable": "error"}`),
+		Assertions: []byte(`[{"contains": "a"}]`)}
+	opts, err := p.apply(options{MissingKey: "zero", LintConfig: `{"printf": "off"}`, Assertions: `[{"contains": "b"}]`})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opts.MissingKey != "zero" || !opts.Strict {
+		t.Errorf("unexpected options %+v", opts)
+	}
+	if opts.LintConfig != `{"printf":"off","unused-variable":"error"}` {
+		t.Errorf("unexpected lint config %s", opts.LintConfig)
+	}
+	if opts.Assertions != `[{"contains":"a"},{"contains":"b"}]` {
+		t.Errorf("unexpected assertions %s", opts.Assertions)
+	}
+}
+
+func TestCreateDataProfile(t *testing.T) {
+	data := (&App{}).createData(context.Background(), "{{.Missing}}", "{}", "", options{Profile: "ci-strict"})
+	if len(data.Errors) != 1 || !strings.Contains(data.Errors[0].Description, `map has no entry for key "Missing"`) {
+		t.Errorf("unexpected errors %+v", data.Errors)
+	}
+	if data.Options.MissingKey != "" {
+		t.Errorf("expected the form to keep the options asked for, actual %+v", data.Options)
+	}
+
+	data = (&App{}).createData(context.Background(), "{{.}}", "", "", options{Profile: "lenient"})
+	expected := `failed to understand the profile: "lenient" isn't one of [ci-strict exploratory standard]`
+	if len(data.Errors) != 1 || data.Errors[0].Description != expected {
+		t.Errorf("unexpected errors %+v", data.Errors)
+	}
+}
+
+func TestLoadProfiles(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "profiles.json")
+	ioutil.WriteFile(path, []byte(`{"release": {"strict": true}, "standard": {"missingkey": "zero"}}`), 0600)
+	profiles, err := loadProfiles(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(profiles) != 4 || !profiles["release"].Strict || profiles["standard"].MissingKey != "zero" {
+		t.Errorf("unexpected profiles %+v", profiles)
+	}
+
+	ioutil.WriteFile(path, []byte(`{"typo": {"lint": {"prinf": "error"}}}`), 0600)
+	if _, err := loadProfiles(path); err == nil || !strings.Contains(err.Error(), `typo: unknown rule "prinf"`) {
+		t.Errorf("unexpected error %v", err)
+	}
+}
@@ -0,0 +1,511 @@
+package main
+
+import (
+	encodingBinary "encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// the types of fields in descriptors
+const (
+	protoDouble   = 1
+	protoFloat    = 2
+	protoInt64    = 3
+	protoUint64   = 4
+	protoInt32    = 5
+	protoFixed64  = 6
+	protoFixed32  = 7
+	protoBool     = 8
+	protoString   = 9
+	protoGroup    = 10
+	protoMessage  = 11
+	protoBytes    = 12
+	protoUint32   = 13
+	protoEnum     = 14
+	protoSfixed32 = 15
+	protoSfixed64 = 16
+	protoSint32   = 17
+	protoSint64   = 18
+)
+
+// the wire types of encoded fields
+const (
+	wireVarint = 0
+	wireI64    = 1
+	wireLen    = 2
+	wireI32    = 5
+)
+
+// protoSchema are the messages and enums of a descriptor set or .proto file, by their full names like .pkg.Message
+type protoSchema struct {
+	messages map[string]*protoMessageType
+	enums    map[string]map[int32]string
+}
+
+type protoMessageType struct {
+	name   string
+	fields []protoField
+	// mapEntry is whether the message is the entry of a map field, with a key and a value field
+	mapEntry bool
+}
+
+type protoField struct {
+	name     string
+	number   int
+	kind     int
+	typeName string
+	repeated bool
+	// oneof is whether the field is one of a oneof, or optional, which have no value when they aren't set
+	oneof bool
+}
+
+func newProtoSchema() *protoSchema {
+	return &protoSchema{messages: make(map[string]*protoMessageType), enums: make(map[string]map[int32]string)}
+}
+
+// protobufJSON converts the protobuf encoded message data, of the type named message in the FileDescriptorSet or
+// .proto file schema, to JSON data. Its fields are named like those of the Go structs generated for it, and all of them
+// are there like in the structs, so templates executed with those validate with it.
+func protobufJSON(schema []byte, isProtoFile bool, message string, data []byte) (string, error) {
+	var s *protoSchema
+	var err error
+	if isProtoFile {
+		s, err = parseProtoFile(string(schema))
+	} else {
+		s, err = parseDescriptorSet(schema)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to understand the schema: %v", err)
+	}
+	m, err := s.lookupMessage(message)
+	if err != nil {
+		return "", err
+	}
+	value, err := s.decode(m, data)
+	if err != nil {
+		return "", fmt.Errorf("failed to decode %s: %v", strings.TrimPrefix(m.name, "."), err)
+	}
+	b, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// lookupMessage returns the message named name, with or without its package, or the only message of s if name is empty
+func (s *protoSchema) lookupMessage(name string) (*protoMessageType, error) {
+	var names []string
+	for full, m := range s.messages {
+		if !m.mapEntry {
+			names = append(names, full)
+		}
+	}
+	sort.Strings(names)
+	if name == "" {
+		if len(names) == 1 {
+			return s.messages[names[0]], nil
+		}
+		return nil, fmt.Errorf("the message type isn't named, it's one of %s", strings.Join(trimDots(names), ", "))
+	}
+	if m, ok := s.messages["."+strings.TrimPrefix(name, ".")]; ok {
+		return m, nil
+	}
+	var found []string
+	for _, full := range names {
+		if strings.HasSuffix(full, "."+name) {
+			found = append(found, full)
+		}
+	}
+	switch len(found) {
+	case 0:
+		return nil, fmt.Errorf("there's no message %s, only %s", name, strings.Join(trimDots(names), ", "))
+	case 1:
+		return s.messages[found[0]], nil
+	}
+	return nil, fmt.Errorf("message %s is ambiguous, it's one of %s", name, strings.Join(trimDots(found), ", "))
+}
+
+func trimDots(names []string) []string {
+	trimmed := make([]string, len(names))
+	for i, name := range names {
+		trimmed[i] = strings.TrimPrefix(name, ".")
+	}
+	return trimmed
+}
+
+// decode decodes the message b of type m into an object of its fields by their Go names
+func (s *protoSchema) decode(m *protoMessageType, b []byte) (map[string]interface{}, error) {
+	object := make(map[string]interface{}, len(m.fields))
+	byNumber := make(map[int]*protoField, len(m.fields))
+	for i := range m.fields {
+		f := &m.fields[i]
+		byNumber[f.number] = f
+		if !f.oneof {
+			object[goFieldName(f.name)] = s.zero(f)
+		}
+	}
+	err := protoFields(b, func(number, wireType int, v uint64, payload []byte) error {
+		f, ok := byNumber[number]
+		if !ok {
+			// unknown fields are skipped, like Go does
+			return nil
+		}
+		key := goFieldName(f.name)
+		if f.repeated && wireType == wireLen && packable(f.kind) {
+			return decodePacked(f.kind, payload, func(v uint64) {
+				object[key] = append(object[key].([]interface{}), s.scalar(f, v))
+			})
+		}
+		value, err := s.value(f, wireType, v, payload)
+		if err != nil {
+			return fmt.Errorf("field %s: %v", f.name, err)
+		}
+		switch {
+		case f.repeated && s.isMap(f):
+			entry := value.(map[string]interface{})
+			object[key].(map[string]interface{})[fmt.Sprint(entry["Key"])] = entry["Value"]
+		case f.repeated:
+			object[key] = append(object[key].([]interface{}), value)
+		default:
+			object[key] = value
+		}
+		return nil
+	})
+	return object, err
+}
+
+// value decodes one value of the field f
+func (s *protoSchema) value(f *protoField, wireType int, v uint64, payload []byte) (interface{}, error) {
+	want := wireVarint
+	switch f.kind {
+	case protoDouble, protoFixed64, protoSfixed64:
+		want = wireI64
+	case protoFloat, protoFixed32, protoSfixed32:
+		want = wireI32
+	case protoString, protoBytes, protoMessage:
+		want = wireLen
+	case protoGroup:
+		return nil, errors.New("groups aren't supported")
+	}
+	if wireType != want {
+		return nil, fmt.Errorf("wire type %d doesn't match the field", wireType)
+	}
+	switch f.kind {
+	case protoString:
+		return string(payload), nil
+	case protoBytes:
+		// like []byte, it's base64 in JSON
+		return append([]byte{}, payload...), nil
+	case protoMessage:
+		m, ok := s.messages[f.typeName]
+		if !ok {
+			return nil, fmt.Errorf("there's no message %s", strings.TrimPrefix(f.typeName, "."))
+		}
+		return s.decode(m, payload)
+	}
+	return s.scalar(f, v), nil
+}
+
+// scalar converts the varint or fixed size value v of the field f
+func (s *protoSchema) scalar(f *protoField, v uint64) interface{} {
+	switch f.kind {
+	case protoDouble:
+		return finite(math.Float64frombits(v))
+	case protoFloat:
+		return finite(float64(math.Float32frombits(uint32(v))))
+	case protoInt64, protoSfixed64:
+		return int64(v)
+	case protoUint64, protoFixed64:
+		return v
+	case protoInt32, protoSfixed32:
+		return int32(v)
+	case protoUint32, protoFixed32:
+		return uint32(v)
+	case protoSint32:
+		return int32(uint32(v>>1) ^ -uint32(v&1))
+	case protoSint64:
+		return int64(v>>1) ^ -int64(v&1)
+	case protoBool:
+		return v != 0
+	case protoEnum:
+		// enums print their names in Go
+		if name, ok := s.enums[f.typeName][int32(v)]; ok {
+			return name
+		}
+		return int32(v)
+	}
+	return v
+}
+
+// finite returns f, or how it prints if it's infinite or not a number, which JSON has no numbers for
+func finite(f float64) interface{} {
+	if math.IsInf(f, 0) || math.IsNaN(f) {
+		return fmt.Sprint(f)
+	}
+	return f
+}
+
+// zero is the value of the field f when it isn't in a message, like in Go structs
+func (s *protoSchema) zero(f *protoField) interface{} {
+	switch {
+	case f.repeated && s.isMap(f):
+		return map[string]interface{}{}
+	case f.repeated:
+		return []interface{}{}
+	}
+	switch f.kind {
+	case protoString, protoBytes:
+		return ""
+	case protoBool:
+		return false
+	case protoMessage, protoGroup:
+		return nil
+	}
+	return s.scalar(f, 0)
+}
+
+func (s *protoSchema) isMap(f *protoField) bool {
+	m, ok := s.messages[f.typeName]
+	return f.kind == protoMessage && ok && m.mapEntry
+}
+
+// packable is whether repeated fields of kind can be packed into one length delimited value
+func packable(kind int) bool {
+	switch kind {
+	case protoString, protoBytes, protoMessage, protoGroup:
+		return false
+	}
+	return true
+}
+
+// decodePacked calls fn with each of the values of kind packed in b
+func decodePacked(kind int, b []byte, fn func(v uint64)) error {
+	for len(b) > 0 {
+		switch kind {
+		case protoDouble, protoFixed64, protoSfixed64:
+			if len(b) < 8 {
+				return errors.New("truncated packed field")
+			}
+			fn(encodingBinary.LittleEndian.Uint64(b))
+			b = b[8:]
+		case protoFloat, protoFixed32, protoSfixed32:
+			if len(b) < 4 {
+				return errors.New("truncated packed field")
+			}
+			fn(uint64(encodingBinary.LittleEndian.Uint32(b)))
+			b = b[4:]
+		default:
+			v, n := encodingBinary.Uvarint(b)
+			if n <= 0 {
+				return errors.New("bad varint in packed field")
+			}
+			fn(v)
+			b = b[n:]
+		}
+	}
+	return nil
+}
+
+// protoFields calls fn with the number, wire type and value of each field encoded in b, the value being in v for
+// varints and fixed size values and in payload for length delimited ones
+func protoFields(b []byte, fn func(number, wireType int, v uint64, payload []byte) error) error {
+	for len(b) > 0 {
+		key, n := encodingBinary.Uvarint(b)
+		if n <= 0 {
+			return errors.New("bad field key")
+		}
+		b = b[n:]
+		number, wireType := int(key>>3), int(key&7)
+		var v uint64
+		var payload []byte
+		switch wireType {
+		case wireVarint:
+			if v, n = encodingBinary.Uvarint(b); n <= 0 {
+				return fmt.Errorf("bad varint in field %d", number)
+			}
+			b = b[n:]
+		case wireI64:
+			if len(b) < 8 {
+				return fmt.Errorf("truncated field %d", number)
+			}
+			v, b = encodingBinary.LittleEndian.Uint64(b), b[8:]
+		case wireI32:
+			if len(b) < 4 {
+				return fmt.Errorf("truncated field %d", number)
+			}
+			v, b = uint64(encodingBinary.LittleEndian.Uint32(b)), b[4:]
+		case wireLen:
+			length, n := encodingBinary.Uvarint(b)
+			if n <= 0 || length > uint64(len(b)-n) {
+				return fmt.Errorf("truncated field %d", number)
+			}
+			payload, b = b[n:n+int(length)], b[n+int(length):]
+		default:
+			return fmt.Errorf("unsupported wire type %d of field %d", wireType, number)
+		}
+		if err := fn(number, wireType, v, payload); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// parseDescriptorSet reads the messages and enums of a FileDescriptorSet, like protoc --descriptor_set_out writes
+func parseDescriptorSet(b []byte) (*protoSchema, error) {
+	s := newProtoSchema()
+	err := protoFields(b, func(number, wireType int, _ uint64, file []byte) error {
+		if number != 1 || wireType != wireLen {
+			return nil
+		}
+		// the package comes before the types, but isn't required to
+		pkg := ""
+		if err := protoFields(file, func(number, wireType int, _ uint64, payload []byte) error {
+			if number == 2 && wireType == wireLen {
+				pkg = "." + string(payload)
+			}
+			return nil
+		}); err != nil {
+			return err
+		}
+		return protoFields(file, func(number, wireType int, _ uint64, payload []byte) error {
+			switch {
+			case number == 4 && wireType == wireLen:
+				return s.addDescriptor(pkg, payload)
+			case number == 5 && wireType == wireLen:
+				return s.addEnumDescriptor(pkg, payload)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(s.messages) == 0 {
+		return nil, errors.New("there are no messages in it")
+	}
+	return s, nil
+}
+
+// addDescriptor adds the message of the DescriptorProto b, and the messages and enums nested in it, in scope
+func (s *protoSchema) addDescriptor(scope string, b []byte) error {
+	m := &protoMessageType{}
+	var nested, enums, fields [][]byte
+	err := protoFields(b, func(number, wireType int, _ uint64, payload []byte) error {
+		if wireType != wireLen {
+			return nil
+		}
+		switch number {
+		case 1:
+			m.name = scope + "." + string(payload)
+		case 2:
+			fields = append(fields, payload)
+		case 3:
+			nested = append(nested, payload)
+		case 4:
+			enums = append(enums, payload)
+		case 7:
+			return protoFields(payload, func(number, wireType int, v uint64, _ []byte) error {
+				if number == 7 && wireType == wireVarint {
+					m.mapEntry = v != 0
+				}
+				return nil
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, b := range fields {
+		f := protoField{}
+		if err := protoFields(b, func(number, wireType int, v uint64, payload []byte) error {
+			switch number {
+			case 1:
+				f.name = string(payload)
+			case 3:
+				f.number = int(v)
+			case 4:
+				f.repeated = v == 3
+			case 5:
+				f.kind = int(v)
+			case 6:
+				f.typeName = string(payload)
+			case 9, 17:
+				f.oneof = true
+			}
+			return nil
+		}); err != nil {
+			return err
+		}
+		m.fields = append(m.fields, f)
+	}
+	s.messages[m.name] = m
+	for _, b := range nested {
+		if err := s.addDescriptor(m.name, b); err != nil {
+			return err
+		}
+	}
+	for _, b := range enums {
+		if err := s.addEnumDescriptor(m.name, b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addEnumDescriptor adds the enum of the EnumDescriptorProto b in scope
+func (s *protoSchema) addEnumDescriptor(scope string, b []byte) error {
+	name := ""
+	values := make(map[int32]string)
+	err := protoFields(b, func(number, wireType int, _ uint64, payload []byte) error {
+		switch {
+		case number == 1 && wireType == wireLen:
+			name = scope + "." + string(payload)
+		case number == 2 && wireType == wireLen:
+			valueName, valueNumber := "", int32(0)
+			if err := protoFields(payload, func(number, _ int, v uint64, payload []byte) error {
+				switch number {
+				case 1:
+					valueName = string(payload)
+				case 2:
+					valueNumber = int32(v)
+				}
+				return nil
+			}); err != nil {
+				return err
+			}
+			// aliases print the first name
+			if _, ok := values[valueNumber]; !ok {
+				values[valueNumber] = valueName
+			}
+		}
+		return nil
+	})
+	s.enums[name] = values
+	return err
+}
+
+// goFieldName is the name of the field of the Go struct protoc-gen-go generates for the proto field name, like
+// UserId for user_id
+func goFieldName(name string) string {
+	var b strings.Builder
+	upper := true
+	for _, r := range name {
+		switch {
+		case r == '_':
+			upper = true
+			continue
+		case upper:
+			r = unicode.ToUpper(r)
+		}
+		b.WriteRune(r)
+		// a letter after a digit starts a word too
+		upper = unicode.IsDigit(r)
+	}
+	return b.String()
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	encodingBinary "encoding/binary"
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+// encodeVarintField and encodeLenField encode the field number as a varint or length delimited value
+func encodeVarintField(number int, v uint64) []byte {
+	b := encodingBinary.AppendUvarint(nil, uint64(number)<<3|wireVarint)
+	return encodingBinary.AppendUvarint(b, v)
+}
+
+func encodeLenField(number int, payload ...[]byte) []byte {
+	var joined []byte
+	for _, p := range payload {
+		joined = append(joined, p...)
+	}
+	b := encodingBinary.AppendUvarint(nil, uint64(number)<<3|wireLen)
+	b = encodingBinary.AppendUvarint(b, uint64(len(joined)))
+	return append(b, joined...)
+}
+
+func encodeStringField(number int, s string) []byte {
+	return encodeLenField(number, []byte(s))
+}
+
+const testProtoFile = `syntax = "proto3";
+
+package shop;
+
+import "google/protobuf/timestamp.proto";
+
+// an order
+message Order {
+  message Item {
+    string sku = 1;
+    int32 quantity = 2;
+  }
+  enum Status {
+    STATUS_UNKNOWN = 0;
+    STATUS_PAID = 1;
+  }
+  int64 order_id = 1;
+  repeated Item items = 2;
+  Status status = 3;
+  map<string, int32> counts = 4;
+  repeated sint32 deltas = 5 [packed = true];
+  oneof payment {
+    string card = 6;
+    string voucher = 7;
+  }
+  bool gift = 8;
+}
+`
+
+// testOrder is an Order with all but its gift and voucher fields set
+var testOrder = append(append(append(append(append(append(
+	encodeVarintField(1, 42),
+	encodeLenField(2, encodeStringField(1, "A1"), encodeVarintField(2, 3))...),
+	encodeLenField(2, encodeStringField(1, "B2"))...),
+	encodeVarintField(3, 1)...),
+	encodeLenField(4, encodeStringField(1, "x"), encodeVarintField(2, 7))...),
+	// -1 and 2 zigzag encoded, packed
+	encodeLenField(5, []byte{1, 4})...),
+	encodeStringField(6, "visa")...)
+
+func TestProtobufJSON(t *testing.T) {
+	raw, err := protobufJSON([]byte(testProtoFile), true, "Order", testOrder)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var actual interface{}
+	if err := json.Unmarshal([]byte(raw), &actual); err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]interface{}{
+		"OrderId": 42.0,
+		"Items": []interface{}{
+			map[string]interface{}{"Sku": "A1", "Quantity": 3.0},
+			map[string]interface{}{"Sku": "B2", "Quantity": 0.0},
+		},
+		"Status": "STATUS_PAID",
+		"Counts": map[string]interface{}{"x": 7.0},
+		"Deltas": []interface{}{-1.0, 2.0},
+		"Card":   "visa",
+		"Gift":   false,
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected %v, got %v", expected, actual)
+	}
+
+	if _, err := protobufJSON([]byte(testProtoFile), true, "", testOrder); err == nil ||
+		err.Error() != "the message type isn't named, it's one of shop.Order, shop.Order.Item" {
+		t.Errorf("unexpected error %v", err)
+	}
+	if _, err := protobufJSON([]byte("message A { B b = 1; }"), true, "", nil); err == nil ||
+		err.Error() != "failed to understand the schema: field b: type B isn't declared in the file, imports aren't followed" {
+		t.Errorf("unexpected error %v", err)
+	}
+	if _, err := protobufJSON([]byte(testProtoFile), true, "shop.Order", testOrder[:5]); err == nil {
+		t.Error("expected an error decoding a truncated message")
+	}
+}
+
+func TestProtobufJSONDescriptorSet(t *testing.T) {
+	// a FileDescriptorSet of message pkg.User { string user_name = 1; repeated string tags = 2; }
+	set := encodeLenField(1,
+		encodeStringField(2, "pkg"),
+		encodeLenField(4,
+			encodeStringField(1, "User"),
+			encodeLenField(2, encodeStringField(1, "user_name"), encodeVarintField(3, 1), encodeVarintField(5, protoString)),
+			encodeLenField(2, encodeStringField(1, "tags"), encodeVarintField(3, 2), encodeVarintField(4, 3), encodeVarintField(5, protoString)),
+		),
+	)
+	raw, err := protobufJSON(set, false, "pkg.User", append(encodeStringField(1, "bob"), encodeStringField(2, "admin")...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var actual interface{}
+	if err := json.Unmarshal([]byte(raw), &actual); err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]interface{}{"UserName": "bob", "Tags": []interface{}{"admin"}}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected %v, got %v", expected, actual)
+	}
+}
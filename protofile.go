@@ -0,0 +1,410 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// protoScalars are the kinds of the scalar types of .proto files
+var protoScalars = map[string]int{
+	"double": protoDouble, "float": protoFloat, "int64": protoInt64, "uint64": protoUint64, "int32": protoInt32,
+	"fixed64": protoFixed64, "fixed32": protoFixed32, "bool": protoBool, "string": protoString, "bytes": protoBytes,
+	"uint32": protoUint32, "sfixed32": protoSfixed32, "sfixed64": protoSfixed64, "sint32": protoSint32,
+	"sint64": protoSint64,
+}
+
+// protoParser reads the messages and enums of a .proto file. Imports aren't followed, so the types of fields have to
+// be declared in the file.
+type protoParser struct {
+	tokens []protoToken
+	i      int
+	schema *protoSchema
+	// unresolved are the fields whose type names are resolved once every type is declared
+	unresolved []protoFieldRef
+}
+
+// protoFieldRef is the field at index of message, whose type name is relative to scope
+type protoFieldRef struct {
+	message *protoMessageType
+	index   int
+	scope   string
+}
+
+type protoToken struct {
+	text string
+	line int
+}
+
+func parseProtoFile(text string) (*protoSchema, error) {
+	tokens, err := tokenizeProto(text)
+	if err != nil {
+		return nil, err
+	}
+	p := &protoParser{tokens: tokens, schema: newProtoSchema()}
+	if err := p.file(); err != nil {
+		return nil, err
+	}
+	for _, ref := range p.unresolved {
+		if err := p.resolve(ref.scope, &ref.message.fields[ref.index]); err != nil {
+			return nil, err
+		}
+	}
+	if len(p.schema.messages) == 0 {
+		return nil, fmt.Errorf("there are no messages in it")
+	}
+	return p.schema, nil
+}
+
+// tokenizeProto splits text into identifiers, numbers, strings and symbols, leaving out comments
+func tokenizeProto(text string) ([]protoToken, error) {
+	var tokens []protoToken
+	line := 1
+	for i := 0; i < len(text); {
+		c := text[i]
+		switch {
+		case c == '\n':
+			line++
+			i++
+		case c == ' ' || c == '\t' || c == '\r':
+			i++
+		case strings.HasPrefix(text[i:], "//"):
+			i = lineEnd(text, i)
+		case strings.HasPrefix(text[i:], "/*"):
+			end := strings.Index(text[i+2:], "*/")
+			if end == -1 {
+				return nil, fmt.Errorf("line %d: unclosed comment", line)
+			}
+			line += strings.Count(text[i:i+2+end], "\n")
+			i += 2 + end + 2
+		case c == '"' || c == '\'':
+			end := i + 1
+			for end < len(text) && text[end] != c && text[end] != '\n' {
+				if text[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(text) || text[end] != c {
+				return nil, fmt.Errorf("line %d: unclosed string", line)
+			}
+			tokens = append(tokens, protoToken{text: text[i : end+1], line: line})
+			i = end + 1
+		case c == '_' || c == '.' || c == '-' || c == '+' || unicode.IsLetter(rune(c)) || unicode.IsDigit(rune(c)):
+			end := i + 1
+			for end < len(text) && (text[end] == '_' || text[end] == '.' || unicode.IsLetter(rune(text[end])) ||
+				unicode.IsDigit(rune(text[end]))) {
+				end++
+			}
+			tokens = append(tokens, protoToken{text: text[i:end], line: line})
+			i = end
+		default:
+			tokens = append(tokens, protoToken{text: string(c), line: line})
+			i++
+		}
+	}
+	return tokens, nil
+}
+
+func (p *protoParser) peek() string {
+	if p.i < len(p.tokens) {
+		return p.tokens[p.i].text
+	}
+	return ""
+}
+
+func (p *protoParser) next() string {
+	s := p.peek()
+	p.i++
+	return s
+}
+
+func (p *protoParser) errorf(format string, args ...interface{}) error {
+	line := 0
+	if len(p.tokens) > 0 {
+		line = p.tokens[min(p.i, len(p.tokens)-1)].line
+	}
+	return fmt.Errorf("line %d: %s", line, fmt.Sprintf(format, args...))
+}
+
+func (p *protoParser) expect(s string) error {
+	if got := p.next(); got != s {
+		return p.errorf("expected %q, found %q", s, got)
+	}
+	return nil
+}
+
+// skipStatement skips to after the ; ending the statement, or the block it starts
+func (p *protoParser) skipStatement() error {
+	for depth := 0; p.i < len(p.tokens); {
+		switch p.next() {
+		case ";":
+			if depth == 0 {
+				return nil
+			}
+		case "{":
+			depth++
+		case "}":
+			if depth--; depth == 0 {
+				// a block can be followed by a ;, which is empty
+				if p.peek() == ";" {
+					p.i++
+				}
+				return nil
+			}
+		}
+	}
+	return p.errorf("unexpected end of file")
+}
+
+func (p *protoParser) file() error {
+	pkg := ""
+	for p.i < len(p.tokens) {
+		switch p.peek() {
+		case "package":
+			p.i++
+			pkg = "." + p.next()
+			if err := p.expect(";"); err != nil {
+				return err
+			}
+		case "message":
+			p.i++
+			if err := p.message(pkg); err != nil {
+				return err
+			}
+		case "enum":
+			p.i++
+			if err := p.enum(pkg); err != nil {
+				return err
+			}
+		case ";":
+			p.i++
+		default:
+			// syntax, edition, import, option, service and extend don't declare types
+			if err := p.skipStatement(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (p *protoParser) message(scope string) error {
+	m := &protoMessageType{name: scope + "." + p.next()}
+	p.schema.messages[m.name] = m
+	if err := p.expect("{"); err != nil {
+		return err
+	}
+	return p.messageBody(m, false)
+}
+
+// messageBody reads the fields and nested types of m up to the } ending them, which are in a oneof if oneof is true
+func (p *protoParser) messageBody(m *protoMessageType, oneof bool) error {
+	for {
+		switch p.peek() {
+		case "":
+			return p.errorf("unexpected end of file")
+		case "}":
+			p.i++
+			return nil
+		case ";":
+			p.i++
+		case "message":
+			p.i++
+			if err := p.message(m.name); err != nil {
+				return err
+			}
+		case "enum":
+			p.i++
+			if err := p.enum(m.name); err != nil {
+				return err
+			}
+		case "oneof":
+			p.i += 2
+			if err := p.expect("{"); err != nil {
+				return err
+			}
+			if err := p.messageBody(m, true); err != nil {
+				return err
+			}
+		case "option", "reserved", "extensions", "extend":
+			if err := p.skipStatement(); err != nil {
+				return err
+			}
+		case "map":
+			if err := p.mapField(m); err != nil {
+				return err
+			}
+		default:
+			if err := p.field(m, oneof); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// field reads a field like `repeated string tags = 2 [packed = true];`
+func (p *protoParser) field(m *protoMessageType, oneof bool) error {
+	f := protoField{oneof: oneof}
+	switch p.peek() {
+	case "repeated":
+		f.repeated = true
+		p.i++
+	case "optional":
+		// optional fields have no value when they aren't set, like those of a oneof
+		f.oneof = true
+		p.i++
+	case "required":
+		p.i++
+	case "group":
+		return p.errorf("groups aren't supported")
+	}
+	typeName := p.next()
+	f.name = p.next()
+	if err := p.expect("="); err != nil {
+		return err
+	}
+	number, err := strconv.Atoi(p.next())
+	if err != nil {
+		return p.errorf("bad number of field %s", f.name)
+	}
+	f.number = number
+	if kind, ok := protoScalars[typeName]; ok {
+		f.kind = kind
+	} else {
+		f.typeName = typeName
+	}
+	if err := p.fieldEnd(); err != nil {
+		return err
+	}
+	p.addField(m, f)
+	return nil
+}
+
+// mapField reads a field like `map<string, int32> counts = 3;` with a map entry message for it like protoc has
+func (p *protoParser) mapField(m *protoMessageType) error {
+	p.i++
+	if err := p.expect("<"); err != nil {
+		return err
+	}
+	keyType := p.next()
+	if err := p.expect(","); err != nil {
+		return err
+	}
+	valueType := p.next()
+	if err := p.expect(">"); err != nil {
+		return err
+	}
+	name := p.next()
+	if err := p.expect("="); err != nil {
+		return err
+	}
+	number, err := strconv.Atoi(p.next())
+	if err != nil {
+		return p.errorf("bad number of field %s", name)
+	}
+	if err := p.fieldEnd(); err != nil {
+		return err
+	}
+
+	entry := &protoMessageType{name: m.name + "." + goFieldName(name) + "Entry", mapEntry: true}
+	p.schema.messages[entry.name] = entry
+	for i, typeName := range []string{keyType, valueType} {
+		f := protoField{name: []string{"key", "value"}[i], number: i + 1}
+		if kind, ok := protoScalars[typeName]; ok {
+			f.kind = kind
+		} else {
+			f.typeName = typeName
+		}
+		// the value's type is named from where the map is
+		p.addFieldIn(entry, m.name, f)
+	}
+	p.addField(m, protoField{name: name, number: number, kind: protoMessage, typeName: entry.name, repeated: true})
+	return nil
+}
+
+// fieldEnd skips the options of a field, and the ; after them
+func (p *protoParser) fieldEnd() error {
+	if p.peek() == "[" {
+		for p.i < len(p.tokens) && p.next() != "]" {
+		}
+	}
+	return p.expect(";")
+}
+
+func (p *protoParser) addField(m *protoMessageType, f protoField) {
+	p.addFieldIn(m, m.name, f)
+}
+
+func (p *protoParser) addFieldIn(m *protoMessageType, scope string, f protoField) {
+	if f.typeName != "" && f.kind == 0 {
+		p.unresolved = append(p.unresolved, protoFieldRef{message: m, index: len(m.fields), scope: scope})
+	}
+	m.fields = append(m.fields, f)
+}
+
+func (p *protoParser) enum(scope string) error {
+	name := scope + "." + p.next()
+	values := make(map[int32]string)
+	p.schema.enums[name] = values
+	if err := p.expect("{"); err != nil {
+		return err
+	}
+	for {
+		switch p.peek() {
+		case "":
+			return p.errorf("unexpected end of file")
+		case "}":
+			p.i++
+			return nil
+		case ";":
+			p.i++
+		case "option", "reserved":
+			if err := p.skipStatement(); err != nil {
+				return err
+			}
+		default:
+			valueName := p.next()
+			if err := p.expect("="); err != nil {
+				return err
+			}
+			number, err := strconv.ParseInt(p.next(), 0, 32)
+			if err != nil {
+				return p.errorf("bad number of enum value %s", valueName)
+			}
+			if err := p.fieldEnd(); err != nil {
+				return err
+			}
+			// aliases print the first name
+			if _, ok := values[int32(number)]; !ok {
+				values[int32(number)] = valueName
+			}
+		}
+	}
+}
+
+// resolve finds the message or enum the type name of f is, looking in scope and then the scopes it's in, like protoc
+func (p *protoParser) resolve(scope string, f *protoField) error {
+	candidates := []string{f.typeName}
+	if !strings.HasPrefix(f.typeName, ".") {
+		candidates = nil
+		for s := scope; ; s = s[:strings.LastIndex(s, ".")] {
+			candidates = append(candidates, s+"."+f.typeName)
+			if s == "" {
+				break
+			}
+		}
+	}
+	for _, name := range candidates {
+		if _, ok := p.schema.messages[name]; ok {
+			f.kind, f.typeName = protoMessage, name
+			return nil
+		}
+		if _, ok := p.schema.enums[name]; ok {
+			f.kind, f.typeName = protoEnum, name
+			return nil
+		}
+	}
+	return fmt.Errorf("field %s: type %s isn't declared in the file, imports aren't followed", f.name, f.typeName)
+}
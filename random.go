@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"strconv"
+	"text/template"
+	"time"
+)
+
+const (
+	alphaChars   = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+	numericChars = "0123456789"
+	// maxRandLength is the longest random string, as it's made before any of it is output
+	maxRandLength = 1 << 20
+)
+
+// randomFunctionDocs document the random functions, named like Sprig's
+var randomFunctionDocs = []functionDoc{
+	{"randAlphaNum", "randAlphaNum count", "Returns a random string of letters and digits.", `{{randAlphaNum 8}}`, "random"},
+	{"randAlpha", "randAlpha count", "Returns a random string of letters.", `{{randAlpha 8}}`, "random"},
+	{"randNumeric", "randNumeric count", "Returns a random string of digits.", `{{randNumeric 6}}`, "random"},
+	{"randInt", "randInt min max", "Returns a random integer from min up to but not including max.", `{{randInt 1 7}}`, "random"},
+	{"uuidv4", "uuidv4", "Returns a random version 4 UUID.", `{{uuidv4}}`, "random"},
+}
+
+// parseSeed parses the seed of the random functions, which is random itself if s is empty
+func parseSeed(s string) (int64, error) {
+	if s == "" {
+		return time.Now().UnixNano(), nil
+	}
+	return strconv.ParseInt(s, 10, 64)
+}
+
+// randomFunctions returns the random functions, which produce the same results every time for the same seed
+func randomFunctions(seed int64) template.FuncMap {
+	r := rand.New(rand.NewSource(seed))
+	randString := func(chars string) func(count int) (string, error) {
+		return func(count int) (string, error) {
+			if count < 0 || count > maxRandLength {
+				return "", fmt.Errorf("count %d isn't between 0 and %d", count, maxRandLength)
+			}
+			b := make([]byte, count)
+			for i := range b {
+				b[i] = chars[r.Intn(len(chars))]
+			}
+			return string(b), nil
+		}
+	}
+	return template.FuncMap{
+		"randAlphaNum": randString(alphaChars + numericChars),
+		"randAlpha":    randString(alphaChars),
+		"randNumeric":  randString(numericChars),
+		"randInt": func(min, max int) int {
+			if max <= min {
+				return min
+			}
+			return min + r.Intn(max-min)
+		},
+		"uuidv4": func() string {
+			b := make([]byte, 16)
+			r.Read(b)
+			b[6] = b[6]&0x0f | 0x40
+			b[8] = b[8]&0x3f | 0x80
+			return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+		},
+	}
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestSeededRandomFunctions(t *testing.T) {
+	text := `{{randAlphaNum 10}} {{randAlpha 3}} {{randNumeric 4}} {{randInt 1 7}} {{uuidv4}}`
+	first := (&App{}).createData(context.Background(), text, "", "", options{Seed: "42"})
+	if len(first.Errors) != 0 {
+		t.Fatalf("unexpected errors found: %v", first.Errors)
+	}
+	if !regexp.MustCompile(`^[a-zA-Z0-9]{10} [a-zA-Z]{3} [0-9]{4} [1-6] [0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`).MatchString(first.Output) {
+		t.Errorf("unexpected output: %s", first.Output)
+	}
+	if second := (&App{}).createData(context.Background(), text, "", "", options{Seed: "42"}); second.Output != first.Output {
+		t.Errorf("output isn't reproducible: `%s` then `%s`", first.Output, second.Output)
+	}
+	if other := (&App{}).createData(context.Background(), text, "", "", options{Seed: "43"}); other.Output == first.Output {
+		t.Errorf("seed doesn't change the output: %s", other.Output)
+	}
+}
+
+func TestBadSeed(t *testing.T) {
+	data := (&App{}).createData(context.Background(), `{{randInt 1 2}}`, "", "", options{Seed: "abc"})
+	if len(data.Errors) != 1 || data.Errors[0].Level != misunderstoodError || data.Output != "1" {
+		t.Errorf("unexpected result: %v %s", data.Errors, data.Output)
+	}
+}
+
+func TestRandStringLength(t *testing.T) {
+	for _, count := range []string{"-1", "8000000000"} {
+		data := (&App{}).createData(context.Background(), "{{randAlphaNum "+count+"}}", "", "", options{Seed: "42"})
+		if len(data.Errors) != 1 || !strings.Contains(data.Errors[0].Description, "count "+count+" isn't between 0 and") {
+			t.Errorf("%s: unexpected errors %v", count, data.Errors)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+// dataRecord is a line of newline delimited JSON data, each executing the template on its own
+type dataRecord struct {
+	// Line is the line of the data the record is on
+	Line int
+	Data interface{}
+}
+
+// recordResult is what executing the template with a record output
+type recordResult struct {
+	// Record counts the records from 1, Line is the line of the data it's on
+	Record int    `json:"record"`
+	Line   int    `json:"line"`
+	Output string `json:"output"`
+	Error  string `json:"error,omitempty"`
+}
+
+// decodeRecords decodes the newline delimited JSON raw, a JSON value a line, skipping blank lines. The error is that of
+// the first line which isn't a JSON value, located in raw.
+func decodeRecords(raw string) ([]dataRecord, error) {
+	if len(raw) > maxDataSize {
+		return nil, fmt.Errorf("data is bigger than %d bytes", maxDataSize)
+	}
+	var records []dataRecord
+	for line, start := 0, 0; start < len(raw); line++ {
+		end := lineEnd(raw, start)
+		text := strings.TrimSuffix(raw[start:end], "\r")
+		if strings.TrimSpace(text) != "" {
+			data, err := decodeData(text)
+			if err != nil {
+				if dataErr, ok := err.(*dataError); ok {
+					return nil, &dataError{offset: start + dataErr.offset, err: dataErr.err}
+				}
+				return nil, err
+			}
+			records = append(records, dataRecord{Line: line, Data: data})
+		}
+		start = end + 1
+	}
+	if len(records) == 0 {
+		return nil, &dataError{offset: len(raw), err: fmt.Errorf("no records in the data")}
+	}
+	return records, nil
+}
+
+// executeRecords executes t with each of records within the limits of opts, the dot of each being the field path dot
+// of it if there is one
+func executeRecords(ctx context.Context, t *template.Template, records []dataRecord, opts options) []recordResult {
+	results := make([]recordResult, 0, len(records))
+	for i, record := range records {
+		if ctx.Err() != nil {
+			break
+		}
+		result := recordResult{Record: i + 1, Line: record.Line}
+		data := record.Data
+		if opts.Dot != "" {
+			dot, err := selectDot(data, opts.Dot)
+			if err != nil {
+				result.Error = fmt.Sprintf("failed to understand dot: %v", err)
+				results = append(results, result)
+				continue
+			}
+			data = dot
+		}
+		limitedT, err := limitTemplate(ctx, t, opts.MaxSteps, opts.MaxIterations)
+		if err != nil {
+			limitedT = t
+		}
+		buf := newCappedBuffer(opts.MaxOutput)
+		if tplErrs := exec(limitedT, data, buf); len(tplErrs) > 0 {
+			describeLimitErrors(tplErrs)
+			result.Error = tplErrs[0].Description
+		}
+		result.Output = buf.String()
+		results = append(results, result)
+	}
+	return results
+}
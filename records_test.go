@@ -0,0 +1,44 @@
+package main
+
+import (
+	"context"
+	"testing"
+)
+
+func TestDecodeRecords(t *testing.T) {
+	records, err := decodeRecords("{\"Name\": \"Bob\"}\n\n{\"Name\": \"Alice\"}\r\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 || records[0].Line != 0 || records[1].Line != 2 ||
+		records[1].Data.(map[string]interface{})["Name"] != "Alice" {
+		t.Errorf("unexpected records %+v", records)
+	}
+
+	raw := "{\"Name\": \"Bob\"}\n{\"Name\" \"Alice\"}\n"
+	_, err = decodeRecords(raw)
+	assertError(t, templateError{File: dataFile, Line: 1, Char: 8, Column: -1, Level: dataErrorLevel,
+		Description: "failed to understand data: invalid character '\"' after object key"},
+		dataTemplateError(raw, err))
+
+	_, err = decodeRecords("\n\n")
+	assertError(t, templateError{File: dataFile, Line: 2, Char: 0, Column: -1, Level: dataErrorLevel,
+		Description: "failed to understand data: no records in the data"}, dataTemplateError("\n\n", err))
+}
+
+func TestCreateDataRecords(t *testing.T) {
+	data := (&App{}).createData(context.Background(), "Hi {{.Name.First}}",
+		"{\"Name\": {\"First\": \"Bob\"}}\n{\"Name\": \"Alice\"}\n", "", options{NDJSON: true})
+	if len(data.Errors) != 0 || data.Output != "Hi Bob" {
+		t.Fatalf("unexpected errors %+v, output %q", data.Errors, data.Output)
+	}
+	if len(data.Records) != 2 {
+		t.Fatalf("unexpected records %+v", data.Records)
+	}
+	if r := data.Records[0]; r.Record != 1 || r.Line != 0 || r.Output != "Hi Bob" || r.Error != "" {
+		t.Errorf("unexpected record %+v", r)
+	}
+	if r := data.Records[1]; r.Record != 2 || r.Line != 1 || r.Error == "" {
+		t.Errorf("unexpected record %+v", r)
+	}
+}
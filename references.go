@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+	templateParse "text/template/parse"
+)
+
+// undefinedTemplates finds the {{template}} invocations of templates that aren't defined in t. Executing them fails,
+// but only if they're reached, so they're reported when parsing.
+func undefinedTemplates(t *template.Template) []templateError {
+	var tplErrs []templateError
+	for _, tree := range trees(t) {
+		tplErrs = append(tplErrs, undefinedInTree(t, tree)...)
+	}
+	return sortErrors(tplErrs)
+}
+
+// undefinedInTree finds the invocations in tree of templates that aren't defined in t
+func undefinedInTree(t *template.Template, tree *templateParse.Tree) []templateError {
+	var tplErrs []templateError
+	walk(tree.Root, func(node templateParse.Node, _ []templateParse.Node) bool {
+		if n, ok := node.(*templateParse.TemplateNode); ok {
+			if tt := t.Lookup(n.Name); tt == nil || tt.Tree == nil || tt.Tree.Root == nil {
+				tplErr := nodeError(tree, n, fmt.Sprintf("template %q not defined", n.Name))
+				tplErr.Level = parseErrorLevel
+				tplErrs = append(tplErrs, tplErr)
+			}
+		}
+		return true
+	})
+	return tplErrs
+}
+
+// withoutReported removes the errors executing undefined templates that were already reported when parsing
+func withoutReported(execTplErrs, undefinedTplErrs []templateError) []templateError {
+	result := execTplErrs[:0]
+	for _, execTplErr := range execTplErrs {
+		reported := false
+		for _, tplErr := range undefinedTplErrs {
+			if tplErr.Line == execTplErr.Line && tplErr.Char == execTplErr.Char &&
+				strings.HasSuffix(execTplErr.Description, tplErr.Description) {
+				reported = true
+				break
+			}
+		}
+		if !reported {
+			result = append(result, execTplErr)
+		}
+	}
+	return result
+}
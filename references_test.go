@@ -0,0 +1,41 @@
+package main
+
+import (
+	"context"
+	"testing"
+)
+
+func TestUndefinedTemplates(t *testing.T) {
+	tplErrs := undefinedTemplates(mustParse(t, `{{define "a"}}{{template "b"}}{{end}}{{if .}}{{template "c" .}}{{end}}{{template "a"}}`))
+	if len(tplErrs) != 2 {
+		t.Fatalf("expected 2 errors, actual %+v", tplErrs)
+	}
+	assertError(t, templateError{Line: 0, Char: 25, Description: `template "b" not defined`, Level: parseErrorLevel}, tplErrs[0])
+	assertError(t, templateError{Line: 0, Char: 56, Description: `template "c" not defined`, Level: parseErrorLevel}, tplErrs[1])
+}
+
+func TestUndefinedTemplatesExecuted(t *testing.T) {
+	data := (&App{}).createData(context.Background(), `{{template "x"}}`, "{}", "", options{})
+	if len(data.Errors) != 1 {
+		t.Fatalf("expected 1 error, actual %+v", data.Errors)
+	}
+	if tplErr := data.Errors[0]; tplErr.Level != parseErrorLevel || tplErr.Description != `template "x" not defined` {
+		t.Errorf("unexpected error %+v", tplErr)
+	}
+}
+
+func TestParseSetUndefinedTemplates(t *testing.T) {
+	sources := []source{
+		{name: "page.tmpl", text: `{{template "footer.tmpl"}}`},
+		{name: "footer.tmpl", text: "\n{{template \"nav\"}}"},
+	}
+	baseTpl, _ := newSetTemplate("page.tmpl", "", "", "")
+	_, tplErrs := parseSet(context.Background(), sources, baseTpl)
+	if len(tplErrs[0]) != 0 {
+		t.Errorf("expected no errors in page.tmpl, actual %+v", tplErrs[0])
+	}
+	if len(tplErrs[1]) != 1 {
+		t.Fatalf("expected 1 error in footer.tmpl, actual %+v", tplErrs[1])
+	}
+	assertError(t, templateError{Line: 1, Char: 11, Description: `template "nav" not defined`, Level: parseErrorLevel}, tplErrs[1][0])
+}
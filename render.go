@@ -0,0 +1,66 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"path"
+)
+
+// renderErrors is what rendering responds with when the template has errors
+type renderErrors struct {
+	Errors []templateError `json:"errors"`
+}
+
+// Render executes the template form value with the data and functions form values, with the same options as the page,
+// and serves the output as it is. Its content type is the content-type form value, or that of the extension of the
+// name form value, or else sniffed from the output. Templates with errors, other than lint errors below error
+// severity, are served as JSON with status 422 instead.
+func (a *App) Render(w http.ResponseWriter, r *http.Request) {
+	text, err := getFormValue(r, "template")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	rawData, err := getFormValue(r, "data")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	data := a.forRequest().createData(r.Context(), text, rawData, r.FormValue("functions"), getOptions(r))
+	if r.Context().Err() != nil {
+		return
+	}
+
+	var failures []templateError
+	for _, tplErr := range data.Errors {
+		if isFailure(tplErr) {
+			failures = append(failures, tplErr)
+		}
+	}
+	if len(failures) > 0 {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		if err := json.NewEncoder(w).Encode(renderErrors{Errors: failures}); err != nil {
+			log.Printf("failed to write render errors: %v", err)
+		}
+		return
+	}
+
+	contentType := r.FormValue("content-type")
+	if contentType == "" {
+		contentType = mime.TypeByExtension(path.Ext(r.FormValue("name")))
+	}
+	if contentType == "" {
+		contentType = http.DetectContentType([]byte(data.Output))
+	}
+	w.Header().Set("Content-Type", contentType)
+	// the output is the template's, not the server's, so browsers mustn't run it as part of the site
+	w.Header().Set("Content-Security-Policy", "sandbox")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	if _, err := io.WriteString(w, data.Output); err != nil {
+		log.Printf("failed to write render output: %v", err)
+	}
+}
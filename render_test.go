@@ -0,0 +1,45 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestRender(t *testing.T) {
+	a := &App{}
+	for _, test := range []struct {
+		form        url.Values
+		contentType string
+		output      string
+	}{
+		{url.Values{"template": {"Hi {{.Name}}"}, "data": {`{"Name": "Bob"}`}}, "text/plain; charset=utf-8", "Hi Bob"},
+		{url.Values{"template": {"<html><body>{{.}}</body></html>"}, "data": {`"Bob"`}}, "text/html; charset=utf-8", "<html><body>Bob</body></html>"},
+		{url.Values{"template": {`[{"name": "{{.}}"}]`}, "data": {`"Bob"`}, "name": {"users.json"}}, "application/json", `[{"name": "Bob"}]`},
+		{url.Values{"template": {"name: {{.}}"}, "data": {`"Bob"`}, "content-type": {"application/yaml"}}, "application/yaml", "name: Bob"},
+		// lint errors below error severity don't stop it from rendering
+		{url.Values{"template": {"{{$x := 1}}Hi"}}, "text/plain; charset=utf-8", "Hi"},
+	} {
+		w := httptest.NewRecorder()
+		a.Render(w, httptest.NewRequest("GET", "/api/v1/render?"+test.form.Encode(), nil))
+		if w.Code != http.StatusOK || w.Header().Get("Content-Type") != test.contentType || w.Body.String() != test.output {
+			t.Errorf("%v: unexpected response: %d %s %q", test.form, w.Code, w.Header().Get("Content-Type"), w.Body)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/api/v1/render", strings.NewReader(url.Values{"template": {"\n{{.Name.First}}"}, "data": {`{"Name": "Bob"}`}}.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	a.Render(w, req)
+	var result renderErrors
+	if err := json.Unmarshal(w.Body.Bytes(), &result); err != nil || w.Code != http.StatusUnprocessableEntity ||
+		w.Header().Get("Content-Type") != "application/json" {
+		t.Fatalf("unexpected response: %d %s, %v", w.Code, w.Body, err)
+	}
+	if len(result.Errors) != 1 || result.Errors[0].Line != 1 || result.Errors[0].Level != execErrorLevel {
+		t.Errorf("unexpected errors: %+v", result.Errors)
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRotatingFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "server.log")
+	if err := os.WriteFile(path, []byte("before the restart\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	f, err := newRotatingFile(path, 40, time.Hour, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	now := time.Date(2021, time.March, 4, 5, 6, 7, 0, time.UTC)
+	f.now = func() time.Time { return now }
+
+	write := func(s string) {
+		if _, err := f.Write([]byte(s)); err != nil {
+			t.Fatal(err)
+		}
+		now = now.Add(time.Second)
+	}
+	// the file is appended to until it's too big
+	write("first line\n")
+	write("second line\n")
+	write("third line\n")
+	if b, _ := os.ReadFile(path); string(b) != "second line\nthird line\n" {
+		t.Errorf("unexpected log file %q", b)
+	}
+	if b, _ := os.ReadFile(filepath.Join(dir, "server-2021-03-04T05-06-08.000.log")); string(b) != "before the restart\nfirst line\n" {
+		t.Errorf("unexpected backup %q", b)
+	}
+
+	// it's rotated once it's old too
+	now = now.Add(time.Hour)
+	write("an hour later\n")
+	if b, _ := os.ReadFile(path); string(b) != "an hour later\n" {
+		t.Errorf("unexpected log file %q", b)
+	}
+
+	write(strings.Repeat("x", 50) + "\n")
+	backups, err := f.backupFiles()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(backups) != 2 || filepath.Base(backups[0]) != "server-2021-03-04T06-06-10.000.log" ||
+		filepath.Base(backups[1]) != "server-2021-03-04T06-06-11.000.log" {
+		t.Errorf("expected the last 2 backups, actual %v", backups)
+	}
+}
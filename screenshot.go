@@ -0,0 +1,158 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	osExec "os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const (
+	// screenshotTimeout is how long starting a browser and rendering may take
+	screenshotTimeout = 30 * time.Second
+
+	screenshotWidth  = 1280
+	screenshotHeight = 960
+	thumbnailWidth   = 320
+
+	// maxScreenshotURL is about the longest argument a process can be started with, which the page's data URL is
+	maxScreenshotURL = 128*1024 - 1
+	// screenshotPolicy is the Content-Security-Policy of pages, which loads nothing but inline styles and data images,
+	// so their screenshots can't show local files or what the server can reach
+	screenshotPolicy = `<meta http-equiv="Content-Security-Policy" content="default-src 'none'; img-src data:; style-src 'unsafe-inline'">`
+)
+
+var doctypeRegex = regexp.MustCompile(`(?i)^\s*<!doctype[^>]*>`)
+
+var (
+	errNoBrowser    = errors.New("screenshots aren't configured, start with -chrome")
+	errBrowsersBusy = errors.New("too many screenshots are being taken, try again later")
+)
+
+// browser takes screenshots of HTML with a headless Chrome or Chromium
+type browser struct {
+	// path is the browser's binary
+	path string
+	// slots has room for each browser which may run at once
+	slots chan struct{}
+}
+
+// newBrowser returns a browser running the binary at path, at most n at once, or nil if path is empty
+func newBrowser(path string, n int) *browser {
+	if path == "" {
+		return nil
+	}
+	return &browser{path: path, slots: make(chan struct{}, max(n, 1))}
+}
+
+// withScreenshotPolicy returns html with screenshotPolicy first, where it's in the head, after the doctype if it has one
+func withScreenshotPolicy(html string) string {
+	doctype := doctypeRegex.FindString(html)
+	return doctype + screenshotPolicy + html[len(doctype):]
+}
+
+// screenshot renders html, with scripts disabled and nothing loaded, and returns a PNG of the top of the page
+func (b *browser) screenshot(ctx context.Context, html string) ([]byte, error) {
+	if b == nil {
+		return nil, errNoBrowser
+	}
+	page := "data:text/html;base64," + base64.StdEncoding.EncodeToString([]byte(withScreenshotPolicy(html)))
+	if len(page) > maxScreenshotURL {
+		return nil, fmt.Errorf("the HTML is too big to take a screenshot of, %d bytes", len(html))
+	}
+	select {
+	case b.slots <- struct{}{}:
+		defer func() { <-b.slots }()
+	default:
+		return nil, errBrowsersBusy
+	}
+	dir, err := os.MkdirTemp("", "go-template-validator-screenshot")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+	shot := filepath.Join(dir, "screenshot.png")
+
+	ctx, cancel := context.WithTimeout(ctx, screenshotTimeout)
+	defer cancel()
+	cmd := osExec.CommandContext(ctx, b.path,
+		"--headless",
+		"--disable-gpu",
+		"--hide-scrollbars",
+		"--no-first-run",
+		"--blink-settings=scriptEnabled=false",
+		// nothing the CSP misses is resolved or connected to either
+		"--host-resolver-rules=MAP * ~NOTFOUND",
+		"--proxy-server=127.0.0.1:9",
+		"--proxy-bypass-list=<-loopback>",
+		"--user-data-dir="+filepath.Join(dir, "profile"),
+		fmt.Sprintf("--window-size=%d,%d", screenshotWidth, screenshotHeight),
+		"--screenshot="+shot,
+		page)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("browser stopped: %v", ctx.Err())
+		}
+		return nil, fmt.Errorf("%v: %s", err, strings.TrimSpace(string(out)))
+	}
+	return os.ReadFile(shot)
+}
+
+// preview returns a base64 encoded PNG thumbnail of html
+func (b *browser) preview(ctx context.Context, html string) (string, error) {
+	shot, err := b.screenshot(ctx, html)
+	if err != nil {
+		return "", err
+	}
+	if shot, err = thumbnail(shot, thumbnailWidth); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(shot), nil
+}
+
+// thumbnail scales a PNG down to width, keeping its aspect ratio, by averaging the pixels each one covers
+func thumbnail(shot []byte, width int) ([]byte, error) {
+	src, err := png.Decode(bytes.NewReader(shot))
+	if err != nil {
+		return nil, err
+	}
+	bounds := src.Bounds()
+	if bounds.Dx() <= width {
+		return shot, nil
+	}
+	height := bounds.Dy() * width / bounds.Dx()
+	if height == 0 {
+		height = 1
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		y0, y1 := bounds.Min.Y+y*bounds.Dy()/height, bounds.Min.Y+(y+1)*bounds.Dy()/height
+		for x := 0; x < width; x++ {
+			x0, x1 := bounds.Min.X+x*bounds.Dx()/width, bounds.Min.X+(x+1)*bounds.Dx()/width
+			var r, g, b, a, n uint32
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					pr, pg, pb, pa := src.At(sx, sy).RGBA()
+					r, g, b, a, n = r+pr, g+pg, b+pb, a+pa, n+1
+				}
+			}
+			dst.Set(x, y, color.RGBA64{R: uint16(r / n), G: uint16(g / n), B: uint16(b / n), A: uint16(a / n)})
+		}
+	}
+	var out bytes.Buffer
+	if err := png.Encode(&out, dst); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
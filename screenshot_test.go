@@ -0,0 +1,127 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"image"
+	"image/color"
+	"image/png"
+	"io/ioutil"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func encodePNG(t *testing.T, img image.Image) []byte {
+	var b bytes.Buffer
+	if err := png.Encode(&b, img); err != nil {
+		t.Fatal(err)
+	}
+	return b.Bytes()
+}
+
+func TestThumbnail(t *testing.T) {
+	src := image.NewRGBA(image.Rect(0, 0, 4, 2))
+	for y := 0; y < 2; y++ {
+		for x := 0; x < 4; x++ {
+			if x%2 == 0 {
+				src.Set(x, y, color.White)
+			} else {
+				src.Set(x, y, color.Black)
+			}
+		}
+	}
+	b, err := thumbnail(encodePNG(t, src), 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := png.Decode(bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size := img.Bounds().Size(); size != image.Pt(2, 1) {
+		t.Fatalf("unexpected size %v", size)
+	}
+	// white and black pixels average to grey
+	if r, g, b, _ := img.At(0, 0).RGBA(); r>>8 != 127 || g>>8 != 127 || b>>8 != 127 {
+		t.Errorf("unexpected color %v", img.At(0, 0))
+	}
+
+	small := encodePNG(t, image.NewRGBA(image.Rect(0, 0, 1, 1)))
+	if b, err := thumbnail(small, 2); err != nil || !bytes.Equal(b, small) {
+		t.Errorf("expected small images to be kept, got %v", err)
+	}
+}
+
+func TestScreenshot(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake browser is a shell script")
+	}
+	dir := t.TempDir()
+	shot := filepath.Join(dir, "shot.png")
+	b := encodePNG(t, image.NewRGBA(image.Rect(0, 0, 1, 1)))
+	if err := ioutil.WriteFile(shot, b, 0644); err != nil {
+		t.Fatal(err)
+	}
+	// the fake browser copies a screenshot to where it's asked to take one, and keeps the page's URL
+	script := filepath.Join(dir, "chrome")
+	page := filepath.Join(dir, "page")
+	fake := "#!/bin/sh\nfor arg; do case $arg in --screenshot=*) cp " + shot + " \"${arg#--screenshot=}\";; esac; done\n" +
+		"for arg; do :; done; printf %s \"$arg\" > " + page + "\n"
+	if err := ioutil.WriteFile(script, []byte(fake), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	a := &App{browser: newBrowser(script, 1)}
+	data := a.createData(context.Background(), "<p>{{.}}</p>", `"hi"`, "", options{Screenshot: true})
+	if len(data.Errors) != 0 {
+		t.Fatalf("unexpected errors found: %v", data.Errors)
+	}
+	if data.Screenshot != base64.StdEncoding.EncodeToString(b) {
+		t.Errorf("unexpected screenshot %q", data.Screenshot)
+	}
+	url, err := ioutil.ReadFile(page)
+	if err != nil {
+		t.Fatal(err)
+	}
+	html, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(string(url), "data:text/html;base64,"))
+	if err != nil || string(html) != screenshotPolicy+"<p>hi</p>" {
+		t.Errorf("unexpected page %s, %v", url, err)
+	}
+
+	data = a.createData(context.Background(), "{{.}}", `"`+strings.Repeat("a", maxScreenshotURL)+`"`, "",
+		options{Screenshot: true, MaxOutput: 2 * maxScreenshotURL})
+	if len(data.Errors) != 1 || !strings.Contains(data.Errors[0].Description, "too big to take a screenshot of") {
+		t.Errorf("unexpected errors %v", data.Errors)
+	}
+
+	// a browser is refused while all of them are busy
+	a = &App{browser: a.browser}
+	a.browser.slots <- struct{}{}
+	data = a.createData(context.Background(), "<p>{{.}}</p>", `"hi"`, "", options{Screenshot: true})
+	if len(data.Errors) != 1 || !strings.Contains(data.Errors[0].Description, errBrowsersBusy.Error()) {
+		t.Errorf("unexpected errors %v", data.Errors)
+	}
+}
+
+func TestWithScreenshotPolicy(t *testing.T) {
+	for html, expected := range map[string]string{
+		"<p>hi</p>":                        screenshotPolicy + "<p>hi</p>",
+		"\n<!DOCTYPE html>\n<html></html>": "\n<!DOCTYPE html>" + screenshotPolicy + "\n<html></html>",
+	} {
+		if actual := withScreenshotPolicy(html); actual != expected {
+			t.Errorf("expected %q, actual %q", expected, actual)
+		}
+	}
+}
+
+func TestScreenshotWithoutBrowser(t *testing.T) {
+	data := (&App{}).createData(context.Background(), "<p>hi</p>", "", "", options{Screenshot: true})
+	if len(data.Errors) != 1 {
+		t.Fatalf("unexpected errors found: %v", data.Errors)
+	}
+	assertError(t, templateError{Line: -1, Char: -1, Level: misunderstoodError,
+		Description: "failed to take a screenshot: " + errNoBrowser.Error()}, data.Errors[0])
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	encodingBinary "encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
+// passphraseIterations are the PBKDF2 iterations deriving the key of a passphrase, to slow down guessing it
+const passphraseIterations = 100000
+
+// passphraseHeader is the header with the passphrase what's requested is encrypted with, if it is
+const passphraseHeader = "X-Passphrase"
+
+var errWrongPassphrase = errors.New("wrong or missing passphrase")
+
+// sealed is encrypted data as it's saved, with what's needed to decrypt it but the keys
+type sealed struct {
+	// Key is whether it was encrypted with the server's key
+	Key bool `json:"key,omitempty"`
+	// Passphrase is whether it was encrypted with a passphrase too, whose key is derived with Salt
+	Passphrase bool   `json:"passphrase,omitempty"`
+	Salt       []byte `json:"salt,omitempty"`
+	Nonce      []byte `json:"nonce"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+// loadSealKey returns the key derived from the secret in the file at path, nil if path is empty
+func loadSealKey(path string) ([]byte, error) {
+	if path == "" {
+		return nil, nil
+	}
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	secret := strings.TrimSpace(string(b))
+	if len(secret) < 16 {
+		return nil, fmt.Errorf("encryption key %s: the secret must be at least 16 characters", path)
+	}
+	key := sha256.Sum256([]byte(secret))
+	return key[:], nil
+}
+
+// seal encrypts plaintext with AES-GCM, with the server's key if it's not nil and the passphrase if it's not empty,
+// returning the JSON of what's sealed. The name is authenticated along with it, so it can't be renamed.
+func seal(key []byte, passphrase, name string, plaintext []byte) ([]byte, error) {
+	s := sealed{Key: key != nil, Passphrase: passphrase != ""}
+	if s.Passphrase {
+		s.Salt = make([]byte, 16)
+		if _, err := rand.Read(s.Salt); err != nil {
+			return nil, err
+		}
+	}
+	aead, err := sealCipher(key, passphrase, s.Salt)
+	if err != nil {
+		return nil, err
+	}
+	s.Nonce = make([]byte, aead.NonceSize())
+	if _, err := rand.Read(s.Nonce); err != nil {
+		return nil, err
+	}
+	s.Ciphertext = aead.Seal(nil, s.Nonce, plaintext, []byte(name))
+	return json.MarshalIndent(s, "", "  ")
+}
+
+// unseal decrypts b if it's sealed, returning it as it is otherwise, for what was saved before there was a key
+func unseal(key []byte, passphrase, name string, b []byte) ([]byte, error) {
+	var s sealed
+	if json.Unmarshal(b, &s) != nil || s.Ciphertext == nil {
+		return b, nil
+	}
+	if s.Key && key == nil {
+		return nil, errors.New("it's encrypted with a key the server isn't started with")
+	}
+	if s.Passphrase != (passphrase != "") {
+		return nil, errWrongPassphrase
+	}
+	if !s.Key {
+		key = nil
+	}
+	aead, err := sealCipher(key, passphrase, s.Salt)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := aead.Open(nil, s.Nonce, s.Ciphertext, []byte(name))
+	if err != nil {
+		if s.Passphrase {
+			return nil, errWrongPassphrase
+		}
+		return nil, errors.New("it can't be decrypted with the server's key")
+	}
+	return plaintext, nil
+}
+
+// isLocked returns whether b is sealed with a passphrase
+func isLocked(b []byte) bool {
+	var s sealed
+	return json.Unmarshal(b, &s) == nil && s.Ciphertext != nil && s.Passphrase
+}
+
+// sealCipher returns the AES-GCM of the key, and the passphrase with salt
+func sealCipher(key []byte, passphrase string, salt []byte) (cipher.AEAD, error) {
+	if passphrase != "" {
+		// the server's key is mixed in, so the files alone aren't enough to guess the passphrase
+		key = pbkdf2SHA256(append(append([]byte{}, key...), passphrase...), salt, passphraseIterations, 32)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// pbkdf2SHA256 derives a key of length bytes from password and salt, as in RFC 8018
+func pbkdf2SHA256(password, salt []byte, iterations, length int) []byte {
+	prf := hmac.New(sha256.New, password)
+	var key []byte
+	for block := uint32(1); len(key) < length; block++ {
+		prf.Reset()
+		prf.Write(salt)
+		prf.Write(encodingBinary.BigEndian.AppendUint32(nil, block))
+		u := prf.Sum(nil)
+		t := append([]byte{}, u...)
+		for i := 1; i < iterations; i++ {
+			prf.Reset()
+			prf.Write(u)
+			u = prf.Sum(u[:0])
+			for j := range t {
+				t[j] ^= u[j]
+			}
+		}
+		key = append(key, t...)
+	}
+	return key[:length]
+}
+
+// getPassphrase returns the passphrase of the request, from its passphraseHeader or the passphrase posted in its form
+func getPassphrase(r *http.Request) string {
+	if passphrase := r.Header.Get(passphraseHeader); passphrase != "" {
+		return passphrase
+	}
+	return r.PostFormValue("passphrase")
+}
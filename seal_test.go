@@ -0,0 +1,55 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+)
+
+func TestPBKDF2SHA256(t *testing.T) {
+	// the test vector of RFC 7914
+	expected := "55ac046e56e3089fec1691c22544b605f94185216dde0465e68b9d57c20dacbc" +
+		"49ca9cccf179b645991664b39d77ef317c71b845b1e30bd509112041d3a19783"
+	if actual := hex.EncodeToString(pbkdf2SHA256([]byte("passwd"), []byte("salt"), 1, 64)); actual != expected {
+		t.Errorf("expected %s, actual %s", expected, actual)
+	}
+}
+
+func TestSeal(t *testing.T) {
+	key := make([]byte, 32)
+	plaintext := []byte(`{"template": "password: {{.Secret}}"}`)
+	for _, test := range []struct {
+		key        []byte
+		passphrase string
+	}{
+		{key: key},
+		{key: key, passphrase: "open sesame"},
+		{passphrase: "open sesame"},
+	} {
+		b, err := seal(test.key, test.passphrase, "suite", plaintext)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if bytes.Contains(b, []byte("Secret")) || isLocked(b) != (test.passphrase != "") {
+			t.Errorf("unexpected sealed %s", b)
+		}
+		if actual, err := unseal(test.key, test.passphrase, "suite", b); err != nil || !bytes.Equal(actual, plaintext) {
+			t.Errorf("expected %s, actual %s, %v", plaintext, actual, err)
+		}
+		if _, err := unseal(test.key, test.passphrase, "renamed", b); err == nil {
+			t.Error("expected the name to be authenticated")
+		}
+		if _, err := unseal(test.key, "guess", "suite", b); err == nil {
+			t.Error("expected the wrong passphrase to fail")
+		}
+	}
+
+	// what was saved before there was a key is read as it is
+	if actual, err := unseal(key, "", "suite", plaintext); err != nil || !bytes.Equal(actual, plaintext) {
+		t.Errorf("expected %s, actual %s, %v", plaintext, actual, err)
+	}
+	b, _ := seal(key, "", "suite", plaintext)
+	if _, err := unseal(nil, "", "suite", b); err == nil {
+		t.Error("expected decrypting without the key to fail")
+	}
+}
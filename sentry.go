@@ -0,0 +1,143 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// sentryTimeout is how long sending an event to Sentry may take
+	sentryTimeout = 10 * time.Second
+	// maxReportedMessages are how many distinct messages are reported, so a flood of one error isn't sent over and over
+	maxReportedMessages = 1000
+)
+
+// errorReporter reports internal failures, nil unless the server is started with a DSN
+var errorReporter *sentryReporter
+
+// sentryReporter sends events to the store endpoint of a Sentry, or GlitchTip, project
+type sentryReporter struct {
+	endpoint string
+	key      string
+	client   *http.Client
+
+	mu       sync.Mutex
+	reported map[string]bool
+	// pending are the events being sent
+	pending sync.WaitGroup
+}
+
+// sentryEvent is an event as the store endpoint takes it
+type sentryEvent struct {
+	EventID    string            `json:"event_id"`
+	Timestamp  string            `json:"timestamp"`
+	Level      string            `json:"level"`
+	Platform   string            `json:"platform"`
+	Logger     string            `json:"logger"`
+	ServerName string            `json:"server_name,omitempty"`
+	Message    string            `json:"message"`
+	Tags       map[string]string `json:"tags,omitempty"`
+	Extra      map[string]string `json:"extra,omitempty"`
+}
+
+// newSentryReporter returns the reporter of the DSN, like https://key@sentry.example.com/42
+func newSentryReporter(dsn string) (*sentryReporter, error) {
+	u, err := url.Parse(dsn)
+	if err != nil {
+		return nil, fmt.Errorf("sentry DSN: %v", err)
+	}
+	project := path.Base(u.Path)
+	if u.User == nil || u.User.Username() == "" || project == "/" || project == "." {
+		return nil, fmt.Errorf("sentry DSN %q must be like https://key@host/project", dsn)
+	}
+	endpoint := fmt.Sprintf("%s://%s%s/api/%s/store/", u.Scheme, u.Host, strings.TrimSuffix(path.Dir(u.Path), "/"), project)
+	return &sentryReporter{endpoint: endpoint, key: u.User.Username(), client: &http.Client{Timeout: sentryTimeout},
+		reported: make(map[string]bool)}, nil
+}
+
+// report sends message to Sentry in the background, unless it was already, with extra details like a stack trace
+func (s *sentryReporter) report(level, kind, message string, extra map[string]string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	if s.reported[message] || len(s.reported) >= maxReportedMessages {
+		s.mu.Unlock()
+		return
+	}
+	s.reported[message] = true
+	s.mu.Unlock()
+
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		log.Printf("failed to report to sentry: %v", err)
+		return
+	}
+	host, _ := os.Hostname()
+	event := sentryEvent{EventID: hex.EncodeToString(id), Timestamp: time.Now().UTC().Format(time.RFC3339),
+		Level: level, Platform: "go", Logger: "go-template-validator", ServerName: host, Message: message,
+		Tags: map[string]string{"kind": kind}, Extra: extra}
+	s.pending.Add(1)
+	go func() {
+		defer s.pending.Done()
+		if err := s.send(context.Background(), event); err != nil {
+			log.Printf("failed to report to sentry: %v", err)
+		}
+	}()
+}
+
+func (s *sentryReporter) send(ctx context.Context, event sentryEvent) error {
+	b, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", s.endpoint, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Sentry-Auth", fmt.Sprintf("Sentry sentry_version=7, sentry_client=go-template-validator/1.0, sentry_key=%s", s.key))
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("sentry responded %s", resp.Status)
+	}
+	return nil
+}
+
+// reportMisunderstood reports an error whose message isn't in a format the validator understands, to support it
+func reportMisunderstood(err error) {
+	errorReporter.report("warning", string(misunderstoodError), err.Error(), map[string]string{"type": fmt.Sprintf("%T", err)})
+}
+
+// reportPanics reports the panics of handlers before panicking again, for the recoverer to respond to them
+func reportPanics(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			if rv := recover(); rv != nil {
+				if rv != http.ErrAbortHandler {
+					errorReporter.report("fatal", "panic", fmt.Sprint(rv), map[string]string{
+						"stack": string(debug.Stack()), "method": r.Method, "path": r.URL.Path})
+				}
+				panic(rv)
+			}
+		}()
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestNewSentryReporter(t *testing.T) {
+	s, err := newSentryReporter("https://abc123@sentry.example.com/prefix/42")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.endpoint != "https://sentry.example.com/prefix/api/42/store/" || s.key != "abc123" {
+		t.Errorf("unexpected endpoint %s and key %s", s.endpoint, s.key)
+	}
+	for _, dsn := range []string{"https://sentry.example.com/42", "https://abc123@sentry.example.com/", "::"} {
+		if _, err := newSentryReporter(dsn); err == nil {
+			t.Errorf("expected %q to be rejected", dsn)
+		}
+	}
+}
+
+func TestReportToSentry(t *testing.T) {
+	var mu sync.Mutex
+	var events []sentryEvent
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/42/store/" || !strings.Contains(r.Header.Get("X-Sentry-Auth"), "sentry_key=abc123") {
+			t.Errorf("unexpected request to %s with %s", r.URL.Path, r.Header.Get("X-Sentry-Auth"))
+		}
+		var event sentryEvent
+		if err := json.NewDecoder(r.Body).Decode(&event); err != nil {
+			t.Error(err)
+		}
+		mu.Lock()
+		events = append(events, event)
+		mu.Unlock()
+	}))
+	defer server.Close()
+	s, err := newSentryReporter(strings.Replace(server.URL, "://", "://abc123@", 1) + "/42")
+	if err != nil {
+		t.Fatal(err)
+	}
+	errorReporter = s
+	defer func() { errorReporter = nil }()
+
+	// errors in a format the regexes don't know are reported once
+	for i := 0; i < 2; i++ {
+		if tplErr := createTemplateError(errors.New("a new kind of error"), parseErrorLevel); tplErr.Level != misunderstoodError {
+			t.Errorf("unexpected error: %+v", tplErr)
+		}
+	}
+	// those they know aren't
+	createTemplateError(errors.New(`template: page:1: function "x" not defined`), parseErrorLevel)
+
+	handler := reportPanics(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("oops")
+	}))
+	func() {
+		defer func() {
+			if rv := recover(); rv != "oops" {
+				t.Errorf("expected the panic to go on, actual %v", rv)
+			}
+		}()
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/boom", nil))
+	}()
+
+	s.pending.Wait()
+	if len(events) != 2 {
+		t.Fatalf("unexpected events: %+v", events)
+	}
+	byKind := map[string]sentryEvent{}
+	for _, event := range events {
+		byKind[event.Tags["kind"]] = event
+	}
+	if event := byKind["misunderstood"]; event.Message != "a new kind of error" || event.Level != "warning" {
+		t.Errorf("unexpected event: %+v", event)
+	}
+	if event := byKind["panic"]; event.Message != "oops" || event.Extra["path"] != "/boom" ||
+		!strings.Contains(event.Extra["stack"], "sentry_test.go") || len(event.EventID) != 32 {
+		t.Errorf("unexpected event: %+v", event)
+	}
+}
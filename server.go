@@ -0,0 +1,36 @@
+package main
+
+import (
+	"net/http"
+	"time"
+
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+)
+
+// serverConfig is how the server handles connections
+type serverConfig struct {
+	// ReadHeaderTimeout, ReadTimeout, WriteTimeout and IdleTimeout are those of http.Server, so slow clients can't hold
+	// connections open
+	ReadHeaderTimeout time.Duration
+	ReadTimeout       time.Duration
+	WriteTimeout      time.Duration
+	IdleTimeout       time.Duration
+	// H2C serves HTTP/2 without TLS, for proxies in front of the server which speak it
+	H2C bool
+}
+
+// newServer returns the server of handler at addr, configured by config
+func newServer(addr string, handler http.Handler, config serverConfig) *http.Server {
+	if config.H2C {
+		handler = h2c.NewHandler(handler, &http2.Server{IdleTimeout: config.IdleTimeout})
+	}
+	return &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		ReadHeaderTimeout: config.ReadHeaderTimeout,
+		ReadTimeout:       config.ReadTimeout,
+		WriteTimeout:      config.WriteTimeout,
+		IdleTimeout:       config.IdleTimeout,
+	}
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"golang.org/x/net/http2"
+)
+
+func TestServerH2C(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, r.Proto)
+	})
+	config := serverConfig{ReadHeaderTimeout: time.Second, ReadTimeout: 2 * time.Second, WriteTimeout: 3 * time.Second,
+		IdleTimeout: 4 * time.Second, H2C: true}
+	server := newServer(":0", handler, config)
+	if server.ReadHeaderTimeout != time.Second || server.ReadTimeout != 2*time.Second ||
+		server.WriteTimeout != 3*time.Second || server.IdleTimeout != 4*time.Second {
+		t.Errorf("unexpected timeouts: %+v", server)
+	}
+
+	ts := httptest.NewUnstartedServer(server.Handler)
+	ts.Config = server
+	ts.Start()
+	defer ts.Close()
+
+	// HTTP/2 with prior knowledge, over a plain connection
+	client := &http.Client{Transport: &http2.Transport{
+		AllowHTTP: true,
+		DialTLSContext: func(ctx context.Context, network, addr string, _ *tls.Config) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, network, addr)
+		},
+	}}
+	for _, c := range []struct {
+		client *http.Client
+		proto  string
+	}{{client, "HTTP/2.0"}, {ts.Client(), "HTTP/1.1"}} {
+		resp, err := c.client.Get(ts.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if proto := string(body); err != nil || proto != c.proto {
+			t.Errorf("expected %s, actual %s", c.proto, proto)
+		}
+	}
+}
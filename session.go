@@ -0,0 +1,250 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi"
+)
+
+// maxSessionEdits are how many edits a request can make to a session, as each copies the text
+const maxSessionEdits = 100
+
+var errSessionNotFound = errors.New("session not found, it may have expired")
+
+// editSession is a template being edited, validated again with each edit to it
+type editSession struct {
+	mu        sync.Mutex
+	text      string
+	rawData   string
+	rawFns    string
+	opts      options
+	version   int
+	lastUsed  time.Time
+	lastValid validationResult
+}
+
+// textEdit replaces the text from Start to End, which are like the positions of a quickFix, with Text
+type textEdit struct {
+	Start position `json:"start"`
+	End   position `json:"end"`
+	Text  string   `json:"text"`
+}
+
+// sessionEdit is a change to a session, made to the version it was at
+type sessionEdit struct {
+	Version int `json:"version"`
+	// Edits are applied in order, each to the text the ones before it made
+	Edits []textEdit `json:"edits"`
+	// Data and Functions replace those of the session, if they're set
+	Data      *string `json:"data,omitempty"`
+	Functions *string `json:"functions,omitempty"`
+}
+
+// sessionResult is what validating a version of a session found
+type sessionResult struct {
+	Session string `json:"session"`
+	Version int    `json:"version"`
+	validationResult
+}
+
+// editSessions keeps sessions in memory until they're unused for ttl, and at most max of them. A nil store keeps none.
+type editSessions struct {
+	mu       sync.Mutex
+	sessions map[string]*editSession
+	max      int
+	ttl      time.Duration
+}
+
+func newEditSessions(max int, ttl time.Duration) *editSessions {
+	return &editSessions{sessions: make(map[string]*editSession), max: max, ttl: ttl}
+}
+
+// create keeps a new session, forgetting the expired ones and, if there are still too many, the least recently used
+func (s *editSessions) create(session *editSession, now time.Time) (string, error) {
+	if s == nil {
+		return "", errors.New("sessions aren't kept")
+	}
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	id := hex.EncodeToString(b)
+	session.lastUsed = now
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var oldestID string
+	var oldest time.Time
+	for otherID, other := range s.sessions {
+		if now.Sub(other.lastUsed) >= s.ttl {
+			delete(s.sessions, otherID)
+		} else if oldestID == "" || other.lastUsed.Before(oldest) {
+			oldestID, oldest = otherID, other.lastUsed
+		}
+	}
+	if len(s.sessions) >= s.max {
+		delete(s.sessions, oldestID)
+	}
+	s.sessions[id] = session
+	return id, nil
+}
+
+// get returns the session id, unless it expired
+func (s *editSessions) get(id string, now time.Time) (*editSession, error) {
+	if s == nil {
+		return nil, errSessionNotFound
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	session, ok := s.sessions[id]
+	if !ok {
+		return nil, errSessionNotFound
+	}
+	if now.Sub(session.lastUsed) >= s.ttl {
+		delete(s.sessions, id)
+		return nil, errSessionNotFound
+	}
+	session.lastUsed = now
+	return session, nil
+}
+
+// applyEdits returns text with edits made to it, with characters counted in unit. The lines are found once, and
+// updated around each edit, and the text can't grow beyond maxRequestSize.
+func applyEdits(text string, edits []textEdit, unit ColumnUnit) (string, error) {
+	if len(edits) > maxSessionEdits {
+		return "", fmt.Errorf("%d edits are more than the %d a request can make", len(edits), maxSessionEdits)
+	}
+	starts := visualLineStarts(text)
+	for i, edit := range edits {
+		lines := len(starts)
+		if edit.Start.Line < 0 || edit.Start.Line >= lines || edit.End.Line < 0 || edit.End.Line >= lines {
+			return "", fmt.Errorf("edit %d is outside the %d lines of the text", i, lines)
+		}
+		start := offsetInLine(text, starts, edit.Start.Line, edit.Start.Char, unit)
+		end := offsetInLine(text, starts, edit.End.Line, edit.End.Char, unit)
+		if end < start {
+			return "", fmt.Errorf("edit %d ends before it starts", i)
+		}
+		if len(text)-(end-start)+len(edit.Text) > maxRequestSize {
+			return "", fmt.Errorf("edit %d makes the text bigger than %d bytes", i, maxRequestSize)
+		}
+		text = text[:start] + edit.Text + text[end:]
+		starts = editLineStarts(text, starts, edit.Start.Line, start, end, len(edit.Text))
+	}
+	return text, nil
+}
+
+// editLineStarts returns the visualLineStarts of text, which had the starts before the bytes from start to end of
+// its line were replaced with n others. Only the lines of the edit are found again, those after it move.
+func editLineStarts(text string, starts []int, line, start, end, n int) []int {
+	var after []int
+	for _, s := range starts[line+1:] {
+		// whether a line starts only depends on the bytes before and at it, which are the same after the edit
+		if s > end {
+			after = append(after, s-end+start+n)
+		}
+	}
+	edited := starts[:line:line]
+	for i := starts[line]; i <= start+n; i++ {
+		if lineStartsAt(text, i) {
+			edited = append(edited, i)
+		}
+	}
+	return append(edited, after...)
+}
+
+// lineStartsAt returns whether one of the lines an editor shows starts at offset i of text
+func lineStartsAt(text string, i int) bool {
+	if i == 0 {
+		return true
+	}
+	switch text[i-1] {
+	case '\n':
+		return true
+	case '\r':
+		return i == len(text) || text[i] != '\n'
+	}
+	return false
+}
+
+// CreateSession starts a session of the template, data and functions form values, with the same options as the page,
+// and serves its id and what validating it found as JSON. It's edited with EditSession.
+func (a *App) CreateSession(w http.ResponseWriter, r *http.Request) {
+	text, err := getFormValue(r, "template")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	rawData, err := getFormValue(r, "data")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	session := &editSession{text: text, rawData: rawData, rawFns: r.FormValue("functions"), opts: getOptions(r),
+		version: 1}
+	data := a.forRequest().createData(r.Context(), session.text, session.rawData, session.rawFns, session.opts)
+	if r.Context().Err() != nil {
+		return
+	}
+	session.lastValid = newValidationResult(data)
+	id, err := a.sessions.create(session, time.Now())
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed to start session: %v", err), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, sessionResult{Session: id, Version: session.version, validationResult: session.lastValid})
+}
+
+// EditSession applies the JSON sessionEdit in the body to the session id and serves what validating it found, as
+// JSON. Edits to another version than the session's conflict, the session has to be started again with the whole
+// template then. An edit changing nothing serves the result of the version before, and parsing text which was parsed
+// before is reused by the parse cache, like when only the data changes or an edit is undone.
+func (a *App) EditSession(w http.ResponseWriter, r *http.Request) {
+	var edit sessionEdit
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestSize)).Decode(&edit); err != nil {
+		http.Error(w, fmt.Sprintf("failed to understand edit: %v", err), http.StatusBadRequest)
+		return
+	}
+	id := chi.URLParam(r, "id")
+	session, err := a.sessions.get(id, time.Now())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	session.mu.Lock()
+	defer session.mu.Unlock()
+	if edit.Version != session.version {
+		http.Error(w, fmt.Sprintf("the session is at version %d, not %d", session.version, edit.Version), http.StatusConflict)
+		return
+	}
+	text, err := applyEdits(session.text, edit.Edits, session.opts.ColumnUnit)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed to apply edits: %v", err), http.StatusBadRequest)
+		return
+	}
+	rawData, rawFns := session.rawData, session.rawFns
+	if edit.Data != nil {
+		rawData = *edit.Data
+	}
+	if edit.Functions != nil {
+		rawFns = *edit.Functions
+	}
+	// the session only changes once the edit is validated, so a request given up on leaves it as it was
+	result := session.lastValid
+	if text != session.text || rawData != session.rawData || rawFns != session.rawFns {
+		data := a.forRequest().createData(r.Context(), text, rawData, rawFns, session.opts)
+		if r.Context().Err() != nil {
+			return
+		}
+		result = newValidationResult(data)
+	}
+	session.text, session.rawData, session.rawFns, session.lastValid = text, rawData, rawFns, result
+	session.version++
+	writeJSON(w, sessionResult{Session: id, Version: session.version, validationResult: session.lastValid})
+}
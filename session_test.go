@@ -0,0 +1,151 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi"
+)
+
+func TestApplyEdits(t *testing.T) {
+	text := "Hi {{.Name}}\r\nBye {{.Nme}}"
+	edits := []textEdit{
+		{Start: position{Line: 1, Char: 8}, End: position{Line: 1, Char: 9}, Text: "am"},
+		{Start: position{Line: 0, Char: 0}, End: position{Line: 0, Char: 2}, Text: "Hello"},
+		{Start: position{Line: 1, Char: 99}, End: position{Line: 1, Char: 99}, Text: "!"},
+	}
+	if edited, err := applyEdits(text, edits, runeColumns); err != nil || edited != "Hello {{.Name}}\r\nBye {{.Name}}!" {
+		t.Errorf("unexpected edited text %q, %v", edited, err)
+	}
+	if edited, err := applyEdits("a\r{{.Nme}}", []textEdit{{Start: position{Line: 1, Char: 4}, End: position{Line: 1, Char: 4},
+		Text: "a"}}, runeColumns); err != nil || edited != "a\r{{.Name}}" {
+		t.Errorf("unexpected edited text %q, %v", edited, err)
+	}
+	if edited, err := applyEdits("¿{{.}}", []textEdit{{Start: position{Char: 2}, End: position{Char: 4}}}, byteColumns); err != nil || edited != "¿.}}" {
+		t.Errorf("unexpected edited text %q, %v", edited, err)
+	}
+	for _, edit := range []textEdit{
+		{Start: position{Line: 2}, End: position{Line: 2}},
+		{Start: position{Line: 1, Char: 3}, End: position{Line: 1, Char: 1}},
+	} {
+		if _, err := applyEdits(text, []textEdit{edit}, runeColumns); err == nil {
+			t.Errorf("expected edit %+v to fail", edit)
+		}
+	}
+	if _, err := applyEdits(text, make([]textEdit, maxSessionEdits+1), runeColumns); err == nil {
+		t.Error("expected too many edits to fail")
+	}
+	huge := textEdit{Text: strings.Repeat("a", maxRequestSize/2)}
+	if _, err := applyEdits(text, []textEdit{huge, huge}, runeColumns); err == nil ||
+		err.Error() != fmt.Sprintf("edit 1 makes the text bigger than %d bytes", maxRequestSize) {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+func TestEditLineStarts(t *testing.T) {
+	tests := []struct {
+		text       string
+		start, end int
+		insert     string
+	}{
+		{"a\nb\nc", 2, 3, "x\ny"},
+		{"a\nb\nc", 1, 4, ""},
+		{"a\rb", 2, 2, "\n"},
+		{"a\r\nb", 2, 2, "x"},
+		{"a\rb\n", 1, 1, "\r"},
+		{"ab\n\r", 4, 4, "\n"},
+		{"a\r", 2, 2, "\nb\r\n"},
+		{"a\nb", 0, 3, "\r"},
+	}
+	for _, test := range tests {
+		starts := visualLineStarts(test.text)
+		line := 0
+		for line+1 < len(starts) && starts[line+1] <= test.start {
+			line++
+		}
+		edited := test.text[:test.start] + test.insert + test.text[test.end:]
+		expected := visualLineStarts(edited)
+		if actual := editLineStarts(edited, starts, line, test.start, test.end, len(test.insert)); !reflect.DeepEqual(expected, actual) {
+			t.Errorf("%q: expected %v, actual %v", edited, expected, actual)
+		}
+	}
+}
+
+func TestEditSessions(t *testing.T) {
+	now := time.Now()
+	s := newEditSessions(2, time.Minute)
+	first, _ := s.create(&editSession{}, now)
+	second, _ := s.create(&editSession{}, now.Add(time.Second))
+	if _, err := s.get(first, now.Add(2*time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	// the second is the least recently used now
+	third, _ := s.create(&editSession{}, now.Add(3*time.Second))
+	if _, err := s.get(second, now.Add(3*time.Second)); err != errSessionNotFound {
+		t.Errorf("expected the second session to be forgotten, %v", err)
+	}
+	if _, err := s.get(third, now.Add(time.Minute)); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if _, err := s.get(first, now.Add(time.Minute+2*time.Second)); err != errSessionNotFound {
+		t.Errorf("expected the first session to expire, %v", err)
+	}
+	if _, err := (*editSessions)(nil).create(&editSession{}, now); err == nil {
+		t.Error("expected a nil store to keep nothing")
+	}
+}
+
+func TestSessionEndpoints(t *testing.T) {
+	a := &App{parseCache: newParseCache(parseCacheSize), sessions: newEditSessions(10, time.Hour)}
+	r := chi.NewRouter()
+	r.Post("/api/v1/sessions", a.CreateSession)
+	r.Patch("/api/v1/sessions/{id}", a.EditSession)
+	edit := func(id string, body string) (*httptest.ResponseRecorder, sessionResult) {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest("PATCH", "/api/v1/sessions/"+id, strings.NewReader(body)))
+		var result sessionResult
+		json.Unmarshal(w.Body.Bytes(), &result)
+		return w, result
+	}
+
+	form := url.Values{"template": {"Hi {{.Name}"}, "data": {`{"Name": "Bob"}`}}
+	req := httptest.NewRequest("POST", "/api/v1/sessions", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	var created sessionResult
+	if err := json.Unmarshal(w.Body.Bytes(), &created); err != nil || created.Session == "" || created.Version != 1 ||
+		len(created.Errors) == 0 {
+		t.Fatalf("unexpected response: %d %s", w.Code, w.Body)
+	}
+
+	w, result := edit(created.Session, `{"version": 1, "edits": [{"start": {"Line": 0, "Char": 11}, "end": {"Line": 0, "Char": 11}, "text": "}"}]}`)
+	if w.Code != http.StatusOK || result.Version != 2 || result.Output != "Hi Bob" || len(result.Errors) != 0 {
+		t.Errorf("unexpected response: %d %s", w.Code, w.Body)
+	}
+	misses := a.parseCache.stats().Misses
+	w, result = edit(created.Session, `{"version": 2, "data": "{\"Name\": \"Alice\"}"}`)
+	if result.Version != 3 || result.Output != "Hi Alice" {
+		t.Errorf("unexpected response: %d %s", w.Code, w.Body)
+	}
+	if stats := a.parseCache.stats(); stats.Misses != misses {
+		t.Errorf("expected the parse to be reused when only the data changes: %+v", stats)
+	}
+
+	if w, _ := edit(created.Session, `{"version": 2, "edits": []}`); w.Code != http.StatusConflict {
+		t.Errorf("unexpected response: %d %s", w.Code, w.Body)
+	}
+	if w, _ := edit(created.Session, `{"version": 3, "edits": [{"start": {"Line": 5}, "end": {"Line": 5}}]}`); w.Code != http.StatusBadRequest {
+		t.Errorf("unexpected response: %d %s", w.Code, w.Body)
+	}
+	if w, _ := edit("missing", `{"version": 1}`); w.Code != http.StatusNotFound {
+		t.Errorf("unexpected response: %d %s", w.Code, w.Body)
+	}
+}
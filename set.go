@@ -0,0 +1,281 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"sort"
+	textTemplate "text/template"
+)
+
+// fileResult is what validating one file of a set of templates found
+type fileResult struct {
+	File   string          `json:"file"`
+	Errors []templateError `json:"errors"`
+	// Stats are the size of the file, those of the set as a whole have none
+	Stats *templateStats `json:"stats,omitempty"`
+	// Mocked are the unknown functions mocked to execute the template
+	Mocked []autoMockedFunction `json:"mocked,omitempty"`
+	// Records are what executing with each record of newline delimited JSON data output
+	Records []recordResult `json:"records,omitempty"`
+	// Lines and Highlighted are the file's lines and their tokens, for the page to show the errors in
+	Lines       []string      `json:"-"`
+	Highlighted [][]highlight `json:"-"`
+}
+
+// parseSet parses sources into one set of associated templates, each named by its source, so they can invoke each other
+// like the templates of one ParseFiles call. The errors are those of each source, in order, including invocations of
+// templates none of them define.
+func parseSet(ctx context.Context, sources []source, baseTpl *textTemplate.Template) (*textTemplate.Template, [][]templateError) {
+	tplErrs := make([][]templateError, len(sources))
+	index := make(map[string]int, len(sources))
+	for i, src := range sources {
+		_, tplErrs[i] = parse(ctx, src.text, baseTpl.New(src.name))
+		if len(tplErrs[i]) > 0 {
+			tplErrs[i] = refineParseErrors(tplErrs[i], precheck(src.name, src.text))
+		}
+		index[src.name] = i
+	}
+	// the templates of a source are parsed with its name
+	for _, tree := range trees(baseTpl) {
+		if i, ok := index[tree.ParseName]; ok {
+			tplErrs[i] = append(tplErrs[i], undefinedInTree(baseTpl, tree)...)
+		}
+	}
+	for i := range tplErrs {
+		tplErrs[i] = sortErrors(tplErrs[i])
+	}
+	return baseTpl, tplErrs
+}
+
+// newSetTemplate returns an empty template named name, with the mocked functions of a preset, presetFns, and rawFns and
+// the builtin ones, which aren't executed so their results don't matter
+func newSetTemplate(name, presetFns, rawFns, missingKey string) (*textTemplate.Template, []templateError) {
+	t := textTemplate.New(name).Funcs(timeFunctions(defaultNow)).Funcs(randomFunctions(0))
+	if missingKey != "" {
+		t = t.Option("missingkey=" + missingKey)
+	}
+	t, _, presetTplErrs := mockFunctions(t, presetFns)
+	t, _, tplErrs := mockFunctions(t, rawFns)
+	return t, append(presetTplErrs, tplErrs...)
+}
+
+// validateSet parses the sources as a set and finds the errors in each of them. With data, the first source, or the
+// entry template of opts, is executed with it too, and the errors of the templates it includes are those of their files.
+// With overrides, each file they match is validated separately, parsed after the others like a page redefining the
+// blocks of a layout.
+func (a *App) validateSet(ctx context.Context, sources []source, rawData, rawFns string, opts options) []fileResult {
+	if opts.TabWidth <= 0 {
+		opts.TabWidth = defaultTabWidth
+	}
+	results := make([]fileResult, 0, len(sources)+1)
+	opts, err := a.withProfile(opts)
+	var setTplErrs []templateError
+	if err != nil {
+		setTplErrs = append(setTplErrs, templateError{Line: -1, Char: -1, Level: misunderstoodError,
+			Description: fmt.Sprintf("failed to understand the profile: %v", err)})
+	}
+	if _, err := a.presetFunctions(opts.FunctionPreset); err != nil {
+		setTplErrs = append(setTplErrs, templateError{Line: -1, Char: -1, Level: misunderstoodError,
+			Description: fmt.Sprintf("failed to understand the function preset: %v", err)})
+	}
+	if _, err := parseGoVersion(opts.GoVersion); err != nil {
+		setTplErrs = append(setTplErrs, templateError{Line: -1, Char: -1, Level: misunderstoodError,
+			Description: fmt.Sprintf("failed to understand the Go version: %v", err)})
+	}
+
+	if opts.ParseGlob {
+		sources = append([]source{}, sources...)
+		sort.SliceStable(sources, func(i, j int) bool { return sources[i].name < sources[j].name })
+	}
+	base, overrides, err := splitOverrides(sources, opts.Overrides)
+	if err != nil {
+		setTplErrs = append(setTplErrs, templateError{Line: -1, Char: -1, Level: misunderstoodError,
+			Description: fmt.Sprintf("failed to understand the overrides: %v", err)})
+	}
+	// a set asking for what isn't understood isn't executed
+	if len(setTplErrs) > 0 {
+		rawData = ""
+	}
+	fileTplErrs := make([][]templateError, len(sources))
+	if len(overrides) == 0 {
+		combinedTplErrs, combinedFileTplErrs := a.validateCombined(ctx, sources, rawData, rawFns, opts)
+		setTplErrs = append(setTplErrs, combinedTplErrs...)
+		fileTplErrs = combinedFileTplErrs
+	}
+	// the errors of the base files are the same with most overrides, they're only reported once
+	for _, override := range overrides {
+		combined := make([]source, 0, len(base)+1)
+		for _, i := range base {
+			combined = append(combined, sources[i])
+		}
+		combinedTplErrs, combinedFileTplErrs := a.validateCombined(ctx, append(combined, sources[override]), rawData,
+			rawFns, opts)
+		setTplErrs = sortErrors(append(setTplErrs, combinedTplErrs...))
+		for j, i := range append(base, override) {
+			fileTplErrs[i] = sortErrors(append(fileTplErrs[i], combinedFileTplErrs[j]...))
+		}
+	}
+
+	if len(setTplErrs) > 0 {
+		localizeErrors(setTplErrs, opts.Language)
+		results = append(results, fileResult{Errors: setTplErrs})
+	}
+	for i, src := range sources {
+		tplErrs := append(make([]templateError, 0), fileTplErrs[i]...)
+		explainErrors(tplErrs)
+		lines := SplitVisualLines(src.text)
+		toVisualLocations(tplErrs, src.text)
+		convertColumns(tplErrs, lines, opts.ColumnUnit, opts.TabWidth)
+		localizeErrors(tplErrs, opts.Language)
+		stats := statistics(src.text)
+		results = append(results, fileResult{File: src.name, Errors: tplErrs, Stats: &stats, Lines: lines,
+			Highlighted: highlightLines(src.text)})
+	}
+	return results
+}
+
+// splitOverrides returns the indexes of the sources which don't match the comma separated path patterns overrides,
+// and of those which do
+func splitOverrides(sources []source, overrides string) (base, matching []int, err error) {
+	patterns := splitList(overrides)
+	for _, pattern := range patterns {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, nil, fmt.Errorf("bad pattern %q: %v", pattern, err)
+		}
+	}
+	for i, src := range sources {
+		matched := false
+		for _, pattern := range patterns {
+			if ok, _ := path.Match(pattern, src.name); ok {
+				matched = true
+			}
+		}
+		if matched {
+			matching = append(matching, i)
+		} else {
+			base = append(base, i)
+		}
+	}
+	return base, matching, nil
+}
+
+// validateCombined parses the sources as one set and executes it, returning the errors of the set and of each source
+func (a *App) validateCombined(ctx context.Context, sources []source, rawData, rawFns string, opts options) ([]templateError, [][]templateError) {
+	var collisionTplErrs [][]templateError
+	parsed := sources
+	if opts.ParseGlob {
+		parsed, collisionTplErrs = globSources(sources)
+	}
+
+	var name string
+	if len(parsed) > 0 {
+		name = parsed[0].name
+	}
+	// a preset which isn't understood is reported by validateSet
+	presetFns, _ := a.presetFunctions(opts.FunctionPreset)
+	t, setTplErrs := newSetTemplate(name, presetFns, rawFns, opts.MissingKey)
+	_, fileTplErrs := parseSet(ctx, parsed, t)
+	// the errors of a template belong to the last file parsed as it
+	index := make(map[string]int, len(parsed))
+	for i, src := range parsed {
+		index[src.name] = i
+	}
+	for _, tplErr := range a.policy.check(t, nil) {
+		if i, ok := index[tplErr.File]; ok {
+			fileTplErrs[i] = sortErrors(append(fileTplErrs[i], tplErr))
+		}
+	}
+	// a Go version which isn't understood is reported by validateSet
+	goVersion, _ := parseGoVersion(opts.GoVersion)
+	for i, src := range parsed {
+		if tplErrs := goVersionSyntaxErrors(src.name, src.text, goVersion); len(tplErrs) > 0 {
+			fileTplErrs[i] = sortErrors(append(fileTplErrs[i], tplErrs...))
+		}
+	}
+	if rawData != "" && len(setTplErrs) == 0 && !anyErrors(fileTplErrs) {
+		for _, tplErr := range a.executeSet(ctx, t, rawData, opts) {
+			if i, ok := index[tplErr.File]; ok {
+				fileTplErrs[i] = append(fileTplErrs[i], tplErr)
+			} else {
+				setTplErrs = append(setTplErrs, tplErr)
+			}
+		}
+	}
+	for i := range collisionTplErrs {
+		fileTplErrs[i] = append(collisionTplErrs[i], fileTplErrs[i]...)
+	}
+	return setTplErrs, fileTplErrs
+}
+
+// globSources returns sources named like ParseGlob and ParseFS name the templates of files, by their base names, with
+// warnings for each file whose template has the name of another's. The last one parsed replaces the others, which
+// only keep the templates they define.
+func globSources(sources []source) ([]source, [][]templateError) {
+	parsed := make([]source, len(sources))
+	last := make(map[string]int, len(sources))
+	for i, src := range sources {
+		parsed[i] = source{name: path.Base(src.name), text: src.text}
+		last[parsed[i].name] = i
+	}
+	tplErrs := make([][]templateError, len(sources))
+	warn := func(i int, description string) {
+		tplErrs[i] = append(tplErrs[i], templateError{File: parsed[i].name, Line: -1, Char: -1,
+			Description: description, Level: lintErrorLevel, Severity: warningSeverity})
+	}
+	for i, src := range parsed {
+		if last[src.name] != i {
+			warn(i, fmt.Sprintf("parsed as %q, like ParseGlob names it, and replaced by %s", src.name,
+				sources[last[src.name]].name))
+			continue
+		}
+		for j := 0; j < i; j++ {
+			if parsed[j].name == src.name {
+				warn(i, fmt.Sprintf("parsed as %q, like ParseGlob names it, replacing %s", src.name, sources[j].name))
+			}
+		}
+	}
+	return parsed, tplErrs
+}
+
+// executeSet executes the root template of t, or the entry template of opts, with rawData, within the limits of opts
+func (a *App) executeSet(ctx context.Context, t *textTemplate.Template, rawData string, opts options) []templateError {
+	data, err := decodeDataFormat(rawData, opts.DataFormat)
+	if err != nil {
+		return dataTemplateErrors(rawData, err)
+	}
+	opts.MaxSteps = clampLimit(opts.MaxSteps, defaultMaxSteps, a.maxSteps)
+	opts.MaxIterations = clampLimit(opts.MaxIterations, defaultMaxIterations, a.maxIterations)
+	opts.MaxOutput = clampLimit(opts.MaxOutput, defaultMaxOutput, a.maxOutput)
+	// parsing the first source replaced the empty root template of the same name, which cloning would bring back
+	name := t.Name()
+	if opts.Entry != "" {
+		name = opts.Entry
+	}
+	root := t.Lookup(name)
+	if root == nil {
+		return []templateError{{Line: -1, Char: -1, Level: misunderstoodError,
+			Description: fmt.Sprintf("failed to understand the template to execute: %q isn't defined", name)}}
+	}
+	limitedT, err := limitTemplate(ctx, root, opts.MaxSteps, opts.MaxIterations)
+	if err != nil {
+		limitedT = root
+	}
+	execRetries := 0
+	if opts.AllExecErrors {
+		execRetries = maxExecFixes
+	}
+	tplErrs := execCollect(ctx, limitedT, data, newCappedBuffer(opts.MaxOutput), execRetries)
+	describeLimitErrors(tplErrs)
+	return tplErrs
+}
+
+// anyErrors is whether any of the lists of errors has one
+func anyErrors(tplErrs [][]templateError) bool {
+	for _, errs := range tplErrs {
+		if len(errs) > 0 {
+			return true
+		}
+	}
+	return false
+}
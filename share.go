@@ -0,0 +1,437 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	htmlTemplate "html/template"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/skip2/go-qrcode"
+)
+
+// shareIDRegex is what the ids of shares look like, so they're safe file names
+var shareIDRegex = regexp.MustCompile(`^[a-z0-9-]+$`)
+
+// shareSession is what's shared: a template with the data, functions and options it was validated with
+type shareSession struct {
+	Template  string  `json:"template"`
+	Data      string  `json:"data,omitempty"`
+	Functions string  `json:"functions,omitempty"`
+	Options   options `json:"options"`
+}
+
+// storedShare is a share as it's saved, the session is encrypted if there's a key or passphrase
+type storedShare struct {
+	Expires time.Time `json:"expires"`
+	// ViewsLeft are how many more times it can be viewed, 0 if that's not limited
+	ViewsLeft int             `json:"views_left,omitempty"`
+	Session   json.RawMessage `json:"session"`
+}
+
+// shareLink is what sharing responds with
+type shareLink struct {
+	ID        string    `json:"id"`
+	URL       string    `json:"url"`
+	Expires   time.Time `json:"expires"`
+	ViewsLeft int       `json:"views_left,omitempty"`
+	// QR is the URL of the QR code of URL
+	QR string `json:"qr"`
+}
+
+// the sizes of QR codes in pixels, which the size form value chooses between
+const (
+	defaultQRSize = 256
+	minQRSize     = 64
+	maxQRSize     = 1024
+)
+
+// a client asking for more than maxShareMisses shares which aren't kept within shareMissWindow is refused until it
+// ends, so slugs can't be guessed
+const (
+	maxShareMisses  = 20
+	shareMissWindow = time.Minute
+)
+
+var errShareNotFound = errors.New("share not found, it may have expired")
+
+// shareMisses counts the shares a client asked for which aren't kept, in the window since start
+type shareMisses struct {
+	start time.Time
+	count int
+}
+
+// shareStore keeps shares as JSON files in a directory until they expire, encrypted if it has a key. A nil store
+// keeps none.
+type shareStore struct {
+	mu  sync.Mutex
+	dir string
+	// key encrypts the sessions, they're saved in plaintext if it's nil
+	key []byte
+	// maxTTL is the longest shares are kept
+	maxTTL time.Duration
+	// misses are of the clients which asked for shares which aren't kept lately
+	misses map[string]shareMisses
+}
+
+func newShareStore(dir string, key []byte, maxTTL time.Duration) *shareStore {
+	return &shareStore{dir: dir, key: key, maxTTL: maxTTL, misses: make(map[string]shareMisses)}
+}
+
+func (s *shareStore) path(id string) string {
+	return filepath.Join(s.dir, id+".json")
+}
+
+// save saves session until ttl after now, or the store's longest if ttl is 0 or longer, and for views if it's not 0,
+// returning the id of the share, a slug which isn't taken by another
+func (s *shareStore) save(session shareSession, passphrase string, ttl time.Duration, views int, now time.Time) (string, storedShare, error) {
+	if s == nil {
+		return "", storedShare{}, errors.New("shares aren't kept")
+	}
+	if ttl <= 0 || ttl > s.maxTTL {
+		ttl = s.maxTTL
+	}
+	b, err := json.Marshal(session)
+	if err != nil {
+		return "", storedShare{}, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := os.MkdirAll(s.dir, 0755); err != nil {
+		return "", storedShare{}, err
+	}
+	id, err := newSlug(func(slug string) bool {
+		_, err := os.Stat(s.path(slug))
+		return !os.IsNotExist(err)
+	})
+	if err != nil {
+		return "", storedShare{}, err
+	}
+	// the id is sealed with the session, so it has to be known first
+	if s.key != nil || passphrase != "" {
+		if b, err = seal(s.key, passphrase, id, b); err != nil {
+			return "", storedShare{}, err
+		}
+	}
+	stored := storedShare{Expires: now.Add(ttl).UTC(), ViewsLeft: views, Session: b}
+	return id, stored, s.write(id, stored)
+}
+
+func (s *shareStore) write(id string, stored storedShare) error {
+	b, err := json.MarshalIndent(stored, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(s.path(id), b, 0600)
+}
+
+// view loads the session of the share id, counting the view, and purges the share once it has no views left. Ids are
+// read in lower case, like they're generated, for those typed from a screenshot.
+func (s *shareStore) view(id, passphrase string, now time.Time) (shareSession, error) {
+	var session shareSession
+	id = strings.ToLower(id)
+	if s == nil || !shareIDRegex.MatchString(id) {
+		return session, errShareNotFound
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stored, err := s.read(id, now)
+	if err != nil {
+		return session, err
+	}
+	b, err := unseal(s.key, passphrase, id, stored.Session)
+	if err != nil {
+		return session, err
+	}
+	if err := json.Unmarshal(b, &session); err != nil {
+		return session, err
+	}
+	switch stored.ViewsLeft {
+	case 0:
+	case 1:
+		err = os.Remove(s.path(id))
+	default:
+		stored.ViewsLeft--
+		err = s.write(id, stored)
+	}
+	return session, err
+}
+
+// exists returns errShareNotFound unless the share id is kept, without counting a view
+func (s *shareStore) exists(id string, now time.Time) error {
+	id = strings.ToLower(id)
+	if s == nil || !shareIDRegex.MatchString(id) {
+		return errShareNotFound
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err := s.read(id, now)
+	return err
+}
+
+// read reads the share id, purging it if it expired
+func (s *shareStore) read(id string, now time.Time) (storedShare, error) {
+	var stored storedShare
+	b, err := ioutil.ReadFile(s.path(id))
+	if os.IsNotExist(err) {
+		return stored, errShareNotFound
+	} else if err != nil {
+		return stored, err
+	}
+	if err := json.Unmarshal(b, &stored); err != nil {
+		return stored, err
+	}
+	if !now.Before(stored.Expires) {
+		if err := os.Remove(s.path(id)); err != nil {
+			return stored, err
+		}
+		return stored, errShareNotFound
+	}
+	return stored, nil
+}
+
+// purge removes every share which expired by now, returning how many it removed
+func (s *shareStore) purge(now time.Time) (int, error) {
+	if s == nil {
+		return 0, nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	files, err := ioutil.ReadDir(s.dir)
+	if os.IsNotExist(err) {
+		return 0, nil
+	} else if err != nil {
+		return 0, err
+	}
+	purged := 0
+	for _, f := range files {
+		id := strings.TrimSuffix(f.Name(), ".json")
+		if id == f.Name() || !shareIDRegex.MatchString(id) {
+			continue
+		}
+		if _, err := s.read(id, now); err == errShareNotFound {
+			purged++
+		} else if err != nil {
+			return purged, err
+		}
+	}
+	return purged, nil
+}
+
+// guessing returns whether client asked for too many shares which aren't kept in the window of now
+func (s *shareStore) guessing(client string, now time.Time) bool {
+	if s == nil {
+		return false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	m := s.misses[client]
+	return m.count >= maxShareMisses && now.Before(m.start.Add(shareMissWindow))
+}
+
+// missed counts that client asked for a share which isn't kept
+func (s *shareStore) missed(client string, now time.Time) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	m := s.misses[client]
+	if !now.Before(m.start.Add(shareMissWindow)) {
+		m = shareMisses{start: now}
+	}
+	m.count++
+	s.misses[client] = m
+	if m.count == maxShareMisses {
+		log.Printf("%s asked for %d shares which aren't kept, refusing it for %v", client, m.count, shareMissWindow)
+	}
+}
+
+// forgetMisses forgets the clients whose windows ended by now
+func (s *shareStore) forgetMisses(now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for client, m := range s.misses {
+		if !now.Before(m.start.Add(shareMissWindow)) {
+			delete(s.misses, client)
+		}
+	}
+}
+
+// purgeEvery purges expired shares every interval, so they're not kept when nobody views them
+func (s *shareStore) purgeEvery(interval time.Duration) {
+	for now := range time.Tick(interval) {
+		if _, err := s.purge(now); err != nil {
+			log.Printf("failed to purge expired shares: %v", err)
+		}
+		s.forgetMisses(now)
+	}
+}
+
+// CreateShare saves the template, data, functions and options of the form as a share, encrypted with the passphrase
+// if there is one, serving the link to it as JSON. The ttl form value is how long it's kept, like 24h, and views how
+// many times it can be viewed. With the redirect form value, it redirects to the share instead, for the page's form.
+func (a *App) CreateShare(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseMultipartForm(maxRequestSize); err != nil && err != http.ErrNotMultipart {
+		http.Error(w, fmt.Sprintf("ParseMultipartForm error: %v", err), http.StatusBadRequest)
+		return
+	}
+	text, err := getText(r)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed to understand template: %v", err), http.StatusBadRequest)
+		return
+	}
+	rawData, err := getFormValue(r, "data")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var ttl time.Duration
+	if s := r.FormValue("ttl"); s != "" {
+		if ttl, err = time.ParseDuration(s); err != nil || ttl <= 0 {
+			http.Error(w, fmt.Sprintf("failed to understand ttl %q, it must be a duration like 24h", s), http.StatusBadRequest)
+			return
+		}
+	}
+	var views int
+	if s := r.FormValue("views"); s != "" {
+		if views, err = strconv.Atoi(s); err != nil || views < 0 {
+			http.Error(w, fmt.Sprintf("failed to understand views %q, it must be a number", s), http.StatusBadRequest)
+			return
+		}
+	}
+
+	session := shareSession{Template: text, Data: rawData, Functions: r.FormValue("functions"), Options: getOptions(r)}
+	id, stored, err := a.shares.save(session, getPassphrase(r), ttl, views, time.Now())
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed to save share: %v", err), http.StatusInternalServerError)
+		return
+	}
+	link := shareLink{ID: id, URL: shareURL(r, id), Expires: stored.Expires, ViewsLeft: stored.ViewsLeft}
+	link.QR = link.URL + "/qr.png"
+	if r.FormValue("redirect") != "" {
+		http.Redirect(w, r, link.URL, http.StatusSeeOther)
+		return
+	}
+	writeJSON(w, link)
+}
+
+// passphrasePage asks for the passphrase of a share, posting it back to the share
+var passphrasePage = htmlTemplate.Must(htmlTemplate.New("passphrase").Parse(`<!DOCTYPE html>
+<title>Go Template Validator</title>
+<form method="POST">
+    <p>{{.}}</p>
+    <label for="passphrase">Passphrase</label> <input type="password" id="passphrase" name="passphrase" autofocus>
+    <button type="submit">Open</button>
+</form>
+`))
+
+// shareClient returns who asked for a share, to count the shares it asked for which aren't kept
+func shareClient(r *http.Request) string {
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		return host
+	}
+	return r.RemoteAddr
+}
+
+// refuseGuessing responds 429 Too Many Requests and returns true if the client of r asked for too many shares which
+// aren't kept lately
+func (a *App) refuseGuessing(w http.ResponseWriter, r *http.Request) bool {
+	if !a.shares.guessing(shareClient(r), time.Now()) {
+		return false
+	}
+	http.Error(w, "too many shares not found, try again in a minute", http.StatusTooManyRequests)
+	return true
+}
+
+// shareNotFound responds 404 Not Found, counting the miss of the client of r
+func (a *App) shareNotFound(w http.ResponseWriter, r *http.Request) {
+	a.shares.missed(shareClient(r), time.Now())
+	http.Error(w, errShareNotFound.Error(), http.StatusNotFound)
+}
+
+// Share serves the page of a share, validating its session again. Shares with a passphrase ask for it.
+func (a *App) Share(w http.ResponseWriter, r *http.Request) {
+	if a.refuseGuessing(w, r) {
+		return
+	}
+	session, err := a.shares.view(chi.URLParam(r, "id"), getPassphrase(r), time.Now())
+	switch {
+	case err == errShareNotFound:
+		a.shareNotFound(w, r)
+		return
+	case err == errWrongPassphrase:
+		w.WriteHeader(http.StatusForbidden)
+		message := "This share is encrypted with a passphrase."
+		if getPassphrase(r) != "" {
+			message = "That's not the passphrase of this share."
+		}
+		if err := passphrasePage.Execute(w, message); err != nil {
+			log.Printf("failed to ask for the passphrase: %v", err)
+		}
+		return
+	case err != nil:
+		http.Error(w, fmt.Sprintf("failed to load share: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	data := a.forRequest().createData(r.Context(), session.Template, session.Data, session.Functions, session.Options)
+	data.Suites, _ = a.suites.names()
+	data.Share = shareURL(r, strings.ToLower(chi.URLParam(r, "id")))
+	w.Header().Add("X-XSS-Protection", "0")
+	if err := a.index.Execute(w, data); err != nil {
+		http.Error(w, fmt.Sprintf("Execute error: %v", err), http.StatusForbidden)
+	}
+}
+
+// ShareQR serves the link of a share as a QR code PNG, to open it on another device. The size form value is its width
+// in pixels. Serving it doesn't count as a view.
+func (a *App) ShareQR(w http.ResponseWriter, r *http.Request) {
+	if a.refuseGuessing(w, r) {
+		return
+	}
+	id := strings.ToLower(chi.URLParam(r, "id"))
+	if err := a.shares.exists(id, time.Now()); err == errShareNotFound {
+		a.shareNotFound(w, r)
+		return
+	} else if err != nil {
+		http.Error(w, fmt.Sprintf("failed to load share: %v", err), http.StatusInternalServerError)
+		return
+	}
+	size := defaultQRSize
+	if s := r.FormValue("size"); s != "" {
+		var err error
+		if size, err = strconv.Atoi(s); err != nil || size < minQRSize || size > maxQRSize {
+			http.Error(w, fmt.Sprintf("failed to understand size %q, it must be from %d to %d", s, minQRSize, maxQRSize), http.StatusBadRequest)
+			return
+		}
+	}
+	png, err := qrcode.Encode(shareURL(r, id), qrcode.Medium, size)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed to encode QR code: %v", err), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "image/png")
+	// the share expires, so the code shouldn't outlive it in a cache
+	w.Header().Set("Cache-Control", "no-store")
+	w.Write(png)
+}
+
+func shareURL(r *http.Request, id string) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	return fmt.Sprintf("%s://%s/s/%s", scheme, r.Host, id)
+}
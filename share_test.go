@@ -0,0 +1,212 @@
+package main
+
+import (
+	"encoding/json"
+	htmlTemplate "html/template"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi"
+)
+
+func TestShareStore(t *testing.T) {
+	s := newShareStore(t.TempDir(), make([]byte, 32), 24*time.Hour)
+	now := time.Date(2021, time.March, 4, 5, 6, 7, 0, time.UTC)
+	session := shareSession{Template: "Hi {{.Name}}", Data: `{"Name": "Bob"}`, Options: options{Strict: true}}
+
+	id, stored, err := s.save(session, "", time.Hour, 0, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b, err := os.ReadFile(s.path(id)); err != nil || strings.Contains(string(b), "Bob") {
+		t.Errorf("expected the session to be encrypted: %s, %v", b, err)
+	}
+	if expected := now.Add(time.Hour); !stored.Expires.Equal(expected) {
+		t.Errorf("expected expiry %v, actual %v", expected, stored.Expires)
+	}
+	for i := 0; i < 3; i++ {
+		if actual, err := s.view(id, "", now.Add(time.Minute)); err != nil || !reflect.DeepEqual(session, actual) {
+			t.Errorf("expected %+v, actual %+v, %v", session, actual, err)
+		}
+	}
+	if _, err := s.view(id, "", now.Add(time.Hour)); err != errShareNotFound {
+		t.Errorf("expected the share to expire, actual %v", err)
+	}
+	if _, err := os.Stat(s.path(id)); !os.IsNotExist(err) {
+		t.Errorf("expected the expired share to be purged: %v", err)
+	}
+
+	// a ttl longer than the store's is cut to it
+	if _, stored, _ := s.save(session, "", 48*time.Hour, 0, now); !stored.Expires.Equal(now.Add(24 * time.Hour)) {
+		t.Errorf("unexpected expiry %v", stored.Expires)
+	}
+
+	id, _, _ = s.save(session, "", 0, 2, now)
+	for i := 0; i < 2; i++ {
+		if _, err := s.view(id, "", now); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	}
+	if _, err := s.view(id, "", now); err != errShareNotFound {
+		t.Errorf("expected the share to be purged after its views, actual %v", err)
+	}
+
+	id, _, _ = s.save(session, "open sesame", 0, 1, now)
+	if _, err := s.view(id, "guess", now); err != errWrongPassphrase {
+		t.Errorf("unexpected error: %v", err)
+	}
+	// wrong passphrases don't use up views
+	if _, err := s.view(id, "open sesame", now); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	id, _, _ = s.save(session, "", 0, 0, now)
+	if _, err := s.view(strings.ToUpper(id), "", now); err != nil {
+		t.Errorf("unexpected error viewing %s in upper case: %v", id, err)
+	}
+
+	if _, err := s.view("../escape", "", now); err != errShareNotFound {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestPurgeShares(t *testing.T) {
+	s := newShareStore(t.TempDir(), nil, 24*time.Hour)
+	now := time.Date(2021, time.March, 4, 5, 6, 7, 0, time.UTC)
+	expiring, _, _ := s.save(shareSession{Template: "soon"}, "", time.Minute, 0, now)
+	kept, _, _ := s.save(shareSession{Template: "later"}, "", time.Hour, 0, now)
+	if purged, err := s.purge(now.Add(time.Minute)); err != nil || purged != 1 {
+		t.Errorf("unexpected purge: %d, %v", purged, err)
+	}
+	if _, err := os.Stat(filepath.Join(s.dir, expiring+".json")); !os.IsNotExist(err) {
+		t.Errorf("expected the expired share to be purged: %v", err)
+	}
+	if _, err := s.view(kept, "", now.Add(time.Minute)); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestShareEndpoints(t *testing.T) {
+	a := &App{index: htmlTemplate.Must(htmlTemplate.New("index.html").Parse("{{.Output}}")),
+		shares: newShareStore(t.TempDir(), nil, time.Hour)}
+	r := chi.NewRouter()
+	r.Post("/api/v1/shares", a.CreateShare)
+	r.Get("/s/{id}", a.Share)
+	r.Post("/s/{id}", a.Share)
+	do := func(method, path string, form url.Values) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(method, path, strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	w := do("POST", "/api/v1/shares", url.Values{"from-raw-text": {"Hi {{.Name}}"}, "data": {`{"Name": "Bob"}`},
+		"views": {"1"}, "passphrase": {"open sesame"}})
+	var link shareLink
+	if err := json.Unmarshal(w.Body.Bytes(), &link); err != nil {
+		t.Fatalf("unexpected response: %d %s", w.Code, w.Body)
+	}
+	if link.ViewsLeft != 1 || link.URL != "http://example.com/s/"+link.ID || link.QR != link.URL+"/qr.png" {
+		t.Errorf("unexpected link: %+v", link)
+	}
+	if w := do("GET", "/s/"+link.ID, nil); w.Code != http.StatusForbidden || !strings.Contains(w.Body.String(), "passphrase") {
+		t.Errorf("unexpected response: %d %s", w.Code, w.Body)
+	}
+	if w := do("POST", "/s/"+link.ID, url.Values{"passphrase": {"open sesame"}}); w.Body.String() != "Hi Bob" {
+		t.Errorf("unexpected response: %d %s", w.Code, w.Body)
+	}
+	if w := do("POST", "/s/"+link.ID, url.Values{"passphrase": {"open sesame"}}); w.Code != http.StatusNotFound {
+		t.Errorf("unexpected response: %d %s", w.Code, w.Body)
+	}
+
+	if w := do("POST", "/api/v1/shares", url.Values{"from-raw-text": {"Hi"}, "ttl": {"forever"}}); w.Code != http.StatusBadRequest {
+		t.Errorf("unexpected response: %d %s", w.Code, w.Body)
+	}
+	w = do("POST", "/api/v1/shares", url.Values{"from-raw-text": {"Hi"}, "redirect": {"1"}})
+	if location := w.Header().Get("Location"); w.Code != http.StatusSeeOther || !strings.HasPrefix(location, "http://example.com/s/") {
+		t.Errorf("unexpected response: %d %s", w.Code, location)
+	}
+}
+
+func TestShareMisses(t *testing.T) {
+	a := &App{shares: newShareStore(t.TempDir(), nil, time.Hour)}
+	r := chi.NewRouter()
+	r.Get("/s/{id}", a.Share)
+	get := func(path, remote string) int {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", path, nil)
+		req.RemoteAddr = remote
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+	for i := 0; i < maxShareMisses; i++ {
+		if code := get("/s/brave-otter-"+strconv.Itoa(i), "192.0.2.1:1234"); code != http.StatusNotFound {
+			t.Fatalf("unexpected response %d", code)
+		}
+	}
+	if code := get("/s/brave-otter-42", "192.0.2.1:5678"); code != http.StatusTooManyRequests {
+		t.Errorf("expected guessing to be refused, actual %d", code)
+	}
+	if code := get("/s/brave-otter-42", "192.0.2.2:1234"); code != http.StatusNotFound {
+		t.Errorf("expected another client not to be refused, actual %d", code)
+	}
+
+	if a.shares.guessing("192.0.2.1", time.Now().Add(shareMissWindow)) {
+		t.Error("expected the client to be refused only until the window ends")
+	}
+	a.shares.forgetMisses(time.Now().Add(shareMissWindow))
+	if len(a.shares.misses) != 0 {
+		t.Errorf("unexpected misses %v", a.shares.misses)
+	}
+}
+
+func TestShareQR(t *testing.T) {
+	a := &App{shares: newShareStore(t.TempDir(), nil, time.Hour)}
+	r := chi.NewRouter()
+	r.Get("/s/{id}/qr.png", a.ShareQR)
+	id, _, err := a.shares.save(shareSession{Template: "Hi"}, "", 0, 1, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/s/"+id+"/qr.png?size=128", nil))
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "image/png" {
+		t.Fatalf("unexpected response: %d %s", w.Code, w.Body)
+	}
+	img, err := png.Decode(w.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size := img.Bounds().Size(); size.X != 128 || size.Y != 128 {
+		t.Errorf("unexpected size %v", size)
+	}
+	// the code isn't a view
+	if _, err := a.shares.view(id, "", time.Now()); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	for _, path := range []string{"/s/" + id + "/qr.png", "/s/missing/qr.png"} {
+		w = httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		if w.Code != http.StatusNotFound {
+			t.Errorf("unexpected response to %s: %d %s", path, w.Code, w.Body)
+		}
+	}
+	id, _, _ = a.shares.save(shareSession{Template: "Hi"}, "", 0, 0, time.Now())
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/s/"+id+"/qr.png?size=1", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("unexpected response: %d %s", w.Code, w.Body)
+	}
+}
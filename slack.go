@@ -0,0 +1,122 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// slackMaxAge is how old a Slack request may be, older ones could be replayed
+	slackMaxAge = 5 * time.Minute
+	// slackMaxOutput is how much output a Slack message shows
+	slackMaxOutput = 2000
+)
+
+// codeBlockRegex matches the ``` code blocks of Slack messages
+var codeBlockRegex = regexp.MustCompile("(?s)```(.*?)```")
+
+// slackEscaper undoes the escaping Slack does to the text of commands
+var slackEscaper = strings.NewReplacer("&amp;", "&", "&lt;", "<", "&gt;", ">")
+
+// slackMessage is a response to a Slack slash command
+type slackMessage struct {
+	ResponseType string `json:"response_type"`
+	Text         string `json:"text"`
+}
+
+// SlackCommand validates the text of a Slack slash command. The command's text is the template, or its first code
+// block is the template and its second one the JSON data.
+func (a *App) SlackCommand(w http.ResponseWriter, r *http.Request) {
+	body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxRequestSize))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed to read request: %v", err), http.StatusBadRequest)
+		return
+	}
+	if err := verifySlackRequest(a.slackSecret, r.Header, body, time.Now()); err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	form, err := url.ParseQuery(string(body))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed to understand request: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	text, rawData := splitCodeBlocks(slackEscaper.Replace(form.Get("text")))
+	data := a.forRequest().createData(r.Context(), text, rawData, "", options{})
+	writeJSON(w, slackMessage{ResponseType: "in_channel", Text: formatSlackMessage(data)})
+}
+
+// verifySlackRequest checks that a request was signed by Slack with secret, see
+// https://api.slack.com/authentication/verifying-requests-from-slack
+func verifySlackRequest(secret string, header http.Header, body []byte, now time.Time) error {
+	timestamp := header.Get("X-Slack-Request-Timestamp")
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return fmt.Errorf("bad Slack request timestamp %q", timestamp)
+	}
+	if math.Abs(now.Sub(time.Unix(seconds, 0)).Seconds()) > slackMaxAge.Seconds() {
+		return errors.New("Slack request is too old")
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "v0:%s:", timestamp)
+	mac.Write(body)
+	expected := "v0=" + hex.EncodeToString(mac.Sum(nil))
+	if !hmac.Equal([]byte(expected), []byte(header.Get("X-Slack-Signature"))) {
+		return errors.New("bad Slack request signature")
+	}
+	return nil
+}
+
+// splitCodeBlocks finds the template and data in the text of a chat message: all of it is the template, unless it has
+// code blocks, then the first is the template and the second is the data
+func splitCodeBlocks(text string) (template, data string) {
+	blocks := codeBlockRegex.FindAllStringSubmatch(text, 2)
+	switch len(blocks) {
+	case 0:
+		return strings.TrimSpace(text), ""
+	case 1:
+		return blocks[0][1], ""
+	}
+	return blocks[0][1], blocks[1][1]
+}
+
+// formatSlackMessage formats the errors and output of validating a template as a Slack message
+func formatSlackMessage(data indexData) string {
+	var b bytes.Buffer
+	if len(data.Errors) == 0 {
+		b.WriteString("No errors found.\n")
+	} else {
+		fmt.Fprintf(&b, "*%s*\n```", countErrors(len(data.Errors)))
+		for _, tplErr := range data.Errors {
+			fmt.Fprintln(&b, formatError("template", tplErr))
+		}
+		b.WriteString("```\n")
+	}
+	if output := data.Output; output != "" {
+		if len(output) > slackMaxOutput {
+			output = output[:slackMaxOutput] + "…"
+		}
+		fmt.Fprintf(&b, "Output:\n```%s```", strings.ReplaceAll(output, "```", "` ` `"))
+	}
+	return b.String()
+}
+
+func countErrors(n int) string {
+	if n == 1 {
+		return "1 error found"
+	}
+	return fmt.Sprintf("%d errors found", n)
+}
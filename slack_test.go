@@ -0,0 +1,72 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func signSlackRequest(r *http.Request, secret, body string, at time.Time) {
+	timestamp := strconv.FormatInt(at.Unix(), 10)
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "v0:%s:%s", timestamp, body)
+	r.Header.Set("X-Slack-Request-Timestamp", timestamp)
+	r.Header.Set("X-Slack-Signature", "v0="+hex.EncodeToString(mac.Sum(nil)))
+}
+
+func TestSlackCommand(t *testing.T) {
+	body := url.Values{"text": {"```Hi {{.Name}}{{if}}```\n```{\"Name\": \"Bob\"}```"}}.Encode()
+	r := httptest.NewRequest("POST", "/slack/command", strings.NewReader(body))
+	signSlackRequest(r, "secret", body, time.Now())
+	w := httptest.NewRecorder()
+	(&App{slackSecret: "secret"}).SlackCommand(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("unexpected response: %d %s", w.Code, w.Body)
+	}
+	var message slackMessage
+	if err := json.Unmarshal(w.Body.Bytes(), &message); err != nil {
+		t.Fatal(err)
+	}
+	expected := "*2 errors found*\n```template:1: missing value for if [parse]\ntemplate:1:13: {{if}} has no matching {{end}} [parse]\n```\nOutput:\n```Hi Bob```"
+	if message.ResponseType != "in_channel" || message.Text != expected {
+		t.Errorf("expected `%s`, actual `%s`", expected, message.Text)
+	}
+}
+
+func TestSlackSignature(t *testing.T) {
+	now := time.Now()
+	for name, sign := range map[string]func(r *http.Request){
+		"unsigned":     func(r *http.Request) {},
+		"wrong secret": func(r *http.Request) { signSlackRequest(r, "guess", "text=x", now) },
+		"too old":      func(r *http.Request) { signSlackRequest(r, "secret", "text=x", now.Add(-time.Hour)) },
+	} {
+		r := httptest.NewRequest("POST", "/slack/command", strings.NewReader("text=x"))
+		sign(r)
+		w := httptest.NewRecorder()
+		(&App{slackSecret: "secret"}).SlackCommand(w, r)
+		if w.Code != http.StatusUnauthorized {
+			t.Errorf("%s: unexpected response: %d %s", name, w.Code, w.Body)
+		}
+	}
+}
+
+func TestSplitCodeBlocks(t *testing.T) {
+	for text, expected := range map[string][2]string{
+		" {{.}} ":                   {"{{.}}", ""},
+		"check ```{{.}}``` please":  {"{{.}}", ""},
+		"```{{.}}```\n```[1, 2]```": {"{{.}}", "[1, 2]"},
+	} {
+		if template, data := splitCodeBlocks(text); template != expected[0] || data != expected[1] {
+			t.Errorf("%q: expected %q, actual %q, %q", text, expected, template, data)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// slugAdjectives and slugNouns are the words of slugs, short and common so they can be said and typed without spelling
+// them out, with no two sounding alike
+var (
+	slugAdjectives = []string{
+		"amber", "bold", "brave", "brisk", "calm", "clever", "cosy", "crisp", "curly", "daring", "eager", "early",
+		"fancy", "fast", "fluffy", "fond", "gentle", "giant", "glad", "golden", "grand", "happy", "hidden", "honest",
+		"humble", "jolly", "keen", "kind", "lazy", "lively", "lucky", "merry", "mighty", "misty", "modern", "noble",
+		"odd", "patient", "plain", "polite", "proud", "quick", "quiet", "rapid", "rare", "rosy", "royal", "rusty",
+		"shiny", "silent", "silver", "simple", "sleepy", "smooth", "sunny", "swift", "tall", "tidy", "tiny", "vivid",
+		"warm", "wild", "wise", "witty",
+	}
+	slugNouns = []string{
+		"apple", "badger", "banjo", "beacon", "bison", "breeze", "canyon", "castle", "cedar", "cloud", "comet",
+		"coral", "otter", "daisy", "dolphin", "dragon", "falcon", "fern", "forest", "garden", "glacier", "harbor",
+		"hawk", "island", "jungle", "kettle", "koala", "lagoon", "lantern", "lemon", "lion", "maple", "meadow",
+		"melon", "moose", "mountain", "nebula", "oak", "ocean", "orchid", "panda", "parrot", "pebble", "penguin",
+		"pepper", "pine", "planet", "pony", "rabbit", "river", "robot", "rocket", "saddle", "salmon", "spruce",
+		"squirrel", "tiger", "tulip", "turtle", "valley", "violin", "walrus", "willow", "zebra",
+	}
+)
+
+const (
+	// maxSlugAttempts are how many slugs are tried before giving up on finding one which isn't taken
+	maxSlugAttempts = 20
+	// minSlugNumbers are how many numbers slugs have at first, for billions of slugs, too many to guess
+	minSlugNumbers = 1000000
+)
+
+// newSlug returns a random slug like brave-otter-421337 for which taken returns false. After a few collisions the
+// number gets longer, so there's always room for more.
+func newSlug(taken func(slug string) bool) (string, error) {
+	max := int64(minSlugNumbers)
+	for attempt := 0; attempt < maxSlugAttempts; attempt++ {
+		if attempt > 0 && attempt%5 == 0 {
+			max *= 10
+		}
+		adjective, err := rand.Int(rand.Reader, big.NewInt(int64(len(slugAdjectives))))
+		if err != nil {
+			return "", err
+		}
+		noun, err := rand.Int(rand.Reader, big.NewInt(int64(len(slugNouns))))
+		if err != nil {
+			return "", err
+		}
+		number, err := rand.Int(rand.Reader, big.NewInt(max))
+		if err != nil {
+			return "", err
+		}
+		slug := fmt.Sprintf("%s-%s-%d", slugAdjectives[adjective.Int64()], slugNouns[noun.Int64()], number.Int64())
+		if !taken(slug) {
+			return slug, nil
+		}
+	}
+	return "", errors.New("every slug tried is taken")
+}
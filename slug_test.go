@@ -0,0 +1,34 @@
+package main
+
+import (
+	"regexp"
+	"testing"
+)
+
+func TestNewSlug(t *testing.T) {
+	words := make(map[string]bool)
+	for _, word := range append(append([]string{}, slugAdjectives...), slugNouns...) {
+		if words[word] {
+			t.Errorf("%s is in the words twice", word)
+		}
+		words[word] = true
+	}
+
+	slugRegex := regexp.MustCompile(`^[a-z]+-[a-z]+-[0-9]+$`)
+	taken := make(map[string]bool)
+	for i := 0; i < 100; i++ {
+		slug, err := newSlug(func(slug string) bool { return taken[slug] })
+		if err != nil {
+			t.Fatal(err)
+		}
+		if taken[slug] || !slugRegex.MatchString(slug) || !shareIDRegex.MatchString(slug) {
+			t.Errorf("unexpected slug %q", slug)
+		}
+		taken[slug] = true
+	}
+
+	attempts := 0
+	if _, err := newSlug(func(string) bool { attempts++; return true }); err == nil || attempts != maxSlugAttempts {
+		t.Errorf("expected giving up after %d attempts, actual %d, %v", maxSlugAttempts, attempts, err)
+	}
+}
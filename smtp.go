@@ -0,0 +1,79 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strings"
+)
+
+var errSMTPNotConfigured = errors.New("sending emails isn't configured, start the server with -smtp")
+
+// smtpRelay sends rendered emails to test addresses through a configured relay
+type smtpRelay struct {
+	// addr is the host:port of the relay
+	addr string
+	from *mail.Address
+	// allowed are the addresses, and @domains, emails may be sent to so the server can't be used to send spam
+	allowed []string
+	// auth is nil when the relay doesn't need authentication
+	auth smtp.Auth
+	// sendMail is smtp.SendMail, except in tests
+	sendMail func(addr string, a smtp.Auth, from string, to []string, msg []byte) error
+}
+
+// newSMTPRelay returns a relay sending from the address from through addr to the allowed addresses, or nil if addr is
+// empty. The user and password are optional, PLAIN authentication needs TLS unless the relay is on localhost.
+func newSMTPRelay(addr, from string, allowed []string, user, password string) (*smtpRelay, error) {
+	if addr == "" {
+		return nil, nil
+	}
+	if len(allowed) == 0 {
+		return nil, errors.New("sending emails needs the addresses they may be sent to")
+	}
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, fmt.Errorf("bad SMTP relay %q: %v", addr, err)
+	}
+	fromAddress, err := mail.ParseAddress(from)
+	if err != nil {
+		return nil, fmt.Errorf("bad SMTP from address %q: %v", from, err)
+	}
+	s := &smtpRelay{addr: addr, from: fromAddress, allowed: allowed, sendMail: smtp.SendMail}
+	if user != "" {
+		s.auth = smtp.PlainAuth("", user, password, host)
+	}
+	return s, nil
+}
+
+// send sends email to the single address to
+func (s *smtpRelay) send(to string, email *emailPreview) error {
+	if s == nil {
+		return errSMTPNotConfigured
+	}
+	if email == nil {
+		return errors.New("only email templates can be sent, render one in email mode")
+	}
+	toAddress, err := mail.ParseAddress(to)
+	if err != nil {
+		return fmt.Errorf("bad address %q: %v", to, err)
+	}
+	if !s.allows(toAddress.Address) {
+		return fmt.Errorf("emails can't be sent to %s, only to %s", toAddress.Address, strings.Join(s.allowed, ", "))
+	}
+	msg := fmt.Sprintf("From: %s\r\nTo: %s\r\n%s", s.from, toAddress, email.message())
+	return s.sendMail(s.addr, s.auth, s.from.Address, []string{toAddress.Address}, []byte(msg))
+}
+
+func (s *smtpRelay) allows(address string) bool {
+	address = strings.ToLower(address)
+	for _, allowed := range s.allowed {
+		allowed = strings.ToLower(allowed)
+		if address == allowed || strings.HasPrefix(allowed, "@") && strings.HasSuffix(address, allowed) {
+			return true
+		}
+	}
+	return false
+}
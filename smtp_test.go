@@ -0,0 +1,75 @@
+package main
+
+import (
+	"net/smtp"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSMTPSend(t *testing.T) {
+	relay, err := newSMTPRelay("localhost:25", "Validator <validator@example.com>", []string{"@example.org"}, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sentFrom string
+	var sentTo []string
+	var sent string
+	relay.sendMail = func(addr string, a smtp.Auth, from string, to []string, msg []byte) error {
+		sentFrom, sentTo, sent = from, to, string(msg)
+		return nil
+	}
+
+	email := &emailPreview{Subject: "Hi", Text: "Hello"}
+	if err := relay.send("Tester <Tester@EXAMPLE.org>", email); err != nil {
+		t.Fatal(err)
+	}
+	if sentFrom != "validator@example.com" || !reflect.DeepEqual(sentTo, []string{"Tester@EXAMPLE.org"}) {
+		t.Errorf("unexpected envelope from %q to %q", sentFrom, sentTo)
+	}
+	expected := "From: \"Validator\" <validator@example.com>\r\nTo: \"Tester\" <Tester@EXAMPLE.org>\r\nSubject: Hi\r\n"
+	if !strings.HasPrefix(sent, expected) || !strings.HasSuffix(sent, "Hello") {
+		t.Errorf("unexpected message %q", sent)
+	}
+}
+
+func TestSMTPSendRefused(t *testing.T) {
+	relay, err := newSMTPRelay("localhost:25", "validator@example.com", []string{"test@example.org"}, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	relay.sendMail = func(string, smtp.Auth, string, []string, []byte) error {
+		t.Error("shouldn't send")
+		return nil
+	}
+	email := &emailPreview{Subject: "Hi", Text: "Hello"}
+
+	tests := []struct {
+		relay *smtpRelay
+		to    string
+		email *emailPreview
+	}{
+		{nil, "test@example.org", email},
+		{relay, "test@example.org", nil},
+		{relay, "not an address", email},
+		{relay, "other@example.org", email},
+		{relay, "test@example.org.evil.com", email},
+	}
+	for _, test := range tests {
+		if err := test.relay.send(test.to, test.email); err == nil {
+			t.Errorf("expected sending to %q to fail", test.to)
+		}
+	}
+}
+
+func TestNewSMTPRelay(t *testing.T) {
+	if relay, err := newSMTPRelay("", "", nil, "", ""); relay != nil || err != nil {
+		t.Errorf("expected no relay without an address, got %v %v", relay, err)
+	}
+	if _, err := newSMTPRelay("localhost:25", "validator@example.com", nil, "", ""); err == nil {
+		t.Error("expected an error without allowed addresses")
+	}
+	if _, err := newSMTPRelay("localhost", "validator@example.com", []string{"@example.org"}, "", ""); err == nil {
+		t.Error("expected an error without a port")
+	}
+}
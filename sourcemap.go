@@ -0,0 +1,127 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"text/template"
+	templateParse "text/template/parse"
+)
+
+// sourceMapFunction is called before every node of a template being mapped which outputs something, it can't clash
+// with a function a template could call because it starts with an underscore
+const sourceMapFunction = "_source"
+
+// outputSpan is a part of the output, and where the node of the template which output it is
+type outputSpan struct {
+	// Start and End are byte offsets into the output
+	Start int    `json:"start"`
+	End   int    `json:"end"`
+	Text  string `json:"text"`
+	// Template is the name of the template the node is in
+	Template string `json:"template"`
+	// Line and Char are the node's position in the text, -1 for output no node output, like a note it was truncated
+	Line int `json:"line"`
+	Char int `json:"char"`
+}
+
+// sourceMapper records which node each part of the output is from
+type sourceMapper struct {
+	out *cappedBuffer
+	// nodes are what each call to sourceMapFunction is for, by its argument, and trees the trees they're in
+	nodes []templateParse.Node
+	trees []*templateParse.Tree
+	// marks are the calls to sourceMapFunction in the order they're made, with the size of the output then
+	marks []sourceMark
+}
+
+type sourceMark struct {
+	offset int
+	node   int
+}
+
+// mapSource executes t, which executed with data into out without errors, again to find the node of text each part of
+// out is from. It's nil if executing doesn't output the same again.
+func mapSource(t *template.Template, data interface{}, text string, out *cappedBuffer) []outputSpan {
+	c, err := copyTemplate(t)
+	if err != nil {
+		return nil
+	}
+	m := &sourceMapper{out: newCappedBuffer(len(out.kept()))}
+	for _, tree := range trees(c) {
+		m.instrument(tree, tree.Root)
+	}
+	// it executed within the limits already, so it does again
+	c = c.Funcs(template.FuncMap{sourceMapFunction: m.mark})
+	if err := c.Execute(m.out, data); err != nil || m.out.kept() != out.kept() {
+		return nil
+	}
+
+	kept := out.kept()
+	var spans []outputSpan
+	for i, mark := range m.marks {
+		end := m.out.size
+		if i+1 < len(m.marks) {
+			end = m.marks[i+1].offset
+		}
+		start := min(mark.offset, len(kept))
+		if end = min(end, len(kept)); start == end {
+			continue
+		}
+		tree := m.trees[mark.node]
+		line, char := visualPosition(text, int(m.nodes[mark.node].Position()))
+		// the iterations of a range output the same node again and again
+		if last := len(spans) - 1; last >= 0 && spans[last].End == start && spans[last].Template == tree.Name &&
+			spans[last].Line == line && spans[last].Char == char {
+			spans[last].End, spans[last].Text = end, kept[spans[last].Start:end]
+			continue
+		}
+		spans = append(spans, outputSpan{Start: start, End: end, Text: kept[start:end], Template: tree.Name,
+			Line: line, Char: char})
+	}
+	if rest := out.String()[len(kept):]; rest != "" {
+		spans = append(spans, outputSpan{Start: len(kept), End: len(kept) + len(rest), Text: rest, Line: -1, Char: -1})
+	}
+	return spans
+}
+
+// instrument adds calls to sourceMapFunction before every node in list and its descendants which outputs something
+func (m *sourceMapper) instrument(tree *templateParse.Tree, list *templateParse.ListNode) {
+	if list == nil {
+		return
+	}
+	nodes := make([]templateParse.Node, 0, 2*len(list.Nodes))
+	for _, node := range list.Nodes {
+		switch n := node.(type) {
+		case *templateParse.TextNode, *templateParse.ActionNode, *templateParse.TemplateNode:
+			nodes = append(nodes, m.call(tree, node))
+		case *templateParse.IfNode:
+			m.instrument(tree, n.List)
+			m.instrument(tree, n.ElseList)
+		case *templateParse.WithNode:
+			m.instrument(tree, n.List)
+			m.instrument(tree, n.ElseList)
+		case *templateParse.RangeNode:
+			m.instrument(tree, n.List)
+			m.instrument(tree, n.ElseList)
+		}
+		nodes = append(nodes, node)
+	}
+	list.Nodes = nodes
+}
+
+func (m *sourceMapper) call(tree *templateParse.Tree, node templateParse.Node) templateParse.Node {
+	arg := strconv.Itoa(len(m.nodes))
+	m.nodes = append(m.nodes, node)
+	m.trees = append(m.trees, tree)
+	return callAt(tree, node, sourceMapFunction, m.mark, arg)
+}
+
+// mark is sourceMapFunction, it outputs nothing
+func (m *sourceMapper) mark(arg string) (string, error) {
+	i, err := strconv.Atoi(arg)
+	if err != nil || i < 0 || i >= len(m.nodes) {
+		return "", fmt.Errorf("unknown node %q", arg)
+	}
+	m.marks = append(m.marks, sourceMark{offset: m.out.size, node: i})
+	return "", nil
+}
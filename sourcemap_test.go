@@ -0,0 +1,39 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestCreateDataSourceMap(t *testing.T) {
+	text := "{{define \"item\"}}<li>{{.}}</li>{{end}}<ul>\n{{range .}}{{template \"item\" .}}{{end}}</ul>"
+	data := (&App{}).createData(context.Background(), text, `["a", "b"]`, "", options{SourceMap: true})
+	if len(data.Errors) > 0 {
+		t.Fatalf("unexpected errors %+v", data.Errors)
+	}
+	expected := []outputSpan{
+		{Start: 0, End: 5, Text: "<ul>\n", Template: inputTemplateName, Line: 0, Char: 38},
+		{Start: 5, End: 9, Text: "<li>", Template: "item", Line: 0, Char: 17},
+		{Start: 9, End: 10, Text: "a", Template: "item", Line: 0, Char: 23},
+		{Start: 10, End: 15, Text: "</li>", Template: "item", Line: 0, Char: 26},
+		{Start: 15, End: 19, Text: "<li>", Template: "item", Line: 0, Char: 17},
+		{Start: 19, End: 20, Text: "b", Template: "item", Line: 0, Char: 23},
+		{Start: 20, End: 25, Text: "</li>", Template: "item", Line: 0, Char: 26},
+		{Start: 25, End: 30, Text: "</ul>", Template: inputTemplateName, Line: 1, Char: 39},
+	}
+	if !reflect.DeepEqual(expected, data.SourceMap) {
+		t.Errorf("expected %+v, actual %+v", expected, data.SourceMap)
+	}
+
+	// the note that the output was truncated isn't from the template
+	data = (&App{}).createData(context.Background(), "{{.}}{{.}}", `"abc"`, "", options{SourceMap: true, MaxOutput: 4})
+	expected = []outputSpan{
+		{Start: 0, End: 3, Text: "abc", Template: inputTemplateName, Line: 0, Char: 2},
+		{Start: 3, End: 4, Text: "a", Template: inputTemplateName, Line: 0, Char: 7},
+		{Start: 4, End: 47, Text: "\n… output truncated, showing 4 of 6 bytes", Line: -1, Char: -1},
+	}
+	if !reflect.DeepEqual(expected, data.SourceMap) {
+		t.Errorf("expected %+v, actual %+v", expected, data.SourceMap)
+	}
+}
@@ -0,0 +1,312 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi"
+)
+
+// suiteNameRegex is what suites can be named, so their name is a safe file name
+var suiteNameRegex = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// suite is a template along with cases of data to execute it with and what to expect from the output
+type suite struct {
+	Name     string `json:"name"`
+	Template string `json:"template"`
+	// TemplateB64 is the template encoded as base64 when saving, instead of Template, so it doesn't have to be escaped
+	TemplateB64 []byte      `json:"template_b64,omitempty"`
+	Functions   string      `json:"functions,omitempty"`
+	Cases       []suiteCase `json:"cases"`
+}
+
+type suiteCase struct {
+	Name string `json:"name"`
+	// Data is the JSON data to execute with
+	Data string `json:"data,omitempty"`
+	// DataB64 is the data encoded as base64 when saving, instead of Data
+	DataB64 []byte `json:"data_b64,omitempty"`
+	// Expected is the output, if it must be exactly that
+	Expected *string `json:"expected,omitempty"`
+	// Assertions are the output's properties, like the assertions of the form
+	Assertions json.RawMessage `json:"assertions,omitempty"`
+}
+
+// caseResult is whether executing a case did what was expected
+type caseResult struct {
+	Name   string          `json:"name"`
+	Passed bool            `json:"passed"`
+	Output string          `json:"output"`
+	Errors []templateError `json:"errors"`
+	// Diff is the difference from the expected output to the output
+	Diff string `json:"diff,omitempty"`
+}
+
+var errSuiteNotFound = errors.New("suite not found")
+
+// suiteStore keeps suites as JSON files in a directory, encrypted if it has a key. A nil store keeps none.
+type suiteStore struct {
+	mu  sync.Mutex
+	dir string
+	// key encrypts the suites, they're saved in plaintext if it's nil
+	key []byte
+}
+
+func newSuiteStore(dir string, key []byte) *suiteStore {
+	return &suiteStore{dir: dir, key: key}
+}
+
+func (s *suiteStore) path(name string) string {
+	return filepath.Join(s.dir, name+".json")
+}
+
+// save saves su, encrypted with the store's key and passphrase if it isn't empty. A suite saved with a passphrase can
+// only be replaced with it.
+func (s *suiteStore) save(su suite, passphrase string) error {
+	if s == nil {
+		return errors.New("suites aren't kept")
+	}
+	if !suiteNameRegex.MatchString(su.Name) {
+		return fmt.Errorf("suite names can only have letters, digits, - and _, not %q", su.Name)
+	}
+	b, err := json.MarshalIndent(su, "", "  ")
+	if err != nil {
+		return err
+	}
+	if s.key != nil || passphrase != "" {
+		if b, err = seal(s.key, passphrase, su.Name, b); err != nil {
+			return err
+		}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if saved, err := os.ReadFile(s.path(su.Name)); err == nil && isLocked(saved) {
+		if _, err := unseal(s.key, passphrase, su.Name, saved); err != nil {
+			return err
+		}
+	}
+	if err := os.MkdirAll(s.dir, 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(s.path(su.Name), b, 0600)
+}
+
+// load loads the suite name, decrypting it with the store's key and passphrase if it was saved with one
+func (s *suiteStore) load(name, passphrase string) (suite, error) {
+	var su suite
+	if s == nil || !suiteNameRegex.MatchString(name) {
+		return su, errSuiteNotFound
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b, err := os.ReadFile(s.path(name))
+	if os.IsNotExist(err) {
+		return su, errSuiteNotFound
+	} else if err != nil {
+		return su, err
+	}
+	if b, err = unseal(s.key, passphrase, name, b); err != nil {
+		return su, err
+	}
+	err = json.Unmarshal(b, &su)
+	return su, err
+}
+
+// names returns the names of every saved suite, in order
+func (s *suiteStore) names() ([]string, error) {
+	if s == nil {
+		return nil, nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	files, err := os.ReadDir(s.dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, f := range files {
+		if name := strings.TrimSuffix(f.Name(), ".json"); name != f.Name() && suiteNameRegex.MatchString(name) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// runSuite validates every case of su. A case passes when it has no errors, other than lint errors below error
+// severity, and its output is what was expected.
+func (a *App) runSuite(ctx context.Context, su suite) []caseResult {
+	results := make([]caseResult, len(su.Cases))
+	for i, c := range su.Cases {
+		ca := a.forRequest()
+		data := ca.createData(ctx, su.Template, c.Data, su.Functions, options{Assertions: string(c.Assertions)})
+		result := caseResult{Name: c.Name, Passed: true, Output: data.Output, Errors: data.Errors}
+		for _, tplErr := range data.Errors {
+			if isFailure(tplErr) {
+				result.Passed = false
+			}
+		}
+		if c.Expected != nil && *c.Expected != data.Output {
+			result.Passed = false
+			result.Diff = unifiedDiff("output", *c.Expected, data.Output)
+		}
+		results[i] = result
+	}
+	return results
+}
+
+// isFailure returns whether tplErr fails a validation, which lint errors below error severity don't
+func isFailure(tplErr templateError) bool {
+	return tplErr.Level != lintErrorLevel || tplErr.Severity == errorSeverity
+}
+
+// Suites serves the names of the saved suites as JSON
+func (a *App) Suites(w http.ResponseWriter, r *http.Request) {
+	names, err := a.suites.names()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed to list suites: %v", err), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, names)
+}
+
+// Suite serves a saved suite as JSON
+func (a *App) Suite(w http.ResponseWriter, r *http.Request) {
+	su, err := a.suites.load(chi.URLParam(r, "name"), getPassphrase(r))
+	if err != nil {
+		suiteError(w, err)
+		return
+	}
+	writeJSON(w, su)
+}
+
+// SaveSuite saves the suite in the request body, named by the URL
+func (a *App) SaveSuite(w http.ResponseWriter, r *http.Request) {
+	var su suite
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestSize)).Decode(&su); err != nil {
+		http.Error(w, fmt.Sprintf("failed to understand suite: %v", err), http.StatusBadRequest)
+		return
+	}
+	su.Name = chi.URLParam(r, "name")
+	// suites are saved decoded, like they're served
+	if su.TemplateB64 != nil {
+		su.Template, su.TemplateB64 = string(su.TemplateB64), nil
+	}
+	for i, c := range su.Cases {
+		if c.DataB64 != nil {
+			su.Cases[i].Data, su.Cases[i].DataB64 = string(c.DataB64), nil
+		}
+	}
+	if err := a.suites.save(su, getPassphrase(r)); err == errWrongPassphrase {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	} else if err != nil {
+		http.Error(w, fmt.Sprintf("failed to save suite: %v", err), http.StatusBadRequest)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// asyncSuites runs suites in the background, a few at once and each for a limited time, as nobody waits for them
+type asyncSuites struct {
+	slots   chan struct{}
+	timeout time.Duration
+}
+
+func newAsyncSuites(n int, timeout time.Duration) *asyncSuites {
+	return &asyncSuites{slots: make(chan struct{}, n), timeout: timeout}
+}
+
+// start runs run in the background, with a context which ends after the timeout, unless as many runs as there are
+// slots are running already, which it returns false for
+func (s *asyncSuites) start(run func(ctx context.Context)) bool {
+	if s == nil {
+		return false
+	}
+	select {
+	case s.slots <- struct{}{}:
+	default:
+		return false
+	}
+	go func() {
+		defer func() { <-s.slots }()
+		// the request is over long before the suite
+		ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+		defer cancel()
+		run(ctx)
+	}()
+	return true
+}
+
+// RunSuite runs a saved suite, serving the result of each case as JSON. With the async form value, it responds right
+// away and the webhook is notified when the suite finishes instead, unless too many suites run in the background.
+func (a *App) RunSuite(w http.ResponseWriter, r *http.Request) {
+	su, err := a.suites.load(chi.URLParam(r, "name"), getPassphrase(r))
+	if err != nil {
+		suiteError(w, err)
+		return
+	}
+	link := suiteLink(r, su.Name)
+
+	if r.FormValue("async") != "" {
+		if a.webhook == "" {
+			http.Error(w, "running suites asynchronously needs a webhook to notify", http.StatusBadRequest)
+			return
+		}
+		started := a.asyncSuites.start(func(ctx context.Context) {
+			a.notify(ctx, summarize(su.Name, a.runSuite(ctx, su), link))
+		})
+		if !started {
+			w.Header().Set("Retry-After", "60")
+			http.Error(w, "too many suites are running in the background, try again later", http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+
+	results := a.runSuite(r.Context(), su)
+	if a.webhook != "" {
+		a.notify(r.Context(), summarize(su.Name, results, link))
+	}
+	writeJSON(w, results)
+}
+
+// notify sends summary to the webhook, a webhook that fails doesn't fail the run
+func (a *App) notify(ctx context.Context, summary suiteSummary) {
+	if err := notifyWebhook(ctx, a.webhook, summary); err != nil {
+		log.Printf("failed to notify webhook of suite %q: %v", summary.Suite, err)
+	}
+}
+
+func suiteError(w http.ResponseWriter, err error) {
+	switch err {
+	case errSuiteNotFound:
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	case errWrongPassphrase:
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	http.Error(w, fmt.Sprintf("failed to load suite: %v", err), http.StatusInternalServerError)
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		http.Error(w, fmt.Sprintf("Encode error: %v", err), http.StatusInternalServerError)
+	}
+}
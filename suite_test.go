@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi"
+)
+
+func TestSuiteStore(t *testing.T) {
+	s := newSuiteStore(t.TempDir(), nil)
+	if names, err := s.names(); err != nil || len(names) != 0 {
+		t.Fatalf("unexpected names: %v, %v", names, err)
+	}
+	expected := suite{Name: "greeting", Template: "Hi {{.}}", Cases: []suiteCase{{Name: "name", Data: `"Bob"`}}}
+	for _, name := range []string{"greeting", "farewell"} {
+		su := expected
+		su.Name = name
+		if err := s.save(su, ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if names, err := s.names(); err != nil || !reflect.DeepEqual(names, []string{"farewell", "greeting"}) {
+		t.Errorf("unexpected names: %v, %v", names, err)
+	}
+	if actual, err := s.load("greeting", ""); err != nil || !reflect.DeepEqual(expected, actual) {
+		t.Errorf("expected %v, actual %v, %v", expected, actual, err)
+	}
+
+	if _, err := s.load("missing", ""); err != errSuiteNotFound {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := s.save(suite{Name: "../escape"}, ""); err == nil {
+		t.Error("expected the name to be rejected")
+	}
+	if _, err := s.load("../escape", ""); err != errSuiteNotFound {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestRunSuite(t *testing.T) {
+	hiBob, hiAlice := "Hi Bob", "Hi Alice"
+	su := suite{Template: "Hi {{.Name}}", Cases: []suiteCase{
+		{Name: "expected", Data: `{"Name": "Bob"}`, Expected: &hiBob},
+		{Name: "unexpected", Data: `{"Name": "Bob"}`, Expected: &hiAlice},
+		{Name: "assertion", Data: `{"Name": "Bob"}`, Assertions: json.RawMessage(`[{"contains": "Alice"}]`)},
+		{Name: "exec error", Data: `[]`},
+	}}
+	var passed []bool
+	for _, result := range (&App{}).runSuite(context.Background(), su) {
+		passed = append(passed, result.Passed)
+	}
+	if expected := []bool{true, false, false, false}; !reflect.DeepEqual(expected, passed) {
+		t.Errorf("expected %v, actual %v", expected, passed)
+	}
+}
+
+func TestSuiteEndpoints(t *testing.T) {
+	a := &App{suites: newSuiteStore(t.TempDir(), nil)}
+	r := chi.NewRouter()
+	r.Get("/api/v1/suites", a.Suites)
+	r.Get("/api/v1/suites/{name}", a.Suite)
+	r.Put("/api/v1/suites/{name}", a.SaveSuite)
+	r.Post("/api/v1/suites/{name}/run", a.RunSuite)
+	do := func(method, url, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(method, url, strings.NewReader(body)))
+		return w
+	}
+
+	if w := do("PUT", "/api/v1/suites/hi", `{"template": "Hi {{.}}", "cases": [{"name": "bob", "data": "\"Bob\"", "expected": "Hi Bob"}]}`); w.Code != http.StatusNoContent {
+		t.Fatalf("unexpected response: %d %s", w.Code, w.Body)
+	}
+	if w := do("GET", "/api/v1/suites", ""); strings.TrimSpace(w.Body.String()) != `["hi"]` {
+		t.Errorf("unexpected suites: %s", w.Body)
+	}
+	w := do("POST", "/api/v1/suites/hi/run", "")
+	var results []caseResult
+	if err := json.Unmarshal(w.Body.Bytes(), &results); err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || !results[0].Passed || results[0].Output != "Hi Bob" {
+		t.Errorf("unexpected results: %+v", results)
+	}
+	if w := do("POST", "/api/v1/suites/bye/run", ""); w.Code != http.StatusNotFound {
+		t.Errorf("unexpected response: %d %s", w.Code, w.Body)
+	}
+}
+
+func TestSuitePassphrase(t *testing.T) {
+	dir := t.TempDir()
+	a := &App{suites: newSuiteStore(dir, make([]byte, 32))}
+	r := chi.NewRouter()
+	r.Get("/api/v1/suites/{name}", a.Suite)
+	r.Put("/api/v1/suites/{name}", a.SaveSuite)
+	do := func(method, url, passphrase, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(method, url, strings.NewReader(body))
+		if passphrase != "" {
+			req.Header.Set(passphraseHeader, passphrase)
+		}
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := do("PUT", "/api/v1/suites/hi", "open sesame", `{"template": "Hi {{.Secret}}", "cases": []}`); w.Code != http.StatusNoContent {
+		t.Fatalf("unexpected response: %d %s", w.Code, w.Body)
+	}
+	if b, err := os.ReadFile(filepath.Join(dir, "hi.json")); err != nil || strings.Contains(string(b), "Secret") {
+		t.Errorf("expected the suite to be encrypted: %s, %v", b, err)
+	}
+	for _, passphrase := range []string{"", "guess"} {
+		if w := do("GET", "/api/v1/suites/hi", passphrase, ""); w.Code != http.StatusForbidden {
+			t.Errorf("unexpected response: %d %s", w.Code, w.Body)
+		}
+		if w := do("PUT", "/api/v1/suites/hi", passphrase, `{"template": "replaced"}`); w.Code != http.StatusForbidden {
+			t.Errorf("unexpected response: %d %s", w.Code, w.Body)
+		}
+	}
+	if w := do("GET", "/api/v1/suites/hi", "open sesame", ""); !strings.Contains(w.Body.String(), "Hi {{.Secret}}") {
+		t.Errorf("unexpected response: %d %s", w.Code, w.Body)
+	}
+}
@@ -0,0 +1,236 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	telegramAPI = "https://api.telegram.org"
+	// telegramPollTimeout is how long each request for updates waits for one
+	telegramPollTimeout = 30 * time.Second
+	// telegramRetryDelay is how long the bot waits after failing to get updates
+	telegramRetryDelay = 5 * time.Second
+	// telegramMaxMessage is the most characters of a message Telegram accepts, with room for the markup
+	telegramMaxMessage = 4000
+)
+
+// telegramBot replies to templates sent to a Telegram bot, as documents or messages, with their errors and output.
+// The caption of a document is its JSON data.
+type telegramBot struct {
+	token string
+	// api is the Telegram bot API's URL
+	api    string
+	client *http.Client
+	app    *App
+}
+
+type telegramUpdate struct {
+	UpdateID int              `json:"update_id"`
+	Message  *telegramMessage `json:"message"`
+}
+
+type telegramMessage struct {
+	MessageID int `json:"message_id"`
+	Chat      struct {
+		ID int64 `json:"id"`
+	} `json:"chat"`
+	Text     string `json:"text"`
+	Caption  string `json:"caption"`
+	Document *struct {
+		FileID string `json:"file_id"`
+	} `json:"document"`
+}
+
+func newTelegramBot(token string, app *App) *telegramBot {
+	return &telegramBot{
+		token:  token,
+		api:    telegramAPI,
+		client: &http.Client{Timeout: telegramPollTimeout + 10*time.Second},
+		app:    app,
+	}
+}
+
+// call calls a method of the bot API, decoding its result into result
+func (b *telegramBot) call(ctx context.Context, method string, params url.Values, result interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, "POST", fmt.Sprintf("%s/bot%s/%s", b.api, b.token, method),
+		bytes.NewBufferString(params.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	var response struct {
+		OK          bool            `json:"ok"`
+		Description string          `json:"description"`
+		Result      json.RawMessage `json:"result"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return err
+	}
+	if !response.OK {
+		return fmt.Errorf("telegram %s: %s", method, response.Description)
+	}
+	if result == nil {
+		return nil
+	}
+	return json.Unmarshal(response.Result, result)
+}
+
+// run replies to messages until ctx is cancelled
+func (b *telegramBot) run(ctx context.Context) {
+	offset := 0
+	for ctx.Err() == nil {
+		next, err := b.poll(ctx, offset)
+		if err != nil {
+			log.Printf("failed to get telegram updates: %v", err)
+			time.Sleep(telegramRetryDelay)
+			continue
+		}
+		offset = next
+	}
+}
+
+// poll replies to the messages after offset, returning the offset of the next ones
+func (b *telegramBot) poll(ctx context.Context, offset int) (int, error) {
+	var updates []telegramUpdate
+	params := url.Values{
+		"offset":          {strconv.Itoa(offset)},
+		"timeout":         {strconv.Itoa(int(telegramPollTimeout.Seconds()))},
+		"allowed_updates": {`["message"]`},
+	}
+	if err := b.call(ctx, "getUpdates", params, &updates); err != nil {
+		return offset, err
+	}
+	for _, update := range updates {
+		offset = update.UpdateID + 1
+		if update.Message == nil {
+			continue
+		}
+		if err := b.replyRecovering(ctx, *update.Message); err != nil {
+			log.Printf("failed to reply to telegram message: %v", err)
+		}
+	}
+	return offset, nil
+}
+
+// replyRecovering replies to m, turning a panic into an error, so one message can't stop the bot or the server
+func (b *telegramBot) replyRecovering(ctx context.Context, m telegramMessage) (err error) {
+	defer func() {
+		if rv := recover(); rv != nil {
+			errorReporter.report("fatal", "panic", fmt.Sprint(rv), map[string]string{"stack": string(debug.Stack()),
+				"bot": "telegram"})
+			err = fmt.Errorf("panic: %v", rv)
+		}
+	}()
+	return b.reply(ctx, m)
+}
+
+func (b *telegramBot) reply(ctx context.Context, m telegramMessage) error {
+	text, rawData := splitCodeBlocks(m.Text)
+	if m.Document != nil {
+		var err error
+		if text, err = b.download(ctx, m.Document.FileID); err != nil {
+			return err
+		}
+		rawData = m.Caption
+	}
+	if text == "" {
+		return nil
+	}
+	data := b.app.forRequest().createData(ctx, text, rawData, "", options{})
+	return b.call(ctx, "sendMessage", url.Values{
+		"chat_id":             {strconv.FormatInt(m.Chat.ID, 10)},
+		"reply_to_message_id": {strconv.Itoa(m.MessageID)},
+		"parse_mode":          {"HTML"},
+		"text":                {formatTelegramMessage(data)},
+	}, nil)
+}
+
+// download returns the contents of a file sent to the bot
+func (b *telegramBot) download(ctx context.Context, fileID string) (string, error) {
+	var file struct {
+		FilePath string `json:"file_path"`
+	}
+	if err := b.call(ctx, "getFile", url.Values{"file_id": {fileID}}, &file); err != nil {
+		return "", err
+	}
+	if file.FilePath == "" {
+		return "", errors.New("telegram getFile: file can't be downloaded")
+	}
+	req, err := http.NewRequestWithContext(ctx, "GET", fmt.Sprintf("%s/file/bot%s/%s", b.api, b.token, file.FilePath), nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("telegram file download: %s", resp.Status)
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(nil, resp.Body, maxRequestSize))
+	return string(body), err
+}
+
+// formatTelegramMessage formats the errors and output of validating a template as a Telegram HTML message
+func formatTelegramMessage(data indexData) string {
+	var b bytes.Buffer
+	if len(data.Errors) == 0 {
+		b.WriteString("No errors found.\n")
+	} else {
+		fmt.Fprintf(&b, "<b>%s</b>\n<pre>", countErrors(len(data.Errors)))
+		// the errors take up to half of the message, the last one cut to fit
+		for _, tplErr := range data.Errors {
+			line, cut := escapeWithin(formatError("template", tplErr), telegramMaxMessage/2-b.Len())
+			b.WriteString(line + "\n")
+			if cut {
+				break
+			}
+		}
+		b.WriteString("</pre>\n")
+	}
+	if data.Output != "" {
+		const outputMarkup = "Output:\n<pre></pre>"
+		if output, _ := escapeWithin(data.Output, telegramMaxMessage-b.Len()-len(outputMarkup)); output != "…" {
+			fmt.Fprintf(&b, "Output:\n<pre>%s</pre>", output)
+		}
+	}
+	return b.String()
+}
+
+// escapeWithin returns s escaped as HTML, cut to at most room bytes with an ellipsis if it's longer, and whether it was.
+// An ellipsis alone means nothing fits.
+func escapeWithin(s string, room int) (string, bool) {
+	escaped := html.EscapeString(s)
+	if len(escaped) <= room {
+		return escaped, false
+	}
+	room -= len("…")
+	var b strings.Builder
+	for _, r := range s {
+		e := html.EscapeString(string(r))
+		if b.Len()+len(e) > room {
+			break
+		}
+		b.WriteString(e)
+	}
+	return b.String() + "…", true
+}
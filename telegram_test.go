@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestTelegramBot(t *testing.T) {
+	sent := make(chan url.Values, 2)
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/bottoken/getUpdates":
+			fmt.Fprint(w, `{"ok": true, "result": [
+				{"update_id": 7, "message": {"message_id": 1, "chat": {"id": 42}, "document": {"file_id": "f"}, "caption": "{\"Name\": \"<Bob>\"}"}},
+				{"update_id": 8, "message": {"message_id": 2, "chat": {"id": 42}, "text": "{{template \"x\"}}"}}
+			]}`)
+		case "/bottoken/getFile":
+			fmt.Fprint(w, `{"ok": true, "result": {"file_path": "documents/t.tmpl"}}`)
+		case "/file/bottoken/documents/t.tmpl":
+			fmt.Fprint(w, "Hi {{.Name}}")
+		case "/bottoken/sendMessage":
+			r.ParseForm()
+			sent <- r.PostForm
+			fmt.Fprint(w, `{"ok": true, "result": {}}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer api.Close()
+
+	b := newTelegramBot("token", &App{})
+	b.api = api.URL
+	offset, err := b.poll(context.Background(), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if offset != 9 {
+		t.Errorf("unexpected offset: %d", offset)
+	}
+
+	first, second := <-sent, <-sent
+	if first.Get("chat_id") != "42" || first.Get("reply_to_message_id") != "1" {
+		t.Errorf("unexpected reply: %v", first)
+	}
+	if expected := "No errors found.\nOutput:\n<pre>Hi &lt;Bob&gt;</pre>"; first.Get("text") != expected {
+		t.Errorf("expected `%s`, actual `%s`", expected, first.Get("text"))
+	}
+	if expected := "<b>1 error found</b>\n<pre>template:1:12: template &#34;x&#34; not defined [parse]\n</pre>\n"; second.Get("text") != expected {
+		t.Errorf("expected `%s`, actual `%s`", expected, second.Get("text"))
+	}
+}
+
+func TestTelegramMessageLongError(t *testing.T) {
+	app := &App{parseCache: newParseCache(10)}
+	data := app.createData(context.Background(), "{{"+strings.Repeat("x", 5000)+"}}hello", "", "", options{})
+	if len(data.Errors) == 0 {
+		t.Fatal("expected an error")
+	}
+	message := formatTelegramMessage(data)
+	if len(message) > telegramMaxMessage {
+		t.Errorf("unexpected message length: %d", len(message))
+	}
+	if !strings.HasSuffix(message, "…\n</pre>\nOutput:\n<pre>&lt;nil&gt;hello</pre>") {
+		t.Errorf("unexpected message: %s", message)
+	}
+}
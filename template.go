@@ -1,64 +1,99 @@
 package main
 
 import (
-	"bytes"
+	"context"
+	"errors"
 	"fmt"
+	htmlTemplate "html/template"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 	"text/template"
+	templateParse "text/template/parse"
 )
 
-const maxFixes = 10
+const (
+	// maxFixes are how many parse errors small templates are recovered from, minFixes how many big ones are
+	maxFixes = 100
+	minFixes = 10
+	// maxRecoveryBytes are about how many bytes recovering from the parse errors of a template reparses, so big
+	// templates are recovered from fewer times, trying fewer rewrites each time
+	maxRecoveryBytes = 4 << 20
+	// maxRecoveryCandidates are how many rewrites of actions are tried to recover from one parse error
+	maxRecoveryCandidates = 16
+	maxExecFixes          = 10
+)
 
 var (
-	templateErrorRegex          = regexp.MustCompile(`template: (.*?):((\d+):)?(\d+): (.*)`)
 	findTokenRegex              = regexp.MustCompile(`['"](.+)['"]`)
-	findExprRegex               = regexp.MustCompile(`<(\..+?)>`)
+	executingRegex              = regexp.MustCompile(`^executing "(.*?)" at <(.+?)>: `)
 	functionNotFoundRegex       = regexp.MustCompile(`function "(.+)" not defined`)
 	missingValueForCommandRegex = regexp.MustCompile(`missing value for command`)
 	firstEmptyCommandRegex      = regexp.MustCompile(`{{((-?\s*?)|(\s*?-?))}}`)
 )
 
+// createTemplateError finds where err is. The errors of html/template have their location, those of text/template only
+// have it in their message, which is parsed.
 func createTemplateError(err error, level ErrorLevel) templateError {
-	matches := templateErrorRegex.FindStringSubmatch(err.Error())
-	if len(matches) != 6 {
-		return templateError{Line: -1, Char: -1, Description: err.Error(), Level: misunderstoodError}
+	var htmlErr *htmlTemplate.Error
+	if errors.As(err, &htmlErr) {
+		tplErr := templateError{File: htmlErr.Name, Line: htmlErr.Line - 1, Char: -1, Description: htmlErr.Description,
+			Level: level}
+		if htmlErr.Node != nil {
+			loc, _ := (*templateParse.Tree)(nil).ErrorContext(htmlErr.Node)
+			tplErr.Line, tplErr.Char = parseLocation(loc)
+		}
+		return tplErr
 	}
-	// tplName := matches[1]
-
-	// 2 is line + : group if char is found
-	// line is in pos 4, unless a char is found in which case it's 3 and char is 4
 
-	lineIndex := 4
-	char := -1
-	if matches[3] != "" {
-		lineIndex = 3
-		char, err = strconv.Atoi(matches[4])
-		if err != nil {
-			char = -1
+	m, ok := parseErrorMessage(err.Error())
+	if !ok {
+		var execErr template.ExecError
+		if errors.As(err, &execErr) {
+			// an exec error in a format this doesn't know, it's still known to be one
+			return templateError{Line: -1, Char: -1, Description: execErr.Err.Error(), Level: level}
 		}
+		reportMisunderstood(err)
+		return templateError{Line: -1, Char: -1, Description: err.Error(), Level: misunderstoodError}
 	}
+	return templateError{File: m.File, Line: m.Line, Char: m.Char, Description: m.Description, Level: level}
+}
 
-	line, err := strconv.Atoi(matches[lineIndex])
-	if err != nil {
-		line = -1
-	} else {
-		line = line - 1
+// parse parses text into baseTpl, finding as many errors as it can. Front matter at its start is left out.
+func parse(ctx context.Context, text string, baseTpl *template.Template) (*template.Template, []templateError) {
+	text, frontMatter := blankFrontMatter(text)
+	t, tplErrs := parseInternal(ctx, text, baseTpl, 0)
+	if frontMatter > 0 {
+		stripFrontMatter(baseTpl.Lookup(baseTpl.Name()), frontMatter)
 	}
-
-	description := matches[5]
-	return templateError{Line: line, Char: char, Description: description, Level: level}
+	return t, sortErrors(tplErrs)
 }
 
-func parse(text string, baseTpl *template.Template) (*template.Template, []templateError) {
-	return parseInternal(text, baseTpl, 0)
+// sortErrors orders errors by location and drops exact duplicates, which happen when several recovery passes run into
+// the same problem (e.g. nested unclosed blocks all report "unexpected EOF" on the last line)
+func sortErrors(tplErrs []templateError) []templateError {
+	sort.SliceStable(tplErrs, func(i, j int) bool {
+		if tplErrs[i].Line != tplErrs[j].Line {
+			return tplErrs[i].Line < tplErrs[j].Line
+		}
+		return tplErrs[i].Char < tplErrs[j].Char
+	})
+	result := make([]templateError, 0, len(tplErrs))
+	for i, tplErr := range tplErrs {
+		if i > 0 && tplErr == tplErrs[i-1] {
+			continue
+		}
+		result = append(result, tplErr)
+	}
+	return result
 }
 
-func parseInternal(text string, baseTpl *template.Template, depth int) (t *template.Template, tplErrs []templateError) {
+func parseInternal(ctx context.Context, text string, baseTpl *template.Template, depth int) (t *template.Template, tplErrs []templateError) {
 	lines := SplitLines(text)
 
-	if depth >= maxFixes {
+	// recovering from errors reparses, which isn't worth it for a request that was cancelled
+	if depth >= fixesFor(len(text)) || depth > 0 && ctx.Err() != nil {
 		return baseTpl, tplErrs
 	}
 
@@ -89,8 +124,8 @@ func parseInternal(text string, baseTpl *template.Template, depth int) (t *templ
 		badFunctionMatch := functionNotFoundRegex.FindStringSubmatch(tplErr.Description)
 		if badFunctionMatch != nil {
 			token := badFunctionMatch[1]
-			t, parseTplErrs := parseInternal(text, baseTpl.Funcs(template.FuncMap{
-				token: func() error { return nil },
+			t, parseTplErrs := parseInternal(ctx, text, baseTpl.Funcs(template.FuncMap{
+				token: mockFunction,
 			}), depth+1)
 			return t, append(tplErrs, parseTplErrs...)
 		}
@@ -104,6 +139,7 @@ func parseInternal(text string, baseTpl *template.Template, depth int) (t *templ
 				}
 				replacement := fmt.Sprintf(fmt.Sprintf("%%%ds", len(matches[0])), "")
 				t, parseTplErrs := parseInternal(
+					ctx,
 					strings.Replace(text, matches[0], replacement, 1),
 					baseTpl,
 					depth+1,
@@ -111,27 +147,366 @@ func parseInternal(text string, baseTpl *template.Template, depth int) (t *templ
 				return t, append(tplErrs, parseTplErrs...)
 			}
 		}
+
+		if tplErr.Description == "unexpected EOF" {
+			// an unclosed block, close it and look for more problems
+			t, parseTplErrs := parseInternal(ctx, text+"{{end}}", baseTpl, depth+1)
+			return t, append(tplErrs, parseTplErrs...)
+		}
+
+		// otherwise neuter the action the error occurred in and keep going
+		if fixed, at, delta, ok := recoverAction(text, baseTpl, *tplErr); ok {
+			t, parseTplErrs := parseInternal(ctx, fixed, baseTpl, depth+1)
+			return t, append(tplErrs, shiftErrors(parseTplErrs, lineOf(fixed, at), columnOf(fixed, at), delta)...)
+		}
 	}
 
 	return baseTpl, tplErrs
 }
 
-func exec(t *template.Template, data interface{}, buf *bytes.Buffer) []templateError {
-	tplErrs := make([]templateError, 0)
-	err := t.Execute(buf, data)
-	if err == nil {
+// fixesFor returns how many parse errors of a template of n bytes are recovered from
+func fixesFor(n int) int {
+	if n == 0 {
+		return maxFixes
+	}
+	return min(maxFixes, max(minFixes, maxRecoveryBytes/n))
+}
+
+// candidatesFor returns how many rewrites are tried to recover from a parse error of a template of n bytes
+func candidatesFor(n int) int {
+	if n == 0 {
+		return maxRecoveryCandidates
+	}
+	return min(maxRecoveryCandidates, max(1, maxRecoveryBytes/(fixesFor(n)*n)))
+}
+
+// mockFunction stands in for functions the validator doesn't have. It accepts any arguments so calling it can't fail.
+func mockFunction(...interface{}) error { return nil }
+
+// action is the byte range of a single `{{ ... }}` in a template, including delimiters
+type action struct {
+	start int
+	end   int
+}
+
+// findActions locates the actions in text. An action without a closing delimiter extends to the end of the text.
+func findActions(text string) []action {
+	var actions []action
+	offset := 0
+	for {
+		start := strings.Index(text[offset:], "{{")
+		if start == -1 {
+			return actions
+		}
+		start += offset
+		end := strings.Index(text[start+2:], "}}")
+		if end == -1 {
+			return append(actions, action{start: start, end: len(text)})
+		}
+		end += start + 4
+		actions = append(actions, action{start: start, end: end})
+		offset = end
+	}
+}
+
+// lineOf returns the zero indexed line of a byte offset in text
+func lineOf(text string, offset int) int {
+	return strings.Count(text[:offset], "\n")
+}
+
+// columnOf returns the byte offset from the start of its line of a byte offset in text
+func columnOf(text string, offset int) int {
+	return offset - (strings.LastIndex(text[:offset], "\n") + 1)
+}
+
+// lineStarts returns the offsets into text of its lines, which end at "\n"
+func lineStarts(text string) []int {
+	starts := []int{0}
+	for i := 0; i < len(text); i++ {
+		if text[i] == '\n' {
+			starts = append(starts, i+1)
+		}
+	}
+	return starts
+}
+
+// lineAt returns the zero indexed line of a byte offset, with the lineStarts of its text, and its column in it
+func lineAt(starts []int, offset int) (line, column int) {
+	line = sort.SearchInts(starts, offset+1) - 1
+	return line, offset - starts[line]
+}
+
+// blockKeywords are the actions that must be kept (with a placeholder argument) for their {{end}} to still match
+var blockKeywords = []string{"if", "range", "with", "else if", "else with"}
+
+// recoverAction finds the action responsible for tplErr and rewrites it so parsing can continue past it. Block opening
+// actions keep their keyword with a placeholder argument so the matching {{end}} stays balanced, everything else is
+// blanked out. The offset and length change of the rewrite are returned so later error positions can be mapped back.
+func recoverAction(text string, baseTpl *template.Template, tplErr templateError) (fixed string, at, delta int, ok bool) {
+	if tplErr.Line < 0 {
+		return "", 0, 0, false
+	}
+
+	starts := lineStarts(text)
+	var candidates []action
+	for _, a := range findActions(text) {
+		startLine, startColumn := lineAt(starts, a.start)
+		endLine, endColumn := lineAt(starts, a.end)
+		if startLine > tplErr.Line || endLine < tplErr.Line {
+			continue
+		}
+		// prefer the action the error's character lies in
+		if startLine == tplErr.Line && tplErr.Char >= startColumn && (endLine > tplErr.Line || tplErr.Char < endColumn) {
+			candidates = append([]action{a}, candidates...)
+		} else {
+			candidates = append(candidates, a)
+		}
+	}
+	// each rewrite tried reparses the text
+	if limit := candidatesFor(len(text)); len(candidates) > limit {
+		candidates = candidates[:limit]
+	}
+
+	for _, a := range candidates {
+		fixed, delta := rewriteAction(text, a)
+		if fixed == text {
+			continue
+		}
+		// only accept a rewrite that actually gets past this error
+		if _, err := template.Must(baseTpl.Clone()).Parse(fixed); err != nil {
+			if next := createTemplateError(err, parseErrorLevel); next.Line == tplErr.Line && next.Description == tplErr.Description {
+				continue
+			}
+		}
+		return fixed, a.start, delta, true
+	}
+	return "", 0, 0, false
+}
+
+func rewriteAction(text string, a action) (string, int) {
+	inner := strings.TrimLeft(text[a.start+2:a.end], "- \t\r\n")
+	for _, keyword := range blockKeywords {
+		if strings.HasPrefix(inner, keyword) && !isIdentChar(inner[len(keyword):]) {
+			replacement := fmt.Sprintf("{{%s 0}}", keyword)
+			if pad := a.end - a.start - len(replacement); pad > 0 {
+				replacement = fmt.Sprintf("{{%s 0%s}}", keyword, strings.Repeat(" ", pad))
+			}
+			return text[:a.start] + replacement + text[a.end:], len(replacement) - (a.end - a.start)
+		}
+	}
+	return blank(text, a.start, a.end), 0
+}
+
+func isIdentChar(s string) bool {
+	if s == "" {
+		return false
+	}
+	c := s[0]
+	return c == '_' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+// blank replaces text between start and end with spaces, keeping line breaks so line numbers are unaffected
+func blank(text string, start, end int) string {
+	b := []byte(text)
+	for i := start; i < end; i++ {
+		if b[i] != '\n' && b[i] != '\r' {
+			b[i] = ' '
+		}
+	}
+	return string(b)
+}
+
+// shiftErrors maps error characters found in a rewritten template back to the original when the rewrite changed the
+// length of line
+func shiftErrors(tplErrs []templateError, line, char, delta int) []templateError {
+	if delta == 0 {
 		return tplErrs
 	}
+	for i := range tplErrs {
+		if tplErrs[i].Line == line && tplErrs[i].Char > char {
+			tplErrs[i].Char -= delta
+		}
+	}
+	return tplErrs
+}
 
-	if err.Error() == fmt.Sprintf(`template: %s: "%s" is an incomplete or empty template`, t.Name(), t.Name()) {
+func exec(t *template.Template, data interface{}, buf execOutput) (tplErrs []templateError) {
+	tplErrs = make([]templateError, 0)
+	// functions' panics become errors, anything else is a problem with the validator but shouldn't take the page down
+	defer func() {
+		if r := recover(); r != nil {
+			tplErrs = append(tplErrs, templateError{Line: -1, Char: -1, Level: execErrorLevel,
+				Description: fmt.Sprintf("panic while executing: %v", r)})
+		}
+	}()
+	// an empty template, like the root one of a file only defining templates, has nothing to execute
+	if t.Tree == nil || t.Tree.Root == nil {
+		return tplErrs
+	}
+	err := t.Execute(buf, data)
+	if err == nil {
 		return tplErrs
 	}
+
 	tplErr := createTemplateError(err, execErrorLevel)
+	if tplErr.Level != misunderstoodError {
+		locateExecError(t, &tplErr)
+		if explanation := explainDataPath(t, tplErr, data); explanation != "" {
+			tplErr.Description += ": " + explanation
+		}
+	}
 	tplErrs = append(tplErrs, tplErr)
+	return tplErrs
+}
 
-	matches := findExprRegex.FindStringSubmatch(tplErr.Description)
-	if len(matches) == 2 {
-		fmt.Println(matches[1])
+// locateExecError fills in the line and character of an exec error from the position of the node it names, when the
+// error message itself doesn't have them
+func locateExecError(t *template.Template, tplErr *templateError) {
+	if tplErr.Line != -1 && tplErr.Char != -1 {
+		return
 	}
-	return tplErrs
+	matches := executingRegex.FindStringSubmatch(tplErr.Description)
+	if matches == nil {
+		return
+	}
+	tt := t.Lookup(matches[1])
+	if tt == nil || tt.Tree == nil || tt.Tree.Root == nil {
+		return
+	}
+	tree := tt.Tree
+
+	type match struct {
+		node       templateParse.Node
+		line, char int
+	}
+	var found []match
+	walk(tree.Root, func(node templateParse.Node, ancestors []templateParse.Node) bool {
+		switch node.(type) {
+		case *templateParse.ListNode, *templateParse.TextNode:
+			return true
+		}
+		if node.String() != matches[2] {
+			return true
+		}
+		loc, _ := tree.ErrorContext(node)
+		line, char := parseLocation(loc)
+		if line == -1 || (tplErr.Line != -1 && line != tplErr.Line) {
+			return true
+		}
+		// a node's string form can match its parent's, e.g. a pipeline with a single field in it, the innermost one is
+		// the one that's evaluated
+		if len(found) > 0 {
+			for _, ancestor := range ancestors {
+				if ancestor == found[len(found)-1].node {
+					found = found[:len(found)-1]
+					break
+				}
+			}
+		}
+		found = append(found, match{node: node, line: line, char: char})
+		return true
+	})
+	// ambiguous, don't guess
+	if len(found) != 1 {
+		return
+	}
+	tplErr.File = tree.ParseName
+	tplErr.Line = found[0].line
+	tplErr.Char = found[0].char
+}
+
+// parseLocation splits the "name:line:char" location created by templateParse.Tree.ErrorContext into a zero indexed line
+// and a character
+func parseLocation(loc string) (line, char int) {
+	parts := strings.Split(loc, ":")
+	if len(parts) < 3 {
+		return -1, -1
+	}
+	line, err := strconv.Atoi(parts[len(parts)-2])
+	if err != nil {
+		return -1, -1
+	}
+	char, err = strconv.Atoi(parts[len(parts)-1])
+	if err != nil {
+		return -1, -1
+	}
+	return line - 1, char
+}
+
+// execCollect executes t like exec, but after an error removes the failing action and executes again, up to retries
+// times, so more than the first runtime error can be found. The output written to buf is from the last execution.
+func execCollect(ctx context.Context, t *template.Template, data interface{}, buf execOutput, retries int) []templateError {
+	tplErrs := exec(t, data, buf)
+	if retries <= 0 || len(tplErrs) == 0 {
+		return tplErrs
+	}
+
+	// the caller's template must not be modified
+	t, err := copyTemplate(t)
+	if err != nil {
+		return tplErrs
+	}
+
+	for i := 0; i < retries; i++ {
+		// the limits keep counting, anything after one is hit would be stopped too
+		if ctx.Err() != nil || isLimitError(tplErrs[len(tplErrs)-1]) || !removeFailingNode(t, tplErrs[len(tplErrs)-1]) {
+			break
+		}
+		buf.Reset()
+		execTplErrs := exec(t, data, buf)
+		if len(execTplErrs) == 0 {
+			break
+		}
+		tplErrs = append(tplErrs, execTplErrs...)
+	}
+	return sortErrors(tplErrs)
+}
+
+// removeFailingNode finds the node an exec error occurred at and removes the statement evaluating it from its tree.
+// Actions and template invocations are dropped, as if they had produced a zero value, and if/with/range blocks are
+// replaced by their else branch, as if their pipeline had been empty.
+func removeFailingNode(t *template.Template, tplErr templateError) bool {
+	_, chain := findNode(t, tplErr.Line, tplErr.Char)
+	for i := len(chain) - 1; i > 0; i-- {
+		var pipe *templateParse.PipeNode
+		var replacement []templateParse.Node
+		switch n := chain[i].(type) {
+		case *templateParse.ActionNode:
+			pipe = n.Pipe
+		case *templateParse.TemplateNode:
+			pipe = n.Pipe
+		case *templateParse.IfNode:
+			pipe, replacement = n.Pipe, elseNodes(n.ElseList)
+		case *templateParse.WithNode:
+			pipe, replacement = n.Pipe, elseNodes(n.ElseList)
+		case *templateParse.RangeNode:
+			pipe, replacement = n.Pipe, elseNodes(n.ElseList)
+		default:
+			continue
+		}
+		// errors inside the body of a block belong to a statement further down the chain
+		if i != len(chain)-1 && chain[i+1] != pipe {
+			continue
+		}
+		parent, ok := chain[i-1].(*templateParse.ListNode)
+		if !ok {
+			return false
+		}
+		for j, sibling := range parent.Nodes {
+			if sibling == chain[i] {
+				nodes := append(append(append([]templateParse.Node{}, parent.Nodes[:j]...), replacement...), parent.Nodes[j+1:]...)
+				parent.Nodes = nodes
+				return true
+			}
+		}
+		return false
+	}
+	return false
+}
+
+func elseNodes(list *templateParse.ListNode) []templateParse.Node {
+	if list == nil {
+		return nil
+	}
+	return list.Nodes
 }
@@ -2,12 +2,15 @@ package main
 
 import (
 	"bytes"
+	"context"
+	"errors"
+	"strings"
 	"testing"
 	textTemplate "text/template"
 )
 
 func TestParseSimple(t *testing.T) {
-	tpl, errs := parse("hello world", textTemplate.New("base"))
+	tpl, errs := parse(context.Background(), "hello world", textTemplate.New("base"))
 	if len(errs) != 0 {
 		t.Fatalf("errs found: %v", errs)
 	}
@@ -33,7 +36,7 @@ func assertError(t *testing.T, expected templateError, actual templateError) {
 }
 
 func TestParseUnexpectedEOF(t *testing.T) {
-	_, errs := parse("{{if .Value}}", textTemplate.New("base"))
+	_, errs := parse(context.Background(), "{{if .Value}}", textTemplate.New("base"))
 	if len(errs) != 1 {
 		t.Errorf("unexpected errors found: %v", errs)
 	}
@@ -46,7 +49,7 @@ func TestParseUnexpectedEOF(t *testing.T) {
 }
 
 func TestParseUnknownFunctions(t *testing.T) {
-	_, errs := parse("{{foo}}{{bar}}", textTemplate.New("base"))
+	_, errs := parse(context.Background(), "{{foo}}{{bar}}", textTemplate.New("base"))
 	if len(errs) != 2 {
 		t.Errorf("unexpected errors found: %v", errs)
 	}
@@ -65,7 +68,7 @@ func TestParseUnknownFunctions(t *testing.T) {
 }
 
 func TestParseNoname(t *testing.T) {
-	_, errs := parse("{{foo}}", textTemplate.New(""))
+	_, errs := parse(context.Background(), "{{foo}}", textTemplate.New(""))
 	if len(errs) != 1 {
 		t.Errorf("unexpected errors found: %v", errs)
 	}
@@ -78,7 +81,7 @@ func TestParseNoname(t *testing.T) {
 }
 
 func TestParseInvalidIf(t *testing.T) {
-	_, errs := parse("{{if}}{{end}}", textTemplate.New("base"))
+	_, errs := parse(context.Background(), "{{if}}{{end}}", textTemplate.New("base"))
 	if len(errs) != 1 {
 		t.Errorf("unexpected errors found: %v", errs)
 	}
@@ -91,7 +94,7 @@ func TestParseInvalidIf(t *testing.T) {
 }
 
 func TestParseIndexSyntax(t *testing.T) {
-	_, errs := parse("<{{.Foo[2]}}>", textTemplate.New("base"))
+	_, errs := parse(context.Background(), "<{{.Foo[2]}}>", textTemplate.New("base"))
 	if len(errs) != 1 {
 		t.Errorf("unexpected errors found: %v", errs)
 	}
@@ -105,7 +108,7 @@ func TestParseIndexSyntax(t *testing.T) {
 
 func TestParseEmptyCommand(t *testing.T) {
 	for _, testCase := range []string{"{{}}", "{{- }}", "{{  -}}"} {
-		_, errs := parse(testCase, textTemplate.New("base"))
+		_, errs := parse(context.Background(), testCase, textTemplate.New("base"))
 		if len(errs) != 1 {
 			t.Errorf("unexpected errors found: %v", errs)
 		}
@@ -119,7 +122,7 @@ func TestParseEmptyCommand(t *testing.T) {
 }
 
 func TestParseEmptyCommands(t *testing.T) {
-	_, errs := parse("\n\n{{ }} hello world {{ }}", textTemplate.New("base"))
+	_, errs := parse(context.Background(), "\n\n{{ }} hello world {{ }}", textTemplate.New("base"))
 	if len(errs) != 2 {
 		t.Errorf("unexpected errors found: %v", errs)
 	}
@@ -137,6 +140,84 @@ func TestParseEmptyCommands(t *testing.T) {
 	}, errs[1])
 }
 
+func TestParseReportsAllErrors(t *testing.T) {
+	_, errs := parse(context.Background(), "{{if}}{{end}}\n{{.Foo[1]}}\n{{end}}\n{{foo}}", textTemplate.New("base"))
+	if len(errs) != 4 {
+		t.Fatalf("unexpected errors found: %v", errs)
+	}
+	assertError(t, templateError{
+		Char:        -1,
+		Line:        0,
+		Level:       parseErrorLevel,
+		Description: `missing value for if`,
+	}, errs[0])
+	assertError(t, templateError{
+		Char:        6,
+		Line:        1,
+		Level:       parseErrorLevel,
+		Description: `bad character U+005B '['`,
+	}, errs[1])
+	assertError(t, templateError{
+		Char:        -1,
+		Line:        2,
+		Level:       parseErrorLevel,
+		Description: `unexpected {{end}}`,
+	}, errs[2])
+	assertError(t, templateError{
+		Char:        2,
+		Line:        3,
+		Level:       parseErrorLevel,
+		Description: `function "foo" not defined`,
+	}, errs[3])
+}
+
+func TestParseRecoveryKeepsCharPositions(t *testing.T) {
+	_, errs := parse(context.Background(), "{{range}}x{{.A[}}{{end}}", textTemplate.New("base"))
+	if len(errs) != 2 {
+		t.Fatalf("unexpected errors found: %v", errs)
+	}
+	assertError(t, templateError{
+		Char:        14,
+		Line:        0,
+		Level:       parseErrorLevel,
+		Description: `bad character U+005B '['`,
+	}, errs[1])
+}
+
+func TestParseRecoveryScalesDown(t *testing.T) {
+	text := strings.Repeat(strings.Repeat("x", 4000)+"{{.A[}}\n", 100)
+	_, errs := parse(context.Background(), text, textTemplate.New("base"))
+	if len(errs) != minFixes {
+		t.Errorf("expected %d errors of a big template, actual %d", minFixes, len(errs))
+	}
+	if fixesFor(10) != maxFixes || candidatesFor(10) != maxRecoveryCandidates || candidatesFor(len(text)) != 1 {
+		t.Errorf("unexpected limits %d, %d, %d", fixesFor(10), candidatesFor(10), candidatesFor(len(text)))
+	}
+}
+
+func TestLineAt(t *testing.T) {
+	text := "ab\n\ncd\n"
+	starts := lineStarts(text)
+	for offset := 0; offset <= len(text); offset++ {
+		if line, column := lineAt(starts, offset); line != lineOf(text, offset) || column != columnOf(text, offset) {
+			t.Errorf("%d: unexpected %d:%d", offset, line, column)
+		}
+	}
+}
+
+func TestParseNestedUnexpectedEOF(t *testing.T) {
+	_, errs := parse(context.Background(), "{{if .A}}\n{{if .B}}\n", textTemplate.New("base"))
+	if len(errs) != 1 {
+		t.Fatalf("unexpected errors found: %v", errs)
+	}
+	assertError(t, templateError{
+		Char:        -1,
+		Line:        2,
+		Level:       parseErrorLevel,
+		Description: `unexpected EOF`,
+	}, errs[0])
+}
+
 func TestExecWorks(t *testing.T) {
 	tpl, _ := textTemplate.New("base").Parse("<{{.Value}}>")
 	var buf bytes.Buffer
@@ -184,3 +265,95 @@ func TestExecIgnoresIncomplete(t *testing.T) {
 		t.Errorf("errs found: %v", errs)
 	}
 }
+
+func TestExecLocatesNode(t *testing.T) {
+	tpl, _ := textTemplate.New("base").Parse("a\n {{.Foo.Bar}}\n{{.Baz}}")
+	tplErr := templateError{
+		Line:        -1,
+		Char:        -1,
+		Level:       execErrorLevel,
+		Description: `executing "base" at <.Foo.Bar>: nil pointer evaluating interface {}.Bar`,
+	}
+	locateExecError(tpl, &tplErr)
+	assertError(t, templateError{
+		Line:        1,
+		Char:        7,
+		Level:       execErrorLevel,
+		Description: `executing "base" at <.Foo.Bar>: nil pointer evaluating interface {}.Bar`,
+	}, tplErr)
+}
+
+func TestExecLocatesNodeAmbiguous(t *testing.T) {
+	tpl, _ := textTemplate.New("base").Parse("{{.Foo}}\n{{.Foo}}")
+	tplErr := templateError{
+		Line:        -1,
+		Char:        -1,
+		Level:       execErrorLevel,
+		Description: `executing "base" at <.Foo>: can't evaluate field Foo in type int`,
+	}
+	locateExecError(tpl, &tplErr)
+	if tplErr.Line != -1 || tplErr.Char != -1 {
+		t.Errorf("ambiguous node was located: %v", tplErr)
+	}
+
+	// only one of them is on the reported line
+	tplErr.Line = 1
+	locateExecError(tpl, &tplErr)
+	if tplErr.Char != 2 {
+		t.Errorf("node wasn't located: %v", tplErr)
+	}
+}
+
+func TestExecCollect(t *testing.T) {
+	tpl, _ := textTemplate.New("base").Parse("<{{.A}}|{{if .B}}b{{else}}c{{end}}|{{range .C}}{{.D}}{{end}}>")
+	var buf bytes.Buffer
+	errs := execCollect(context.Background(), tpl, struct{ C []int }{C: []int{1, 2}}, &buf, maxExecFixes)
+	if len(errs) != 3 {
+		t.Fatalf("unexpected errs: %v", errs)
+	}
+	assertError(t, templateError{
+		Line:        0,
+		Char:        3,
+		Level:       execErrorLevel,
+		Description: `executing "base" at <.A>: can't evaluate field A in type struct { C []int }`,
+	}, errs[0])
+	assertError(t, templateError{
+		Line:        0,
+		Char:        13,
+		Level:       execErrorLevel,
+		Description: `executing "base" at <.B>: can't evaluate field B in type struct { C []int }`,
+	}, errs[1])
+	assertError(t, templateError{
+		Line:        0,
+		Char:        49,
+		Level:       execErrorLevel,
+		Description: `executing "base" at <.D>: can't evaluate field D in type int`,
+	}, errs[2])
+	if buf.String() != "<|c|>" {
+		t.Errorf("output doesn't match: `%s`", buf.String())
+	}
+
+	// the original template is untouched
+	buf.Reset()
+	if errs := exec(tpl, struct{ C []int }{}, &buf); len(errs) != 1 {
+		t.Errorf("unexpected errs: %v", errs)
+	}
+}
+
+func TestExecCollectDisabled(t *testing.T) {
+	tpl, _ := textTemplate.New("base").Parse("<{{.A}}{{.B}}>")
+	var buf bytes.Buffer
+	errs := execCollect(context.Background(), tpl, struct{}{}, &buf, 0)
+	if len(errs) != 1 {
+		t.Errorf("unexpected errs: %v", errs)
+	}
+}
+
+func TestCreateTemplateErrorFile(t *testing.T) {
+	tplErr := createTemplateError(errors.New(`template: partials/footer.tmpl:3:5: executing "partials/footer.tmpl" at <.X>: boom`), execErrorLevel)
+	if tplErr.File != "partials/footer.tmpl" {
+		t.Errorf("expected the file partials/footer.tmpl, actual %q", tplErr.File)
+	}
+	assertError(t, templateError{Line: 2, Char: 5, Level: execErrorLevel,
+		Description: `executing "partials/footer.tmpl" at <.X>: boom`}, tplErr)
+}
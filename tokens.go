@@ -0,0 +1,239 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// tokenType is the class of a token, named after the items of the text/template lexer
+type tokenType string
+
+const (
+	textToken       tokenType = "text"
+	leftDelimToken  tokenType = "leftDelim"
+	rightDelimToken tokenType = "rightDelim"
+	commentToken    tokenType = "comment"
+	spaceToken      tokenType = "space"
+	keywordToken    tokenType = "keyword"
+	identifierToken tokenType = "identifier"
+	fieldToken      tokenType = "field"
+	variableToken   tokenType = "variable"
+	dotToken        tokenType = "dot"
+	stringToken     tokenType = "string"
+	rawStringToken  tokenType = "rawString"
+	charToken       tokenType = "char"
+	numberToken     tokenType = "number"
+	boolToken       tokenType = "bool"
+	nilToken        tokenType = "nil"
+	pipeToken       tokenType = "pipe"
+	declareToken    tokenType = "declare"
+	assignToken     tokenType = "assign"
+	commaToken      tokenType = "comma"
+	leftParenToken  tokenType = "leftParen"
+	rightParenToken tokenType = "rightParen"
+	// errorToken is what the lexer would stop at, like an unterminated string, the rest of the text isn't tokenized
+	errorToken tokenType = "error"
+)
+
+// keywords are the identifiers the lexer makes keywords of
+var keywords = map[string]bool{
+	"block": true, "break": true, "continue": true, "define": true, "else": true, "end": true, "if": true,
+	"range": true, "template": true, "with": true,
+}
+
+// token is a lexical element of a template, the bytes from Start to End. Delimiters include their trim markers and
+// comments their delimiters.
+type token struct {
+	Type  tokenType `json:"type"`
+	Start int       `json:"start"`
+	End   int       `json:"end"`
+}
+
+// tokenize splits text into tokens like the text/template lexer does, with the default delimiters. Unlike the lexer it
+// keeps the whitespace trim markers remove and the comments the parser drops, and an unclosed action just ends the
+// tokens, so incomplete templates can be highlighted.
+func tokenize(text string) []token {
+	tokens := make([]token, 0)
+	emit := func(t tokenType, start, end int) {
+		if end > start {
+			tokens = append(tokens, token{Type: t, Start: start, End: end})
+		}
+	}
+
+	offset := 0
+	for offset < len(text) {
+		start := strings.Index(text[offset:], "{{")
+		if start == -1 {
+			emit(textToken, offset, len(text))
+			break
+		}
+		start += offset
+		emit(textToken, offset, start)
+
+		inside := start + 2
+		if strings.HasPrefix(text[inside:], "- ") {
+			inside += 2
+		}
+		if strings.HasPrefix(text[inside:], "/*") {
+			end := strings.Index(text[inside+2:], "*/")
+			if end == -1 {
+				emit(errorToken, start, len(text))
+				break
+			}
+			end += inside + 4
+			if strings.HasPrefix(text[end:], " -}}") {
+				end += 4
+			} else if strings.HasPrefix(text[end:], "}}") {
+				end += 2
+			}
+			emit(commentToken, start, end)
+			offset = end
+			continue
+		}
+
+		emit(leftDelimToken, start, inside)
+		offset = tokenizeAction(text, inside, emit)
+	}
+	return tokens
+}
+
+// tokenizeAction emits the tokens of the inside of an action starting at offset up to and including its right
+// delimiter, and returns where the action ends
+func tokenizeAction(text string, offset int, emit func(t tokenType, start, end int)) int {
+	i := offset
+	for i < len(text) {
+		start := i
+		r, size := utf8.DecodeRuneInString(text[i:])
+		switch {
+		case strings.HasPrefix(text[i:], " -}}"):
+			emit(rightDelimToken, i, i+4)
+			return i + 4
+		case strings.HasPrefix(text[i:], "}}"):
+			emit(rightDelimToken, i, i+2)
+			return i + 2
+		case strings.HasPrefix(text[i:], "{{"):
+			// an unclosed action, the lexer's error
+			emit(errorToken, i, len(text))
+			return len(text)
+		case unicode.IsSpace(r):
+			for i < len(text) && !strings.HasPrefix(text[i:], " -}}") {
+				r, size := utf8.DecodeRuneInString(text[i:])
+				if !unicode.IsSpace(r) {
+					break
+				}
+				i += size
+			}
+			emit(spaceToken, start, i)
+		case r == '"' || r == '\'':
+			i++
+			for i < len(text) && text[i] != byte(r) && text[i] != '\n' {
+				if text[i] == '\\' {
+					i++
+				}
+				i++
+			}
+			if i >= len(text) || text[i] != byte(r) {
+				emit(errorToken, start, len(text))
+				return len(text)
+			}
+			i++
+			if r == '"' {
+				emit(stringToken, start, i)
+			} else {
+				emit(charToken, start, i)
+			}
+		case r == '`':
+			end := strings.IndexByte(text[i+1:], '`')
+			if end == -1 {
+				emit(errorToken, start, len(text))
+				return len(text)
+			}
+			i += end + 2
+			emit(rawStringToken, start, i)
+		case strings.HasPrefix(text[i:], ":="):
+			i += 2
+			emit(declareToken, start, i)
+		case r == '=' || r == '|' || r == ',' || r == '(' || r == ')':
+			i++
+			emit(map[rune]tokenType{'=': assignToken, '|': pipeToken, ',': commaToken, '(': leftParenToken,
+				')': rightParenToken}[r], start, i)
+		case r == '$':
+			i = scanIdentifier(text, i+1)
+			emit(variableToken, start, i)
+		case r == '.' && i+1 < len(text) && isDigit(text[i+1]):
+			i = scanNumber(text, i)
+			emit(numberToken, start, i)
+		case r == '.':
+			i = scanIdentifier(text, i+1)
+			if i == start+1 {
+				emit(dotToken, start, i)
+			} else {
+				emit(fieldToken, start, i)
+			}
+		case isDigit(text[i]) || (r == '+' || r == '-') && i+1 < len(text) && isDigit(text[i+1]):
+			i = scanNumber(text, i+1)
+			emit(numberToken, start, i)
+		case r == '_' || unicode.IsLetter(r):
+			i = scanIdentifier(text, i)
+			switch word := text[start:i]; {
+			case keywords[word]:
+				emit(keywordToken, start, i)
+			case word == "true" || word == "false":
+				emit(boolToken, start, i)
+			case word == "nil":
+				emit(nilToken, start, i)
+			default:
+				emit(identifierToken, start, i)
+			}
+		default:
+			i += size
+			emit(errorToken, start, i)
+		}
+	}
+	return i
+}
+
+// scanIdentifier returns the end of the letters, digits and underscores starting at offset
+func scanIdentifier(text string, offset int) int {
+	for offset < len(text) {
+		r, size := utf8.DecodeRuneInString(text[offset:])
+		if r != '_' && !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			break
+		}
+		offset += size
+	}
+	return offset
+}
+
+// scanNumber returns the end of the number continuing at offset, which can be hexadecimal, have underscores,
+// exponents or an imaginary part
+func scanNumber(text string, offset int) int {
+	for offset < len(text) {
+		c := text[offset]
+		switch {
+		case isDigit(c) || c == '.' || c == '_' || c == 'x' || c == 'X' || c == 'o' || c == 'O' || c == 'b' ||
+			c == 'B' || c == 'i' || ('a' <= c && c <= 'f') || ('A' <= c && c <= 'F'):
+		case (c == '+' || c == '-') && strings.ContainsRune("eEpP", rune(text[offset-1])):
+		default:
+			return offset
+		}
+		offset++
+	}
+	return offset
+}
+
+func isDigit(c byte) bool {
+	return '0' <= c && c <= '9'
+}
+
+// Tokens serves the tokens of the template form value, as JSON
+func (a *App) Tokens(w http.ResponseWriter, r *http.Request) {
+	text, err := getFormValue(r, "template")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	writeJSON(w, tokenize(text))
+}
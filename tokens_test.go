@@ -0,0 +1,65 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// tokenStrings formats tokens as their type and text, for readable expectations
+func tokenStrings(text string, tokens []token) []string {
+	result := make([]string, len(tokens))
+	for i, tok := range tokens {
+		result[i] = string(tok.Type) + " " + text[tok.Start:tok.End]
+	}
+	return result
+}
+
+func TestTokenize(t *testing.T) {
+	tests := map[string][]string{
+		"Hi {{- .User.Name -}}!": {
+			"text Hi ", "leftDelim {{- ", "field .User", "field .Name", "rightDelim  -}}", "text !",
+		},
+		`{{range $i, $x := .Items}}{{if eq $x.N 1.5e-3}}{{printf "%q}}" ` + "`raw`" + ` 'c' | len}}{{end}}{{end}}`: {
+			"leftDelim {{", "keyword range", "space  ", "variable $i", "comma ,", "space  ", "variable $x", "space  ",
+			"declare :=", "space  ", "field .Items", "rightDelim }}",
+			"leftDelim {{", "keyword if", "space  ", "identifier eq", "space  ", "variable $x", "field .N", "space  ",
+			"number 1.5e-3", "rightDelim }}",
+			"leftDelim {{", "identifier printf", "space  ", `string "%q}}"`, "space  ", "rawString `raw`", "space  ",
+			"char 'c'", "space  ", "pipe |", "space  ", "identifier len", "rightDelim }}",
+			"leftDelim {{", "keyword end", "rightDelim }}", "leftDelim {{", "keyword end", "rightDelim }}",
+		},
+		"{{/* a\ncomment */}} {{(.) | not true | and nil}}": {
+			"comment {{/* a\ncomment */}}", "text  ", "leftDelim {{", "leftParen (", "dot .", "rightParen )", "space  ",
+			"pipe |", "space  ", "identifier not", "space  ", "bool true", "space  ", "pipe |", "space  ",
+			"identifier and", "space  ", "nil nil", "rightDelim }}",
+		},
+		"a {{.A \"b\nc}}": {"text a ", "leftDelim {{", "field .A", "space  ", "error \"b\nc}}"},
+		"{{.A {{.B}}":     {"leftDelim {{", "field .A", "space  ", "error {{.B}}"},
+	}
+	for text, expected := range tests {
+		if actual := tokenStrings(text, tokenize(text)); !reflect.DeepEqual(expected, actual) {
+			t.Errorf("%q: expected %q, actual %q", text, expected, actual)
+		}
+	}
+}
+
+func TestTokens(t *testing.T) {
+	form := url.Values{"template": {"a{{.}}"}}
+	r := httptest.NewRequest("POST", "/api/v1/tokens", strings.NewReader(form.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+	(&App{}).Tokens(w, r)
+
+	var actual []token
+	if err := json.NewDecoder(w.Body).Decode(&actual); err != nil {
+		t.Fatal(err)
+	}
+	expected := []token{{textToken, 0, 1}, {leftDelimToken, 1, 3}, {dotToken, 3, 4}, {rightDelimToken, 4, 6}}
+	if !reflect.DeepEqual(expected, actual) {
+		t.Errorf("expected %+v, actual %+v", expected, actual)
+	}
+}
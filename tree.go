@@ -0,0 +1,120 @@
+package main
+
+import (
+	"fmt"
+	"text/template"
+	templateParse "text/template/parse"
+)
+
+// walk calls fn for node and all of its descendants in document order. ancestors holds the chain of parents of the
+// node being visited, closest last. Returning false from fn skips the node's children.
+func walk(node templateParse.Node, fn func(node templateParse.Node, ancestors []templateParse.Node) bool) {
+	walkInternal(node, nil, fn)
+}
+
+func walkInternal(node templateParse.Node, ancestors []templateParse.Node, fn func(node templateParse.Node, ancestors []templateParse.Node) bool) {
+	if node == nil {
+		return
+	}
+	if !fn(node, ancestors) {
+		return
+	}
+	ancestors = append(ancestors, node)
+	for _, child := range children(node) {
+		// ancestors is shared between siblings, make sure a child can't see its siblings by capping the slice
+		walkInternal(child, ancestors[:len(ancestors):len(ancestors)], fn)
+	}
+}
+
+// children returns the direct children of node, skipping nil ones
+func children(node templateParse.Node) []templateParse.Node {
+	var result []templateParse.Node
+	add := func(nodes ...templateParse.Node) {
+		for _, n := range nodes {
+			// typed nil pointers need to be checked individually
+			switch n := n.(type) {
+			case *templateParse.ListNode:
+				if n == nil {
+					continue
+				}
+			case *templateParse.PipeNode:
+				if n == nil {
+					continue
+				}
+			}
+			result = append(result, n)
+		}
+	}
+	switch n := node.(type) {
+	case *templateParse.ListNode:
+		add(n.Nodes...)
+	case *templateParse.ActionNode:
+		add(n.Pipe)
+	case *templateParse.IfNode:
+		add(n.Pipe, n.List, n.ElseList)
+	case *templateParse.RangeNode:
+		add(n.Pipe, n.List, n.ElseList)
+	case *templateParse.WithNode:
+		add(n.Pipe, n.List, n.ElseList)
+	case *templateParse.TemplateNode:
+		add(n.Pipe)
+	case *templateParse.PipeNode:
+		for _, v := range n.Decl {
+			add(v)
+		}
+		for _, c := range n.Cmds {
+			add(c)
+		}
+	case *templateParse.CommandNode:
+		add(n.Args...)
+	case *templateParse.ChainNode:
+		add(n.Node)
+	}
+	return result
+}
+
+// findNode returns the innermost node at a zero indexed line and character in any of t's trees, along with its
+// ancestors, closest last. Locations are compared the way exec errors report them.
+func findNode(t *template.Template, line, char int) (*templateParse.Tree, []templateParse.Node) {
+	if line < 0 || char < 0 {
+		return nil, nil
+	}
+	location := fmt.Sprintf(":%d:%d", line+1, char)
+
+	for _, tt := range t.Templates() {
+		if tt.Tree == nil || tt.Tree.Root == nil {
+			continue
+		}
+		tree := tt.Tree
+
+		var chain []templateParse.Node
+		walk(tree.Root, func(node templateParse.Node, ancestors []templateParse.Node) bool {
+			if _, ok := node.(*templateParse.ListNode); ok {
+				return true
+			}
+			// nodes are visited outermost first, so the last match is the innermost one
+			if loc, _ := tree.ErrorContext(node); loc == tree.ParseName+location {
+				chain = append(append([]templateParse.Node{}, ancestors...), node)
+			}
+			return true
+		})
+		if chain != nil {
+			return tree, chain
+		}
+	}
+	return nil, nil
+}
+
+// copyTemplate clones t along with deep copies of all its parse trees, so the copy can be modified without affecting t
+func copyTemplate(t *template.Template) (*template.Template, error) {
+	c, err := t.Clone()
+	if err != nil {
+		return nil, err
+	}
+	for _, tt := range c.Templates() {
+		if tt.Tree != nil {
+			tt.Tree = tt.Tree.Copy()
+		}
+	}
+	return c, nil
+}
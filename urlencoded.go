@@ -0,0 +1,168 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// maxURLEncodedIndex is the largest index of an array in URL encoded data, so a key can't make a huge one
+const maxURLEncodedIndex = 10000
+
+// urlEncodedPart is a part of a key of URL encoded data, a field or an index of an array, -1 to append to it
+type urlEncodedPart struct {
+	field string
+	index int
+	isArr bool
+}
+
+// decodeURLEncoded decodes the URL encoded raw within maxDataSize, like a query string a=1&b[0]=x&c.d=y, into objects
+// of the fields of keys, arrays of their indexes and their string values. Dots and brackets nest keys, [] appends to an
+// array, and a key without an index given several values has an array of them. Pairs can be separated by lines too.
+func decodeURLEncoded(raw string) (interface{}, error) {
+	if len(raw) > maxDataSize {
+		return nil, fmt.Errorf("data is bigger than %d bytes", maxDataSize)
+	}
+	var data interface{} = map[string]interface{}{}
+	var list dataErrors
+	for start := 0; start < len(raw); {
+		end := start + strings.IndexAny(raw[start:]+"&", "&\n")
+		pair := strings.TrimSpace(raw[start:end])
+		offset := start + strings.Index(raw[start:end], pair)
+		start = end + 1
+		if offset == 0 {
+			// a pasted query string can start with its ?
+			pair = strings.TrimPrefix(pair, "?")
+		}
+		if pair == "" {
+			continue
+		}
+		rawKey, rawValue, _ := strings.Cut(pair, "=")
+		key, err := url.QueryUnescape(rawKey)
+		if err != nil {
+			list = append(list, &dataError{offset: offset, err: err})
+			continue
+		}
+		value, err := url.QueryUnescape(rawValue)
+		if err != nil {
+			list = append(list, &dataError{offset: offset + len(rawKey) + 1, err: err})
+			continue
+		}
+		parts, err := parseURLEncodedKey(key)
+		if err == nil {
+			data, err = setURLEncoded(data, parts, value)
+		}
+		if err != nil {
+			list = append(list, &dataError{offset: offset, err: fmt.Errorf("key %s: %v", key, err)})
+		}
+	}
+	if len(list) > 0 {
+		return nil, list
+	}
+	return data, nil
+}
+
+// parseURLEncodedKey splits key into its fields and indexes, like a, b, 0 for a.b[0]. Bracketed numbers are indexes,
+// the rest fields.
+func parseURLEncodedKey(key string) ([]urlEncodedPart, error) {
+	var parts []urlEncodedPart
+	field := func(s string) error {
+		for _, name := range strings.Split(s, ".") {
+			if name == "" {
+				return fmt.Errorf("a field has no name")
+			}
+			parts = append(parts, urlEncodedPart{field: name})
+		}
+		return nil
+	}
+	open := strings.IndexByte(key, '[')
+	if open == -1 {
+		return parts, field(key)
+	}
+	if err := field(key[:open]); err != nil {
+		return nil, err
+	}
+	for rest := key[open:]; rest != ""; {
+		switch {
+		case rest[0] == '.':
+			next := strings.IndexByte(rest, '[')
+			if next == -1 {
+				next = len(rest)
+			}
+			if err := field(rest[1:next]); err != nil {
+				return nil, err
+			}
+			rest = rest[next:]
+		case rest[0] == '[':
+			end := strings.IndexByte(rest, ']')
+			if end == -1 {
+				return nil, fmt.Errorf("[ isn't closed by ]")
+			}
+			switch name := rest[1:end]; {
+			case name == "":
+				parts = append(parts, urlEncodedPart{index: -1, isArr: true})
+			case strings.Trim(name, "0123456789") == "":
+				index, err := strconv.Atoi(name)
+				if err != nil || index > maxURLEncodedIndex {
+					return nil, fmt.Errorf("index %s is bigger than %d", name, maxURLEncodedIndex)
+				}
+				parts = append(parts, urlEncodedPart{index: index, isArr: true})
+			default:
+				parts = append(parts, urlEncodedPart{field: name})
+			}
+			rest = rest[end+1:]
+		default:
+			return nil, fmt.Errorf("expected . or [ after ], found %q", rest)
+		}
+	}
+	return parts, nil
+}
+
+// setURLEncoded returns node with value in it at the path of parts
+func setURLEncoded(node interface{}, parts []urlEncodedPart, value string) (interface{}, error) {
+	if len(parts) == 0 {
+		switch existing := node.(type) {
+		case nil:
+			return value, nil
+		case string:
+			return []interface{}{existing, value}, nil
+		case []interface{}:
+			return append(existing, value), nil
+		}
+		return nil, fmt.Errorf("it's an object, it can't have a value")
+	}
+	part := parts[0]
+	if !part.isArr {
+		object, ok := node.(map[string]interface{})
+		if node == nil {
+			object, ok = map[string]interface{}{}, true
+		}
+		if !ok {
+			return nil, fmt.Errorf("%s is a field of something which isn't an object", part.field)
+		}
+		child, err := setURLEncoded(object[part.field], parts[1:], value)
+		if err != nil {
+			return nil, err
+		}
+		object[part.field] = child
+		return object, nil
+	}
+	array, ok := node.([]interface{})
+	if !ok && node != nil {
+		return nil, fmt.Errorf("an index of something which isn't an array")
+	}
+	index := part.index
+	if index == -1 {
+		index = len(array)
+	}
+	for len(array) <= index {
+		array = append(array, nil)
+	}
+	child, err := setURLEncoded(array[index], parts[1:], value)
+	if err != nil {
+		return nil, err
+	}
+	array[index] = child
+	return array, nil
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	htmlTemplate "html/template"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDecodeURLEncoded(t *testing.T) {
+	data, err := decodeURLEncoded("?a=1&b[0]=x&b[2]=z&c.d=y&c[e][]=p&c[e][]=q&name=Bob%20Smith\ntag=a&tag=b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]interface{}{
+		"a": "1",
+		"b": []interface{}{"x", nil, "z"},
+		"c": map[string]interface{}{
+			"d": "y",
+			"e": []interface{}{"p", "q"},
+		},
+		"name": "Bob Smith",
+		"tag":  []interface{}{"a", "b"},
+	}
+	if !reflect.DeepEqual(data, expected) {
+		t.Errorf("expected %v, got %v", expected, data)
+	}
+
+	raw := "a=1&a.b=2&c[=3&d=%zz"
+	_, err = decodeURLEncoded(raw)
+	tplErrs := dataTemplateErrors(raw, err)
+	if len(tplErrs) != 3 {
+		t.Fatalf("expected 3 errors, got %+v", tplErrs)
+	}
+	assertError(t, templateError{File: dataFile, Line: 0, Char: 4, Column: -1, Level: dataErrorLevel,
+		Description: "failed to understand data: key a.b: b is a field of something which isn't an object"}, tplErrs[0])
+	assertError(t, templateError{File: dataFile, Line: 0, Char: 10, Column: -1, Level: dataErrorLevel,
+		Description: "failed to understand data: key c[: [ isn't closed by ]"}, tplErrs[1])
+	assertError(t, templateError{File: dataFile, Line: 0, Char: 17, Column: -1, Level: dataErrorLevel,
+		Description: `failed to understand data: invalid URL escape "%zz"`}, tplErrs[2])
+}
+
+func TestPostURLEncodedForm(t *testing.T) {
+	a := &App{index: htmlTemplate.Must(htmlTemplate.New("index.html").Parse("{{.Output}}"))}
+	form := url.Values{"from-raw-text": {"{{.a}} {{index .b 1}}"}, "data": {"a=1&b[]=x&b[]=y"},
+		"data-format": {urlEncodedDataFormat}}
+	r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(form.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+	a.Post(w, r)
+	if w.Code != http.StatusOK || w.Body.String() != "1 y" {
+		t.Errorf("unexpected response %d %q", w.Code, w.Body.String())
+	}
+}
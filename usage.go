@@ -0,0 +1,121 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"text/template"
+	templateParse "text/template/parse"
+)
+
+// FunctionKind is where a function called by a template comes from
+type FunctionKind string
+
+const (
+	builtinFunction  FunctionKind = "builtin"
+	providedFunction FunctionKind = "provided"
+	mockedFunction   FunctionKind = "mocked"
+)
+
+// functionUsage lists the calls to a function
+type functionUsage struct {
+	Name  string
+	Kind  FunctionKind
+	Calls []functionCall
+}
+
+// functionCall is where a function is called and with how many arguments, including one passed by a pipeline
+type functionCall struct {
+	At   position
+	Args int
+
+	// byte offset into the text while validating
+	offset int
+	tree   *templateParse.Tree
+	node   *templateParse.IdentifierNode
+}
+
+// functionUsages finds the calls to every function in t, ordered by name. functions are the provided functions, any
+// others which aren't builtin were mocked.
+func functionUsages(t *template.Template, functions []string) []functionUsage {
+	usages := make(map[string]*functionUsage)
+	for _, tree := range trees(t) {
+		walk(tree.Root, func(node templateParse.Node, ancestors []templateParse.Node) bool {
+			ident, ok := node.(*templateParse.IdentifierNode)
+			if !ok {
+				return true
+			}
+			args := 0
+			if cmd, ok := ancestors[len(ancestors)-1].(*templateParse.CommandNode); ok && cmd.Args[0] == node {
+				args = len(cmd.Args) - 1
+				if pipe, ok := ancestors[len(ancestors)-2].(*templateParse.PipeNode); ok && pipe.Cmds[0] != cmd {
+					args++
+				}
+			}
+
+			usage, ok := usages[ident.Ident]
+			if !ok {
+				usage = &functionUsage{Name: ident.Ident, Kind: functionKind(ident.Ident, functions)}
+				usages[ident.Ident] = usage
+			}
+			usage.Calls = append(usage.Calls, functionCall{Args: args, offset: int(ident.Position()), tree: tree, node: ident})
+			return true
+		})
+	}
+
+	result := make([]functionUsage, 0, len(usages))
+	for _, usage := range usages {
+		sort.Slice(usage.Calls, func(i, j int) bool {
+			return usage.Calls[i].offset < usage.Calls[j].offset
+		})
+		result = append(result, *usage)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+	return result
+}
+
+func functionKind(name string, functions []string) FunctionKind {
+	for _, fn := range functions {
+		if fn == name {
+			return providedFunction
+		}
+	}
+	for _, fn := range builtinFunctions {
+		if fn == name {
+			return builtinFunction
+		}
+	}
+	return mockedFunction
+}
+
+// locateCalls sets the positions of function calls from their offsets, the same way as error locations
+func locateCalls(usages []functionUsage, text string, lines []string, unit ColumnUnit) {
+	for _, usage := range usages {
+		for i := range usage.Calls {
+			usage.Calls[i].At = offsetPosition(text, lines, usage.Calls[i].offset, unit)
+		}
+	}
+}
+
+// lintMockedArity warns about mocked functions called with different numbers of arguments, which the real function can't
+// accept. Mocks accept anything, so executing the template can't find these. The functions of the config are real.
+func lintMockedArity(t *template.Template, _ []source, config lintConfig) []templateError {
+	var tplErrs []templateError
+	for _, usage := range functionUsages(t, config.functions) {
+		if usage.Kind != mockedFunction {
+			continue
+		}
+		first := usage.Calls[0]
+		for _, c := range usage.Calls[1:] {
+			if c.Args == first.Args {
+				continue
+			}
+			firstErr := nodeError(first.tree, first.node, "")
+			tplErrs = append(tplErrs, nodeError(c.tree, c.node, fmt.Sprintf(
+				"mocked function %s is called with %s here but with %s on line %d",
+				usage.Name, countArgs(c.Args), countArgs(first.Args), firstErr.Line+1)))
+		}
+	}
+	return tplErrs
+}
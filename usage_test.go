@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+	"testing"
+)
+
+func TestFunctionUsages(t *testing.T) {
+	data := (&App{}).createData(context.Background(), "{{len .A}}\n{{.B | upper}} {{printf \"%s %s\" .A .B | lower}}{{upper}}", "", "upper", options{})
+	expected := []functionUsage{
+		{Name: "len", Kind: builtinFunction, Calls: []functionCall{{At: position{Line: 0, Char: 2}, Args: 1}}},
+		{Name: "lower", Kind: mockedFunction, Calls: []functionCall{{At: position{Line: 1, Char: 40}, Args: 1}}},
+		{Name: "printf", Kind: builtinFunction, Calls: []functionCall{{At: position{Line: 1, Char: 17}, Args: 3}}},
+		{Name: "upper", Kind: providedFunction, Calls: []functionCall{
+			{At: position{Line: 1, Char: 7}, Args: 1},
+			{At: position{Line: 1, Char: 49}, Args: 0},
+		}},
+	}
+	if len(data.Functions) != len(expected) {
+		t.Fatalf("unexpected functions: %+v", data.Functions)
+	}
+	for i, usage := range data.Functions {
+		if usage.Name != expected[i].Name || usage.Kind != expected[i].Kind || len(usage.Calls) != len(expected[i].Calls) {
+			t.Errorf("expected %+v, actual %+v", expected[i], usage)
+			continue
+		}
+		for j, c := range usage.Calls {
+			if c.At != expected[i].Calls[j].At || c.Args != expected[i].Calls[j].Args {
+				t.Errorf("%s call %d: expected %+v, actual %+v", usage.Name, j, expected[i].Calls[j], c)
+			}
+		}
+	}
+}
+
+func TestMockedFunctionArguments(t *testing.T) {
+	data := (&App{}).createData(context.Background(), "{{upper .A}}\n{{.A | upper}}{{upper .A .B}}{{lower}}{{lower .A}}",
+		`{"A": "a"}`, "lower", options{})
+	if len(data.Errors) != 2 {
+		t.Fatalf("unexpected errors found: %v", data.Errors)
+	}
+	// mocks accept any arguments, so only the lint warning and the parse error for the missing function are left, and
+	// lower is provided, so it's real
+	assertError(t, templateError{
+		Line:        1,
+		Char:        16,
+		Description: "mocked function upper is called with 2 args here but with 1 arg on line 1",
+		Level:       lintErrorLevel,
+	}, data.Errors[1])
+}
@@ -13,6 +13,49 @@ func CountDigits(i int) (count int) {
 
 // SplitLines splits a string into lines
 // Supports windows or unix line segments
+// Lines are counted the way the template packages count them, a lone carriage return doesn't end a line
 func SplitLines(str string) []string {
 	return strings.Split(strings.Replace(str, "\r\n", "\n", -1), "\n")
 }
+
+// SplitVisualLines splits a string into lines the way an editor shows them
+// Supports windows, unix or classic mac line segments, even mixed together
+func SplitVisualLines(str string) []string {
+	return strings.Split(normalizeLineEndings(str), "\n")
+}
+
+func normalizeLineEndings(str string) string {
+	return strings.Replace(strings.Replace(str, "\r\n", "\n", -1), "\r", "\n", -1)
+}
+
+// Levenshtein returns the edit distance between two strings, in runes
+func Levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur := make([]int, len(rb)+1)
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min3(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev = cur
+	}
+	return prev[len(rb)]
+}
+
+func min3(a, b, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+	return a
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// webhookTimeout is how long notifying a webhook may take
+const webhookTimeout = 10 * time.Second
+
+// suiteSummary is what a webhook is sent when a suite finishes running
+type suiteSummary struct {
+	Suite   string   `json:"suite"`
+	Cases   int      `json:"cases"`
+	Passed  int      `json:"passed"`
+	Failed  int      `json:"failed"`
+	Failing []string `json:"failing,omitempty"`
+	// Link is where the suite can be seen
+	Link string `json:"link"`
+}
+
+func summarize(name string, results []caseResult, link string) suiteSummary {
+	summary := suiteSummary{Suite: name, Cases: len(results), Link: link}
+	for _, result := range results {
+		if result.Passed {
+			summary.Passed++
+		} else {
+			summary.Failed++
+			summary.Failing = append(summary.Failing, result.Name)
+		}
+	}
+	return summary
+}
+
+// notifyWebhook posts summary to url as JSON
+func notifyWebhook(ctx context.Context, url string, summary suiteSummary) error {
+	b, err := json.Marshal(summary)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, webhookTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook responded %s", resp.Status)
+	}
+	return nil
+}
+
+// suiteLink is the URL of the suite named name on the server r was sent to
+func suiteLink(r *http.Request, name string) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	return fmt.Sprintf("%s://%s/api/v1/suites/%s", scheme, r.Host, name)
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi"
+)
+
+func TestSummarize(t *testing.T) {
+	results := []caseResult{{Name: "a", Passed: true}, {Name: "b"}, {Name: "c"}}
+	expected := suiteSummary{Suite: "s", Cases: 3, Passed: 1, Failed: 2, Failing: []string{"b", "c"}, Link: "l"}
+	if actual := summarize("s", results, "l"); !reflect.DeepEqual(expected, actual) {
+		t.Errorf("expected %+v, actual %+v", expected, actual)
+	}
+}
+
+func TestNotifyWebhook(t *testing.T) {
+	summaries := make(chan suiteSummary, 1)
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var summary suiteSummary
+		if err := json.NewDecoder(r.Body).Decode(&summary); err != nil {
+			t.Error(err)
+		}
+		summaries <- summary
+	}))
+	defer hook.Close()
+
+	a := &App{suites: newSuiteStore(t.TempDir(), nil), webhook: hook.URL, asyncSuites: newAsyncSuites(1, time.Minute)}
+	if err := a.suites.save(suite{Name: "hi", Template: "{{.X}}", Cases: []suiteCase{{Name: "no data"}}}, ""); err != nil {
+		t.Fatal(err)
+	}
+	r := chi.NewRouter()
+	r.Post("/api/v1/suites/{name}/run", a.RunSuite)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("POST", "http://example.com/api/v1/suites/hi/run?async=1", nil))
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("unexpected response: %d %s", w.Code, w.Body)
+	}
+
+	select {
+	case summary := <-summaries:
+		expected := suiteSummary{Suite: "hi", Cases: 1, Passed: 1, Link: "http://example.com/api/v1/suites/hi"}
+		if !reflect.DeepEqual(expected, summary) {
+			t.Errorf("expected %+v, actual %+v", expected, summary)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("webhook wasn't notified")
+	}
+
+	// a run keeps the only slot
+	a.asyncSuites.slots <- struct{}{}
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("POST", "http://example.com/api/v1/suites/hi/run?async=1", nil))
+	if w.Code != http.StatusServiceUnavailable || w.Header().Get("Retry-After") == "" {
+		t.Errorf("unexpected response: %d %s", w.Code, w.Body)
+	}
+}
+
+func TestFailingWebhook(t *testing.T) {
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer hook.Close()
+	if err := notifyWebhook(context.Background(), hook.URL, suiteSummary{}); err == nil {
+		t.Error("expected an error")
+	}
+}